@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	"github.com/jw3/example-tensorflow-golang/model"
+)
+
+// a redaction, one json line of the audit log
+type redaction struct {
+	Source     string    `json:"source"`
+	Image      string    `json:"image"`
+	Time       time.Time `json:"time"`
+	Kind       string    `json:"kind"`
+	Bounds     [4]int    `json:"bounds"`
+	Confidence float32   `json:"confidence"`
+}
+
+func main() {
+	facesfile := flag.String("faces", "", "Path to a face detection model")
+	platesfile := flag.String("plates", "", "Path to a license plate detection model")
+	inputop := flag.String("input-op", "", "Name of the image input op of the models, found by name or as the single uint8 placeholder when not given")
+	outputops := flag.String("output-ops", "", "Comma separated names of the boxes, scores, classes and num_detections output ops of the models, found by their object detection api names when not given")
+	var imagefiles Strings
+	flag.Var(&imagefiles, "image", "Image or video to redact; file, dir, archive, video file, http(s) or rtsp url. Repeatable")
+	outdir := flag.String("outdir", "redacted", "Dir to write the redacted images and videos to")
+	blur := flag.Int("blur", 12, "Blur radius in pixels, larger is stronger")
+	min := flag.Float64("min", .3, "Minimum confidence to redact, err on the low side")
+	pad := flag.Float64("pad", .1, "Grow each box by this fraction of its size before blurring")
+	fps := flag.Float64("fps", 0, "Frame rate of redacted videos, the rate ffprobe finds for the source when not given")
+	audit := flag.String("audit", "", "Append a json line per redaction to this file")
+	retain := RetentionFlags()
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
+
+	SetUsage("anonymize", `Blur the faces and license plates in images and videos, writing redacted copies to -outdir.`,
+		"anonymize -faces faces.pb -plates plates.pb -image street/ -audit redactions.jsonl",
+		"anonymize -faces faces.pb -image dashcam.mp4 -blur 20")
+
+	flag.Parse()
+	if *completion != "" {
+		PrintCompletion(*completion, "anonymize")
+		return
+	}
+	if (*facesfile == "" && *platesfile == "") || len(imagefiles) == 0 {
+		flag.Usage()
+		return
+	}
+
+	detectors := make(map[string]*model.Detector)
+	for kind, file := range map[string]string{"face": *facesfile, "plate": *platesfile} {
+		if file == "" {
+			continue
+		}
+		var outputs []string
+		if *outputops != "" {
+			outputs = strings.Split(*outputops, ",")
+		}
+		d, err := model.NewDetector(file, *inputop, outputs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer d.Close()
+		detectors[kind] = d
+	}
+
+	var audited *json.Encoder
+	if *audit != "" {
+		f, err := os.OpenFile(*audit, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		audited = json.NewEncoder(f)
+	}
+
+	if err := os.MkdirAll(*outdir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	retain(*outdir, "*-anon.*")
+
+	for _, uri := range imagefiles {
+		src, err := OpenSource(uri)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = redactSource(src, func(frame *Frame) (image.Image, error) {
+			rgba := image.NewRGBA(frame.Im.Bounds())
+			draw.Draw(rgba, rgba.Bounds(), frame.Im, frame.Im.Bounds().Min, draw.Src)
+			for kind, d := range detectors {
+				detects, err := d.Detect(frame.Im, float32(*min))
+				if err != nil {
+					return nil, err
+				}
+				for _, det := range detects {
+					Blur(rgba, grow(det.Bounds, *pad), *blur)
+					if audited != nil {
+						b := det.Bounds
+						if err := audited.Encode(redaction{uri, frame.Name, frame.Time, kind, [4]int{b.Min.X, b.Min.Y, b.Max.X, b.Max.Y}, det.Confidence}); err != nil {
+							return nil, fmt.Errorf("-audit: %v", err)
+						}
+					}
+				}
+			}
+			return rgba, nil
+		}, *outdir, *fps)
+		src.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", uri, err)
+		}
+	}
+}
+
+// redact every frame of src, videos and streams are written back out as a
+// video, in the container of a video file with its audio and as mp4 for a
+// stream, anything else as jpegs. Names taken in outdir get an index.
+func redactSource(src FrameSource, redact func(*Frame) (image.Image, error), outdir string, fps float64) (err error) {
+	meta := src.Meta()
+	var video *VideoWriter
+	if meta.Kind == "video" || meta.Kind == "rtsp" {
+		if fps <= 0 {
+			if fps, err = ProbeFPS(meta.URI); err != nil {
+				return err
+			}
+		}
+		_, name, ext := SplitPath(meta.URI)
+		if meta.Kind == "rtsp" {
+			name, ext = fmt.Sprintf("rtsp-%d", time.Now().Unix()), ".mp4"
+		}
+		// holds the name, ffmpeg writes over it
+		f, err := CreateUnique(outdir, name, "-anon"+ext)
+		if err != nil {
+			return err
+		}
+		f.Close()
+		if meta.Kind == "video" {
+			video, err = NewDubbedVideoWriter(f.Name(), fps, meta.URI)
+		} else {
+			video, err = NewVideoWriter(f.Name(), fps)
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return err
+		}
+		defer func() {
+			// a failed encode is an error of the source as much as a failed
+			// redaction
+			if cerr := video.Close(); err == nil && cerr != nil {
+				err = fmt.Errorf("ffmpeg: %v", cerr)
+			}
+		}()
+	}
+
+	for {
+		frame, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		redacted, err := redact(frame)
+		if err != nil {
+			return err
+		}
+		if video != nil {
+			if err := video.WriteFrame(redacted, frame); err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, name, _ := SplitPath(frame.Name)
+		out, err := CreateUnique(outdir, strings.Replace(name, ":", "_", -1), "-anon.jpg")
+		if err != nil {
+			return err
+		}
+		err = jpeg.Encode(out, redacted, &jpeg.Options{Quality: 95})
+		out.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// grow r by frac of its size on every side
+func grow(r image.Rectangle, frac float64) image.Rectangle {
+	dx, dy := int(float64(r.Dx())*frac), int(float64(r.Dy())*frac)
+	return image.Rect(r.Min.X-dx, r.Min.Y-dy, r.Max.X+dx, r.Max.Y+dy)
+}
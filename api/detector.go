@@ -0,0 +1,261 @@
+// Package api is the grpc Detector service of detector.proto, its messages
+// and service are written out here rather than generated, so building needs
+// no protoc. They are kept in step with detector.proto, which other languages
+// generate their clients from.
+package api // import "github.com/jw3/example-tensorflow-golang/api"
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/grpc"
+)
+
+type DetectRequest struct {
+	Image    []byte   `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
+	Name     string   `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Min      float32  `protobuf:"fixed32,3,opt,name=min,proto3" json:"min,omitempty"`
+	Max      int32    `protobuf:"varint,4,opt,name=max,proto3" json:"max,omitempty"`
+	Classes  []int32  `protobuf:"varint,5,rep,packed,name=classes,proto3" json:"classes,omitempty"`
+	Models   []string `protobuf:"bytes,6,rep,name=models,proto3" json:"models,omitempty"`
+	Strategy string   `protobuf:"bytes,7,opt,name=strategy,proto3" json:"strategy,omitempty"`
+}
+
+type DetectResponse struct {
+	Name       string       `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Width      int32        `protobuf:"varint,2,opt,name=width,proto3" json:"width,omitempty"`
+	Height     int32        `protobuf:"varint,3,opt,name=height,proto3" json:"height,omitempty"`
+	Detections []*Detection `protobuf:"bytes,4,rep,name=detections,proto3" json:"detections,omitempty"`
+}
+
+type Detection struct {
+	Xmin       int32   `protobuf:"varint,1,opt,name=xmin,proto3" json:"xmin,omitempty"`
+	Ymin       int32   `protobuf:"varint,2,opt,name=ymin,proto3" json:"ymin,omitempty"`
+	Xmax       int32   `protobuf:"varint,3,opt,name=xmax,proto3" json:"xmax,omitempty"`
+	Ymax       int32   `protobuf:"varint,4,opt,name=ymax,proto3" json:"ymax,omitempty"`
+	Class      int32   `protobuf:"varint,5,opt,name=class,proto3" json:"class,omitempty"`
+	Label      string  `protobuf:"bytes,6,opt,name=label,proto3" json:"label,omitempty"`
+	Confidence float32 `protobuf:"fixed32,7,opt,name=confidence,proto3" json:"confidence,omitempty"`
+}
+
+func (m *DetectRequest) Reset()                    { *m = DetectRequest{} }
+func (m *DetectRequest) String() string            { return proto.CompactTextString(m) }
+func (*DetectRequest) ProtoMessage()               {}
+func (*DetectRequest) Descriptor() ([]byte, []int) { return fileDescriptor, []int{0} }
+
+func (m *DetectResponse) Reset()                    { *m = DetectResponse{} }
+func (m *DetectResponse) String() string            { return proto.CompactTextString(m) }
+func (*DetectResponse) ProtoMessage()               {}
+func (*DetectResponse) Descriptor() ([]byte, []int) { return fileDescriptor, []int{1} }
+
+func (m *Detection) Reset()                    { *m = Detection{} }
+func (m *Detection) String() string            { return proto.CompactTextString(m) }
+func (*Detection) ProtoMessage()               {}
+func (*Detection) Descriptor() ([]byte, []int) { return fileDescriptor, []int{2} }
+
+// gzipped FileDescriptorProto of detector.proto, for server reflection
+var fileDescriptor []byte
+
+func init() {
+	b, err := proto.Marshal(describe())
+	if err != nil {
+		panic(err)
+	}
+	buf := bytes.Buffer{}
+	gz := gzip.NewWriter(&buf)
+	gz.Write(b)
+	gz.Close()
+	fileDescriptor = buf.Bytes()
+
+	proto.RegisterFile("detector.proto", fileDescriptor)
+	proto.RegisterType((*DetectRequest)(nil), "detector.DetectRequest")
+	proto.RegisterType((*DetectResponse)(nil), "detector.DetectResponse")
+	proto.RegisterType((*Detection)(nil), "detector.Detection")
+}
+
+// detector.proto as protoc describes it
+func describe() *descriptor.FileDescriptorProto {
+	field := func(name string, number int32, typ descriptor.FieldDescriptorProto_Type, repeated bool) *descriptor.FieldDescriptorProto {
+		label := descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+		if repeated {
+			label = descriptor.FieldDescriptorProto_LABEL_REPEATED
+		}
+		return &descriptor.FieldDescriptorProto{Name: proto.String(name), JsonName: proto.String(name), Number: proto.Int32(number), Label: &label, Type: &typ}
+	}
+	const (
+		bytesType   = descriptor.FieldDescriptorProto_TYPE_BYTES
+		stringType  = descriptor.FieldDescriptorProto_TYPE_STRING
+		floatType   = descriptor.FieldDescriptorProto_TYPE_FLOAT
+		int32Type   = descriptor.FieldDescriptorProto_TYPE_INT32
+		messageType = descriptor.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	detections := field("detections", 4, messageType, true)
+	detections.TypeName = proto.String(".detector.Detection")
+
+	return &descriptor.FileDescriptorProto{
+		Name:    proto.String("detector.proto"),
+		Package: proto.String("detector"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptor.FileOptions{GoPackage: proto.String("api")},
+		MessageType: []*descriptor.DescriptorProto{{
+			Name: proto.String("DetectRequest"),
+			Field: []*descriptor.FieldDescriptorProto{
+				field("image", 1, bytesType, false),
+				field("name", 2, stringType, false),
+				field("min", 3, floatType, false),
+				field("max", 4, int32Type, false),
+				field("classes", 5, int32Type, true),
+				field("models", 6, stringType, true),
+				field("strategy", 7, stringType, false),
+			},
+		}, {
+			Name: proto.String("DetectResponse"),
+			Field: []*descriptor.FieldDescriptorProto{
+				field("name", 1, stringType, false),
+				field("width", 2, int32Type, false),
+				field("height", 3, int32Type, false),
+				detections,
+			},
+		}, {
+			Name: proto.String("Detection"),
+			Field: []*descriptor.FieldDescriptorProto{
+				field("xmin", 1, int32Type, false),
+				field("ymin", 2, int32Type, false),
+				field("xmax", 3, int32Type, false),
+				field("ymax", 4, int32Type, false),
+				field("class", 5, int32Type, false),
+				field("label", 6, stringType, false),
+				field("confidence", 7, floatType, false),
+			},
+		}},
+		Service: []*descriptor.ServiceDescriptorProto{{
+			Name: proto.String("Detector"),
+			Method: []*descriptor.MethodDescriptorProto{{
+				Name:       proto.String("Detect"),
+				InputType:  proto.String(".detector.DetectRequest"),
+				OutputType: proto.String(".detector.DetectResponse"),
+			}, {
+				Name:            proto.String("DetectStream"),
+				InputType:       proto.String(".detector.DetectRequest"),
+				OutputType:      proto.String(".detector.DetectResponse"),
+				ClientStreaming: proto.Bool(true),
+				ServerStreaming: proto.Bool(true),
+			}},
+		}},
+	}
+}
+
+// DetectorServer is the server side of the Detector service
+type DetectorServer interface {
+	Detect(context.Context, *DetectRequest) (*DetectResponse, error)
+	DetectStream(DetectStreamServer) error
+}
+
+// DetectStreamServer is the server side of a DetectStream call
+type DetectStreamServer interface {
+	Send(*DetectResponse) error
+	Recv() (*DetectRequest, error)
+	grpc.ServerStream
+}
+
+func RegisterDetectorServer(s *grpc.Server, srv DetectorServer) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "detector.Detector",
+	HandlerType: (*DetectorServer)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Detect",
+		Handler:    detectHandler,
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "DetectStream",
+		Handler:       detectStreamHandler,
+		ServerStreams: true,
+		ClientStreams: true,
+	}},
+	Metadata: "detector.proto",
+}
+
+func detectHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(DetectRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DetectorServer).Detect(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/detector.Detector/Detect"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DetectorServer).Detect(ctx, req.(*DetectRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+func detectStreamHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(DetectorServer).DetectStream(&detectStreamServer{stream})
+}
+
+type detectStreamServer struct {
+	grpc.ServerStream
+}
+
+func (s *detectStreamServer) Send(m *DetectResponse) error {
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *detectStreamServer) Recv() (*DetectRequest, error) {
+	m := new(DetectRequest)
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Client calls the Detector service of a connection
+type Client struct {
+	cc *grpc.ClientConn
+}
+
+func NewClient(cc *grpc.ClientConn) *Client {
+	return &Client{cc}
+}
+
+func (c *Client) Detect(ctx context.Context, req *DetectRequest, opts ...grpc.CallOption) (*DetectResponse, error) {
+	out := new(DetectResponse)
+	if err := c.cc.Invoke(ctx, "/detector.Detector/Detect", req, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DetectStream opens a stream of requests, answered in order
+func (c *Client) DetectStream(ctx context.Context, opts ...grpc.CallOption) (*DetectStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &serviceDesc.Streams[0], "/detector.Detector/DetectStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &DetectStreamClient{stream}, nil
+}
+
+// DetectStreamClient is the client side of a DetectStream call, CloseSend
+// once all requests are sent
+type DetectStreamClient struct {
+	grpc.ClientStream
+}
+
+func (c *DetectStreamClient) Send(m *DetectRequest) error {
+	return c.ClientStream.SendMsg(m)
+}
+
+func (c *DetectStreamClient) Recv() (*DetectResponse, error) {
+	m := new(DetectResponse)
+	if err := c.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
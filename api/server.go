@@ -0,0 +1,115 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"time"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// largest request accepted, as for POST /detect
+const maxMessage = 64 << 20
+
+// Server answers the Detector service with a DetectHandler, so requests
+// have the settings and bounds of POST /detect
+type Server struct {
+	Handler *DetectHandler
+}
+
+// Serve the Detector service on lis, with server reflection for grpcurl
+func Serve(lis net.Listener, h *DetectHandler) error {
+	return NewServer(h).Serve(lis)
+}
+
+// NewServer of the Detector service, with server reflection, to be served on
+// any number of listeners
+func NewServer(h *DetectHandler) *grpc.Server {
+	s := grpc.NewServer(grpc.MaxRecvMsgSize(maxMessage))
+	RegisterDetectorServer(s, &Server{h})
+	reflection.Register(s)
+	return s
+}
+
+func (s *Server) Detect(ctx context.Context, req *DetectRequest) (*DetectResponse, error) {
+	return s.detect(req)
+}
+
+// DetectStream answers each request as it comes, the first failing one
+// ends the stream
+func (s *Server) DetectStream(stream DetectStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resp, err := s.detect(req)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) detect(req *DetectRequest) (*DetectResponse, error) {
+	start := time.Now()
+	im, err := s.Handler.DecodeImage(bytes.NewReader(req.Image))
+	if p, ok := err.(*Problem); ok && p.Code == CodePayloadTooLarge {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	} else if err != nil {
+		s.Handler.Metrics.Failed("decode")
+		return nil, status.Errorf(codes.InvalidArgument, "invalid image: %v", err)
+	}
+	s.Handler.Metrics.Preprocessed(time.Since(start))
+	// zero values are the server's
+	var o Overrides
+	if req.Min != 0 {
+		o.Min = &req.Min
+	}
+	if req.Max != 0 {
+		max := int(req.Max)
+		o.Max = &max
+	}
+	for _, c := range req.Classes {
+		o.Classes = append(o.Classes, CID(c))
+	}
+	if len(req.Models) > 0 {
+		o.Models, o.Strategy = req.Models, req.Strategy
+	}
+
+	detects, err := s.Handler.Detect(req.Name, im, o)
+	if p, ok := err.(*Problem); ok && p.Code == CodeTimeout {
+		return nil, status.Error(codes.DeadlineExceeded, err.Error())
+	} else if ok && p.Code == CodeModelNotLoaded {
+		return nil, status.Error(codes.NotFound, err.Error())
+	} else if _, ok := err.(OverrideError); ok {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	b := im.Bounds()
+	resp := &DetectResponse{Name: RedactName(req.Name), Width: int32(b.Dx()), Height: int32(b.Dy()), Detections: make([]*Detection, len(detects))}
+	for i, d := range detects {
+		resp.Detections[i] = &Detection{
+			Xmin:       int32(d.Bounds.Min.X),
+			Ymin:       int32(d.Bounds.Min.Y),
+			Xmax:       int32(d.Bounds.Max.X),
+			Ymax:       int32(d.Bounds.Max.Y),
+			Class:      int32(d.Class),
+			Label:      s.Handler.Labels.Name(d.Class),
+			Confidence: d.Confidence,
+		}
+	}
+	return resp, nil
+}
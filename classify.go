@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	"github.com/jw3/example-tensorflow-golang/model"
+)
+
+func main() {
+	modelfile := flag.String("model", "", "Path to a classification model")
+	labelfile := flag.String("labels", "labels.txt", "Path of the class labels of the model, empty to output class ids, eg. for regression")
+	input := flag.String("input", "input", "Input op of the model")
+	output := flag.String("output", "scores", "Output op of the model, [N,C] class scores")
+	format := flag.String("format", "pretty", "Output format; pretty, plain or json. pretty falls back to plain when stdout is not a terminal")
+	scoreFormat := ScoreFlags()
+	setLocale := LocaleFlags()
+	configureSessions := model.SessionFlags()
+	cpubudget := flag.String("cpu-budget", "", "Share of the machine to use, eg. 4 cores or 50%, sizing GOMAXPROCS and the tf thread pools and running at nice 10, or nice=N, eg. 4,nice=15")
+	top := flag.Int("top", 5, "Number of classes to output per input, 0 for all")
+	flag.IntVar(top, "topk", 5, "Alias of -top")
+	softmax := flag.String("softmax", "never", "Put the scores through softmax; never, always, or auto when they are logits rather than probabilities in [0,1]")
+	min := flag.Float64("min", 0, "Minimum score to output")
+	var audiofiles Strings
+	flag.Var(&audiofiles, "audio", "Wav file to classify, cut into patches of log mel spectrogram. Repeatable")
+	rate := flag.Int("sample-rate", DefaultMel.Rate, "Sample rate the model expects, audio is resampled to it")
+	bands := flag.Int("mel-bands", DefaultMel.Bands, "Mel bands of the spectrogram, read from the input shape when static")
+	frames := flag.Int("patch-frames", 96, "Spectrogram frames of 10ms in each patch, read from the input shape when static")
+	hop := flag.Int("patch-hop", 48, "Frames between the starts of patches")
+	var texts, textfiles Strings
+	flag.Var(&texts, "text", "Text to classify, for models with a string input. Repeatable")
+	flag.Var(&textfiles, "text-file", "File of texts to classify, one per line, or - for stdin. Repeatable")
+	var csvfiles Strings
+	flag.Var(&csvfiles, "csv", "Csv of rows to score with a tabular model, with a header row. Repeatable")
+	specfile := flag.String("features", "features.json", "Json spec mapping csv columns to the [N,F] float features of a tabular model")
+	idcolumn := flag.String("id-column", "", "Csv column naming each row in the output, rows are named by file and row number without one")
+	batchsize := flag.Int("batch-size", 32, "Number of texts or csv rows per session run")
+	configfile := flag.String("config", "", "Json, yaml or toml file of flag values, eg. top: 3, overridden by flags given")
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
+
+	SetUsage("classify", `Run a classification model on inputs that aren't images, printing the top class scores of each.
+
+Audio is converted to log mel spectrogram patches, as yamnet and vggish take
+them, and each patch is classified; [N,frames,bands] or [N,frames,bands,1].
+Texts are fed to models that tokenize in the graph as [N] or [N,1] strings.
+Csv rows are mapped to float feature vectors by the -features spec.`,
+		"classify -model yamnet.pb -labels yamnet.txt -input features -audio dog.wav",
+		"classify -model yamnet.pb -labels yamnet.txt -input features -audio street.wav -format json -top 3",
+		`classify -model sentiment.pb -labels sentiment.txt -text "great service, would come again"`,
+		"classify -model toxicity.pb -labels toxicity.txt -text-file comments.txt -format json",
+		"classify -model churn.pb -labels churn.txt -features churn.json -csv customers.csv -id-column customer_id -top 1")
+
+	flag.Parse()
+	if *configfile != "" {
+		config, err := LoadConfig(*configfile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := config.Apply(flag.CommandLine); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := setLocale(); err != nil {
+		log.Fatal(err)
+	}
+	if *completion != "" {
+		PrintCompletion(*completion, "classify")
+		return
+	}
+	if *modelfile == "" || len(audiofiles)+len(texts)+len(textfiles)+len(csvfiles) == 0 {
+		flag.Usage()
+		return
+	}
+	if *format == "pretty" && !IsTerminal(os.Stdout) {
+		*format = "plain"
+	}
+
+	labels := Labels{}
+	if *labelfile != "" {
+		l, err := LoadLabels(*labelfile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		labels = l
+	}
+	out, err := NewScoreWriter(*format, os.Stdout, labels, *top, float32(*min))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if out, err = SoftmaxWriter(out, *softmax); err != nil {
+		log.Fatal(err)
+	}
+	scoreFormat(out)
+	budget, err := ParseCPUBudget(*cpubudget)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := budget.Apply(); err != nil {
+		log.Fatal(err)
+	}
+	model.SetThreads(budget.Threads())
+	if err := configureSessions(); err != nil {
+		log.Fatal(err)
+	}
+	scorer, err := model.NewScorer(*modelfile, *input, *output)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer scorer.Close()
+
+	mel := DefaultMel
+	mel.Rate = *rate
+	mel.Bands = *bands
+	shape := scorer.Input.Shape()
+	if n := shape.NumDimensions(); (n == 3 || n == 4) && shape.Size(1) > 0 && shape.Size(2) > 0 {
+		*frames, mel.Bands = int(shape.Size(1)), int(shape.Size(2))
+	}
+	channel := shape.NumDimensions() == 4
+	column := shape.NumDimensions() == 2
+
+	// texts are named by themselves, or by file and line number
+	var names, batch []string
+	classifyTexts := func() {
+		if len(batch) == 0 {
+			return
+		}
+		var feed interface{} = batch
+		if column {
+			feed = textColumn(batch)
+		}
+		scores, err := scorer.Score(feed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, s := range scores {
+			if err := out.Write(names[i], s); err != nil {
+				log.Fatal(err)
+			}
+		}
+		names, batch = names[:0], batch[:0]
+	}
+	addText := func(name, text string) {
+		names, batch = append(names, name), append(batch, text)
+		if len(batch) >= *batchsize {
+			classifyTexts()
+		}
+	}
+	for _, text := range texts {
+		addText(text, text)
+	}
+	for _, file := range textfiles {
+		err := readLines(file, func(n int, line string) {
+			addText(fmt.Sprintf("%s:%d", file, n), line)
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	classifyTexts()
+
+	if len(csvfiles) > 0 {
+		spec, err := LoadFeatureSpec(*specfile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, file := range csvfiles {
+			if err := scoreCsv(scorer, out, spec, file, *idcolumn, *batchsize); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	for _, file := range audiofiles {
+		wav, err := LoadWav(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		patches := Patches(mel.MelSpectrogram(wav), *frames, *hop, mel.Silence())
+		if len(patches) == 0 {
+			log.Fatalf("%s: shorter than a spectrogram window", file)
+		}
+		var batch interface{} = patches
+		if channel {
+			batch = withChannel(patches)
+		}
+		scores, err := scorer.Score(batch)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, s := range scores {
+			at := float64(i**hop) * mel.Hop
+			if err := out.Write(fmt.Sprintf("%s@%.2fs", file, at), s); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
+
+// score the rows of a csv in batches
+func scoreCsv(scorer *model.Scorer, out ScoreWriter, spec *FeatureSpec, file, idcolumn string, batchsize int) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	if err := spec.Header(header); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	id := -1
+	for i, h := range header {
+		if idcolumn != "" && strings.TrimSpace(h) == idcolumn {
+			id = i
+		}
+	}
+	if idcolumn != "" && id < 0 {
+		return fmt.Errorf("%s: no %q column", file, idcolumn)
+	}
+
+	var names []string
+	var batch [][]float32
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		scores, err := scorer.Score(batch)
+		if err != nil {
+			return err
+		}
+		for i, s := range scores {
+			if err := out.Write(names[i], s); err != nil {
+				return err
+			}
+		}
+		names, batch = names[:0], batch[:0]
+		return nil
+	}
+	// rows are numbered from 1 after the header
+	for n := 1; ; n++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+		vec, err := spec.Vector(row)
+		if err != nil {
+			return fmt.Errorf("%s row %d: %v", file, n, err)
+		}
+		name := fmt.Sprintf("%s:%d", file, n)
+		if id >= 0 && id < len(row) {
+			name = row[id]
+		}
+		names, batch = append(names, name), append(batch, vec)
+		if len(batch) >= batchsize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// call fn with each non-blank line of file, numbered from 1
+func readLines(file string, fn func(n int, line string)) error {
+	f := os.Stdin
+	if file != "-" {
+		var err error
+		if f, err = os.Open(file); err != nil {
+			return err
+		}
+		defer f.Close()
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		if strings.TrimSpace(scanner.Text()) != "" {
+			fn(n, scanner.Text())
+		}
+	}
+	return scanner.Err()
+}
+
+// [N] to [N,1]
+func textColumn(texts []string) [][]string {
+	col := make([][]string, len(texts))
+	for i, t := range texts {
+		col[i] = []string{t}
+	}
+	return col
+}
+
+// [N,frames,bands] to [N,frames,bands,1]
+func withChannel(patches [][][]float32) [][][][]float32 {
+	batch := make([][][][]float32, len(patches))
+	for i, p := range patches {
+		batch[i] = make([][][]float32, len(p))
+		for j, row := range p {
+			batch[i][j] = make([][]float32, len(row))
+			for k, v := range row {
+				batch[i][j][k] = []float32{v}
+			}
+		}
+	}
+	return batch
+}
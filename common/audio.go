@@ -0,0 +1,271 @@
+package common
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/cmplx"
+	"os"
+)
+
+// Wav is mono audio, with samples in [-1,1]
+type Wav struct {
+	Rate    int
+	Samples []float32
+}
+
+// LoadWav reads a pcm or float wav file, mixing channels down to mono
+func LoadWav(wavfile string) (*Wav, error) {
+	f, err := os.Open(wavfile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	w, err := ReadWav(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", wavfile, err)
+	}
+	return w, nil
+}
+
+func ReadWav(r io.Reader) (*Wav, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 12 || string(b[:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("not a wav file")
+	}
+
+	var format, channels, bits int
+	rate := 0
+	for p := 12; p+8 <= len(b); {
+		id, size := string(b[p:p+4]), int(binary.LittleEndian.Uint32(b[p+4:]))
+		body := b[p+8:]
+		if size > len(body) {
+			size = len(body)
+		}
+		body = body[:size]
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("short fmt chunk")
+			}
+			format = int(binary.LittleEndian.Uint16(body))
+			channels = int(binary.LittleEndian.Uint16(body[2:]))
+			rate = int(binary.LittleEndian.Uint32(body[4:]))
+			bits = int(binary.LittleEndian.Uint16(body[14:]))
+			// extensible, the format is the start of the subformat guid
+			if format == 0xFFFE && size >= 26 {
+				format = int(binary.LittleEndian.Uint16(body[24:]))
+			}
+		case "data":
+			if rate == 0 {
+				return nil, fmt.Errorf("data before fmt chunk")
+			}
+			samples, err := wavSamples(body, format, channels, bits)
+			if err != nil {
+				return nil, err
+			}
+			return &Wav{Rate: rate, Samples: samples}, nil
+		}
+		// chunks are padded to an even size
+		p += 8 + size + size%2
+	}
+	return nil, fmt.Errorf("no data chunk")
+}
+
+func wavSamples(b []byte, format, channels, bits int) ([]float32, error) {
+	width := bits / 8
+	if channels < 1 || width < 1 {
+		return nil, fmt.Errorf("invalid %d channels of %d bits", channels, bits)
+	}
+	var sample func(s []byte) float32
+	switch {
+	case format == 1 && bits == 8:
+		sample = func(s []byte) float32 { return (float32(s[0]) - 128) / 128 }
+	case format == 1 && bits == 16:
+		sample = func(s []byte) float32 { return float32(int16(binary.LittleEndian.Uint16(s))) / (1 << 15) }
+	case format == 1 && bits == 24:
+		sample = func(s []byte) float32 {
+			return float32(int32(uint32(s[0])<<8|uint32(s[1])<<16|uint32(s[2])<<24)>>8) / (1 << 23)
+		}
+	case format == 1 && bits == 32:
+		sample = func(s []byte) float32 { return float32(int32(binary.LittleEndian.Uint32(s))) / (1 << 31) }
+	case format == 3 && bits == 32:
+		sample = func(s []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(s)) }
+	default:
+		return nil, fmt.Errorf("unsupported wav format %d with %d bits", format, bits)
+	}
+
+	frame := width * channels
+	samples := make([]float32, len(b)/frame)
+	for i := range samples {
+		var sum float32
+		for c := 0; c < channels; c++ {
+			sum += sample(b[i*frame+c*width:])
+		}
+		samples[i] = sum / float32(channels)
+	}
+	return samples, nil
+}
+
+// Resample to rate by linear interpolation
+func (w *Wav) Resample(rate int) *Wav {
+	if rate == w.Rate || len(w.Samples) == 0 {
+		return w
+	}
+	step := float64(w.Rate) / float64(rate)
+	n := int(float64(len(w.Samples)) / step)
+	out := make([]float32, n)
+	for i := range out {
+		pos := float64(i) * step
+		j := int(pos)
+		frac := float32(pos - float64(j))
+		next := w.Samples[len(w.Samples)-1]
+		if j+1 < len(w.Samples) {
+			next = w.Samples[j+1]
+		}
+		out[i] = w.Samples[j]*(1-frac) + next*frac
+	}
+	return &Wav{Rate: rate, Samples: out}
+}
+
+// MelConfig are the log mel spectrogram features of an audio model, the
+// defaults match yamnet and vggish
+type MelConfig struct {
+	Rate int
+	// stft window and hop
+	Window, Hop float64
+	Bands       int
+	Low, High   float64
+	// added before the log to keep silence finite
+	Offset float64
+}
+
+var DefaultMel = MelConfig{Rate: 16000, Window: .025, Hop: .010, Bands: 64, Low: 125, High: 7500, Offset: .001}
+
+// MelSpectrogram computes the log mel spectrogram of w, one row of Bands
+// per hop. w is resampled to the rate of the config first.
+func (c MelConfig) MelSpectrogram(w *Wav) [][]float32 {
+	samples := w.Resample(c.Rate).Samples
+	window := int(math.Round(c.Window * float64(c.Rate)))
+	hop := int(math.Round(c.Hop * float64(c.Rate)))
+	fftLen := 1
+	for fftLen < window {
+		fftLen *= 2
+	}
+
+	hann := make([]float64, window)
+	for i := range hann {
+		hann[i] = .5 - .5*math.Cos(2*math.Pi*float64(i)/float64(window))
+	}
+	weights := melWeights(fftLen/2+1, c.Bands, c.Rate, c.Low, c.High)
+
+	var rows [][]float32
+	buf := make([]complex128, fftLen)
+	for start := 0; start+window <= len(samples); start += hop {
+		for i := range buf {
+			buf[i] = 0
+			if i < window {
+				buf[i] = complex(float64(samples[start+i])*hann[i], 0)
+			}
+		}
+		fft(buf)
+		row := make([]float32, c.Bands)
+		for m := range row {
+			var sum float64
+			for k, wt := range weights[m] {
+				if wt > 0 {
+					sum += wt * cmplx.Abs(buf[k])
+				}
+			}
+			row[m] = float32(math.Log(sum + c.Offset))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Silence is the value of a spectrogram of silence
+func (c MelConfig) Silence() float32 {
+	return float32(math.Log(c.Offset))
+}
+
+func hzToMel(f float64) float64 {
+	return 1127 * math.Log(1+f/700)
+}
+
+// triangular filters evenly spaced on the mel scale, over the stft bins
+func melWeights(bins, bands, rate int, low, high float64) [][]float64 {
+	lo, hi := hzToMel(low), hzToMel(high)
+	edges := make([]float64, bands+2)
+	for i := range edges {
+		edges[i] = lo + (hi-lo)*float64(i)/float64(bands+1)
+	}
+	weights := make([][]float64, bands)
+	for m := range weights {
+		weights[m] = make([]float64, bins)
+		// the dc bin is left out
+		for k := 1; k < bins; k++ {
+			mel := hzToMel(float64(k) * float64(rate) / 2 / float64(bins-1))
+			up := (mel - edges[m]) / (edges[m+1] - edges[m])
+			down := (edges[m+2] - mel) / (edges[m+2] - edges[m+1])
+			weights[m][k] = math.Max(0, math.Min(up, down))
+		}
+	}
+	return weights
+}
+
+// in place radix 2 fft, len(x) is a power of 2
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size *= 2 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*wk
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= w
+			}
+		}
+	}
+}
+
+// Patches cuts spectrogram rows into patches of frames rows, every hop rows.
+// The end of audio shorter than a patch is padded with
+// silence.
+func Patches(rows [][]float32, frames, hop int, silence float32) [][][]float32 {
+	if len(rows) == 0 {
+		return nil
+	}
+	pad := make([]float32, len(rows[0]))
+	for i := range pad {
+		pad[i] = silence
+	}
+	var patches [][][]float32
+	for start := 0; start == 0 || start+frames <= len(rows); start += hop {
+		patch := make([][]float32, frames)
+		for i := range patch {
+			patch[i] = pad
+			if start+i < len(rows) {
+				patch[i] = rows[start+i]
+			}
+		}
+		patches = append(patches, patch)
+	}
+	return patches
+}
@@ -0,0 +1,220 @@
+package common
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditLog appends a json line per prediction to a file, each chained to the
+// one before by hash, so removing, reordering or editing a line breaks the
+// chain from there on; see VerifyAudit. A log is continued across runs, and
+// may be written to from any goroutine.
+type AuditLog struct {
+	mu     sync.Mutex
+	f      *os.File
+	labels Namer
+	model  string
+	seq    int
+	prev   string
+}
+
+// AuditRecord is a line of an audit log. Hash is the sha256 of the record
+// with an empty Hash, and Prev is the Hash of the line before, empty for the
+// first line.
+type AuditRecord struct {
+	Seq        int           `json:"seq"`
+	Time       time.Time     `json:"time"`
+	Image      string        `json:"image"`
+	Input      string        `json:"input_sha256"`
+	Model      string        `json:"model_sha256"`
+	Detections []auditDetect `json:"detections"`
+	Prev       string        `json:"prev"`
+	Hash       string        `json:"hash"`
+}
+
+type auditDetect struct {
+	Bounds     [4]int  `json:"bounds"`
+	Class      CID     `json:"class"`
+	Label      string  `json:"label"`
+	Confidence float32 `json:"confidence"`
+}
+
+// OpenAuditLog appends to the audit log in file, predictions are recorded
+// against the hash of modelfile
+func OpenAuditLog(file, modelfile string, labels Namer) (*AuditLog, error) {
+	model, err := FileHash(modelfile)
+	if err != nil {
+		return nil, err
+	}
+	a := &AuditLog{labels: labels, model: model}
+	// continue the chain of an existing log
+	if f, err := os.Open(file); err == nil {
+		last, n, err := verifyAudit(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		a.seq, a.prev = n, last
+	}
+	if a.f, err = os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *AuditLog) Write(frame *Frame, detects []Detect) error {
+	input := ImageHash(frame.Im)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r := AuditRecord{
+		Seq:        a.seq,
+		Time:       time.Now().UTC(),
+		Image:      frame.Name,
+		Input:      input,
+		Model:      a.model,
+		Detections: make([]auditDetect, len(detects)),
+		Prev:       a.prev,
+	}
+	for i, d := range detects {
+		r.Detections[i] = auditDetect{
+			Bounds:     [4]int{d.Bounds.Min.X, d.Bounds.Min.Y, d.Bounds.Max.X, d.Bounds.Max.Y},
+			Class:      d.Class,
+			Label:      a.labels.Name(d.Class),
+			Confidence: d.Confidence,
+		}
+	}
+	hash, err := r.hash()
+	if err != nil {
+		return err
+	}
+	r.Hash = hash
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := a.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	a.seq++
+	a.prev = hash
+	// a record is on disk before the prediction is acted on
+	return a.f.Sync()
+}
+
+func (a *AuditLog) Close() error {
+	return a.f.Close()
+}
+
+func (r AuditRecord) hash() (string, error) {
+	r.Hash = ""
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyAudit checks the hash chain of an audit log, returning the number of
+// records, or an error naming the first record that breaks the chain
+func VerifyAudit(r io.Reader) (int, error) {
+	_, n, err := verifyAudit(r)
+	return n, err
+}
+
+func verifyAudit(r io.Reader) (string, int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	prev, n := "", 0
+	for ; scanner.Scan(); n++ {
+		var rec AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return "", n, fmt.Errorf("record %d: %v", n, err)
+		}
+		hash, err := rec.hash()
+		if err != nil {
+			return "", n, err
+		}
+		switch {
+		case rec.Seq != n:
+			return "", n, fmt.Errorf("record %d: has seq %d, records are missing or reordered", n, rec.Seq)
+		case rec.Prev != prev:
+			return "", n, fmt.Errorf("record %d: prev doesn't match the hash of record %d", n, n-1)
+		case rec.Hash != hash:
+			return "", n, fmt.Errorf("record %d: hash doesn't match its content", n)
+		}
+		prev = rec.Hash
+	}
+	return prev, n, scanner.Err()
+}
+
+// FileHash is the hex sha256 of a file, as sha256sum has it, or of the
+// files of a dir such as a SavedModel, by their paths and contents, so
+// retrained variables under the same graph change it too
+func FileHash(file string) (string, error) {
+	// walked from where a link points
+	if real, err := filepath.EvalSymlinks(file); err == nil {
+		file = real
+	}
+	h := sha256.New()
+	err := filepath.Walk(file, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if path != file {
+			rel, _ := filepath.Rel(file, path)
+			fmt.Fprintf(h, "%s %d\n", filepath.ToSlash(rel), info.Size())
+		}
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ImageHash is the hex sha256 of the size and decoded pixels of im, the same
+// for any encoding of the same pixels
+func ImageHash(im image.Image) string {
+	h := sha256.New()
+	b := im.Bounds()
+	fmt.Fprintf(h, "%dx%d\n", b.Dx(), b.Dy())
+	switch im := im.(type) {
+	case *image.YCbCr:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			h.Write(im.Y[im.YOffset(b.Min.X, y) : im.YOffset(b.Max.X-1, y)+1])
+		}
+		h.Write(im.Cb)
+		h.Write(im.Cr)
+	case *image.Gray:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			h.Write(im.Pix[im.PixOffset(b.Min.X, y):im.PixOffset(b.Max.X, y)])
+		}
+	default:
+		rgba, ok := im.(*image.RGBA)
+		if !ok {
+			rgba = image.NewRGBA(b)
+			draw.Draw(rgba, b, im, b.Min, draw.Src)
+		}
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			h.Write(rgba.Pix[rgba.PixOffset(b.Min.X, y):rgba.PixOffset(b.Max.X, y)])
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Bench collects the latency of each image of a run, for a report of its
+// distribution and the throughput once the run is over
+type Bench struct {
+	mu    sync.Mutex
+	start time.Time
+	took  []time.Duration
+	// estimated flops of a chip and of the images run, and the device
+	chipFLOPs, flops float64
+	device           string
+}
+
+func NewBench() *Bench {
+	return &Bench{start: time.Now()}
+}
+
+// Add the latency of n images run together, each taking its share
+func (b *Bench) Add(took time.Duration, n int) {
+	if b == nil || n <= 0 {
+		return
+	}
+	b.mu.Lock()
+	for i := 0; i < n; i++ {
+		b.took = append(b.took, took/time.Duration(n))
+	}
+	b.mu.Unlock()
+}
+
+// SetCost of running a chip on a device, for the report
+func (b *Bench) SetCost(chipFLOPs float64, device string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.chipFLOPs, b.device = chipFLOPs, device
+	b.mu.Unlock()
+}
+
+// Spent the estimated flops of an image
+func (b *Bench) Spent(flops float64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.flops += flops
+	b.mu.Unlock()
+}
+
+// Time f, of n images
+func (b *Bench) Time(n int, f func() error) error {
+	start := time.Now()
+	err := f()
+	if err == nil {
+		b.Add(time.Since(start), n)
+	}
+	return err
+}
+
+// Report writes the latency min, mean, p50, p95, p99 and max, and the
+// images a second over the run
+func (b *Bench) Report(w io.Writer) error {
+	b.mu.Lock()
+	took := append([]time.Duration(nil), b.took...)
+	chipFLOPs, flops, device := b.chipFLOPs, b.flops, b.device
+	b.mu.Unlock()
+	elapsed := time.Since(b.start)
+	if len(took) == 0 {
+		_, err := fmt.Fprintln(w, T("bench: no images run"))
+		return err
+	}
+	sort.Slice(took, func(i, j int) bool { return took[i] < took[j] })
+	var sum time.Duration
+	for _, t := range took {
+		sum += t
+	}
+	// nearest rank
+	p := func(q float64) time.Duration {
+		i := int(q*float64(len(took))+.5) - 1
+		if i < 0 {
+			i = 0
+		}
+		return took[i]
+	}
+	round := func(d time.Duration) time.Duration { return d.Round(10 * time.Microsecond) }
+	_, err := fmt.Fprintf(w, "%s\nbench: latency min %v mean %v p50 %v p95 %v p99 %v max %v\n",
+		T("bench: %d images in %v, %.2f images/s", len(took), elapsed.Round(time.Millisecond), float64(len(took))/elapsed.Seconds()),
+		round(took[0]), round(sum/time.Duration(len(took))), round(p(.5)), round(p(.95)), round(p(.99)), round(took[len(took)-1]))
+	if err != nil || chipFLOPs == 0 {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "bench: cost %s a chip on %s, %s an image, %s/s\n",
+		FormatFLOPs(chipFLOPs), device, FormatFLOPs(flops/float64(len(took))), FormatFLOPs(flops/elapsed.Seconds()))
+	return err
+}
+
+// FormatFLOPs as eg. 8.41 GFLOPs
+func FormatFLOPs(f float64) string {
+	for _, unit := range []string{"", "K", "M", "G", "T"} {
+		if f < 1000 || unit == "T" {
+			return fmt.Sprintf("%.3g %sFLOPs", f, unit)
+		}
+		f /= 1000
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// CPUBudget is the share of the machine a long running job may use, so it
+// shares the machine politely with other workloads
+type CPUBudget struct {
+	// cores to use, 0 for all of them
+	Cores int
+	// niceness of the process, 0 to leave it
+	Nice int
+}
+
+// ParseCPUBudget reads a budget of cores, eg. 4, or a share of the cores of
+// the machine, eg. 50%, with an optional niceness, eg. 4,nice=15. Empty is
+// the whole machine. A budget below the whole machine runs at nice 10 unless
+// given.
+func ParseCPUBudget(s string) (CPUBudget, error) {
+	var b CPUBudget
+	if s == "" {
+		return b, nil
+	}
+	splits := strings.Split(s, ",")
+	cores := strings.TrimSpace(splits[0])
+	if strings.HasSuffix(cores, "%") {
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(cores, "%"), 64)
+		if err != nil || pct <= 0 || pct > 100 {
+			return b, fmt.Errorf("invalid cpu budget %q, expected a share of 1%% to 100%%", s)
+		}
+		b.Cores = int(float64(runtime.NumCPU())*pct/100 + .5)
+		if b.Cores < 1 {
+			b.Cores = 1
+		}
+	} else {
+		n, err := strconv.Atoi(cores)
+		if err != nil || n < 1 {
+			return b, fmt.Errorf("invalid cpu budget %q, expected cores, eg. 4, or a share, eg. 50%%", s)
+		}
+		b.Cores = n
+	}
+	if b.Cores >= runtime.NumCPU() {
+		b.Cores = 0
+	} else {
+		b.Nice = 10
+	}
+	for _, opt := range splits[1:] {
+		kv := strings.SplitN(strings.TrimSpace(opt), "=", 2)
+		if len(kv) != 2 || kv[0] != "nice" {
+			return b, fmt.Errorf("invalid cpu budget option %q, expected nice=N", opt)
+		}
+		n, err := strconv.Atoi(kv[1])
+		if err != nil || n < -20 || n > 19 {
+			return b, fmt.Errorf("invalid niceness %q, expected -20 to 19", kv[1])
+		}
+		b.Nice = n
+	}
+	return b, nil
+}
+
+// Threads are the sizes of the intra and inter op thread pools of tf that
+// fit the budget, 0 to leave them to tf
+func (b CPUBudget) Threads() (intra, inter int) {
+	if b.Cores == 0 {
+		return 0, 0
+	}
+	// ops run in parallel over at most two streams, each op on the cores
+	inter = 1
+	if b.Cores >= 4 {
+		inter = 2
+	}
+	return b.Cores, inter
+}
+
+// Apply sets GOMAXPROCS and the niceness of the process
+func (b CPUBudget) Apply() error {
+	if b.Cores > 0 {
+		runtime.GOMAXPROCS(b.Cores)
+	}
+	if b.Nice != 0 {
+		if err := setNice(b.Nice); err != nil {
+			return err
+		}
+	}
+	return nil
+}
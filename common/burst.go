@@ -0,0 +1,113 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Event is a burst of images taken in quick succession, eg. by a camera trap,
+// with the detections of all of them aggregated
+type Event struct {
+	ID     int       `json:"event"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Images []string  `json:"images"`
+	// highest confidence of each label across the burst
+	Species map[string]float32 `json:"species"`
+	// most of each label seen in a single image of the burst
+	Counts map[string]int `json:"counts"`
+}
+
+// BurstWriter groups frames less than Gap apart into events, writing an event
+// rather than a line per image once the burst is over
+type BurstWriter struct {
+	w      io.Writer
+	format string
+	labels Namer
+	gap    time.Duration
+	min    float32
+
+	event *Event
+	n     int
+	// frames of the current event, and of the events written, for a
+	// Checkpoint
+	held, written []*frameMark
+}
+
+// NewBurstWriter writes events as json, or as a plain line per label
+func NewBurstWriter(w io.Writer, format string, labels Namer, gap time.Duration, min float32) *BurstWriter {
+	return &BurstWriter{w: w, format: format, labels: labels, gap: gap, min: min}
+}
+
+func (b *BurstWriter) Write(frame *Frame, detects []Detect) error {
+	if b.event != nil && frame.Time.Sub(b.event.End) > b.gap {
+		if err := b.Flush(); err != nil {
+			return err
+		}
+	}
+	if b.event == nil {
+		b.n++
+		b.event = &Event{ID: b.n, Start: frame.Time, Species: make(map[string]float32), Counts: make(map[string]int)}
+	}
+	e := b.event
+	e.End = frame.Time
+	e.Images = append(e.Images, frame.Name)
+	b.held = append(b.held, frame.mark)
+	counts := make(map[string]int)
+	for _, d := range detects {
+		if d.Confidence <= b.min {
+			continue
+		}
+		label := b.labels.Name(d.Class)
+		if d.Confidence > e.Species[label] {
+			e.Species[label] = d.Confidence
+		}
+		counts[label]++
+	}
+	for label, n := range counts {
+		if n > e.Counts[label] {
+			e.Counts[label] = n
+		}
+	}
+	return nil
+}
+
+// Flush writes the current event
+func (b *BurstWriter) Flush() error {
+	e := b.event
+	if e == nil {
+		return nil
+	}
+	b.event = nil
+	if err := b.writeEvent(e); err != nil {
+		return err
+	}
+	b.written, b.held = append(b.written, b.held...), nil
+	return nil
+}
+
+func (b *BurstWriter) writeEvent(e *Event) error {
+	if b.format == "json" {
+		return json.NewEncoder(b.w).Encode(e)
+	}
+	if len(e.Species) == 0 {
+		_, err := fmt.Fprintf(b.w, "%d %s %s %d empty\n", e.ID, e.Start.Format(time.RFC3339), e.End.Format(time.RFC3339), len(e.Images))
+		return err
+	}
+	for _, label := range sortedKeys(e.Counts) {
+		_, err := fmt.Fprintf(b.w, "%d %s %s %d %s %d %v\n", e.ID, e.Start.Format(time.RFC3339), e.End.Format(time.RFC3339), len(e.Images), label, e.Counts[label], e.Species[label])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the frames of the events written
+func (b *BurstWriter) released() []*frameMark {
+	written := b.written
+	b.written = nil
+	return written
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"image"
+	"math/bits"
+
+	"golang.org/x/image/draw"
+)
+
+// CropCache remembers the results of a secondary model for crops seen in the
+// last Window frames, so the same object isn't rerun on every frame. Crops
+// match when their average hashes differ in at most Distance bits.
+type CropCache struct {
+	Window   int
+	Distance int
+
+	frame   int
+	entries []cacheEntry
+}
+
+type cacheEntry struct {
+	hash  uint64
+	frame int
+	value interface{}
+}
+
+// Next moves the cache on to the next frame, forgetting entries outside the window
+func (c *CropCache) Next() {
+	c.frame++
+	live := c.entries[:0]
+	for _, e := range c.entries {
+		if c.frame-e.frame < c.Window {
+			live = append(live, e)
+		}
+	}
+	c.entries = live
+}
+
+// Get returns the closest cached result for the crop
+func (c *CropCache) Get(crop image.Image) (interface{}, bool) {
+	if c == nil || c.Window <= 0 {
+		return nil, false
+	}
+	h := AverageHash(crop)
+	best, found := c.Distance+1, -1
+	for i, e := range c.entries {
+		if d := bits.OnesCount64(h ^ e.hash); d < best {
+			best, found = d, i
+		}
+	}
+	if found < 0 {
+		return nil, false
+	}
+	return c.entries[found].value, true
+}
+
+func (c *CropCache) Put(crop image.Image, value interface{}) {
+	if c == nil || c.Window <= 0 {
+		return
+	}
+	c.entries = append(c.entries, cacheEntry{AverageHash(crop), c.frame, value})
+}
+
+// AverageHash is a 64 bit perceptual hash, a bit per pixel of an 8x8 gray
+// thumbnail that is set when the pixel is brighter than the mean
+func AverageHash(im image.Image) uint64 {
+	thumb := image.NewGray(image.Rect(0, 0, 8, 8))
+	draw.ApproxBiLinear.Scale(thumb, thumb.Bounds(), im, im.Bounds(), draw.Src, nil)
+	sum := 0
+	for _, p := range thumb.Pix {
+		sum += int(p)
+	}
+	mean := sum / len(thumb.Pix)
+	var h uint64
+	for i, p := range thumb.Pix {
+		if int(p) > mean {
+			h |= 1 << uint(i)
+		}
+	}
+	return h
+}
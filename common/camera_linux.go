@@ -0,0 +1,45 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// struct v4l2_streamparm of a capture device
+type v4l2StreamParm struct {
+	Type         uint32
+	Capability   uint32
+	CaptureMode  uint32
+	Numerator    uint32
+	Denominator  uint32
+	ExtendedMode uint32
+	ReadBuffers  uint32
+	Reserved     [4]uint32
+	_            [160]byte
+}
+
+const (
+	v4l2BufTypeVideoCapture = 1
+	// _IOWR('V', 21, struct v4l2_streamparm)
+	vidiocGParm = 0xC0CC5615
+)
+
+// CameraFPS is the frame rate a /dev/videoN camera is set to, by its time
+// per frame, which can be read while ffmpeg streams from it
+func CameraFPS(dev string) (float64, error) {
+	f, err := os.OpenFile(dev, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	parm := v4l2StreamParm{Type: v4l2BufTypeVideoCapture}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), vidiocGParm, uintptr(unsafe.Pointer(&parm))); errno != 0 {
+		return 0, fmt.Errorf("%s: %v", dev, errno)
+	}
+	if parm.Numerator == 0 || parm.Denominator == 0 {
+		return 0, fmt.Errorf("%s: no frame rate", dev)
+	}
+	return float64(parm.Denominator) / float64(parm.Numerator), nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package common
+
+import "fmt"
+
+// CameraFPS is the frame rate of a /dev/videoN camera, v4l2 is linux only
+func CameraFPS(dev string) (float64, error) {
+	return 0, fmt.Errorf("%s: v4l2 cameras are only supported on linux", dev)
+}
@@ -0,0 +1,175 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Canary is a golden set of images and the objects in them, run on a new
+// model before it replaces the serving one
+type Canary struct {
+	Names  []string
+	Images []image.Image
+	Truth  [][]Truth
+	// confidence detections count from
+	Min float32
+	// most the f1 may drop by, and the mean latency may grow by as a
+	// fraction, from the serving model to the new one
+	MaxDrop, MaxSlowdown float64
+}
+
+// CanaryResult is how a model did on the golden set
+type CanaryResult struct {
+	F1      float64
+	Latency time.Duration
+}
+
+func (r CanaryResult) String() string {
+	return fmt.Sprintf("f1 %.3f, %v per image", r.F1, r.Latency)
+}
+
+// LoadCanary reads the images of dir, each with a .txt of the same name
+// holding a "xmin ymin xmax ymax class" line per object in it
+func LoadCanary(dir string) (*Canary, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	c := &Canary{}
+	for _, f := range files {
+		if !isImage(f.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		im, err := LoadJpeg(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		truth, err := readTruth(strings.TrimSuffix(path, filepath.Ext(path)) + ".txt")
+		if err != nil {
+			return nil, err
+		}
+		c.Names = append(c.Names, f.Name())
+		c.Images = append(c.Images, im)
+		c.Truth = append(c.Truth, truth)
+	}
+	if len(c.Images) == 0 {
+		return nil, fmt.Errorf("%s: no canary images", dir)
+	}
+	return c, nil
+}
+
+func readTruth(file string) ([]Truth, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	truth := make([]Truth, 0)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("%s:%d: expected xmin ymin xmax ymax class", file, line)
+		}
+		var v [5]int
+		for i := range v {
+			if v[i], err = strconv.Atoi(fields[i]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", file, line, err)
+			}
+		}
+		truth = append(truth, Truth{Id: TID(len(truth)), Bounds: image.Rect(v[0], v[1], v[2], v[3]), Class: CID(v[4])})
+	}
+	return truth, scanner.Err()
+}
+
+// Run the golden set on a model, detections match objects of their class
+// they overlap by an IoU of .5
+func (c *Canary) Run(predict Predictor) (CanaryResult, error) {
+	var tp, fp, fn int
+	var took time.Duration
+	for i, im := range c.Images {
+		start := time.Now()
+		detects, err := predict(im)
+		if err != nil {
+			return CanaryResult{}, fmt.Errorf("%s: %v", c.Names[i], err)
+		}
+		took += time.Since(start)
+
+		matched := make([]bool, len(c.Truth[i]))
+		for _, d := range detects {
+			if d.Confidence < c.Min {
+				continue
+			}
+			hit := false
+			for j, t := range c.Truth[i] {
+				if !matched[j] && t.Class == d.Class && IoU(t.Bounds, d.Bounds) >= .5 {
+					matched[j], hit = true, true
+					break
+				}
+			}
+			if hit {
+				tp++
+			} else {
+				fp++
+			}
+		}
+		for _, m := range matched {
+			if !m {
+				fn++
+			}
+		}
+	}
+	r := CanaryResult{F1: 1, Latency: took / time.Duration(len(c.Images))}
+	if tp+fp+fn > 0 {
+		r.F1 = float64(2*tp) / float64(2*tp+fp+fn)
+	}
+	return r, nil
+}
+
+// Check a new model's result against the serving one's, the error says how
+// it regressed
+func (c *Canary) Check(serving, next CanaryResult) error {
+	if serving.F1-next.F1 > c.MaxDrop {
+		return fmt.Errorf("f1 dropped from %.3f to %.3f, by more than %v", serving.F1, next.F1, c.MaxDrop)
+	}
+	if float64(next.Latency) > float64(serving.Latency)*(1+c.MaxSlowdown) {
+		return fmt.Errorf("latency grew from %v to %v per image, by more than %.0f%%", serving.Latency, next.Latency, c.MaxSlowdown*100)
+	}
+	return nil
+}
+
+// HotSwap is a Predictor whose model can be replaced while serving
+type HotSwap struct {
+	mu      sync.RWMutex
+	predict Predictor
+}
+
+func NewHotSwap(predict Predictor) *HotSwap {
+	return &HotSwap{predict: predict}
+}
+
+func (h *HotSwap) Predict(im image.Image) ([]Detect, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.predict(im)
+}
+
+// Swap in a new model, once the predictions running on the old one are
+// done, so it can be closed when Swap returns
+func (h *HotSwap) Swap(predict Predictor) {
+	h.mu.Lock()
+	h.predict = predict
+	h.mu.Unlock()
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoint persists how many frames of each source have been processed, so
+// a run that is restarted resumes where it stopped. A frame is committed once
+// its output is written, after writers holding frames back let it go, and
+// every frame read before it from its sources is, so a crash repeats at most
+// the frames whose output wasn't written yet.
+type Checkpoint struct {
+	file    string
+	Offsets map[string]int `json:"offsets"`
+	// frames read from each source and not committed yet, in read order
+	pending map[string][]*frameMark
+}
+
+// frameMark places a frame in the sources it was read from, a merged view
+// stands for a frame of every camera
+type frameMark struct {
+	done bool
+}
+
+// frameHolder is a writer that holds frames back rather than writing them
+// right away, eg. to reorder them or group them into bursts. released hands
+// over the frames whose output was written since it was last called.
+type frameHolder interface {
+	released() []*frameMark
+}
+
+// LoadCheckpoint reads the checkpoint file, a missing file starts from scratch
+func LoadCheckpoint(file string) (*Checkpoint, error) {
+	c := &Checkpoint{file: file, Offsets: make(map[string]int), pending: make(map[string][]*frameMark)}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return c, nil
+}
+
+// Resume skips the frames of src processed by a previous run, without
+// decoding them. Only dirs and archives can be resumed.
+func (c *Checkpoint) Resume(src FrameSource) FrameSource {
+	skip := c.Offsets[src.Meta().URI]
+	if f, ok := src.(filtered); ok && skip > 0 {
+		f.setFilter(func(name string) bool {
+			if skip > 0 {
+				skip--
+				return false
+			}
+			return true
+		})
+	}
+	return src
+}
+
+// Read places frame, read from srcs, for Commit
+func (c *Checkpoint) Read(frame *Frame, srcs ...FrameSource) {
+	m := &frameMark{}
+	for _, src := range srcs {
+		uri := src.Meta().URI
+		c.pending[uri] = append(c.pending[uri], m)
+	}
+	frame.mark = m
+}
+
+// Commit frame once every output of it is written by w and the other
+// writers; nil after flushing w. Frames w holds back are committed when a
+// later Commit finds them released.
+func (c *Checkpoint) Commit(w DetectWriter, frame *Frame) error {
+	var written []*frameMark
+	if h, ok := w.(frameHolder); ok {
+		written = h.released()
+	} else if frame != nil {
+		written = []*frameMark{frame.mark}
+	}
+	for _, m := range written {
+		if m != nil {
+			m.done = true
+		}
+	}
+	changed := false
+	for uri, marks := range c.pending {
+		n := 0
+		for n < len(marks) && marks[n].done {
+			n++
+		}
+		if n > 0 {
+			c.Offsets[uri] += n
+			c.pending[uri] = marks[n:]
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return c.save()
+}
+
+func (c *Checkpoint) save() error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	// replaced atomically, a crash mid write leaves the previous checkpoint
+	tmp, err := ioutil.TempFile(filepath.Dir(c.file), filepath.Base(c.file))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.file)
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CocoWriter collects detections as a coco results json, written as one
+// array on Flush for pycocotools' loadRes. Classes are the category ids as
+// they are, as the coco models of the object detection api number them.
+type CocoWriter struct {
+	w io.Writer
+	// image ids by file name, from the ground truth annotations; without
+	// them the ids are the numbers coco image files are named by
+	ids     map[string]int
+	results []cocoResult
+}
+
+type cocoResult struct {
+	ImageID    int        `json:"image_id"`
+	CategoryID CID        `json:"category_id"`
+	Bbox       [4]float32 `json:"bbox"`
+	Score      float32    `json:"score"`
+}
+
+func NewCocoWriter(w io.Writer) *CocoWriter {
+	return &CocoWriter{w: w}
+}
+
+// LoadImageIDs reads the ids of images from the "images" of a coco
+// annotations file, eg. instances_val2017.json
+func (c *CocoWriter) LoadImageIDs(annotations string) error {
+	f, err := os.Open(annotations)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var gt struct {
+		Images []struct {
+			ID       int    `json:"id"`
+			FileName string `json:"file_name"`
+		} `json:"images"`
+	}
+	if err := json.NewDecoder(f).Decode(&gt); err != nil {
+		return fmt.Errorf("%s: %v", annotations, err)
+	}
+	c.ids = make(map[string]int, len(gt.Images))
+	for _, im := range gt.Images {
+		c.ids[filepath.Base(im.FileName)] = im.ID
+	}
+	return nil
+}
+
+func (c *CocoWriter) imageID(name string) (int, error) {
+	base := filepath.Base(name)
+	if c.ids != nil {
+		if id, ok := c.ids[base]; ok {
+			return id, nil
+		}
+		return 0, fmt.Errorf("%s: not an image of the annotations", RedactName(name))
+	}
+	id, err := strconv.Atoi(strings.TrimSuffix(base, filepath.Ext(base)))
+	if err != nil {
+		return 0, fmt.Errorf("%s: no image id, name images by their coco id or give the annotations", RedactName(name))
+	}
+	return id, nil
+}
+
+func (c *CocoWriter) Write(frame *Frame, detects []Detect) error {
+	id, err := c.imageID(frame.Name)
+	if err != nil {
+		return err
+	}
+	for _, d := range detects {
+		b := d.Bounds
+		c.results = append(c.results, cocoResult{
+			ImageID:    id,
+			CategoryID: d.Class,
+			Bbox:       [4]float32{float32(b.Min.X), float32(b.Min.Y), float32(b.Dx()), float32(b.Dy())},
+			Score:      d.Confidence,
+		})
+	}
+	return nil
+}
+
+// Flush writes the results as a json array
+func (c *CocoWriter) Flush() error {
+	results := c.results
+	if results == nil {
+		// an array even when empty
+		results = []cocoResult{}
+	}
+	return json.NewEncoder(c.w).Encode(results)
+}
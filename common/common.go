@@ -1,10 +1,17 @@
-package common
+package common // import "github.com/jw3/example-tensorflow-golang/common"
 
 import (
+	"bufio"
 	"bytes"
+	"fmt"
+	_ "golang.org/x/image/bmp"
 	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 	"image"
+	_ "image/gif"
 	"image/jpeg"
+	_ "image/png"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -19,6 +26,9 @@ type Chip struct {
 	X  int
 	Y  int
 	Im image.Image
+	// region of the source image covered by the chip, may extend past the
+	// source on padded edge chips
+	Bounds image.Rectangle
 }
 
 type Truth struct {
@@ -33,6 +43,22 @@ type Detect struct {
 	Class      CID
 	Chip       *Chip
 	Confidence float32
+	// re-identification, 0 when not identified
+	Identity  int
+	Embedding []float32
+	// tracking, 0 when not tracked
+	Track int
+	Speed float32
+	// seconds spent in each zone the detection is in
+	Dwell map[string]float64
+	// corner keypoints, from models that predict them
+	Keypoints []image.Point
+	// license plate read within the detection
+	Plate           string
+	PlateConfidence float32
+	// finer label of a classifier run on the crop
+	Subclass           string
+	SubclassConfidence float32
 }
 
 type Match struct {
@@ -69,18 +95,52 @@ func SplitPath(fname string) (string, string, string) {
 	return d, f, x
 }
 
+// CreateUnique creates name+ext in dir, or name-1+ext, name-2+ext... when
+// taken, never overwriting a file already written
+func CreateUnique(dir, name, ext string) (*os.File, error) {
+	for i := 0; ; i++ {
+		path := filepath.Join(dir, name+ext)
+		if i > 0 {
+			path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", name, i, ext))
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if !os.IsExist(err) {
+			return f, err
+		}
+	}
+}
+
 func LoadJpeg(imagefile string) (image.Image, error) {
 	file, err := os.Open(imagefile)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
+
+	return ReadJpeg(file)
+}
+
+// ExifOrient turns jpegs upright by their exif orientation in ReadJpeg, set
+// false to keep the pixels as stored
+var ExifOrient = true
 
-	im, ext, err := image.Decode(file)
+// decode any registered format, normalizing to jpeg, with jpegs turned
+// upright by their exif orientation, as phones save them held sideways
+func ReadJpeg(r io.Reader) (image.Image, error) {
+	br := bufio.NewReaderSize(r, exifPeek)
+	head, _ := br.Peek(exifPeek)
+	im, ext, err := image.Decode(br)
 	if err != nil {
 		return nil, err
 	}
 
 	if ext == "jpeg" {
+		if !ExifOrient {
+			return im, nil
+		}
+		if exif, err := ReadExif(head); err == nil {
+			im = Orient(im, exif.Orientation)
+		}
 		return im, nil
 	}
 
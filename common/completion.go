@@ -0,0 +1,87 @@
+package common
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SetUsage replaces flag.Usage with a structured help page for the tool
+func SetUsage(prog, synopsis string, examples ...string) {
+	flag.Usage = func() {
+		w := flag.CommandLine.Output()
+		fmt.Fprintf(w, "Usage: %s [flags]\n\n%s\n\nFlags:\n", prog, synopsis)
+		flag.PrintDefaults()
+		if len(examples) > 0 {
+			fmt.Fprintf(w, "\nExamples:\n")
+			for _, e := range examples {
+				fmt.Fprintf(w, "  %s\n", e)
+			}
+		}
+	}
+}
+
+// PrintCompletion writes a completion script for the flags of prog to stdout
+func PrintCompletion(shell, prog string) {
+	s, err := Completion(shell, prog, flag.CommandLine)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fmt.Print(s)
+}
+
+// Completion generates a bash, zsh or fish completion script for a flag set,
+// flags that take a value complete file names
+func Completion(shell, prog string, fs *flag.FlagSet) (string, error) {
+	buf := bytes.Buffer{}
+	switch shell {
+	case "bash":
+		var all, values []string
+		fs.VisitAll(func(f *flag.Flag) {
+			all = append(all, "-"+f.Name)
+			if !isBoolFlag(f) {
+				values = append(values, "-"+f.Name)
+			}
+		})
+		fn := "_" + strings.Replace(prog, "-", "_", -1)
+		fmt.Fprintf(&buf, "%s() {\n", fn)
+		fmt.Fprintf(&buf, "  local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+		if len(values) > 0 {
+			fmt.Fprintf(&buf, "  case \"$prev\" in\n    %s)\n", strings.Join(values, "|"))
+			fmt.Fprintf(&buf, "      COMPREPLY=($(compgen -f -- \"$cur\"))\n      return;;\n  esac\n")
+		}
+		fmt.Fprintf(&buf, "  COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n}\n", strings.Join(all, " "))
+		fmt.Fprintf(&buf, "complete -o filenames -F %s %s\n", fn, prog)
+	case "zsh":
+		fmt.Fprintf(&buf, "#compdef %s\n\n_arguments \\\n", prog)
+		fs.VisitAll(func(f *flag.Flag) {
+			usage := strings.NewReplacer("[", "(", "]", ")", ":", " ", "'", "").Replace(f.Usage)
+			if isBoolFlag(f) {
+				fmt.Fprintf(&buf, "  '-%s[%s]' \\\n", f.Name, usage)
+			} else {
+				fmt.Fprintf(&buf, "  '-%s[%s]:%s:_files' \\\n", f.Name, usage, f.Name)
+			}
+		})
+		buf.WriteString("  '*:file:_files'\n")
+	case "fish":
+		fs.VisitAll(func(f *flag.Flag) {
+			usage := strings.Replace(f.Usage, "'", "\\'", -1)
+			if isBoolFlag(f) {
+				fmt.Fprintf(&buf, "complete -c %s -o %s -d '%s'\n", prog, f.Name, usage)
+			} else {
+				fmt.Fprintf(&buf, "complete -c %s -o %s -d '%s' -r -F\n", prog, f.Name, usage)
+			}
+		})
+	default:
+		return "", fmt.Errorf("unsupported shell %q, expected bash, zsh or fish", shell)
+	}
+	return buf.String(), nil
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,345 @@
+package common
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is a file of flag values, flags given on the command line
+// overriding it, eg. for detect in json
+//
+//	{"model": "/models/m.pb", "min": 0.5, "serve": ":8080", "serve-model": ["a=a.pb", "b=b.pb"]}
+//
+// or the same in yaml or toml, told apart by the extension, of flat keys
+// and values only
+//
+//	model: /models/m.pb        model = "/models/m.pb"
+//	min: 0.5                   min = 0.5
+//	serve-model:               serve-model = ["a=a.pb", "b=b.pb"]
+//	  - a=a.pb
+//	  - b=b.pb
+//
+// Lists set repeatable flags once per value. Reload applies the changes to
+// the file of flags that are safe to change while running.
+type Config struct {
+	file   string
+	mtime  time.Time
+	polled time.Time
+	values map[string][]string
+	// flags given on the command line, which the file doesn't change
+	given map[string]bool
+}
+
+func LoadConfig(file string) (*Config, error) {
+	c := &Config{file: file}
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if c.values, err = readConfig(file); err != nil {
+		return nil, err
+	}
+	c.mtime, c.polled = info.ModTime(), time.Now()
+	return c, nil
+}
+
+func readConfig(file string) (map[string][]string, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return readFlat(file, b, ":")
+	case ".toml":
+		return readFlat(file, b, "=")
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	values := make(map[string][]string, len(raw))
+	for name, v := range raw {
+		list, ok := v.([]interface{})
+		if !ok {
+			list = []interface{}{v}
+		}
+		for _, item := range list {
+			switch item.(type) {
+			case string, float64, bool:
+				values[name] = append(values[name], fmt.Sprint(item))
+			default:
+				return nil, fmt.Errorf("%s: %s is not a string, number, bool or list of them", file, name)
+			}
+		}
+	}
+	return values, nil
+}
+
+// Presets are named flag values for a use of a tool, applied as a Config
+// under the command line and -config
+var Presets = map[string]map[string][]string{
+	// license plates of the cars, motorcycles, buses and trucks of a coco
+	// model, read by its -plates and -ocr models
+	"anpr": {
+		"vehicle-classes": {"3,4,6,8"},
+		"plates-min":      {"0.5"},
+	},
+}
+
+// PresetNames are the names of Presets, sorted
+func PresetNames() string {
+	names := make([]string, 0, len(Presets))
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// NewPreset is the Config of a preset, to Apply after any -config
+func NewPreset(name string) (*Config, error) {
+	values, ok := Presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown -preset %q, expected one of %s", name, PresetNames())
+	}
+	return &Config{file: "preset " + name, values: values}, nil
+}
+
+// Apply sets the flags of fs the command line didn't give, once parsed
+func (c *Config) Apply(fs *flag.FlagSet) error {
+	c.given = make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { c.given[f.Name] = true })
+	for _, name := range configKeys(c.values) {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown flag %s", c.file, name)
+		}
+		if c.given[name] {
+			continue
+		}
+		for _, v := range c.values[name] {
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("%s: %s %q: %v", c.file, name, v, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Changed tells whether the file was modified since it was last read,
+// looking at most once a second
+func (c *Config) Changed() bool {
+	if time.Since(c.polled) < time.Second {
+		return false
+	}
+	c.polled = time.Now()
+	info, err := os.Stat(c.file)
+	return err == nil && !info.ModTime().Equal(c.mtime)
+}
+
+// Reload rereads the file and sets the flags of fs that changed, if they
+// all parse, then calls apply to put them to use; if apply fails the flags
+// are put back. Changes to flags that aren't live are left out, returned
+// as an error naming the restart they need, along with the changes made as
+// "name old -> new" lines.
+func (c *Config) Reload(fs *flag.FlagSet, live map[string]bool, apply func() error) ([]string, error) {
+	info, err := os.Stat(c.file)
+	if err != nil {
+		return nil, err
+	}
+	values, err := readConfig(c.file)
+	if err != nil {
+		return nil, err
+	}
+	c.mtime = info.ModTime()
+
+	var names, diff, refused []string
+	old := make(map[string]string)
+	for _, name := range configKeys(c.values, values) {
+		before, after := c.values[name], values[name]
+		if reflect.DeepEqual(before, after) || c.given[name] {
+			continue
+		}
+		f := fs.Lookup(name)
+		if f == nil {
+			return nil, fmt.Errorf("%s: unknown flag %s", c.file, name)
+		}
+		if !live[name] {
+			refused = append(refused, fmt.Sprintf("%s from %q to %q", name, strings.Join(before, ","), strings.Join(after, ",")))
+			// still to apply, until it's put back or on restart
+			values[name] = before
+			continue
+		}
+		if len(after) == 0 {
+			after = []string{f.DefValue}
+		}
+		// parsed into a scratch value of the same type, to refuse the
+		// whole reload before any flag is set
+		scratch := reflect.New(reflect.TypeOf(f.Value).Elem()).Interface().(flag.Value)
+		for _, v := range after {
+			if err := scratch.Set(v); err != nil {
+				return nil, fmt.Errorf("%s: %s %q: %v", c.file, name, v, err)
+			}
+		}
+		names, old[name] = append(names, name), f.Value.String()
+		diff = append(diff, fmt.Sprintf("%s %s -> %s", name, f.Value.String(), strings.Join(after, ",")))
+		values[name] = after
+	}
+	if len(refused) > 0 {
+		err = fmt.Errorf("%s: changes that need a restart were not applied: %s", c.file, strings.Join(refused, ", "))
+	}
+
+	if len(names) > 0 {
+		for _, name := range names {
+			for _, v := range values[name] {
+				fs.Set(name, v)
+			}
+		}
+		if applyErr := apply(); applyErr != nil {
+			for _, name := range names {
+				fs.Set(name, old[name])
+			}
+			return nil, applyErr
+		}
+	}
+	c.values = values
+	return diff, err
+}
+
+// the flag names of configs, sorted
+func configKeys(maps ...map[string][]string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// read the flat subset of yaml, or of toml, of keys and values separated
+// by sep: scalars, quoted or not, [a, b] lists, and for yaml lists of
+// "- item" lines under a key
+func readFlat(file string, b []byte, sep string) (map[string][]string, error) {
+	values := make(map[string][]string)
+	list := ""
+	for n, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimRight(stripComment(line), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", file, n+1, fmt.Sprintf(format, args...))
+		}
+		if strings.HasPrefix(trimmed, "- ") && sep == ":" {
+			if list == "" {
+				return nil, fail("list item without a key")
+			}
+			v, err := unquoteScalar(strings.TrimSpace(trimmed[2:]))
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			values[list] = append(values[list], v)
+			continue
+		}
+		if line != trimmed {
+			return nil, fail("nested values are not supported, flags are flat keys")
+		}
+		if strings.HasPrefix(trimmed, "[") {
+			return nil, fail("tables are not supported, flags are flat keys")
+		}
+		i := strings.Index(line, sep)
+		if i < 1 {
+			return nil, fail("expected key%s value", sep)
+		}
+		key, err := unquoteScalar(strings.TrimSpace(line[:i]))
+		if err != nil {
+			return nil, fail("%v", err)
+		}
+		value := strings.TrimSpace(line[i+1:])
+		list = ""
+		switch {
+		case value == "" && sep == ":":
+			list = key
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			for _, item := range splitList(value[1 : len(value)-1]) {
+				v, err := unquoteScalar(item)
+				if err != nil {
+					return nil, fail("%v", err)
+				}
+				values[key] = append(values[key], v)
+			}
+		default:
+			v, err := unquoteScalar(value)
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			values[key] = []string{v}
+		}
+	}
+	return values, nil
+}
+
+// the line up to a # outside quotes
+func stripComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// the comma separated items of a list, commas in quotes kept
+func splitList(s string) []string {
+	var items []string
+	var quote rune
+	start := 0
+	for i, c := range s {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == ',':
+			items = append(items, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
+
+// a scalar without its quotes, double quoted ones with escapes
+func unquoteScalar(v string) (string, error) {
+	switch {
+	case len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"':
+		return strconv.Unquote(v)
+	case len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'':
+		return v[1 : len(v)-1], nil
+	}
+	return v, nil
+}
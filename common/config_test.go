@@ -0,0 +1,73 @@
+package common
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestReadFlat(t *testing.T) {
+	tests := []struct {
+		name   string
+		sep    string
+		in     string
+		values map[string][]string
+		err    bool
+	}{
+		{name: "yaml scalars", sep: ":", in: "model: /models/m.pb\nmin: 0.5\nserve: :8080\n", values: map[string][]string{"model": {"/models/m.pb"}, "min": {"0.5"}, "serve": {":8080"}}},
+		{name: "yaml document marker and blank lines", sep: ":", in: "---\n\nmin: 0.5\n\n", values: map[string][]string{"min": {"0.5"}}},
+		{name: "yaml comments", sep: ":", in: "# detect\nmin: 0.5 # lowest score\n  # indented comment\n", values: map[string][]string{"min": {"0.5"}}},
+		{name: "yaml double quoted", sep: ":", in: `label: "a # not a comment"` + "\n" + `tab: "a\tb"`, values: map[string][]string{"label": {"a # not a comment"}, "tab": {"a\tb"}}},
+		{name: "yaml single quoted", sep: ":", in: `label: 'it''s: "raw" \t'`, values: map[string][]string{"label": {`it''s: "raw" \t`}}},
+		{name: "yaml quoted key", sep: ":", in: `"min": 0.5`, values: map[string][]string{"min": {"0.5"}}},
+		{name: "yaml dash list", sep: ":", in: "serve-model:\n  - a=a.pb\n  - \"b=b.pb\" # second\n", values: map[string][]string{"serve-model": {"a=a.pb", "b=b.pb"}}},
+		{name: "yaml flow list", sep: ":", in: `serve-model: [a=a.pb, "b,c=b.pb"]`, values: map[string][]string{"serve-model": {"a=a.pb", "b,c=b.pb"}}},
+		{name: "yaml list item without a key", sep: ":", in: "- a\n", err: true},
+		{name: "yaml nested", sep: ":", in: "serve:\n  addr: :8080\n", err: true},
+		{name: "yaml bad escape", sep: ":", in: `label: "\q"`, err: true},
+		{name: "yaml no separator", sep: ":", in: "min\n", err: true},
+		{name: "toml scalars", sep: "=", in: "model = \"/models/m.pb\"\nmin = 0.5\ntrack = true\n", values: map[string][]string{"model": {"/models/m.pb"}, "min": {"0.5"}, "track": {"true"}}},
+		{name: "toml comments", sep: "=", in: "# detect\nmin = 0.5 # lowest score\nlabel = \"x # y\"\n", values: map[string][]string{"min": {"0.5"}, "label": {"x # y"}}},
+		{name: "toml literal string", sep: "=", in: `path = 'C:\models\m.pb'`, values: map[string][]string{"path": {`C:\models\m.pb`}}},
+		{name: "toml array", sep: "=", in: `serve-model = ["a=a.pb", 'b=b.pb']`, values: map[string][]string{"serve-model": {"a=a.pb", "b=b.pb"}}},
+		{name: "toml table", sep: "=", in: "[serve]\naddr = \":8080\"\n", err: true},
+		{name: "toml dash is not a list", sep: "=", in: "- a\n", err: true},
+	}
+	for _, test := range tests {
+		values, err := readFlat("test", []byte(test.in), test.sep)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(values, test.values) {
+			t.Errorf("%s: got %q, expected %q", test.name, values, test.values)
+		}
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	fs := flag.NewFlagSet("detect", flag.ContinueOnError)
+	min := fs.Float64("min", 0, "")
+	model := fs.String("model", "", "")
+	if err := fs.Parse([]string{"-model", "given.pb"}); err != nil {
+		t.Fatal(err)
+	}
+	c := &Config{file: "test", values: map[string][]string{"min": {"0.5"}, "model": {"file.pb"}}}
+	if err := c.Apply(fs); err != nil {
+		t.Fatal(err)
+	}
+	// flags given on the command line win
+	if *min != .5 || *model != "given.pb" {
+		t.Errorf("got min %v model %s, expected 0.5 given.pb", *min, *model)
+	}
+	c = &Config{file: "test", values: map[string][]string{"max": {"1"}}}
+	if err := c.Apply(fs); err == nil {
+		t.Error("expected an error for an unknown flag")
+	}
+}
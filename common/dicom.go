@@ -0,0 +1,396 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// dicom files are registered as an image format, so they decode anywhere an
+// image does; the 128 byte preamble is followed by DICM
+func init() {
+	image.RegisterFormat("dicom", strings.Repeat("?", 128)+"DICM", DecodeDicom, DecodeDicomConfig)
+}
+
+// the attributes needed to read the pixels
+type dicom struct {
+	syntax                 string
+	samples                int
+	photometric            string
+	rows, cols             int
+	bitsAllocated          int
+	bitsStored             int
+	signed                 bool
+	planar                 int
+	center, width          float64
+	slope, intercept       float64
+	pixels                 []byte
+	fragments              [][]byte
+	hasWindow, hasRescaled bool
+}
+
+type dicomTag struct{ group, elem uint16 }
+
+var (
+	tagTransferSyntax = dicomTag{0x0002, 0x0010}
+	tagSamples        = dicomTag{0x0028, 0x0002}
+	tagPhotometric    = dicomTag{0x0028, 0x0004}
+	tagPlanar         = dicomTag{0x0028, 0x0006}
+	tagRows           = dicomTag{0x0028, 0x0010}
+	tagCols           = dicomTag{0x0028, 0x0011}
+	tagBitsAllocated  = dicomTag{0x0028, 0x0100}
+	tagBitsStored     = dicomTag{0x0028, 0x0101}
+	tagPixelRep       = dicomTag{0x0028, 0x0103}
+	tagWindowCenter   = dicomTag{0x0028, 0x1050}
+	tagWindowWidth    = dicomTag{0x0028, 0x1051}
+	tagIntercept      = dicomTag{0x0028, 0x1052}
+	tagSlope          = dicomTag{0x0028, 0x1053}
+	tagPixelData      = dicomTag{0x7FE0, 0x0010}
+	tagItem           = dicomTag{0xFFFE, 0xE000}
+	tagItemEnd        = dicomTag{0xFFFE, 0xE00D}
+	tagSequenceEnd    = dicomTag{0xFFFE, 0xE0DD}
+)
+
+const (
+	implicitLittle = "1.2.840.10008.1.2"
+	explicitLittle = "1.2.840.10008.1.2.1"
+	jpegBaseline   = "1.2.840.10008.1.2.4.50"
+	undefinedLen   = 0xFFFFFFFF
+)
+
+// VRs with a 4 byte length in explicit syntaxes
+var longVRs = map[string]bool{"OB": true, "OD": true, "OF": true, "OL": true, "OV": true, "OW": true, "SQ": true, "SV": true, "UC": true, "UN": true, "UR": true, "UT": true, "UV": true}
+
+// DecodeDicom reads the first frame of a dicom file as an 8 bit image.
+// Monochrome images are windowed by the stored window center and width, or
+// stretched over their range without one, and MONOCHROME1 is inverted.
+// Uncompressed little endian and baseline jpeg transfer syntaxes are supported.
+func DecodeDicom(r io.Reader) (image.Image, error) {
+	d, err := parseDicom(r, true)
+	if err != nil {
+		return nil, err
+	}
+	if d.syntax == jpegBaseline {
+		if len(d.fragments) == 0 {
+			return nil, fmt.Errorf("dicom: no jpeg fragments")
+		}
+		return jpeg.Decode(bytes.NewReader(bytes.Join(d.fragments, nil)))
+	}
+	if d.fragments != nil {
+		return nil, fmt.Errorf("dicom: unsupported compressed transfer syntax %s", d.syntax)
+	}
+	switch {
+	case d.samples == 3 && d.bitsAllocated == 8:
+		return d.rgb()
+	case d.samples == 1 && (d.bitsAllocated == 8 || d.bitsAllocated == 16):
+		return d.gray()
+	}
+	return nil, fmt.Errorf("dicom: unsupported %d samples of %d bits", d.samples, d.bitsAllocated)
+}
+
+func DecodeDicomConfig(r io.Reader) (image.Config, error) {
+	d, err := parseDicom(r, false)
+	if err != nil {
+		return image.Config{}, err
+	}
+	model := color.GrayModel
+	if d.samples == 3 {
+		model = color.RGBAModel
+	}
+	return image.Config{ColorModel: model, Width: d.cols, Height: d.rows}, nil
+}
+
+func parseDicom(r io.Reader, pixels bool) (*dicom, error) {
+	br := bufio.NewReader(r)
+	head := make([]byte, 132)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, err
+	}
+	if string(head[128:]) != "DICM" {
+		return nil, fmt.Errorf("dicom: missing DICM")
+	}
+
+	d := &dicom{samples: 1, slope: 1}
+	p := &dicomParser{r: br, explicit: true}
+	for {
+		tag, vr, n, err := p.header()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if tag == tagPixelData {
+			if !pixels {
+				return d, nil
+			}
+			if n == undefinedLen {
+				d.fragments, err = p.fragments()
+			} else {
+				d.pixels, err = p.read(n)
+			}
+			return d, err
+		}
+		if n == undefinedLen || vr == "SQ" {
+			if err := p.skip(n); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		v, err := p.read(n)
+		if err != nil {
+			return nil, err
+		}
+		d.set(tag, v)
+		if tag == tagTransferSyntax {
+			if d.syntax != implicitLittle && d.syntax != explicitLittle && d.syntax != jpegBaseline {
+				return nil, fmt.Errorf("dicom: unsupported transfer syntax %s", d.syntax)
+			}
+			p.explicit = d.syntax != implicitLittle
+		}
+	}
+	if pixels {
+		return nil, fmt.Errorf("dicom: no pixel data")
+	}
+	return d, nil
+}
+
+func (d *dicom) set(tag dicomTag, v []byte) {
+	us := func() int {
+		if len(v) < 2 {
+			return 0
+		}
+		return int(binary.LittleEndian.Uint16(v))
+	}
+	// multi valued strings use the first value
+	str := func() string {
+		return strings.TrimRight(strings.SplitN(string(v), "\\", 2)[0], " \x00")
+	}
+	ds := func() (float64, bool) {
+		f, err := strconv.ParseFloat(strings.TrimSpace(str()), 64)
+		return f, err == nil
+	}
+	switch tag {
+	case tagTransferSyntax:
+		d.syntax = str()
+	case tagSamples:
+		d.samples = us()
+	case tagPhotometric:
+		d.photometric = strings.TrimSpace(str())
+	case tagPlanar:
+		d.planar = us()
+	case tagRows:
+		d.rows = us()
+	case tagCols:
+		d.cols = us()
+	case tagBitsAllocated:
+		d.bitsAllocated = us()
+	case tagBitsStored:
+		d.bitsStored = us()
+	case tagPixelRep:
+		d.signed = us() == 1
+	case tagWindowCenter:
+		d.center, d.hasWindow = ds()
+	case tagWindowWidth:
+		var ok bool
+		d.width, ok = ds()
+		d.hasWindow = d.hasWindow && ok && d.width >= 1
+	case tagIntercept:
+		d.intercept, _ = ds()
+	case tagSlope:
+		if s, ok := ds(); ok && s != 0 {
+			d.slope = s
+		}
+	}
+}
+
+// rescaled modality values of every pixel
+func (d *dicom) values() ([]float64, error) {
+	n := d.rows * d.cols
+	bytesPer := d.bitsAllocated / 8
+	if len(d.pixels) < n*bytesPer {
+		return nil, fmt.Errorf("dicom: %d bytes of pixel data for %dx%d", len(d.pixels), d.cols, d.rows)
+	}
+	stored := d.bitsStored
+	if stored == 0 || stored > d.bitsAllocated {
+		stored = d.bitsAllocated
+	}
+	mask := uint32(1)<<uint(stored) - 1
+	vals := make([]float64, n)
+	for i := range vals {
+		var raw uint32
+		if bytesPer == 2 {
+			raw = uint32(binary.LittleEndian.Uint16(d.pixels[2*i:]))
+		} else {
+			raw = uint32(d.pixels[i])
+		}
+		raw &= mask
+		v := float64(raw)
+		// sign extend from the stored bits
+		if d.signed && raw&(1<<uint(stored-1)) != 0 {
+			v -= float64(uint32(1) << uint(stored))
+		}
+		vals[i] = v*d.slope + d.intercept
+	}
+	return vals, nil
+}
+
+func (d *dicom) gray() (image.Image, error) {
+	vals, err := d.values()
+	if err != nil {
+		return nil, err
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	if d.hasWindow {
+		lo = d.center - 0.5 - (d.width-1)/2
+		hi = d.center - 0.5 + (d.width-1)/2
+	} else {
+		for _, v := range vals {
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+	}
+	span := hi - lo
+	if span <= 0 {
+		span = 1
+	}
+
+	im := image.NewGray(image.Rect(0, 0, d.cols, d.rows))
+	for i, v := range vals {
+		g := (v - lo) / span * 255
+		if g < 0 {
+			g = 0
+		} else if g > 255 {
+			g = 255
+		}
+		if d.photometric == "MONOCHROME1" {
+			g = 255 - g
+		}
+		im.Pix[i] = uint8(g + .5)
+	}
+	return im, nil
+}
+
+func (d *dicom) rgb() (image.Image, error) {
+	n := d.rows * d.cols
+	if len(d.pixels) < 3*n {
+		return nil, fmt.Errorf("dicom: %d bytes of pixel data for %dx%d rgb", len(d.pixels), d.cols, d.rows)
+	}
+	if d.photometric != "RGB" {
+		return nil, fmt.Errorf("dicom: unsupported photometric interpretation %s", d.photometric)
+	}
+	im := image.NewRGBA(image.Rect(0, 0, d.cols, d.rows))
+	for i := 0; i < n; i++ {
+		var r, g, b byte
+		if d.planar == 1 {
+			// all reds, then all greens, then all blues
+			r, g, b = d.pixels[i], d.pixels[n+i], d.pixels[2*n+i]
+		} else {
+			r, g, b = d.pixels[3*i], d.pixels[3*i+1], d.pixels[3*i+2]
+		}
+		copy(im.Pix[4*i:], []byte{r, g, b, 255})
+	}
+	return im, nil
+}
+
+type dicomParser struct {
+	r *bufio.Reader
+	// whether the dataset has explicit vrs; the meta group always has
+	explicit bool
+}
+
+func (p *dicomParser) header() (dicomTag, string, uint32, error) {
+	var h [8]byte
+	if _, err := io.ReadFull(p.r, h[:4]); err != nil {
+		return dicomTag{}, "", 0, err
+	}
+	tag := dicomTag{binary.LittleEndian.Uint16(h[:]), binary.LittleEndian.Uint16(h[2:])}
+	// items and delimiters have no vr in any syntax
+	if (!p.explicit && tag.group != 0x0002) || tag.group == 0xFFFE {
+		if _, err := io.ReadFull(p.r, h[4:]); err != nil {
+			return tag, "", 0, err
+		}
+		return tag, "", binary.LittleEndian.Uint32(h[4:]), nil
+	}
+	if _, err := io.ReadFull(p.r, h[4:]); err != nil {
+		return tag, "", 0, err
+	}
+	vr := string(h[4:6])
+	if !longVRs[vr] {
+		return tag, vr, uint32(binary.LittleEndian.Uint16(h[6:])), nil
+	}
+	var l [4]byte
+	if _, err := io.ReadFull(p.r, l[:]); err != nil {
+		return tag, vr, 0, err
+	}
+	return tag, vr, binary.LittleEndian.Uint32(l[:]), nil
+}
+
+// read a value of n bytes, as they come rather than all allocated up front,
+// so a corrupt length can't take more memory than the file has
+func (p *dicomParser) read(n uint32) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(p.r, int64(n)))
+	if err == nil && int64(len(b)) < int64(n) {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+// skip a sequence, of defined or undefined length
+func (p *dicomParser) skip(n uint32) error {
+	if n != undefinedLen {
+		_, err := io.CopyN(ioutil.Discard, p.r, int64(n))
+		return err
+	}
+	for {
+		tag, _, l, err := p.header()
+		if err != nil {
+			return err
+		}
+		switch tag {
+		case tagSequenceEnd, tagItemEnd:
+			return nil
+		case tagItem:
+			if l == undefinedLen {
+				// elements until the item delimiter
+				if err := p.skip(undefinedLen); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := p.skip(l); err != nil {
+			return err
+		}
+	}
+}
+
+// encapsulated pixel data; an offset table item then the fragments of the
+// frames
+func (p *dicomParser) fragments() ([][]byte, error) {
+	var frags [][]byte
+	for first := true; ; first = false {
+		tag, _, l, err := p.header()
+		if err != nil {
+			return nil, err
+		}
+		if tag == tagSequenceEnd {
+			return frags, nil
+		}
+		b, err := p.read(l)
+		if err != nil {
+			return nil, err
+		}
+		if !first {
+			frags = append(frags, b)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"fmt"
+	"image"
+	"sort"
+)
+
+// detections of different models overlapping by this IoU are the same object
+const ensembleIoU = 0.5
+
+// Ensemble combines the detections several models made of the same image,
+// matching same class detections across models by IoU, with the strategy
+//
+//	mean  the confidence averaged over all models, those that missed the
+//	      object counting as 0, and the boxes averaged weighted by it
+//	max   the most confident detection of each object
+//	vote  objects found by more than half of the models, averaged as mean
+//	      over the models that found them
+func Ensemble(views [][]Detect, strategy string) ([]Detect, error) {
+	if strategy != "mean" && strategy != "max" && strategy != "vote" {
+		return nil, fmt.Errorf("unsupported strategy %q, expected mean, max or vote", strategy)
+	}
+	type member struct {
+		model int
+		d     Detect
+	}
+	var all []member
+	for m, detects := range views {
+		for _, d := range detects {
+			all = append(all, member{m, d})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].d.Confidence > all[j].d.Confidence
+	})
+
+	// each object is its most confident detection, and at most one more of
+	// each other model
+	var objects [][]member
+	for _, a := range all {
+		found := false
+		for i, o := range objects {
+			if o[0].d.Class != a.d.Class || IoU(o[0].d.Bounds, a.d.Bounds) < ensembleIoU {
+				continue
+			}
+			seen := false
+			for _, b := range o {
+				seen = seen || b.model == a.model
+			}
+			if !seen {
+				objects[i] = append(o, a)
+				found = true
+				break
+			}
+		}
+		if !found {
+			objects = append(objects, []member{a})
+		}
+	}
+
+	out := make([]Detect, 0, len(objects))
+	for _, o := range objects {
+		d := o[0].d
+		switch strategy {
+		case "mean", "vote":
+			if strategy == "vote" && 2*len(o) <= len(views) {
+				continue
+			}
+			var sum float32
+			var x0, y0, x1, y1 float32
+			for _, b := range o {
+				c := b.d.Confidence
+				sum += c
+				x0 += c * float32(b.d.Bounds.Min.X)
+				y0 += c * float32(b.d.Bounds.Min.Y)
+				x1 += c * float32(b.d.Bounds.Max.X)
+				y1 += c * float32(b.d.Bounds.Max.Y)
+			}
+			if sum > 0 {
+				d.Bounds = image.Rect(int(x0/sum+.5), int(y0/sum+.5), int(x1/sum+.5), int(y1/sum+.5))
+			}
+			d.Confidence = sum / float32(len(views))
+			if strategy == "vote" {
+				d.Confidence = sum / float32(len(o))
+			}
+		}
+		out = append(out, d)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Confidence > out[j].Confidence
+	})
+	return out, nil
+}
@@ -0,0 +1,231 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// events are POSTed with a timeout, so a hung sink can't stall a stream
+var eventClient = &http.Client{Timeout: 10 * time.Second}
+
+const (
+	// events waiting to be POSTed, past it new ones are dropped
+	eventQueue = 1000
+	// times a failed POST is tried again, a second apart and doubling
+	eventRetries = 3
+)
+
+// TrackEvent is an object appearing in or leaving a stream, as told by its
+// track: a start when it's first detected, an end once its track is lost
+type TrackEvent struct {
+	Event  string    `json:"event"`
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	Track  int       `json:"track"`
+	Class  CID       `json:"class"`
+	Label  string    `json:"label"`
+	// of the first detection on start, the highest of the track on end
+	Confidence float32 `json:"confidence"`
+	// where it was first seen on start, last seen on end
+	Bounds [4]int `json:"bounds"`
+	// from the first frame the track was seen in to the last, on end
+	Seconds            float64 `json:"seconds,omitempty"`
+	Plate              string  `json:"plate,omitempty"`
+	Subclass           string  `json:"subclass,omitempty"`
+	SubclassConfidence float32 `json:"subclass_confidence,omitempty"`
+}
+
+// TrackEventWriter writes the start and end events of the tracks of a
+// stream as json lines, rather than a line per frame. Detections need their
+// Track set, by a Tracker updated before Write. Events to an url are POSTed
+// from a queue, so a slow sink doesn't hold up the stream.
+type TrackEventWriter struct {
+	// frames a track goes unseen before it ends, the MaxMissed of its
+	// tracker
+	Missed int
+
+	source string
+	labels Namer
+	w      io.Writer
+	f      *os.File
+	post   string
+	queue  chan []byte
+	posted chan bool
+	live   map[int]*liveTrack
+}
+
+type liveTrack struct {
+	start  TrackEvent
+	last   TrackEvent
+	missed int
+}
+
+// NewTrackEventWriter writes the events of source to dest: - for stdout, an
+// http(s) url each event is POSTed to, or a file appended to
+func NewTrackEventWriter(dest, source string, labels Namer) (*TrackEventWriter, error) {
+	e := &TrackEventWriter{Missed: 5, source: RedactName(StripCredentials(source)), labels: labels, live: make(map[int]*liveTrack)}
+	switch {
+	case dest == "-":
+		e.w = os.Stdout
+	case strings.HasPrefix(dest, "http://"), strings.HasPrefix(dest, "https://"):
+		e.post = dest
+		e.queue, e.posted = make(chan []byte, eventQueue), make(chan bool)
+		go e.poster()
+	default:
+		f, err := os.OpenFile(dest, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		e.w, e.f = f, f
+	}
+	return e, nil
+}
+
+func (e *TrackEventWriter) Write(frame *Frame, detects []Detect) error {
+	seen := make(map[int]bool)
+	var started []int
+	for _, d := range detects {
+		if d.Track == 0 {
+			continue
+		}
+		seen[d.Track] = true
+		ev := TrackEvent{Time: frame.Time, Source: e.source, Track: d.Track, Class: d.Class, Label: e.labels.Name(d.Class), Confidence: d.Confidence,
+			Bounds: [4]int{d.Bounds.Min.X, d.Bounds.Min.Y, d.Bounds.Max.X, d.Bounds.Max.Y}, Plate: d.Plate, Subclass: d.Subclass, SubclassConfidence: d.SubclassConfidence}
+		t, ok := e.live[d.Track]
+		if !ok {
+			ev.Event = "start"
+			t = &liveTrack{start: ev, last: ev}
+			e.live[d.Track] = t
+			started = append(started, d.Track)
+		}
+		best := t.last.Confidence
+		if ev.Confidence < best {
+			ev.Confidence = best
+		}
+		// secondary results are carried forward between classifications
+		if ev.Plate == "" {
+			ev.Plate = t.last.Plate
+		}
+		if ev.Subclass == "" {
+			ev.Subclass, ev.SubclassConfidence = t.last.Subclass, t.last.SubclassConfidence
+		}
+		t.last, t.missed = ev, 0
+	}
+	sort.Ints(started)
+	for _, id := range started {
+		if err := e.emit(e.live[id].start); err != nil {
+			return err
+		}
+	}
+	var ended []int
+	for id, t := range e.live {
+		if seen[id] {
+			continue
+		}
+		if t.missed++; t.missed > e.Missed {
+			ended = append(ended, id)
+		}
+	}
+	return e.end(ended)
+}
+
+// Flush ends the tracks still live, as at the end of a stream
+func (e *TrackEventWriter) Flush() error {
+	var ids []int
+	for id := range e.live {
+		ids = append(ids, id)
+	}
+	return e.end(ids)
+}
+
+func (e *TrackEventWriter) end(ids []int) error {
+	sort.Ints(ids)
+	for _, id := range ids {
+		t := e.live[id]
+		delete(e.live, id)
+		ev := t.last
+		ev.Event, ev.Seconds = "end", t.last.Time.Sub(t.start.Time).Seconds()
+		if err := e.emit(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write an event, or queue it to be POSTed; a full queue drops it, logged
+// rather than stopping the stream
+func (e *TrackEventWriter) emit(ev TrackEvent) error {
+	b, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if e.post == "" {
+		_, err := e.w.Write(b)
+		return err
+	}
+	select {
+	case e.queue <- b:
+	default:
+		log.Printf("ERROR: event of track %d to %s dropped, %d events are waiting", ev.Track, e.post, eventQueue)
+	}
+	return nil
+}
+
+// POST the queued events in order, retrying failures but for requests the
+// sink refuses
+func (e *TrackEventWriter) poster() {
+	defer close(e.posted)
+	for b := range e.queue {
+		backoff := time.Second
+		for try := 0; ; try++ {
+			retry, err := postEvent(e.post, b)
+			if err == nil {
+				break
+			}
+			if !retry || try == eventRetries {
+				log.Printf("ERROR: event to %s: %v", e.post, err)
+				break
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+// whether a failed POST may be retried, it may not when refused with a 4xx
+func postEvent(url string, b []byte) (bool, error) {
+	resp, err := eventClient.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode/100 != 4, fmt.Errorf("%s", resp.Status)
+	}
+	return false, nil
+}
+
+// Close waits a while for the queued events to be POSTed
+func (e *TrackEventWriter) Close() error {
+	if e.queue != nil {
+		close(e.queue)
+		select {
+		case <-e.posted:
+		case <-time.After(30 * time.Second):
+			log.Printf("ERROR: %d events to %s not POSTed", len(e.queue), e.post)
+		}
+	}
+	if e.f != nil {
+		return e.f.Close()
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// Exif holds the few tags read from jpeg and tiff headers
+type Exif struct {
+	// DateTimeOriginal, in local time as exif has no zone; zero when missing
+	Taken time.Time
+	// georeferencing of a geotiff, nil when missing
+	Geo *GeoTransform
+	// how the camera was held, 1 to 8 as exif numbers them, 1 upright
+	// and 0 when missing
+	Orientation int
+}
+
+// exif sits in APP1 near the start of the file, peeking this much covers it
+const exifPeek = 64 * 1024
+
+// pixelsError refuses an image of more pixels than allowed
+type pixelsError string
+
+func (e pixelsError) Error() string {
+	return string(e)
+}
+
+// readImage decodes an image, along with the exif of a jpeg or the
+// georeferencing of a geotiff
+func readImage(r io.Reader) (image.Image, *Exif, error) {
+	return readImageLimited(r, 0)
+}
+
+// readImageLimited refuses images of more than max pixels, 0 for any, by
+// their header before they're decoded. The header is read as the image
+// streams in and kept for the decoder, an image without one is refused.
+func readImageLimited(r io.Reader, max int) (image.Image, *Exif, error) {
+	if max > 0 {
+		var header bytes.Buffer
+		c, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+		if err != nil {
+			return nil, nil, err
+		}
+		if int64(c.Width)*int64(c.Height) > int64(max) {
+			return nil, nil, pixelsError(fmt.Sprintf("image of %dx%d is past the %d pixels allowed", c.Width, c.Height, max))
+		}
+		r = io.MultiReader(&header, r)
+	}
+	br := bufio.NewReaderSize(r, exifPeek)
+	head, _ := br.Peek(exifPeek)
+	if bytes.HasPrefix(head, []byte("II*\x00")) || bytes.HasPrefix(head, []byte("MM\x00*")) {
+		// tiff tags can be anywhere in the file, and the decoder reads it
+		// all anyway
+		b, err := ioutil.ReadAll(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		geo, _ := ReadGeoTiff(b)
+		im, err := ReadJpeg(bytes.NewReader(b))
+		return im, &Exif{Geo: geo}, err
+	}
+	exif, _ := ReadExif(head)
+	im, err := ReadJpeg(br)
+	return im, exif, err
+}
+
+func (e *Exif) taken() time.Time {
+	if e == nil {
+		return time.Time{}
+	}
+	return e.Taken
+}
+
+func (e *Exif) geo() *GeoTransform {
+	if e == nil {
+		return nil
+	}
+	return e.Geo
+}
+
+// ReadExif parses the exif of a jpeg from the start of the file
+func ReadExif(b []byte) (*Exif, error) {
+	if len(b) < 4 || b[0] != 0xFF || b[1] != 0xD8 {
+		return nil, fmt.Errorf("not a jpeg")
+	}
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xFF {
+			return nil, fmt.Errorf("bad jpeg marker at %d", i)
+		}
+		marker := b[i+1]
+		size := int(binary.BigEndian.Uint16(b[i+2:]))
+		// start of scan, the headers are over
+		if marker == 0xDA {
+			break
+		}
+		// the size counts its own 2 bytes, and exif's 6 byte header follows
+		if marker == 0xE1 && size >= 8 && i+2+size <= len(b) && bytes.HasPrefix(b[i+4:], []byte("Exif\x00\x00")) {
+			return parseTiff(b[i+10 : i+2+size])
+		}
+		i += 2 + size
+	}
+	return nil, fmt.Errorf("no exif")
+}
+
+const (
+	tagOrientation      = 0x0112
+	tagExifIFD          = 0x8769
+	tagDateTimeOriginal = 0x9003
+)
+
+// byte order from the tiff header
+func tiffOrder(t []byte) (binary.ByteOrder, error) {
+	if len(t) < 8 {
+		return nil, fmt.Errorf("short tiff")
+	}
+	switch string(t[:2]) {
+	case "II":
+		return binary.LittleEndian, nil
+	case "MM":
+		return binary.BigEndian, nil
+	}
+	return nil, fmt.Errorf("bad tiff byte order")
+}
+
+// tag -> 12 byte entry, of the entries of the ifd at off. Offsets are
+// untrusted, bounds are worked out in int64 so they can't overflow.
+func tiffIFD(t []byte, order binary.ByteOrder, off uint32) map[uint16][]byte {
+	entries := make(map[uint16][]byte)
+	if int64(off)+2 > int64(len(t)) {
+		return entries
+	}
+	n := int64(order.Uint16(t[off:]))
+	for e := int64(0); e < n; e++ {
+		p := int64(off) + 2 + e*12
+		if p+12 > int64(len(t)) {
+			break
+		}
+		entries[order.Uint16(t[p:])] = t[p : p+12]
+	}
+	return entries
+}
+
+func parseTiff(t []byte) (*Exif, error) {
+	order, err := tiffOrder(t)
+	if err != nil {
+		return nil, err
+	}
+
+	exif := &Exif{}
+	ifd0 := tiffIFD(t, order, order.Uint32(t[4:]))
+	if e, ok := ifd0[tagOrientation]; ok {
+		// a short, stored in the entry itself
+		exif.Orientation = int(order.Uint16(e[8:]))
+	}
+	if e, ok := ifd0[tagExifIFD]; ok {
+		sub := tiffIFD(t, order, order.Uint32(e[8:]))
+		if e, ok := sub[tagDateTimeOriginal]; ok {
+			// ascii "2006:01:02 15:04:05\x00", stored at an offset
+			count, off := int64(order.Uint32(e[4:])), int64(order.Uint32(e[8:]))
+			if off <= int64(len(t)) && off+count <= int64(len(t)) {
+				s := strings.TrimRight(string(t[off:off+count]), "\x00 ")
+				exif.Taken, _ = time.ParseInLocation("2006:01:02 15:04:05", s, time.Local)
+			}
+		}
+	}
+	return exif, nil
+}
+
+// Orient turns im upright by an exif orientation, flipping and rotating it
+// as the camera was held. Upright images and unknown orientations are
+// returned as they are.
+func Orient(im image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return im
+	}
+	b := im.Bounds()
+	w, h := b.Dx(), b.Dy()
+	src, ok := im.(*image.RGBA)
+	if !ok {
+		src = image.NewRGBA(b)
+		draw.Draw(src, b, im, b.Min, draw.Src)
+	}
+	size := image.Pt(w, h)
+	// 5 to 8 are turned a quarter, swapping width and height
+	if orientation >= 5 {
+		size = image.Pt(h, w)
+	}
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			// the source pixel of x,y
+			sx, sy := x, y
+			switch orientation {
+			case 2:
+				sx = w - 1 - x
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sy = h - 1 - y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			s := src.PixOffset(b.Min.X+sx, b.Min.Y+sy)
+			copy(dst.Pix[dst.PixOffset(x, y):], src.Pix[s:s+4])
+		}
+	}
+	return dst
+}
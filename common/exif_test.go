@@ -0,0 +1,114 @@
+package common
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// a jpeg header with an APP1 exif of the given tiff
+func exifJpeg(tiff []byte) []byte {
+	b := []byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(b[4:], uint16(2+6+len(tiff)))
+	b = append(b, "Exif\x00\x00"...)
+	b = append(b, tiff...)
+	return append(b, 0xFF, 0xDA, 0, 2)
+}
+
+// a little endian tiff of ifd0 with an orientation and an exif ifd holding
+// DateTimeOriginal
+func exifTiff(orientation uint16, taken string) []byte {
+	t := make([]byte, 8+2+2*12+4+2+12+4)
+	le := binary.LittleEndian
+	copy(t, "II*\x00")
+	le.PutUint32(t[4:], 8)
+	le.PutUint16(t[8:], 2)
+	e := t[10:]
+	le.PutUint16(e, tagOrientation)
+	le.PutUint16(e[2:], 3)
+	le.PutUint32(e[4:], 1)
+	le.PutUint16(e[8:], orientation)
+	e = t[22:]
+	le.PutUint16(e, tagExifIFD)
+	le.PutUint16(e[2:], 4)
+	le.PutUint32(e[4:], 1)
+	le.PutUint32(e[8:], 38)
+	e = t[38:]
+	le.PutUint16(e, 1)
+	e = t[40:]
+	le.PutUint16(e, tagDateTimeOriginal)
+	le.PutUint16(e[2:], 2)
+	le.PutUint32(e[4:], uint32(len(taken)+1))
+	le.PutUint32(e[8:], uint32(len(t)))
+	return append(append(t, taken...), 0)
+}
+
+func TestReadExif(t *testing.T) {
+	valid := exifJpeg(exifTiff(6, "2019:06:01 12:30:00"))
+	// the DateTimeOriginal entry of the exif ifd, from the start of the jpeg
+	dto := 12 + 40
+	corrupt := func(f func(b []byte)) []byte {
+		b := append([]byte(nil), valid...)
+		f(b)
+		return b
+	}
+	tests := []struct {
+		name        string
+		b           []byte
+		orientation int
+		taken       time.Time
+		err         bool
+	}{
+		{name: "valid", b: valid, orientation: 6, taken: time.Date(2019, 6, 1, 12, 30, 0, 0, time.Local)},
+		{name: "empty", b: nil, err: true},
+		{name: "not a jpeg", b: []byte("GIF89a"), err: true},
+		{name: "no exif", b: []byte{0xFF, 0xD8, 0xFF, 0xDA, 0, 2}, err: true},
+		{name: "app1 size 0", b: corrupt(func(b []byte) { b[4], b[5] = 0, 0 }), err: true},
+		{name: "app1 size 2", b: corrupt(func(b []byte) { b[4], b[5] = 0, 2 }), err: true},
+		{name: "app1 size 7", b: corrupt(func(b []byte) { b[4], b[5] = 0, 7 }), err: true},
+		{name: "app1 past the end", b: corrupt(func(b []byte) { b[4], b[5] = 0xFF, 0xFF }), err: true},
+		{name: "short tiff", b: exifJpeg([]byte("II*\x00")), err: true},
+		{name: "ifd0 past the end", b: corrupt(func(b []byte) { binary.LittleEndian.PutUint32(b[16:], 0xFFFFFFF0) })},
+		{name: "entries past the end", b: corrupt(func(b []byte) { binary.LittleEndian.PutUint16(b[20:], 0xFFFF) }), orientation: 6, taken: time.Date(2019, 6, 1, 12, 30, 0, 0, time.Local)},
+		{name: "exif ifd past the end", b: corrupt(func(b []byte) { binary.LittleEndian.PutUint32(b[12+22+8:], 0xFFFFFFFF) }), orientation: 6},
+		{name: "date offset past the end", b: corrupt(func(b []byte) { binary.LittleEndian.PutUint32(b[dto+8:], 0xFFFFFFF0) }), orientation: 6},
+		{name: "date offset and count overflowing", b: corrupt(func(b []byte) {
+			binary.LittleEndian.PutUint32(b[dto+4:], 0x20)
+			binary.LittleEndian.PutUint32(b[dto+8:], 0xFFFFFFF0)
+		}), orientation: 6},
+		{name: "date count past the end", b: corrupt(func(b []byte) { binary.LittleEndian.PutUint32(b[dto+4:], 0xFFFFFFFF) }), orientation: 6},
+	}
+	for _, test := range tests {
+		exif, err := ReadExif(test.b)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if exif.Orientation != test.orientation || !exif.Taken.Equal(test.taken) {
+			t.Errorf("%s: orientation %d taken %v, expected %d %v", test.name, exif.Orientation, exif.Taken, test.orientation, test.taken)
+		}
+	}
+}
+
+// corrupted headers may fail to parse but must never panic
+func TestReadExifCorrupt(t *testing.T) {
+	valid := exifJpeg(exifTiff(6, "2019:06:01 12:30:00"))
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100000; i++ {
+		b := append([]byte(nil), valid...)
+		for n := r.Intn(4) + 1; n > 0; n-- {
+			b[r.Intn(len(b))] = byte(r.Intn(256))
+		}
+		if r.Intn(4) == 0 {
+			b = b[:r.Intn(len(b))]
+		}
+		ReadExif(b)
+	}
+}
@@ -0,0 +1,50 @@
+package common
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Strings is a flag that can be repeated
+type Strings []string
+
+func (s *Strings) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *Strings) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// ParseClasses reads a comma separated list of class ids, empty is nil
+func ParseClasses(s string) (map[CID]bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+	classes := make(map[CID]bool)
+	for _, split := range strings.Split(s, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(split))
+		if err != nil {
+			return nil, fmt.Errorf("invalid class id %q", split)
+		}
+		classes[CID(id)] = true
+	}
+	return classes, nil
+}
+
+// ScoreFlags adds the -precision, -percent and -sci-below flags of a tool,
+// the returned func sets the ScoreFormat of a writer from them once parsed,
+// leaving writers to their own format when none is given
+func ScoreFlags() func(w interface{}) {
+	precision := flag.Int("precision", -1, "Digits of scores after the point, in every output format; -1 for as many as needed")
+	percent := flag.Bool("percent", false, "Scores as 0-100 percentages rather than 0-1 probabilities")
+	sci := flag.Float64("sci-below", 0, "Nonzero scores below this are in scientific notation, eg. 1e-4")
+	return func(w interface{}) {
+		if *precision >= 0 || *percent || *sci > 0 {
+			SetScoreFormat(w, ScoreFormat{Precision: *precision, Percent: *percent, SciBelow: *sci})
+		}
+	}
+}
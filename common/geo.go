@@ -0,0 +1,290 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+// GeoTransform maps pixels of a georeferenced raster to its crs, as gdal
+// does
+//
+//	x = Affine[0] + px*Affine[1] + py*Affine[2]
+//	y = Affine[3] + px*Affine[4] + py*Affine[5]
+type GeoTransform struct {
+	Affine [6]float64
+	// epsg code of the crs, 0 when unknown
+	EPSG int
+}
+
+// Apply maps pixel coordinates, of pixel corners, to the crs
+func (g *GeoTransform) Apply(px, py float64) (float64, float64) {
+	a := g.Affine
+	return a[0] + px*a[1] + py*a[2], a[3] + px*a[4] + py*a[5]
+}
+
+const (
+	tagModelPixelScale    = 33550
+	tagModelTiepoint      = 33922
+	tagModelTransform     = 34264
+	tagGeoKeyDirectory    = 34735
+	keyRasterType         = 1025
+	keyGeographicType     = 2048
+	keyProjectedCSType    = 3072
+	rasterPixelIsPoint    = 2
+	tiffDouble, tiffShort = 12, 3
+)
+
+// ReadGeoTiff reads the georeferencing of a geotiff, from a model
+// transformation or a tiepoint and pixel scale
+func ReadGeoTiff(t []byte) (*GeoTransform, error) {
+	order, err := tiffOrder(t)
+	if err != nil {
+		return nil, err
+	}
+	ifd0 := tiffIFD(t, order, order.Uint32(t[4:]))
+
+	g := &GeoTransform{}
+	if m := tiffValues(t, order, ifd0[tagModelTransform]); len(m) == 16 {
+		// row major 4x4, of which the 2d part is used
+		g.Affine = [6]float64{m[3], m[0], m[1], m[7], m[4], m[5]}
+	} else {
+		tie := tiffValues(t, order, ifd0[tagModelTiepoint])
+		scale := tiffValues(t, order, ifd0[tagModelPixelScale])
+		if len(tie) < 6 || len(scale) < 2 {
+			return nil, fmt.Errorf("not georeferenced")
+		}
+		// pixel (i,j) is at (x,y), rows run south
+		g.Affine = [6]float64{tie[3] - tie[0]*scale[0], scale[0], 0, tie[4] + tie[1]*scale[1], 0, -scale[1]}
+	}
+
+	// geokeys are a header of 4 shorts then 4 shorts a key; id, location,
+	// count, and the value when location is 0
+	keys := tiffShorts(t, order, ifd0[tagGeoKeyDirectory])
+	for k := 4; k+4 <= len(keys); k += 4 {
+		if keys[k+1] != 0 {
+			continue
+		}
+		switch v := int(keys[k+3]); keys[k] {
+		case keyProjectedCSType, keyGeographicType:
+			// user defined
+			if v != 32767 && (g.EPSG == 0 || keys[k] == keyProjectedCSType) {
+				g.EPSG = v
+			}
+		case keyRasterType:
+			if v == rasterPixelIsPoint {
+				// the tiepoint is the center of the pixel, not its corner
+				g.Affine[0] -= (g.Affine[1] + g.Affine[2]) / 2
+				g.Affine[3] -= (g.Affine[4] + g.Affine[5]) / 2
+			}
+		}
+	}
+	return g, nil
+}
+
+// the doubles of an entry, which never fit inline
+func tiffValues(t []byte, order binary.ByteOrder, e []byte) []float64 {
+	if e == nil || order.Uint16(e[2:]) != tiffDouble {
+		return nil
+	}
+	count, off := int(order.Uint32(e[4:])), int(order.Uint32(e[8:]))
+	if off+8*count > len(t) {
+		return nil
+	}
+	vals := make([]float64, count)
+	for i := range vals {
+		vals[i] = math.Float64frombits(order.Uint64(t[off+8*i:]))
+	}
+	return vals
+}
+
+// the shorts of an entry, inline when there are at most 2
+func tiffShorts(t []byte, order binary.ByteOrder, e []byte) []uint16 {
+	if e == nil || order.Uint16(e[2:]) != tiffShort {
+		return nil
+	}
+	count := int(order.Uint32(e[4:]))
+	b := e[8:]
+	if count > 2 {
+		off := int(order.Uint32(e[8:]))
+		if off+2*count > len(t) {
+			return nil
+		}
+		b = t[off:]
+	}
+	vals := make([]uint16, count)
+	for i := range vals {
+		vals[i] = order.Uint16(b[2*i:])
+	}
+	return vals
+}
+
+// GeoJsonWriter writes detections as the features of a FeatureCollection,
+// streaming them out as frames are written: the collection opens with the
+// first frame, which sets its crs, and closes on Flush. Boxes of
+// georeferenced frames are in the crs of the raster, others in an
+// image-local crs of pixels with y pointing up, so images show upright in
+// GIS tools.
+type GeoJsonWriter struct {
+	w      io.Writer
+	labels Namer
+	point  bool
+	scores *ScoreFormat
+	// crs of the collection open, set by its first frame, and whether a
+	// feature was written to it
+	started  bool
+	local    bool
+	epsg     int
+	features bool
+}
+
+// NewGeoJsonWriter writes boxes as polygon or point geometries, of the box
+// center
+func NewGeoJsonWriter(w io.Writer, labels Namer, geometry string) (*GeoJsonWriter, error) {
+	if geometry != "polygon" && geometry != "point" {
+		return nil, fmt.Errorf("unsupported geometry %q, expected polygon or point", geometry)
+	}
+	return &GeoJsonWriter{w: w, labels: labels, point: geometry == "point"}, nil
+}
+
+type geoFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoGeometry            `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// pixels of images without georeferencing
+var imageLocal = &GeoTransform{Affine: [6]float64{0, 1, 0, 0, 0, -1}}
+
+func (g *GeoJsonWriter) Write(frame *Frame, detects []Detect) error {
+	// a collection has a single crs, wgs84 when the first frame names none
+	geo := frame.Geo
+	if g.started && g.local != (geo == nil) {
+		return fmt.Errorf("%s: can't mix georeferenced and pixel space images in one collection", frame.Name)
+	}
+	if geo == nil {
+		geo = imageLocal
+	} else if epsg := g.crs(); g.started && geo.EPSG != 0 && geo.EPSG != epsg {
+		return fmt.Errorf("%s: crs EPSG:%d differs from EPSG:%d", frame.Name, geo.EPSG, epsg)
+	}
+	if !g.started {
+		g.local, g.epsg = frame.Geo == nil, geo.EPSG
+		if err := g.open(); err != nil {
+			return err
+		}
+	}
+
+	var b bytes.Buffer
+	for _, d := range detects {
+		geometry := geoGeometry{"Polygon", [][][2]float64{boxRing(geo, d.Bounds)}}
+		if g.point {
+			c := d.Bounds.Min.Add(d.Bounds.Max)
+			x, y := geo.Apply(float64(c.X)/2, float64(c.Y)/2)
+			geometry = geoGeometry{"Point", [2]float64{x, y}}
+		}
+		feature, err := json.Marshal(geoFeature{
+			Type:       "Feature",
+			Geometry:   geometry,
+			Properties: g.properties(frame, d),
+		})
+		if err != nil {
+			return err
+		}
+		if g.features {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+		b.Write(feature)
+		g.features = true
+	}
+	_, err := g.w.Write(b.Bytes())
+	return err
+}
+
+// the epsg of the collection open
+func (g *GeoJsonWriter) crs() int {
+	if g.epsg == 0 {
+		return 4326
+	}
+	return g.epsg
+}
+
+// open a collection in the crs of its first frame; a crs other than wgs84 is
+// named in the pre-rfc 7946 crs member, which gdal and qgis still read
+func (g *GeoJsonWriter) open() error {
+	collection := map[string]interface{}{"type": "FeatureCollection"}
+	switch {
+	case g.local:
+		collection["crs"] = geoCrs("image")
+	case g.crs() != 4326:
+		collection["crs"] = geoCrs(fmt.Sprintf("urn:ogc:def:crs:EPSG::%d", g.epsg))
+	}
+	b, err := json.Marshal(collection)
+	if err != nil {
+		return err
+	}
+	// the features follow the members of the collection
+	if _, err := fmt.Fprintf(g.w, "%s,\"features\":[", b[:len(b)-1]); err != nil {
+		return err
+	}
+	g.started = true
+	return nil
+}
+
+func (g *GeoJsonWriter) setScoreFormat(f ScoreFormat) { g.scores = &f }
+
+func (g *GeoJsonWriter) properties(frame *Frame, d Detect) map[string]interface{} {
+	p := map[string]interface{}{
+		"image":      frame.Name,
+		"index":      frame.Index,
+		"class":      d.Class,
+		"label":      g.labels.Name(d.Class),
+		"confidence": jsonScore(g.scores, d.Confidence),
+	}
+	if !frame.Time.IsZero() {
+		p["time"] = frame.Time
+	}
+	if d.Track > 0 {
+		p["track"] = d.Track
+	}
+	return p
+}
+
+// corners of the box, counterclockwise and closed
+func boxRing(geo *GeoTransform, r image.Rectangle) [][2]float64 {
+	corners := []image.Point{r.Min, {r.Min.X, r.Max.Y}, r.Max, {r.Max.X, r.Min.Y}, r.Min}
+	ring := make([][2]float64, len(corners))
+	for i, c := range corners {
+		x, y := geo.Apply(float64(c.X), float64(c.Y))
+		ring[i] = [2]float64{x, y}
+	}
+	return ring
+}
+
+// Flush closes the collection open, or writes an empty one when no frame
+// was written since the last
+func (g *GeoJsonWriter) Flush() error {
+	if !g.started {
+		_, err := io.WriteString(g.w, `{"type":"FeatureCollection","features":[]}`+"\n")
+		return err
+	}
+	g.started, g.local, g.epsg, g.features = false, false, 0, false
+	_, err := io.WriteString(g.w, "\n]}\n")
+	return err
+}
+
+func geoCrs(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "name",
+		"properties": map[string]string{"name": name},
+	}
+}
@@ -0,0 +1,681 @@
+package common
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type Predictor func(im image.Image) ([]Detect, error)
+
+// Job is a batch of images submitted to the jobs api
+//
+//	{"images": ["s3.jpg", "http://host/a.jpg", "frames/"], "min": 0.5, "output": "results.jsonl", "retries": 2}
+type Job struct {
+	ID     string   `json:"id"`
+	Images []string `json:"images"`
+	// minimum confidence to output
+	Min float32 `json:"min"`
+	// file under the jobs dir, or http(s) url if allowed, the json results
+	// are written to
+	Output string `json:"output"`
+	// times a failed image is retried
+	Retries int `json:"retries"`
+
+	State    string     `json:"state"`
+	Items    []JobItem  `json:"items"`
+	Done     int        `json:"done"`
+	Failed   int        `json:"failed"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+	// stored in private mode, with the images and output redacted
+	Private bool `json:"private,omitempty"`
+}
+
+// JobItem is the progress of a single image of a job
+type JobItem struct {
+	URI      string `json:"uri"`
+	State    string `json:"state"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+var jobsBucket = []byte("jobs")
+
+// JobServer runs the images of submitted jobs on Parallel workers, taking
+// turns between jobs so a large job doesn't hold up the ones behind it. Jobs
+// are kept in a bolt db in the jobs dir, and unfinished jobs resume on restart.
+//
+//	POST   /jobs             submit a job manifest, returns the job
+//	GET    /jobs/{id}        progress of a job
+//	DELETE /jobs/{id}        cancel a job, images already running finish
+//	POST   /jobs/{id}/retry  rerun the failed images of a job
+type JobServer struct {
+	predict Predictor
+	labels  Namer
+	dir     string
+	access  JobAccess
+	audit   DetectWriter
+	db      *bolt.DB
+
+	mu   sync.Mutex
+	work *sync.Cond
+	jobs map[string]*Job
+	// unfinished jobs in submission order, and the next to take a turn
+	active []string
+	turn   int
+}
+
+// JobAccess is what jobs may read and write beyond the files under the jobs
+// dir: images under Roots, and images and outputs of the url Schemes, eg.
+// https or rtsp
+type JobAccess struct {
+	Schemes map[string]bool
+	Roots   []string
+}
+
+// ParseJobAccess parses a comma separated list of url schemes and dirs,
+// dirs having a / or ., eg. https,rtsp,/data,./frames
+func ParseJobAccess(s string) (JobAccess, error) {
+	a := JobAccess{Schemes: make(map[string]bool)}
+	for _, v := range strings.Split(s, ",") {
+		switch v = strings.TrimSpace(v); {
+		case v == "":
+		case !strings.ContainsAny(v, "/."):
+			a.Schemes[strings.ToLower(v)] = true
+		default:
+			root, err := resolvePath(v)
+			if err != nil {
+				return a, err
+			}
+			a.Roots = append(a.Roots, root)
+		}
+	}
+	return a, nil
+}
+
+// NewJobServer runs jobs with predict on parallel workers, recording every
+// prediction in audit, an AuditLog behind a PrivateWriter or nil for none
+func NewJobServer(predict Predictor, labels Namer, dir string, access JobAccess, audit DetectWriter, parallel int) (*JobServer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	dir, err := resolvePath(dir)
+	if err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(dir, "jobs.db"), 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", dir, err)
+	}
+	s := &JobServer{predict: predict, labels: labels, dir: dir, access: access, audit: audit, db: db, jobs: make(map[string]*Job)}
+	s.work = sync.NewCond(&s.mu)
+	if err := s.load(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+	for i := 0; i < parallel; i++ {
+		go s.worker()
+	}
+	return s, nil
+}
+
+func (s *JobServer) Close() error {
+	return s.db.Close()
+}
+
+// load the stored jobs, requeuing images that were running when the server stopped
+func (s *JobServer) load() error {
+	loaded := make([]*Job, 0)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(jobsBucket)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			job := &Job{}
+			if err := json.Unmarshal(v, job); err != nil {
+				return fmt.Errorf("job %s: %v", k, err)
+			}
+			loaded = append(loaded, job)
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	// ids are random, resume in submission order
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].Created.Before(loaded[j].Created) })
+	for _, job := range loaded {
+		s.jobs[job.ID] = job
+		if job.State != JobQueued && job.State != JobRunning {
+			continue
+		}
+		if job.Private {
+			// its images weren't kept
+			for i := range job.Items {
+				if it := &job.Items[i]; it.State == JobQueued || it.State == JobRunning {
+					it.State, it.Error = JobFailed, errPrivateJob.Error()
+					job.Failed++
+				}
+			}
+			now := time.Now()
+			job.State, job.Error, job.Finished = JobFailed, errPrivateJob.Error(), &now
+			if err := s.save(job); err != nil {
+				return err
+			}
+			continue
+		}
+		for i := range job.Items {
+			if job.Items[i].State == JobRunning {
+				job.Items[i].State = JobQueued
+			}
+		}
+		s.active = append(s.active, job.ID)
+	}
+	return nil
+}
+
+var errPrivateJob = errors.New("the images of jobs aren't kept across restarts in private mode, submit it again")
+
+// save the job, the caller holds mu. In private mode the images and output
+// are stored redacted, so a job stopped by a restart can't be resumed.
+func (s *JobServer) save(job *Job) error {
+	if Private() {
+		job = copyJob(job)
+		job.Images = append([]string(nil), job.Images...)
+		for i := range job.Images {
+			job.Images[i] = RedactName(job.Images[i])
+		}
+		for i := range job.Items {
+			job.Items[i].URI = RedactName(job.Items[i].URI)
+		}
+		job.Output, job.Private = RedactName(job.Output), true
+	}
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).Put([]byte(job.ID), b)
+	})
+}
+
+// store saves the job for a worker, which has no request to answer with a
+// failure, so it's logged and kept as the error of the job
+func (s *JobServer) store(job *Job) {
+	if err := s.save(job); err != nil {
+		log.Printf("jobs: %s: %v", job.ID, err)
+		job.Error = fmt.Sprintf("saving the job: %v", err)
+	}
+}
+
+func (s *JobServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/"), "/")
+	id := path[0]
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		s.submit(w, r)
+	case id != "" && len(path) == 1 && r.Method == http.MethodGet:
+		if job, ok := s.Get(id); ok {
+			writeJson(w, http.StatusOK, job)
+		} else {
+			WriteProblem(w, http.StatusNotFound, CodeNotFound, "no such job")
+		}
+	case id != "" && len(path) == 1 && r.Method == http.MethodDelete:
+		s.update(w, id, s.cancel)
+	case id != "" && len(path) == 2 && path[1] == "retry" && r.Method == http.MethodPost:
+		s.update(w, id, s.retry)
+	default:
+		WriteProblem(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "expected POST /jobs, GET or DELETE /jobs/{id} or POST /jobs/{id}/retry")
+	}
+}
+
+func (s *JobServer) submit(w http.ResponseWriter, r *http.Request) {
+	job := &Job{}
+	if err := json.NewDecoder(r.Body).Decode(job); err != nil {
+		WriteProblem(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	if len(job.Images) == 0 || job.Output == "" {
+		WriteProblem(w, http.StatusBadRequest, CodeInvalidRequest, "a job needs images and an output")
+		return
+	}
+	if _, err := s.outputPath(job.Output); err != nil {
+		WriteProblem(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	for _, uri := range job.Images {
+		if _, err := s.imagePath(uri); err != nil {
+			WriteProblem(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+	}
+	job.ID = newJobID()
+	job.State = JobQueued
+	job.Created = time.Now()
+	job.Items = make([]JobItem, len(job.Images))
+	for i, uri := range job.Images {
+		job.Items[i] = JobItem{URI: uri, State: JobQueued}
+	}
+	job.Done, job.Failed, job.Error, job.Finished = 0, 0, "", nil
+
+	s.mu.Lock()
+	if err := s.save(job); err != nil {
+		s.mu.Unlock()
+		WriteProblem(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	s.jobs[job.ID] = job
+	s.active = append(s.active, job.ID)
+	s.work.Broadcast()
+	c := copyJob(job)
+	s.mu.Unlock()
+
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJson(w, http.StatusAccepted, c)
+}
+
+// apply f to a job under mu and save it
+func (s *JobServer) update(w http.ResponseWriter, id string, f func(job *Job) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		WriteProblem(w, http.StatusNotFound, CodeNotFound, "no such job")
+		return
+	}
+	if err := f(job); err != nil {
+		WriteProblem(w, http.StatusConflict, CodeJobConflict, err.Error())
+		return
+	}
+	if err := s.save(job); err != nil {
+		WriteProblem(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	writeJson(w, http.StatusOK, copyJob(job))
+}
+
+// cancel the queued images of a job, the job's state is what was asked for
+// and its images already running finish, or are cancelled when they'd be
+// retried
+func (s *JobServer) cancel(job *Job) error {
+	if job.State != JobQueued && job.State != JobRunning {
+		return fmt.Errorf("job is %s", job.State)
+	}
+	for i := range job.Items {
+		if job.Items[i].State == JobQueued {
+			job.Items[i].State = JobCancelled
+		}
+	}
+	job.State = JobCancelled
+	s.deactivate(job.ID)
+	return nil
+}
+
+func (s *JobServer) retry(job *Job) error {
+	if job.State == JobQueued || job.State == JobRunning {
+		return fmt.Errorf("job is %s", job.State)
+	}
+	if job.Private {
+		return errPrivateJob
+	}
+	n := 0
+	for i := range job.Items {
+		if it := &job.Items[i]; it.State == JobFailed || it.State == JobCancelled {
+			if it.State == JobFailed {
+				job.Failed--
+			}
+			it.State, it.Attempts, it.Error = JobQueued, 0, ""
+			n++
+		}
+	}
+	if n == 0 {
+		return fmt.Errorf("nothing to retry")
+	}
+	job.State, job.Error, job.Finished = JobQueued, "", nil
+	s.active = append(s.active, job.ID)
+	s.work.Broadcast()
+	return nil
+}
+
+func (s *JobServer) deactivate(id string) {
+	for i, a := range s.active {
+		if a == id {
+			s.active = append(s.active[:i], s.active[i+1:]...)
+			if s.turn > i {
+				s.turn--
+			}
+			return
+		}
+	}
+}
+
+// Get a copy of the job, safe to read while it runs
+func (s *JobServer) Get(id string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return copyJob(job), true
+}
+
+func copyJob(job *Job) *Job {
+	c := *job
+	c.Items = append([]JobItem(nil), job.Items...)
+	return &c
+}
+
+// next queued image, taking turns between the active jobs. The caller holds mu.
+func (s *JobServer) next() (*Job, int, bool) {
+	for n := 0; n < len(s.active); n++ {
+		t := (s.turn + n) % len(s.active)
+		job := s.jobs[s.active[t]]
+		for i := range job.Items {
+			if job.Items[i].State == JobQueued {
+				s.turn = t + 1
+				return job, i, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+func (s *JobServer) worker() {
+	for {
+		s.mu.Lock()
+		job, i, ok := s.next()
+		for !ok {
+			s.work.Wait()
+			job, i, ok = s.next()
+		}
+		job.State = JobRunning
+		item := &job.Items[i]
+		item.State = JobRunning
+		item.Attempts++
+		s.store(job)
+		uri, min := item.URI, job.Min
+		s.mu.Unlock()
+
+		err := s.runSource(job.ID, uri, min)
+
+		s.mu.Lock()
+		item = &job.Items[i]
+		switch {
+		case err == nil:
+			item.State, item.Error = JobDone, ""
+			job.Done++
+		case job.State == JobCancelled:
+			// not retried in a cancelled job, until the job is
+			item.State, item.Error = JobCancelled, err.Error()
+		case item.Attempts <= job.Retries:
+			item.State, item.Error = JobQueued, err.Error()
+		default:
+			item.State, item.Error = JobFailed, err.Error()
+			job.Failed++
+		}
+		// the job is over once every image is, whether it was retried or
+		// cancelled while this one ran
+		finished := job.State != JobDone && job.State != JobFailed && job.Done+job.Failed == len(job.Items)
+		if finished {
+			s.deactivate(job.ID)
+		}
+		s.store(job)
+		s.mu.Unlock()
+
+		if finished {
+			s.finish(job)
+		}
+	}
+}
+
+// spool of the json results of a job, appended to as images finish
+func (s *JobServer) spool(id string) string {
+	return filepath.Join(s.dir, id+".jsonl")
+}
+
+func (s *JobServer) isSpool(path string) bool {
+	name := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+	if filepath.Dir(path) != s.dir || !strings.HasSuffix(path, ".jsonl") || len(name) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// results of a single image are buffered, so a failed or retried image
+// doesn't leave partial results in the spool
+func (s *JobServer) runSource(id, uri string, min float32) error {
+	// checked again, for jobs stored before the access was narrowed
+	path, err := s.imagePath(uri)
+	if err != nil {
+		return err
+	}
+	src, err := OpenSource(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	buf := &strings.Builder{}
+	out, _ := NewDetectWriter("json", buf, s.labels)
+	out = PrivateWriter(out)
+	for {
+		frame, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		detects, err := s.predict(frame.Im)
+		if err != nil {
+			return err
+		}
+		n := 0
+		for _, d := range detects {
+			if d.Confidence > min {
+				detects[n] = d
+				n++
+			}
+		}
+		if s.audit != nil {
+			if err := s.audit.Write(frame, detects[:n]); err != nil {
+				return fmt.Errorf("audit log: %v", err)
+			}
+		}
+		if err := out.Write(frame, detects[:n]); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.spool(id), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(buf.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// write the spooled results to the job output
+func (s *JobServer) finish(job *Job) {
+	err := s.writeOutput(job)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.State = JobDone
+	if err != nil {
+		job.State = JobFailed
+		job.Error = err.Error()
+	}
+	now := time.Now()
+	job.Finished = &now
+	s.store(job)
+}
+
+func (s *JobServer) writeOutput(job *Job) error {
+	f, err := os.OpenFile(s.spool(job.ID), os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	path, err := s.outputPath(job.Output)
+	if err != nil {
+		return err
+	}
+	return writeOutput(path, f)
+}
+
+// the image a job may open for uri: an url of an allowed scheme, or a path
+// under the jobs dir or an allowed root, relative paths being in the jobs dir
+func (s *JobServer) imagePath(uri string) (string, error) {
+	if scheme := urlScheme(uri); scheme != "" {
+		if !s.access.Schemes[scheme] {
+			return "", fmt.Errorf("%s: %s urls are not allowed for jobs, see -jobs-allow", RedactName(uri), scheme)
+		}
+		return uri, nil
+	}
+	if uri == "-" {
+		return "", fmt.Errorf("stdin is not an image of a job")
+	}
+	path := uri
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.dir, path)
+	}
+	path, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	for _, root := range append([]string{s.dir}, s.access.Roots...) {
+		if within(root, path) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("%s: not under -jobs-dir or a dir of -jobs-allow", RedactName(uri))
+}
+
+// the output a job may write to: an url of an allowed scheme, or a file
+// under the jobs dir, given relative to it
+func (s *JobServer) outputPath(output string) (string, error) {
+	if scheme := urlScheme(output); scheme != "" && scheme != "file" {
+		if (scheme != "http" && scheme != "https") || !s.access.Schemes[scheme] {
+			return "", fmt.Errorf("%s: %s outputs are not allowed for jobs, see -jobs-allow", RedactName(output), scheme)
+		}
+		return output, nil
+	}
+	rel := filepath.Clean(strings.TrimPrefix(output, "file://"))
+	if filepath.IsAbs(rel) || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s: the output of a job is a file relative to -jobs-dir", RedactName(output))
+	}
+	path, err := resolvePath(filepath.Join(s.dir, rel))
+	if err != nil {
+		return "", err
+	}
+	// symlinks out of the dir, and the db and spools of the server, are refused
+	if !within(s.dir, path) || path == filepath.Join(s.dir, "jobs.db") || s.isSpool(path) {
+		return "", fmt.Errorf("%s: the output of a job is a file relative to -jobs-dir", RedactName(output))
+	}
+	return path, nil
+}
+
+// scheme of an url, or "" for a path
+func urlScheme(uri string) string {
+	i := strings.Index(uri, "://")
+	if i <= 0 || strings.ContainsAny(uri[:i], "/.") {
+		return ""
+	}
+	return strings.ToLower(uri[:i])
+}
+
+// absolute path with the symlinks of its longest existing prefix resolved
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	rest := ""
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(real, rest), nil
+		}
+		if dir == filepath.Dir(dir) {
+			return path, nil
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
+
+// whether path is root or under it
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// write the results to a file, or POST them to an http(s) url
+func writeOutput(uri string, r io.Reader) error {
+	if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+		resp, err := http.Post(uri, "application/x-ndjson", r)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("%s: %s", RedactName(uri), resp.Status)
+		}
+		return nil
+	}
+	f, err := os.Create(strings.TrimPrefix(uri, "file://"))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,168 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// a jobs dir and an allowed dir beside an outside one, with symlinks out
+//
+//	jobs/in.jpg
+//	jobs/out -> outside
+//	jobs/out.jpg -> outside/secret.jpg
+//	jobs/allowed -> allowed
+//	allowed/a.jpg
+//	outside/secret.jpg
+func jobsTree(t *testing.T) (string, *JobServer) {
+	tmp, err := ioutil.TempDir("", "jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmp, err = filepath.EvalSymlinks(tmp); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"jobs", "allowed", "outside"} {
+		if err := os.Mkdir(filepath.Join(tmp, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"jobs/in.jpg", "allowed/a.jpg", "outside/secret.jpg"} {
+		if err := ioutil.WriteFile(filepath.Join(tmp, f), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"jobs/out":     filepath.Join(tmp, "outside"),
+		"jobs/out.jpg": filepath.Join(tmp, "outside", "secret.jpg"),
+		"jobs/allowed": filepath.Join(tmp, "allowed"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(tmp, link)); err != nil {
+			t.Skip("symlinks not supported:", err)
+		}
+	}
+	access, err := ParseJobAccess("https," + filepath.Join(tmp, "allowed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tmp, &JobServer{dir: filepath.Join(tmp, "jobs"), access: access}
+}
+
+func TestJobImagePath(t *testing.T) {
+	tmp, s := jobsTree(t)
+	defer os.RemoveAll(tmp)
+	tests := []struct {
+		uri  string
+		path string
+		err  bool
+	}{
+		{uri: "in.jpg", path: "jobs/in.jpg"},
+		{uri: "./sub/../in.jpg", path: "jobs/in.jpg"},
+		{uri: filepath.Join(tmp, "jobs", "in.jpg"), path: "jobs/in.jpg"},
+		{uri: filepath.Join(tmp, "allowed", "a.jpg"), path: "allowed/a.jpg"},
+		{uri: "allowed/a.jpg", path: "allowed/a.jpg"},
+		{uri: "not-yet.jpg", path: "jobs/not-yet.jpg"},
+		{uri: "https://example.com/a.jpg", path: "https://example.com/a.jpg"},
+		{uri: "../outside/secret.jpg", err: true},
+		{uri: "../../etc/passwd", err: true},
+		{uri: filepath.Join(tmp, "outside", "secret.jpg"), err: true},
+		{uri: filepath.Join(tmp, "jobs", "..", "outside", "secret.jpg"), err: true},
+		{uri: filepath.Join(tmp, "allowed-not", "a.jpg"), err: true},
+		{uri: "out/secret.jpg", err: true},
+		{uri: "out.jpg", err: true},
+		{uri: "out/not-yet/a.jpg", err: true},
+		{uri: "http://example.com/a.jpg", err: true},
+		{uri: "-", err: true},
+	}
+	for _, test := range tests {
+		path, err := s.imagePath(test.uri)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %s", test.uri, path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.uri, err)
+			continue
+		}
+		expected := test.path
+		if urlScheme(expected) == "" {
+			expected = filepath.Join(tmp, expected)
+		}
+		if path != expected {
+			t.Errorf("%s: got %s, expected %s", test.uri, path, expected)
+		}
+	}
+}
+
+func TestJobOutputPath(t *testing.T) {
+	tmp, s := jobsTree(t)
+	defer os.RemoveAll(tmp)
+	s.access.Schemes["http"] = true
+	s.access.Schemes["rtsp"] = true
+	tests := []struct {
+		output string
+		path   string
+		err    bool
+	}{
+		{output: "results.jsonl", path: "jobs/results.jsonl"},
+		{output: "file://results.jsonl", path: "jobs/results.jsonl"},
+		{output: "sub/../results.jsonl", path: "jobs/results.jsonl"},
+		{output: "http://example.com/hook", path: "http://example.com/hook"},
+		{output: "../results.jsonl", err: true},
+		{output: "sub/../../results.jsonl", err: true},
+		{output: filepath.Join(tmp, "jobs", "results.jsonl"), err: true},
+		{output: "file://" + filepath.Join(tmp, "outside", "results.jsonl"), err: true},
+		{output: "out/results.jsonl", err: true},
+		{output: "out.jpg", err: true},
+		{output: "allowed/results.jsonl", err: true},
+		{output: ".", err: true},
+		{output: "jobs.db", err: true},
+		{output: "0123456789abcdef.jsonl", err: true},
+		{output: "rtsp://example.com/stream", err: true},
+		{output: "ftp://example.com/results.jsonl", err: true},
+	}
+	for _, test := range tests {
+		path, err := s.outputPath(test.output)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %s", test.output, path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.output, err)
+			continue
+		}
+		expected := test.path
+		if urlScheme(expected) == "" {
+			expected = filepath.Join(tmp, expected)
+		}
+		if path != expected {
+			t.Errorf("%s: got %s, expected %s", test.output, path, expected)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	tests := []struct {
+		root, path string
+		within     bool
+	}{
+		{"/data", "/data", true},
+		{"/data", "/data/a.jpg", true},
+		{"/data", "/data/..a.jpg", true},
+		{"/data", "/data2/a.jpg", false},
+		{"/data", "/", false},
+		{"/data", "/data/../etc", false},
+		{"/data/jobs", "/data", false},
+	}
+	for _, test := range tests {
+		if within(test.root, test.path) != test.within {
+			t.Errorf("within(%s, %s) expected %v", test.root, test.path, test.within)
+		}
+	}
+}
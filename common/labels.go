@@ -0,0 +1,350 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// class id to display name
+type Labels map[CID]string
+
+type Namer interface {
+	Name(c CID) string
+}
+
+// LoadLabels reads a labels file of any supported format
+func LoadLabels(labelsFile string) (Labels, error) {
+	return ReadLabels(labelsFile, "auto", "")
+}
+
+// ReadLabels reads the labels for a language in one of the formats
+//  txt    id:name lines, or a flat list of names where line i is class i
+//  pbtxt  an object detection api label map, with sparse ids
+//  csv    id,name rows such as the open images class descriptions, or a
+//         header of id and a column per language
+//  auto   picks one of the above from the extension and content
+//
+// Translations of txt and pbtxt labels are read from labels.<lang>.txt next
+// to labelsFile. Names missing from the translation fall back to labelsFile.
+func ReadLabels(labelsFile, format, lang string) (Labels, error) {
+	b, err := ioutil.ReadFile(labelsFile)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" || format == "auto" {
+		format = DetectLabelFormat(labelsFile, b)
+	}
+
+	labels, err := parseLabels(b, format, lang)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", labelsFile, err)
+	}
+	if lang == "" || format == "csv" {
+		return labels, nil
+	}
+
+	d, f, x := SplitPath(labelsFile)
+	translated, err := ReadLabels(filepath.Join(d, f+"."+lang+x), format, "")
+	if err != nil {
+		return nil, err
+	}
+	for id, name := range translated {
+		labels[id] = name
+	}
+	return labels, nil
+}
+
+// DetectLabelFormat guesses the format of a labels file from its name and content
+func DetectLabelFormat(labelsFile string, content []byte) string {
+	switch strings.ToLower(filepath.Ext(labelsFile)) {
+	case ".pbtxt":
+		return "pbtxt"
+	case ".csv":
+		return "csv"
+	}
+	trimmed := bytes.TrimSpace(content)
+	if bytes.HasPrefix(trimmed, []byte("item")) {
+		return "pbtxt"
+	}
+	return "txt"
+}
+
+func parseLabels(b []byte, format, lang string) (Labels, error) {
+	switch format {
+	case "txt":
+		return parseLabelsTxt(b)
+	case "pbtxt":
+		return parseLabelMap(b)
+	case "csv":
+		return parseLabelsCsv(b, lang)
+	}
+	return nil, fmt.Errorf("unsupported label format %q", format)
+}
+
+// parseLabelsTxt reads id:name lines, when every line but blank and #
+// comment ones is one, or else a flat list where line i is class i
+func parseLabelsTxt(b []byte) (Labels, error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	labels := make(Labels)
+	// the id of each line, and whether it's blank or a comment
+	ids := make([]int, len(lines))
+	skip := make([]bool, len(lines))
+	idnames := false
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			skip[i] = true
+			continue
+		}
+		splits := strings.SplitN(line, ":", 2)
+		id, err := strconv.Atoi(strings.TrimSpace(splits[0]))
+		if len(splits) != 2 || err != nil {
+			idnames = false
+			break
+		}
+		ids[i], idnames = id, true
+	}
+	for i, line := range lines {
+		if !idnames {
+			// blank lines are classes without a name
+			if name := strings.TrimSpace(line); name != "" {
+				labels[CID(i)] = name
+			}
+		} else if !skip[i] {
+			labels[CID(ids[i])] = strings.SplitN(line, ":", 2)[1]
+		}
+	}
+	return labels, nil
+}
+
+// csv with a header row of id,<lang>,... uses the lang column, or the first
+// language when lang is empty. Headerless rows are id,name, where ids that
+// are not numbers, eg. open images /m/011k07, are numbered from 1 in order.
+func parseLabelsCsv(b []byte, lang string) (Labels, error) {
+	rows, err := csv.NewReader(bytes.NewReader(b)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 || len(rows[0]) < 2 {
+		return nil, fmt.Errorf("expected id and name columns")
+	}
+
+	col := 1
+	if strings.TrimSpace(rows[0][0]) == "id" {
+		if lang != "" {
+			col = -1
+			for i, h := range rows[0] {
+				if i > 0 && strings.TrimSpace(h) == lang {
+					col = i
+				}
+			}
+			if col < 0 {
+				return nil, fmt.Errorf("no %q column", lang)
+			}
+		}
+		rows = rows[1:]
+	} else if lang != "" {
+		return nil, fmt.Errorf("no header row to find %q in", lang)
+	}
+
+	labels := make(Labels)
+	for i, row := range rows {
+		id, err := strconv.Atoi(strings.TrimSpace(row[0]))
+		if err != nil {
+			id = i + 1
+		}
+		// fall back to the first language when the translation is blank
+		name := row[col]
+		if name == "" {
+			name = row[1]
+		}
+		labels[CID(id)] = name
+	}
+	return labels, nil
+}
+
+// item { id: 1 name: "/m/01g317" display_name: "person" }, fields of
+// nested blocks such as the keypoints of tf2 label maps are skipped
+func parseLabelMap(b []byte) (Labels, error) {
+	labels := make(Labels)
+	toks := pbtxtTokens(string(b))
+	for i := 0; i < len(toks); i++ {
+		if toks[i] != "item" {
+			continue
+		}
+		id := -1
+		var name, display string
+		depth := 0
+		for i++; i < len(toks); i++ {
+			if toks[i] == "{" {
+				depth++
+				continue
+			}
+			if toks[i] == "}" {
+				if depth--; depth == 0 {
+					break
+				}
+				continue
+			}
+			if depth != 1 || i+2 >= len(toks) || toks[i+1] != ":" {
+				continue
+			}
+			switch toks[i] {
+			case "id":
+				n, err := strconv.Atoi(toks[i+2])
+				if err != nil {
+					return nil, fmt.Errorf("invalid id %q", toks[i+2])
+				}
+				id = n
+			case "name":
+				name = unquote(toks[i+2])
+			case "display_name":
+				display = unquote(toks[i+2])
+			}
+			i += 2
+		}
+		if id < 0 {
+			return nil, fmt.Errorf("label map item without an id")
+		}
+		if display == "" {
+			display = name
+		}
+		labels[CID(id)] = display
+	}
+	return labels, nil
+}
+
+func pbtxtTokens(s string) []string {
+	var toks []string
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '#':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == ' ', c == '\t', c == '\n', c == '\r', c == ',', c == ';':
+			i++
+		case c == '{', c == '}', c == ':':
+			toks = append(toks, string(c))
+			i++
+		case c == '"', c == '\'':
+			j := i + 1
+			for j < len(s) && s[j] != c {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				j = len(s) - 1
+			}
+			toks = append(toks, s[i:j+1])
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\r\n{}:,;\"'#", rune(s[j])) {
+				j++
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		}
+	}
+	return toks
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') {
+		if u, err := strconv.Unquote(`"` + s[1:len(s)-1] + `"`); err == nil {
+			return u
+		}
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// Name of the class, or its id when unmapped
+func (l Labels) Name(c CID) string {
+	if name, ok := l[c]; ok {
+		return name
+	}
+	return strconv.Itoa(int(c))
+}
+
+func (l Labels) IDs() []CID {
+	ids := make([]CID, 0, len(l))
+	for id := range l {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// LiveLabels are labels that can be reloaded from disk while in use
+type LiveLabels struct {
+	mu     sync.RWMutex
+	labels Labels
+	// reloads so far, see Generation
+	generation int
+
+	file, format, lang string
+}
+
+func NewLiveLabels(labelsFile, format, lang string) (*LiveLabels, error) {
+	l := &LiveLabels{file: labelsFile, format: format, lang: lang}
+	return l, l.Reload()
+}
+
+// Reload rereads the labels file, keeping the current labels on error
+func (l *LiveLabels) Reload() error {
+	labels, err := ReadLabels(l.file, l.format, l.lang)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.labels = labels
+	l.generation++
+	l.mu.Unlock()
+	return nil
+}
+
+// Generation changes on each reload, for the responses naming the labels
+// to be told apart, see DetectHandler.etag
+func (l *LiveLabels) Generation() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.generation
+}
+
+func (l *LiveLabels) Name(c CID) string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.labels.Name(c)
+}
+
+// Labels is a snapshot of the current labels
+func (l *LiveLabels) Labels() Labels {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.labels
+}
+
+func (l *LiveLabels) File() string {
+	return l.file
+}
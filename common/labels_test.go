@@ -0,0 +1,114 @@
+package common
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		lang   string
+		in     string
+		labels Labels
+		err    bool
+	}{
+		{name: "txt flat", format: "txt", in: "background\nperson\nbicycle\n", labels: Labels{0: "background", 1: "person", 2: "bicycle"}},
+		{name: "txt flat with a blank class", format: "txt", in: "person\n\ncar\n", labels: Labels{0: "person", 2: "car"}},
+		{name: "txt id:name", format: "txt", in: "11:Fixed-wing Aircraft\n12:Small Aircraft\n", labels: Labels{11: "Fixed-wing Aircraft", 12: "Small Aircraft"}},
+		{name: "txt id:name with blanks and comments", format: "txt", in: "# xview\n\n1:car\n\n# more\n2:truck\n", labels: Labels{1: "car", 2: "truck"}},
+		{name: "txt mixed is flat", format: "txt", in: "1:car\ntruck\n", labels: Labels{0: "1:car", 1: "truck"}},
+		{name: "txt name with a colon", format: "txt", in: "1:bus: double decker\n", labels: Labels{1: "bus: double decker"}},
+		{name: "pbtxt", format: "pbtxt", in: `item { id: 1 name: "/m/01g317" display_name: "person" }
+item {
+  name: "/m/0199g"
+  id: 2
+}`, labels: Labels{1: "person", 2: "/m/0199g"}},
+		{name: "pbtxt nested blocks", format: "pbtxt", in: `item {
+  id: 1
+  display_name: "person"
+  keypoints {
+    id: 0
+    label: "nose"
+  }
+  keypoints { id: 1 label: "left_eye" }
+}
+item { id: 3, display_name: 'car' } # trailing comment
+`, labels: Labels{1: "person", 3: "car"}},
+		{name: "pbtxt without an id", format: "pbtxt", in: `item { name: "x" }`, err: true},
+		{name: "pbtxt with a bad id", format: "pbtxt", in: `item { id: x name: "x" }`, err: true},
+		{name: "csv headerless", format: "csv", in: "1,person\n2,bicycle\n", labels: Labels{1: "person", 2: "bicycle"}},
+		{name: "csv open images ids", format: "csv", in: "/m/011k07,Tortoise\n/m/011q46kg,Container\n", labels: Labels{1: "Tortoise", 2: "Container"}},
+		{name: "csv header first language", format: "csv", in: "id,en,de\n1,person,Person\n2,car,Auto\n", labels: Labels{1: "person", 2: "car"}},
+		{name: "csv translated column", format: "csv", lang: "de", in: "id,en,de\n1,person,Person\n2,car,Auto\n", labels: Labels{1: "Person", 2: "Auto"}},
+		{name: "csv blank translation", format: "csv", lang: "de", in: "id,en,de\n1,person,\n2,car,Auto\n", labels: Labels{1: "person", 2: "Auto"}},
+		{name: "csv missing language", format: "csv", lang: "fr", in: "id,en,de\n1,person,Person\n", err: true},
+		{name: "csv no header for a language", format: "csv", lang: "de", in: "1,person\n", err: true},
+		{name: "csv one column", format: "csv", in: "person\n", err: true},
+		{name: "unknown format", format: "xml", in: "", err: true},
+	}
+	for _, test := range tests {
+		labels, err := parseLabels([]byte(test.in), test.format, test.lang)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(labels, test.labels) {
+			t.Errorf("%s: got %v, expected %v", test.name, labels, test.labels)
+		}
+	}
+}
+
+func TestDetectLabelFormat(t *testing.T) {
+	tests := []struct {
+		file, content, format string
+	}{
+		{"labels.txt", "person\n", "txt"},
+		{"label_map.pbtxt", "", "pbtxt"},
+		{"LABELS.CSV", "", "csv"},
+		{"labels", "  item { id: 1 }", "pbtxt"},
+		{"labels", "1:person", "txt"},
+	}
+	for _, test := range tests {
+		if format := DetectLabelFormat(test.file, []byte(test.content)); format != test.format {
+			t.Errorf("%s %q: got %s, expected %s", test.file, test.content, format, test.format)
+		}
+	}
+}
+
+func TestReadLabelsTranslated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "labels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("labels.txt", "1:person\n2:car\n")
+	write("labels.de.txt", "2:Auto\n")
+
+	labels, err := ReadLabels(filepath.Join(dir, "labels.txt"), "auto", "de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// names missing from the translation fall back
+	if expected := (Labels{1: "person", 2: "Auto"}); !reflect.DeepEqual(labels, expected) {
+		t.Errorf("got %v, expected %v", labels, expected)
+	}
+	if _, err := ReadLabels(filepath.Join(dir, "labels.txt"), "auto", "fr"); err == nil {
+		t.Error("expected an error for a missing translation")
+	}
+}
@@ -0,0 +1,35 @@
+//go:build cgo
+// +build cgo
+
+package common
+
+/*
+#include <launch.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// the socket launchd opened for the Sockets entry of the name in the plist
+// of the job, nil when not run by launchd or it has none of the name. An
+// entry listening on both ipv4 and ipv6 serves the first only.
+func launchdSocket(name string) *os.File {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	var fds *C.int
+	var n C.size_t
+	if C.launch_activate_socket(cname, &fds, &n) != 0 || n == 0 {
+		return nil
+	}
+	defer C.free(unsafe.Pointer(fds))
+	all := (*[1 << 10]C.int)(unsafe.Pointer(fds))[:n:n]
+	for _, fd := range all[1:] {
+		syscall.Close(int(fd))
+	}
+	return os.NewFile(uintptr(all[0]), name)
+}
@@ -0,0 +1,11 @@
+//go:build !darwin || !cgo
+// +build !darwin !cgo
+
+package common
+
+import "os"
+
+// launchd runs jobs on macos only
+func launchdSocket(name string) *os.File {
+	return nil
+}
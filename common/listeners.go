@@ -0,0 +1,306 @@
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// the first bytes of an http/2 connection with prior knowledge, as grpc
+// clients open theirs
+const http2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// Listeners serves http and grpc on a set of addresses that can change while
+// running, through its admin api, so a load balancer can be moved to a new
+// address before the old one is closed. Each address serves http, grpc or
+// both on the one port, told apart by the first bytes of each connection.
+type Listeners struct {
+	http http.Handler
+	grpc func(net.Listener) error
+
+	mu   sync.Mutex
+	open map[*listener]bool
+	errs chan error
+}
+
+// Served is an address served on, as it was asked for and as listened on,
+// eg. :8080 and [::]:8080
+type Served struct {
+	Addr      string `json:"addr"`
+	Listening string `json:"listening,omitempty"`
+	Protocol  string `json:"protocol"`
+}
+
+type listener struct {
+	Served
+	lis     net.Listener
+	removed bool
+}
+
+// NewListeners serves h on the http listeners and grpc, eg. the Serve of a
+// grpc.Server, on the grpc ones
+func NewListeners(h http.Handler, grpc func(net.Listener) error) *Listeners {
+	return &Listeners{http: h, grpc: grpc, open: make(map[*listener]bool), errs: make(chan error, 1)}
+}
+
+// Add listens on addr and serves protocol on it; http, grpc or both
+func (l *Listeners) Add(addr, protocol string) (string, error) {
+	if err := checkProtocol(protocol); err != nil {
+		return "", err
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return "", err
+	}
+	return l.Serve(lis, addr, protocol)
+}
+
+// Serve protocol on lis, eg. a socket passed by a supervisor for addr,
+// returning the address listened on
+func (l *Listeners) Serve(lis net.Listener, addr, protocol string) (string, error) {
+	if err := checkProtocol(protocol); err != nil {
+		lis.Close()
+		return "", err
+	}
+	e := &listener{Served: Served{Addr: addr, Listening: lis.Addr().String(), Protocol: protocol}, lis: lis}
+	l.mu.Lock()
+	l.open[e] = true
+	l.mu.Unlock()
+	log.Printf("serving %s on %s", protocol, addr)
+	go l.serve(e)
+	return e.Listening, nil
+}
+
+func checkProtocol(protocol string) error {
+	switch protocol {
+	case "http", "grpc", "both":
+		return nil
+	}
+	return fmt.Errorf("unsupported protocol %q, expected http, grpc or both", protocol)
+}
+
+func (l *Listeners) serve(e *listener) {
+	var err error
+	switch e.Protocol {
+	case "http":
+		err = http.Serve(e.lis, l.http)
+	case "grpc":
+		err = l.grpc(e.lis)
+	case "both":
+		grpc, h := splitListener(e.lis)
+		errs := make(chan error, 2)
+		go func() { errs <- l.grpc(grpc) }()
+		go func() { errs <- http.Serve(h, l.http) }()
+		err = <-errs
+		e.lis.Close()
+		<-errs
+	}
+	l.mu.Lock()
+	removed := e.removed
+	delete(l.open, e)
+	l.mu.Unlock()
+	if removed {
+		log.Printf("stopped serving %s on %s", e.Protocol, e.Addr)
+		return
+	}
+	if err == nil {
+		err = fmt.Errorf("%s: stopped serving %s", e.Addr, e.Protocol)
+	}
+	select {
+	case l.errs <- err:
+	default:
+	}
+}
+
+// Remove stops listening on addr, as asked for or as listened on.
+// Connections already open are served on until their clients close them.
+func (l *Listeners) Remove(addr string) error {
+	l.mu.Lock()
+	var found []*listener
+	for e := range l.open {
+		if !e.removed && (e.Addr == addr || e.Listening == addr) {
+			e.removed = true
+			found = append(found, e)
+		}
+	}
+	l.mu.Unlock()
+	if len(found) == 0 {
+		return fmt.Errorf("not listening on %q", addr)
+	}
+	for _, e := range found {
+		e.lis.Close()
+	}
+	return nil
+}
+
+// List the addresses served on, sorted
+func (l *Listeners) List() []Served {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	list := make([]Served, 0, len(l.open))
+	for e := range l.open {
+		if !e.removed {
+			list = append(list, e.Served)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Addr < list[j].Addr })
+	return list
+}
+
+// Wait for a listener to fail, one removed doesn't
+func (l *Listeners) Wait() error {
+	return <-l.errs
+}
+
+// ServeHTTP is the admin api of the listeners:
+//
+//	GET /listeners                 lists them
+//	POST /listeners                adds {"addr": ":8081", "protocol": "both"}
+//	DELETE /listeners?addr=:8080   removes one
+func (l *Listeners) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/listeners" {
+		WriteProblem(w, http.StatusNotFound, CodeNotFound, "expected /listeners")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(l.List())
+	case http.MethodPost:
+		var req Served
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+			WriteProblem(w, http.StatusBadRequest, CodeInvalidRequest, "invalid listener: "+err.Error())
+			return
+		}
+		if req.Protocol == "" {
+			req.Protocol = "both"
+		}
+		if err := checkProtocol(req.Protocol); err != nil {
+			WriteProblem(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		addr, err := l.Add(req.Addr, req.Protocol)
+		if err != nil {
+			WriteProblem(w, http.StatusConflict, CodeListenFailed, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(Served{Addr: req.Addr, Listening: addr, Protocol: req.Protocol})
+	case http.MethodDelete:
+		if err := l.Remove(r.URL.Query().Get("addr")); err != nil {
+			WriteProblem(w, http.StatusNotFound, CodeNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		WriteProblem(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "expected GET, POST or DELETE")
+	}
+}
+
+var errListenerClosed = errors.New("listener closed")
+
+// splitListener hands the connections of lis to a grpc and an http
+// listener, by whether they open with the http/2 preface. Closing lis
+// closes both.
+func splitListener(lis net.Listener) (grpc, h net.Listener) {
+	g, hl := newConnListener(lis), newConnListener(lis)
+	go func() {
+		defer g.Close()
+		defer hl.Close()
+		for {
+			c, err := lis.Accept()
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Temporary() {
+					time.Sleep(5 * time.Millisecond)
+					continue
+				}
+				return
+			}
+			go func() {
+				c, isGrpc, err := sniff(c)
+				if err != nil {
+					c.Close()
+					return
+				}
+				if isGrpc {
+					g.hand(c)
+				} else {
+					hl.hand(c)
+				}
+			}()
+		}
+	}()
+	return g, hl
+}
+
+// sniff whether c opens with the http/2 preface, reading no further than
+// the first byte that differs, as short http/1 requests wait for a reply
+func sniff(c net.Conn) (net.Conn, bool, error) {
+	c.SetReadDeadline(time.Now().Add(10 * time.Second))
+	defer c.SetReadDeadline(time.Time{})
+	r := bufio.NewReaderSize(c, len(http2Preface))
+	peeked := &peekedConn{c, r}
+	for n := 1; n <= len(http2Preface); n++ {
+		b, err := r.Peek(n)
+		if err != nil {
+			return c, false, err
+		}
+		if b[n-1] != http2Preface[n-1] {
+			return peeked, false, nil
+		}
+	}
+	return peeked, true, nil
+}
+
+// a conn whose first bytes were peeked at
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) { return c.r.Read(b) }
+
+// connListener accepts the conns handed to it
+type connListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newConnListener(lis net.Listener) *connListener {
+	return &connListener{addr: lis.Addr(), conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *connListener) hand(c net.Conn) {
+	select {
+	case l.conns <- c:
+	case <-l.done:
+		c.Close()
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, errListenerClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr { return l.addr }
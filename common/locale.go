@@ -0,0 +1,159 @@
+package common
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// console messages are printed in the -locale: translated by the catalog of
+// its language, falling back to english, with numbers grouped and the
+// decimal separator of the locale. Output meant for programs, plain, json
+// and the logs of servers, stays english.
+var locale = struct {
+	printer *message.Printer
+	decimal string
+}{message.NewPrinter(language.English), "."}
+
+// T formats a console message in the locale, the english format is the key
+// of its translations
+func T(format string, args ...interface{}) string {
+	return locale.printer.Sprintf(format, args...)
+}
+
+// LocalizeDecimal swaps the point of a number formatted for programs for
+// the decimal separator of the locale
+func LocalizeDecimal(s string) string {
+	return strings.Replace(s, ".", locale.decimal, 1)
+}
+
+// SetLocale picks the locale of console messages, a BCP 47 tag or a posix
+// locale as LANG has it, eg. de, pt-BR or de_DE.UTF-8
+func SetLocale(name string) error {
+	tag, err := parseLocale(name)
+	if err != nil {
+		return err
+	}
+	locale.printer = message.NewPrinter(tag)
+	// the separator of the locale, between the digits of a formatted 1.5.
+	// Locales of native digits, eg. ar or fa, keep the point: their
+	// separator amid the latin digits of a number for programs garbles it.
+	locale.decimal = "."
+	if s := locale.printer.Sprintf("%.1f", 1.5); len(s) > 2 && s[0] == '1' && s[len(s)-1] == '5' {
+		locale.decimal = s[1 : len(s)-1]
+	}
+	return nil
+}
+
+func parseLocale(name string) (language.Tag, error) {
+	// de_DE.UTF-8@euro
+	if i := strings.IndexAny(name, ".@"); i >= 0 {
+		name = name[:i]
+	}
+	if name == "" || name == "C" || name == "POSIX" {
+		return language.English, nil
+	}
+	tag, err := language.Parse(strings.Replace(name, "_", "-", -1))
+	if err != nil {
+		return language.English, fmt.Errorf("invalid locale %q: %v", name, err)
+	}
+	return tag, nil
+}
+
+// LoadMessages adds a catalog of translations to the messages of a locale,
+// a json object of english format to translated format, eg.
+//
+//	{"%d detections": "%d Erkennungen"}
+func LoadMessages(name, file string) error {
+	tag, err := parseLocale(name)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var messages map[string]string
+	if err := json.Unmarshal(b, &messages); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	for key, msg := range messages {
+		if err := message.SetString(tag, key, msg); err != nil {
+			return fmt.Errorf("%s: %q: %v", file, key, err)
+		}
+	}
+	return nil
+}
+
+// LocaleFlags adds the -locale and -locale-messages flags of a tool, the
+// returned func sets the locale from them once parsed. A locale of the
+// environment that doesn't parse is warned about and english used.
+func LocaleFlags() func() error {
+	name := flag.String("locale", "", "Language and number format of console messages, eg. de or pt-BR; LC_ALL, LC_MESSAGES or LANG when not given")
+	messages := flag.String("locale-messages", "", "Json catalog of translations of the console messages for -locale, over the built-in ones")
+	return func() error {
+		if *name == "" {
+			for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+				if *name = os.Getenv(env); *name != "" {
+					if _, err := parseLocale(*name); err != nil {
+						log.Printf("%s: %v, using english", env, err)
+						*name = ""
+					}
+					break
+				}
+			}
+		}
+		if *messages != "" {
+			if err := LoadMessages(*name, *messages); err != nil {
+				return err
+			}
+		}
+		return SetLocale(*name)
+	}
+}
+
+// the built-in catalogs
+func init() {
+	catalogs := map[string]map[string]string{
+		"de": {
+			"%d detections":                         "%d Erkennungen",
+			"track %s":                              "Spur %s",
+			"%d images timed out":                   "Zeitüberschreitung bei %d Bildern",
+			"smoke: %s ok in %v":                    "Rauchtest: %s ok in %v",
+			"smoke: ok":                             "Rauchtest: ok",
+			"bench: no images run":                  "bench: keine Bilder verarbeitet",
+			"bench: %d images in %v, %.2f images/s": "bench: %d Bilder in %v, %.2f Bilder/s",
+		},
+		"fr": {
+			"%d detections":                         "%d détections",
+			"track %s":                              "piste %s",
+			"%d images timed out":                   "%d images ont expiré",
+			"smoke: %s ok in %v":                    "test de fumée : %s ok en %v",
+			"smoke: ok":                             "test de fumée : ok",
+			"bench: no images run":                  "bench : aucune image traitée",
+			"bench: %d images in %v, %.2f images/s": "bench : %d images en %v, %.2f images/s",
+		},
+		"es": {
+			"%d detections":                         "%d detecciones",
+			"track %s":                              "pista %s",
+			"%d images timed out":                   "%d imágenes agotaron el tiempo",
+			"smoke: %s ok in %v":                    "prueba de humo: %s ok en %v",
+			"smoke: ok":                             "prueba de humo: ok",
+			"bench: no images run":                  "bench: ninguna imagen procesada",
+			"bench: %d images in %v, %.2f images/s": "bench: %d imágenes en %v, %.2f imágenes/s",
+		},
+	}
+	for lang, messages := range catalogs {
+		tag := language.MustParse(lang)
+		for key, msg := range messages {
+			message.SetString(tag, key, msg)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+// Homography maps points of one view onto another, row major 3x3
+type Homography [9]float64
+
+var Identity = Homography{1, 0, 0, 0, 1, 0, 0, 0, 1}
+
+func (h Homography) Apply(x, y float64) (float64, float64) {
+	w := h[6]*x + h[7]*y + h[8]
+	return (h[0]*x + h[1]*y + h[2]) / w, (h[3]*x + h[4]*y + h[5]) / w
+}
+
+// ApplyRect maps the corners of r, returning their bounding box
+func (h Homography) ApplyRect(r image.Rectangle) image.Rectangle {
+	if h == Identity {
+		return r
+	}
+	mx, my := math.Inf(1), math.Inf(1)
+	Mx, My := math.Inf(-1), math.Inf(-1)
+	for _, p := range []image.Point{r.Min, {r.Max.X, r.Min.Y}, r.Max, {r.Min.X, r.Max.Y}} {
+		x, y := h.Apply(float64(p.X), float64(p.Y))
+		mx, my = math.Min(mx, x), math.Min(my, y)
+		Mx, My = math.Max(Mx, x), math.Max(My, y)
+	}
+	return image.Rect(int(mx), int(my), int(math.Ceil(Mx)), int(math.Ceil(My)))
+}
+
+// LoadHomographies reads a json object of source uri to 9 homography values
+func LoadHomographies(file string) (map[string]Homography, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	hs := make(map[string]Homography)
+	if err := json.Unmarshal(b, &hs); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return hs, nil
+}
+
+func Area(r image.Rectangle) int {
+	z := r.Size()
+	return z.X * z.Y
+}
+
+// IoU is the intersection over union of two boxes
+func IoU(a, b image.Rectangle) float32 {
+	i := Area(a.Intersect(b))
+	if i == 0 {
+		return 0
+	}
+	return float32(i) / float32(Area(a)+Area(b)-i)
+}
+
+// IoS is the intersection over the smaller of two boxes, so a box cut off at
+// a tile edge still matches the complete box from the neighbouring tile
+func IoS(a, b image.Rectangle) float32 {
+	i := Area(a.Intersect(b))
+	if i == 0 {
+		return 0
+	}
+	s := Area(a)
+	if bs := Area(b); bs < s {
+		s = bs
+	}
+	return float32(i) / float32(s)
+}
+
+// MergeDetects collapses detections of the same class that overlap by at
+// least ios into the most confident one, growing its box to cover the others
+func MergeDetects(detects []Detect, ios float32) []Detect {
+	sorted := make([]Detect, len(detects))
+	copy(sorted, detects)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Confidence > sorted[j].Confidence
+	})
+
+	merged := make([]Detect, 0, len(sorted))
+	for _, d := range sorted {
+		dup := false
+		for i := range merged {
+			if merged[i].Class == d.Class && IoS(merged[i].Bounds, d.Bounds) >= ios {
+				merged[i].Bounds = merged[i].Bounds.Union(d.Bounds)
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			merged = append(merged, d)
+		}
+	}
+	return merged
+}
+
+// NMS is class aware non-maximum suppression: of detections of the same
+// class overlapping by at least iou, only the most confident one is kept,
+// for graphs that output their raw boxes
+func NMS(detects []Detect, iou float32) []Detect {
+	sorted := make([]Detect, len(detects))
+	copy(sorted, detects)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Confidence > sorted[j].Confidence
+	})
+
+	kept := make([]Detect, 0, len(sorted))
+	for _, d := range sorted {
+		suppressed := false
+		for _, k := range kept {
+			if k.Class == d.Class && IoU(k.Bounds, d.Bounds) >= iou {
+				suppressed = true
+				break
+			}
+		}
+		if !suppressed {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// Merger unifies the detections of several views of the same scene
+type Merger struct {
+	// keyed by source uri, sources without one are already in the shared view
+	Homographies map[string]Homography
+	IoS          float32
+}
+
+func (m *Merger) Merge(views map[string][]Detect) []Detect {
+	all := make([]Detect, 0)
+	for uri, detects := range views {
+		h, ok := m.Homographies[uri]
+		if !ok {
+			h = Identity
+		}
+		for _, d := range detects {
+			d.Bounds = h.ApplyRect(d.Bounds)
+			all = append(all, d)
+		}
+	}
+	return MergeDetects(all, m.IoS)
+}
@@ -0,0 +1,192 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latency buckets in seconds, those of the prometheus client
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics of a server, answering GET /metrics in the prometheus text format.
+// Methods on a nil Metrics do nothing, so handlers without one needn't
+// check.
+type Metrics struct {
+	Labels Namer
+
+	mu         sync.Mutex
+	images     int
+	errors     map[string]int
+	detections map[CID]int
+	preprocess *histogram
+	inference  *histogram
+	// estimated flops of a chip, on device, and of the images run
+	chipFLOPs, flops float64
+	device           string
+}
+
+func NewMetrics(labels Namer) *Metrics {
+	return &Metrics{
+		Labels:     labels,
+		errors:     make(map[string]int),
+		detections: make(map[CID]int),
+		preprocess: newHistogram(latencyBuckets),
+		inference:  newHistogram(latencyBuckets),
+	}
+}
+
+// Preprocessed records the time an image took to read and decode
+func (m *Metrics) Preprocessed(took time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.preprocess.observe(took.Seconds())
+	m.mu.Unlock()
+}
+
+// Detected records an image run on the model and the detections answered
+func (m *Metrics) Detected(took time.Duration, detects []Detect) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.images++
+	m.inference.observe(took.Seconds())
+	for _, d := range detects {
+		m.detections[d.Class]++
+	}
+}
+
+// SetCost of running a chip of the model on a device
+func (m *Metrics) SetCost(chipFLOPs float64, device string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.chipFLOPs, m.device = chipFLOPs, device
+	m.mu.Unlock()
+}
+
+// Spent records the estimated flops of an image run
+func (m *Metrics) Spent(flops float64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.flops += flops
+	m.mu.Unlock()
+}
+
+// Failed records a failed request, of a kind such as decode, request,
+// inference, timeout or audit
+func (m *Metrics) Failed(kind string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.errors[kind]++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := &strings.Builder{}
+	metric := func(name, typ, help string) {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	metric("detect_images_total", "counter", "Images run on the model.")
+	fmt.Fprintf(b, "detect_images_total %d\n", m.images)
+
+	metric("detect_errors_total", "counter", "Failed requests by kind: decode, request, inference or timeout.")
+	kinds := make([]string, 0, len(m.errors))
+	for kind := range m.errors {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(b, "detect_errors_total{kind=\"%s\"} %d\n", escapeLabel(kind), m.errors[kind])
+	}
+
+	metric("detect_detections_total", "counter", "Detections answered by class.")
+	classes := make([]CID, 0, len(m.detections))
+	for c := range m.detections {
+		classes = append(classes, c)
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i] < classes[j] })
+	for _, c := range classes {
+		fmt.Fprintf(b, "detect_detections_total{class=\"%s\"} %d\n", escapeLabel(m.Labels.Name(c)), m.detections[c])
+	}
+
+	metric("detect_preprocess_seconds", "histogram", "Time to read and decode an image.")
+	m.preprocess.write(b, "detect_preprocess_seconds")
+	metric("detect_inference_seconds", "histogram", "Time to run an image on the model.")
+	m.inference.write(b, "detect_inference_seconds")
+
+	if m.chipFLOPs > 0 {
+		metric("detect_chip_flops", "gauge", "Estimated floating point operations of running a chip, from the graph, by device.")
+		fmt.Fprintf(b, "detect_chip_flops{device=\"%s\"} %g\n", escapeLabel(m.device), m.chipFLOPs)
+		metric("detect_flops_total", "counter", "Estimated floating point operations spent on images.")
+		fmt.Fprintf(b, "detect_flops_total %g\n", m.flops)
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// label values escape backslashes, quotes and newlines
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+type histogram struct {
+	bounds []float64
+	counts []int
+	sum    float64
+	count  int
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]int, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.bounds {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) write(w io.Writer, name string) {
+	h.writeLabelled(w, name, "")
+}
+
+// write the series of the histogram with more labels, eg. zone="door"
+func (h *histogram) writeLabelled(w io.Writer, name, labels string) {
+	le, braced := "le", ""
+	if labels != "" {
+		le, braced = labels+",le", "{"+labels+"}"
+	}
+	for i, b := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{%s=\"%g\"} %d\n", name, le, b, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%s=\"+Inf\"} %d\n", name, le, h.count)
+	fmt.Fprintf(w, "%s_sum%s %g\n%s_count%s %d\n", name, braced, h.sum, name, braced, h.count)
+}
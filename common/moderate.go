@@ -0,0 +1,64 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+const (
+	Allow  = "allow"
+	Review = "review"
+	Block  = "block"
+)
+
+// Thresholds of a moderation category, a score at or above Block blocks the
+// image and at or above Review sends it for review. Zero disables either.
+type Thresholds struct {
+	Review float32 `json:"review"`
+	Block  float32 `json:"block"`
+}
+
+// Moderation maps category labels to their thresholds
+//
+//	{"categories": {"porn": {"review": 0.5, "block": 0.85}, "gore": {"review": 0.4, "block": 0.8}}}
+type Moderation struct {
+	Categories map[string]Thresholds `json:"categories"`
+}
+
+func LoadModeration(file string) (*Moderation, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	m := &Moderation{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if len(m.Categories) == 0 {
+		return nil, fmt.Errorf("%s: no categories", file)
+	}
+	return m, nil
+}
+
+// Verdict on the class scores of an image, with the categories that caused it
+func (m *Moderation) Verdict(scores []float32, labels Namer) (string, []string) {
+	verdict, why := Allow, []string(nil)
+	for c, score := range scores {
+		t, ok := m.Categories[labels.Name(CID(c))]
+		if !ok {
+			continue
+		}
+		switch {
+		case t.Block > 0 && score >= t.Block:
+			if verdict != Block {
+				verdict, why = Block, nil
+			}
+			why = append(why, labels.Name(CID(c)))
+		case t.Review > 0 && score >= t.Review && verdict != Block:
+			verdict = Review
+			why = append(why, labels.Name(CID(c)))
+		}
+	}
+	return verdict, why
+}
@@ -0,0 +1,224 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Npy is an array in numpy .npy format, with its data in c order
+type Npy struct {
+	// numpy dtype, eg. <f4
+	Descr string
+	Shape []int64
+	Data  []byte
+}
+
+var npyMagic = []byte("\x93NUMPY")
+
+func LoadNpy(npyfile string) (*Npy, error) {
+	f, err := os.Open(npyfile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	n, err := ReadNpy(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", npyfile, err)
+	}
+	return n, nil
+}
+
+var (
+	npyDescr   = regexp.MustCompile(`'descr':\s*'([^']*)'`)
+	npyFortran = regexp.MustCompile(`'fortran_order':\s*(True|False)`)
+	npyShape   = regexp.MustCompile(`'shape':\s*\(([^)]*)\)`)
+)
+
+func ReadNpy(r io.Reader) (*Npy, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 10 || !bytes.HasPrefix(b, npyMagic) {
+		return nil, fmt.Errorf("not a npy file")
+	}
+	// version 1 has a 2 byte header length, 2 and 3 a 4 byte one
+	var hlen, start int
+	switch b[6] {
+	case 1:
+		hlen, start = int(binary.LittleEndian.Uint16(b[8:])), 10
+	case 2, 3:
+		if len(b) < 12 {
+			return nil, fmt.Errorf("short npy header")
+		}
+		hlen, start = int(binary.LittleEndian.Uint32(b[8:])), 12
+	default:
+		return nil, fmt.Errorf("unsupported npy version %d", b[6])
+	}
+	if start+hlen > len(b) {
+		return nil, fmt.Errorf("short npy header")
+	}
+	header := string(b[start : start+hlen])
+
+	n := &Npy{Data: b[start+hlen:]}
+	m := npyDescr.FindStringSubmatch(header)
+	if m == nil {
+		return nil, fmt.Errorf("npy header without descr")
+	}
+	n.Descr = m[1]
+	if m := npyFortran.FindStringSubmatch(header); m != nil && m[1] == "True" {
+		return nil, fmt.Errorf("fortran order npy is unsupported")
+	}
+	m = npyShape.FindStringSubmatch(header)
+	if m == nil {
+		return nil, fmt.Errorf("npy header without shape")
+	}
+	n.Shape = []int64{}
+	for _, d := range strings.Split(m[1], ",") {
+		if d = strings.TrimSpace(d); d == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(d, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid npy shape %q", m[1])
+		}
+		n.Shape = append(n.Shape, v)
+	}
+	return n, nil
+}
+
+// Write the array as version 1 npy
+func (n *Npy) Write(w io.Writer) error {
+	dims := make([]string, len(n.Shape))
+	for i, d := range n.Shape {
+		dims[i] = strconv.FormatInt(d, 10)
+	}
+	shape := strings.Join(dims, ", ")
+	if len(dims) == 1 {
+		shape += ","
+	}
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%s), }", n.Descr, shape)
+	// the data starts 64 byte aligned, the header ends in a newline
+	pad := 64 - (10+len(header)+1)%64
+	if pad == 64 {
+		pad = 0
+	}
+	header += strings.Repeat(" ", pad) + "\n"
+
+	buf := bytes.Buffer{}
+	buf.Write(npyMagic)
+	buf.Write([]byte{1, 0})
+	binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(n.Data)
+	return err
+}
+
+func (n *Npy) Save(npyfile string) error {
+	f, err := os.Create(npyfile)
+	if err != nil {
+		return err
+	}
+	if err := n.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Floats converts the numeric data of the array to float64s
+func (n *Npy) Floats() ([]float64, error) {
+	if len(n.Descr) < 3 || n.Descr[0] == '>' {
+		return nil, fmt.Errorf("unsupported npy dtype %q", n.Descr)
+	}
+	size, err := strconv.Atoi(n.Descr[2:])
+	if err != nil || size == 0 || len(n.Data)%size != 0 {
+		return nil, fmt.Errorf("unsupported npy dtype %q", n.Descr)
+	}
+	le := binary.LittleEndian
+	out := make([]float64, len(n.Data)/size)
+	for i := range out {
+		b := n.Data[i*size:]
+		switch n.Descr[1:] {
+		case "f4":
+			out[i] = float64(math.Float32frombits(le.Uint32(b)))
+		case "f8":
+			out[i] = math.Float64frombits(le.Uint64(b))
+		case "i1":
+			out[i] = float64(int8(b[0]))
+		case "u1", "b1":
+			out[i] = float64(b[0])
+		case "i2":
+			out[i] = float64(int16(le.Uint16(b)))
+		case "u2":
+			out[i] = float64(le.Uint16(b))
+		case "i4":
+			out[i] = float64(int32(le.Uint32(b)))
+		case "u4":
+			out[i] = float64(le.Uint32(b))
+		case "i8":
+			out[i] = float64(int64(le.Uint64(b)))
+		case "u8":
+			out[i] = float64(le.Uint64(b))
+		default:
+			return nil, fmt.Errorf("unsupported npy dtype %q", n.Descr)
+		}
+	}
+	return out, nil
+}
+
+// NpyDiff is how far an array is from a reference
+type NpyDiff struct {
+	// largest absolute difference, and the flat index of it
+	Max   float64
+	Index int
+	// mean absolute difference
+	Mean float64
+}
+
+// CompareNpy compares the values of two arrays of the same shape
+func CompareNpy(a, ref *Npy) (NpyDiff, error) {
+	if fmt.Sprint(a.Shape) != fmt.Sprint(ref.Shape) {
+		return NpyDiff{}, fmt.Errorf("shape %v, reference is %v", a.Shape, ref.Shape)
+	}
+	x, err := a.Floats()
+	if err != nil {
+		return NpyDiff{}, err
+	}
+	y, err := ref.Floats()
+	if err != nil {
+		return NpyDiff{}, err
+	}
+	if len(x) != len(y) {
+		return NpyDiff{}, fmt.Errorf("%d values, reference has %d", len(x), len(y))
+	}
+	var d NpyDiff
+	for i := range x {
+		diff := math.Abs(x[i] - y[i])
+		// nan where the reference isn't counts as the largest difference
+		if math.IsNaN(diff) && !(math.IsNaN(x[i]) && math.IsNaN(y[i])) {
+			diff = math.Inf(1)
+		}
+		if diff > d.Max {
+			d.Max, d.Index = diff, i
+		}
+		if !math.IsNaN(diff) {
+			d.Mean += diff
+		}
+	}
+	if len(x) > 0 {
+		d.Mean /= float64(len(x))
+	}
+	return d, nil
+}
@@ -0,0 +1,413 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DetectWriter formats the detections of each frame
+type DetectWriter interface {
+	Write(frame *Frame, detects []Detect) error
+}
+
+// NewDetectWriter supports the formats
+//
+//	plain   xmin ymin xmax ymax class confidence, as read by score and render
+//	pretty  colored lines with confidence bars, for terminals
+//	json    one object per frame, in the current SchemaVersion
+//	geojson a FeatureCollection of box polygons, in the crs of georeferenced
+//	        images or in pixels, written on Flush
+//	coco    a coco results array for pycocotools, written on Flush
+func NewDetectWriter(format string, w io.Writer, labels Namer) (DetectWriter, error) {
+	switch format {
+	case "plain":
+		return &plainWriter{w: w}, nil
+	case "pretty":
+		return &prettyWriter{w: w, labels: labels, scores: ScoreFormat{Precision: 3}}, nil
+	case "json":
+		return NewJsonWriter(w, labels, SchemaVersion)
+	case "geojson":
+		return NewGeoJsonWriter(w, labels, "polygon")
+	case "coco":
+		return NewCocoWriter(w), nil
+	}
+	return nil, fmt.Errorf("unsupported output format %q", format)
+}
+
+// ScoreFormat formats confidences the same way in every output format, the
+// writers that print them take one with SetScoreFormat and otherwise keep
+// their own
+type ScoreFormat struct {
+	// digits after the point, -1 for as many as needed
+	Precision int
+	// 0-100 rather than 0-1
+	Percent bool
+	// nonzero scores below it are in scientific notation, 0 for never
+	SciBelow float64
+}
+
+// Number is the score as a json number
+func (f ScoreFormat) Number(c float32) json.Number {
+	v := float64(c)
+	if f.Percent {
+		v *= 100
+	}
+	if f.SciBelow > 0 && c != 0 && math.Abs(float64(c)) < f.SciBelow {
+		return json.Number(strconv.FormatFloat(v, 'e', f.Precision, 32))
+	}
+	return json.Number(strconv.FormatFloat(v, 'f', f.Precision, 32))
+}
+
+// String is the score for people, with a % sign as a percentage
+func (f ScoreFormat) String(c float32) string {
+	if f.Percent {
+		return string(f.Number(c)) + "%"
+	}
+	return string(f.Number(c))
+}
+
+// SetScoreFormat makes a writer print its scores in f, writers without
+// scores are left as they are
+func SetScoreFormat(w interface{}, f ScoreFormat) {
+	if s, ok := w.(interface{ setScoreFormat(ScoreFormat) }); ok {
+		s.setScoreFormat(f)
+	}
+}
+
+// a score as encoding/json writes a float32, in the format when there is one
+func jsonScore(f *ScoreFormat, c float32) json.Number {
+	if f != nil {
+		return f.Number(c)
+	}
+	b, _ := json.Marshal(c)
+	return json.Number(b)
+}
+
+// IsTerminal reports whether f is attached to a tty
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+type plainWriter struct {
+	w      io.Writer
+	scores *ScoreFormat
+}
+
+func (p *plainWriter) setScoreFormat(f ScoreFormat) { p.scores = &f }
+
+func (p *plainWriter) Write(frame *Frame, detects []Detect) error {
+	for _, d := range detects {
+		var confidence interface{} = d.Confidence
+		if p.scores != nil {
+			confidence = p.scores.Number(d.Confidence)
+		}
+		_, err := fmt.Fprintf(p.w, "%v %v %v %v %v %v\n", d.Bounds.Min.X, d.Bounds.Min.Y, d.Bounds.Max.X, d.Bounds.Max.Y, d.Class, confidence)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 256 color palette entries that read well on dark and light backgrounds
+var classColors = []int{196, 46, 33, 226, 201, 51, 208, 118, 129, 214, 39, 161}
+
+type prettyWriter struct {
+	w      io.Writer
+	labels Namer
+	scores ScoreFormat
+}
+
+func (p *prettyWriter) setScoreFormat(f ScoreFormat) { p.scores = f }
+
+func (p *prettyWriter) Write(frame *Frame, detects []Detect) error {
+	fmt.Fprintf(p.w, "\x1b[1m%s\x1b[0m  %s", frame.Name, T("%d detections", len(detects)))
+	for _, zone := range sortedKeys(frame.Occupancy) {
+		fmt.Fprintf(p.w, "  %s: %d", zone, frame.Occupancy[zone])
+	}
+	for _, line := range sortedKeys(frame.Crossings) {
+		fmt.Fprintf(p.w, "  %s: %d→ %d←", line, frame.Crossings[line].AB, frame.Crossings[line].BA)
+	}
+	fmt.Fprintln(p.w)
+	for _, d := range detects {
+		color := classColors[int(d.Class)%len(classColors)]
+		id := ""
+		if d.Track > 0 {
+			id += "  " + T("track %s", strconv.Itoa(d.Track))
+		}
+		if d.Speed > 0 {
+			id += "  " + T("%.1f/s", d.Speed)
+		}
+		if d.Identity > 0 {
+			id += fmt.Sprintf("  #%d", d.Identity)
+		}
+		if d.Plate != "" {
+			id += fmt.Sprintf("  [%s %s]", d.Plate, LocalizeDecimal(p.scores.String(d.PlateConfidence)))
+		}
+		if d.Subclass != "" {
+			id += fmt.Sprintf("  %s %s", d.Subclass, LocalizeDecimal(p.scores.String(d.SubclassConfidence)))
+		}
+		for _, zone := range sortedKeys(d.Dwell) {
+			id += "  " + T("%s %.0fs", zone, d.Dwell[zone])
+		}
+		_, err := fmt.Fprintf(p.w, "  \x1b[38;5;%dm%-24s\x1b[0m %s %s  (%d,%d)-(%d,%d)%s\n",
+			color, p.labels.Name(d.Class), ConfidenceBar(d.Confidence, 10), LocalizeDecimal(p.scores.String(d.Confidence)),
+			d.Bounds.Min.X, d.Bounds.Min.Y, d.Bounds.Max.X, d.Bounds.Max.Y, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := make([]string, 0)
+	switch m := m.(type) {
+	case map[string]int:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]float64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]Crossings:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string][]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]map[string]int:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]map[string]*histogram:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*histogram:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var eighths = []rune(" ▏▎▍▌▋▊▉")
+
+// ConfidenceBar draws c in [0,1] as a bar of width cells
+func ConfidenceBar(c float32, width int) string {
+	if c < 0 {
+		c = 0
+	} else if c > 1 {
+		c = 1
+	}
+	n := int(c * float32(width*8))
+	bar := strings.Repeat("█", n/8)
+	if n%8 > 0 {
+		bar += string(eighths[n%8])
+	}
+	return bar + strings.Repeat(" ", width-len([]rune(bar)))
+}
+
+// SchemaVersion of the json output
+//
+//	1  image, detections, occupancy and crossings
+//	2  adds schema_version, and the index and time of the frame
+//	3  adds the width and height of the frame, the box of each detection
+//	   normalized to them, and its plate, subclass and embedding
+const SchemaVersion = 3
+
+type jsonWriter struct {
+	enc     *json.Encoder
+	labels  Namer
+	version int
+	scores  *ScoreFormat
+}
+
+func (j *jsonWriter) setScoreFormat(f ScoreFormat) { j.scores = &f }
+
+// NewJsonWriter writes json in an older schema version, for consumers that
+// haven't caught up yet
+func NewJsonWriter(w io.Writer, labels Namer, version int) (DetectWriter, error) {
+	if version < 1 || version > SchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version %d, expected 1 to %d", version, SchemaVersion)
+	}
+	return &jsonWriter{enc: json.NewEncoder(w), labels: labels, version: version}, nil
+}
+
+type jsonDetect struct {
+	Bounds             [4]int             `json:"bounds"`
+	Box                *[4]float32        `json:"box,omitempty"`
+	Class              CID                `json:"class"`
+	Label              string             `json:"label"`
+	Confidence         json.Number        `json:"confidence"`
+	Identity           int                `json:"identity,omitempty"`
+	Track              int                `json:"track,omitempty"`
+	Speed              float32            `json:"speed,omitempty"`
+	Dwell              map[string]float64 `json:"dwell,omitempty"`
+	Plate              string             `json:"plate,omitempty"`
+	PlateConfidence    json.Number        `json:"plate_confidence,omitempty"`
+	Subclass           string             `json:"subclass,omitempty"`
+	SubclassConfidence json.Number        `json:"subclass_confidence,omitempty"`
+	Embedding          []float32          `json:"embedding,omitempty"`
+}
+
+type jsonFrame struct {
+	Image      string               `json:"image"`
+	Detections []jsonDetect         `json:"detections"`
+	Occupancy  map[string]int       `json:"occupancy,omitempty"`
+	Crossings  map[string]Crossings `json:"crossings,omitempty"`
+}
+
+type jsonFrameV3 struct {
+	SchemaVersion int `json:"schema_version"`
+	jsonFrame
+	Index  int        `json:"index"`
+	Time   *time.Time `json:"time,omitempty"`
+	Width  int        `json:"width,omitempty"`
+	Height int        `json:"height,omitempty"`
+}
+
+// the detections of schema versions 1 and 2, fields added since are left
+// out rather than leaking into the older versions
+type jsonDetectV1 struct {
+	Bounds     [4]int             `json:"bounds"`
+	Class      CID                `json:"class"`
+	Label      string             `json:"label"`
+	Confidence json.Number        `json:"confidence"`
+	Identity   int                `json:"identity,omitempty"`
+	Track      int                `json:"track,omitempty"`
+	Speed      float32            `json:"speed,omitempty"`
+	Dwell      map[string]float64 `json:"dwell,omitempty"`
+}
+
+type jsonFrameV1 struct {
+	Image      string               `json:"image"`
+	Detections []jsonDetectV1       `json:"detections"`
+	Occupancy  map[string]int       `json:"occupancy,omitempty"`
+	Crossings  map[string]Crossings `json:"crossings,omitempty"`
+}
+
+type jsonFrameV2 struct {
+	SchemaVersion int `json:"schema_version"`
+	jsonFrameV1
+	Index int        `json:"index"`
+	Time  *time.Time `json:"time,omitempty"`
+}
+
+// downgrade a frame to schema version 1 or 2
+func downgrade(frame *jsonFrameV3, version int) interface{} {
+	v1 := jsonFrameV1{Image: frame.Image, Detections: make([]jsonDetectV1, len(frame.Detections)), Occupancy: frame.Occupancy, Crossings: frame.Crossings}
+	for i, d := range frame.Detections {
+		v1.Detections[i] = jsonDetectV1{
+			Bounds:     d.Bounds,
+			Class:      d.Class,
+			Label:      d.Label,
+			Confidence: d.Confidence,
+			Identity:   d.Identity,
+			Track:      d.Track,
+			Speed:      d.Speed,
+			Dwell:      d.Dwell,
+		}
+	}
+	if version == 1 {
+		return v1
+	}
+	return jsonFrameV2{SchemaVersion: version, jsonFrameV1: v1, Index: frame.Index, Time: frame.Time}
+}
+
+func (j *jsonWriter) Write(frame *Frame, detects []Detect) error {
+	out := jsonFrame{Image: frame.Name, Detections: make([]jsonDetect, len(detects)), Occupancy: frame.Occupancy, Crossings: frame.Crossings}
+	for i, d := range detects {
+		var plateConfidence json.Number
+		if d.PlateConfidence != 0 {
+			plateConfidence = jsonScore(j.scores, d.PlateConfidence)
+		}
+		var subclassConfidence json.Number
+		if d.Subclass != "" {
+			subclassConfidence = jsonScore(j.scores, d.SubclassConfidence)
+		}
+		out.Detections[i] = jsonDetect{
+			Bounds:             [4]int{d.Bounds.Min.X, d.Bounds.Min.Y, d.Bounds.Max.X, d.Bounds.Max.Y},
+			Class:              d.Class,
+			Label:              j.labels.Name(d.Class),
+			Confidence:         jsonScore(j.scores, d.Confidence),
+			Identity:           d.Identity,
+			Track:              d.Track,
+			Speed:              d.Speed,
+			Dwell:              d.Dwell,
+			Plate:              d.Plate,
+			PlateConfidence:    plateConfidence,
+			Subclass:           d.Subclass,
+			SubclassConfidence: subclassConfidence,
+			Embedding:          d.Embedding,
+		}
+	}
+	v3 := jsonFrameV3{SchemaVersion: j.version, jsonFrame: out, Index: frame.Index}
+	if !frame.Time.IsZero() {
+		v3.Time = &frame.Time
+	}
+	if j.version < 3 {
+		return j.enc.Encode(downgrade(&v3, j.version))
+	}
+	if frame.Im != nil {
+		// xmin, ymin, xmax, ymax as fractions of the frame
+		b := frame.Im.Bounds()
+		v3.Width, v3.Height = b.Dx(), b.Dy()
+		w, h := float32(b.Dx()), float32(b.Dy())
+		for i, d := range detects {
+			r := d.Bounds.Sub(b.Min)
+			out.Detections[i].Box = &[4]float32{float32(r.Min.X) / w, float32(r.Min.Y) / h, float32(r.Max.X) / w, float32(r.Max.Y) / h}
+		}
+	}
+	return j.enc.Encode(v3)
+}
+
+// ConvertSchema rewrites json output, one frame per line, into another
+// schema version. Frames without a schema_version are version 1, and get
+// their line number as their index when upgraded.
+func ConvertSchema(r io.Reader, w io.Writer, to int) error {
+	if to < 1 || to > SchemaVersion {
+		return fmt.Errorf("unsupported schema version %d, expected 1 to %d", to, SchemaVersion)
+	}
+	dec := json.NewDecoder(r)
+	enc := json.NewEncoder(w)
+	for line := 0; ; line++ {
+		var frame jsonFrameV3
+		if err := dec.Decode(&frame); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("frame %d: %v", line, err)
+		}
+		if frame.SchemaVersion > SchemaVersion {
+			return fmt.Errorf("frame %d: schema version %d is newer than %d", line, frame.SchemaVersion, SchemaVersion)
+		}
+		if frame.SchemaVersion == 0 {
+			frame.Index = line
+		}
+		// frames from before 3 are upgraded without normalized boxes, there
+		// is no frame size to normalize by
+		var err error
+		if to < 3 {
+			err = enc.Encode(downgrade(&frame, to))
+		} else {
+			frame.SchemaVersion = to
+			err = enc.Encode(frame)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
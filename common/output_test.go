@@ -0,0 +1,89 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// the frames of json lines, decoded generically to compare
+func decodeFrames(t *testing.T, s string) []interface{} {
+	var frames []interface{}
+	dec := json.NewDecoder(strings.NewReader(s))
+	for dec.More() {
+		var f interface{}
+		if err := dec.Decode(&f); err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+func convert(t *testing.T, in string, to int) string {
+	var out bytes.Buffer
+	if err := ConvertSchema(strings.NewReader(in), &out, to); err != nil {
+		t.Fatalf("to %d: %v", to, err)
+	}
+	return out.String()
+}
+
+func TestConvertSchemaRoundTrip(t *testing.T) {
+	v1 := `{"image":"a.jpg","detections":[{"bounds":[1,2,3,4],"class":3,"label":"car","confidence":0.91,"track":7,"speed":1.5,"dwell":{"gate":2}}],"occupancy":{"gate":1}}
+{"image":"b.jpg","detections":[],"crossings":{"line":{"ab":1,"ba":0}}}
+`
+	v3 := convert(t, v1, 3)
+	frames := decodeFrames(t, v3)
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, expected 2", len(frames))
+	}
+	for i, f := range frames {
+		f := f.(map[string]interface{})
+		// frames of version 1 are numbered by their line
+		if f["schema_version"] != 3.0 || f["index"] != float64(i) {
+			t.Errorf("frame %d: got schema_version %v index %v, expected 3 %d", i, f["schema_version"], f["index"], i)
+		}
+	}
+	if back := convert(t, v3, 1); !reflect.DeepEqual(decodeFrames(t, back), decodeFrames(t, v1)) {
+		t.Errorf("round trip: got\n%s\nexpected\n%s", back, v1)
+	}
+}
+
+func TestConvertSchemaDowngrade(t *testing.T) {
+	v3 := `{"schema_version":3,"image":"a.jpg","detections":[{"bounds":[0,0,5,10],"box":[0,0,0.5,1],"class":3,"label":"car","confidence":0.9,"plate":"AB12","plate_confidence":0.8,"subclass":"sedan","subclass_confidence":0.7,"embedding":[0.1,0.2]}],"index":4,"time":"2020-01-02T03:04:05Z","width":10,"height":10}
+`
+	tests := []struct {
+		to       int
+		expected string
+	}{
+		{1, `{"image":"a.jpg","detections":[{"bounds":[0,0,5,10],"class":3,"label":"car","confidence":0.9}]}`},
+		{2, `{"schema_version":2,"image":"a.jpg","detections":[{"bounds":[0,0,5,10],"class":3,"label":"car","confidence":0.9}],"index":4,"time":"2020-01-02T03:04:05Z"}`},
+		{3, v3},
+	}
+	for _, test := range tests {
+		got := convert(t, v3, test.to)
+		if !reflect.DeepEqual(decodeFrames(t, got), decodeFrames(t, test.expected)) {
+			t.Errorf("to %d: got\n%s\nexpected\n%s", test.to, got, test.expected)
+		}
+	}
+}
+
+func TestConvertSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		to   int
+	}{
+		{"version 0", "", 0},
+		{"version past the latest", "", SchemaVersion + 1},
+		{"frame newer than the latest", `{"schema_version":99,"image":"a.jpg","detections":[]}`, 1},
+		{"not json", "{", 1},
+	}
+	for _, test := range tests {
+		if err := ConvertSchema(strings.NewReader(test.in), &bytes.Buffer{}, test.to); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
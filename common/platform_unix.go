@@ -0,0 +1,37 @@
+//go:build !windows
+// +build !windows
+
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// ReloadSignal reloads the model of a server, SIGUSR1
+var ReloadSignal os.Signal = syscall.SIGUSR1
+
+// setNice renices the process. Linux keeps a niceness per thread, so every
+// thread running is reniced, the runtime's and tf's, and the threads started
+// later take it from the thread starting them.
+func setNice(nice int) error {
+	tids := []int{0}
+	if dirs, err := ioutil.ReadDir("/proc/self/task"); err == nil {
+		tids = tids[:0]
+		for _, d := range dirs {
+			if tid, err := strconv.Atoi(d.Name()); err == nil {
+				tids = append(tids, tid)
+			}
+		}
+	}
+	for _, tid := range tids {
+		// a thread that exited since it was listed is gone, not a failure
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("nice %d: %v", nice, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package common
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// ReloadSignal reloads the model of a server, windows has no SIGUSR1 so
+// this one is never sent
+var ReloadSignal os.Signal = syscall.Signal(0x1e)
+
+// windows has no niceness, any is taken as below normal priority
+func setNice(nice int) error {
+	class := uint32(windows.BELOW_NORMAL_PRIORITY_CLASS)
+	if nice < 0 {
+		class = windows.ABOVE_NORMAL_PRIORITY_CLASS
+	}
+	return windows.SetPriorityClass(windows.CurrentProcess(), class)
+}
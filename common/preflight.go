@@ -0,0 +1,93 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Preflight is the report of the checks a server runs before it serves:
+// each check, whether it passed, what it found and how long it took. It
+// answers GET /preflight with the report as json, 503 when a check failed.
+type Preflight struct {
+	OK     bool             `json:"ok"`
+	Time   time.Time        `json:"time"`
+	Checks []PreflightCheck `json:"checks"`
+}
+
+type PreflightCheck struct {
+	Name    string  `json:"name"`
+	OK      bool    `json:"ok"`
+	Detail  string  `json:"detail,omitempty"`
+	Error   string  `json:"error,omitempty"`
+	Seconds float64 `json:"seconds"`
+}
+
+func NewPreflight() *Preflight {
+	return &Preflight{OK: true, Time: time.Now()}
+}
+
+// Check runs a check, logging and recording its outcome, and returns
+// whether it passed. Checks after a failed one still run, for the report
+// to have all the failures.
+func (p *Preflight) Check(name string, check func() (string, error)) bool {
+	start := time.Now()
+	detail, err := check()
+	c := PreflightCheck{Name: name, OK: err == nil, Detail: detail, Seconds: time.Since(start).Seconds()}
+	if err != nil {
+		c.Error = err.Error()
+		p.OK = false
+		log.Printf("preflight: %s failed: %v", name, err)
+	} else {
+		log.Printf("preflight: %s ok in %v: %s", name, time.Since(start).Round(time.Millisecond), detail)
+	}
+	p.Checks = append(p.Checks, c)
+	return c.OK
+}
+
+// Err names the failed checks, nil when all passed
+func (p *Preflight) Err() error {
+	if p.OK {
+		return nil
+	}
+	var failed []string
+	for _, c := range p.Checks {
+		if !c.OK {
+			failed = append(failed, c.Name)
+		}
+	}
+	return fmt.Errorf("preflight failed: %v", failed)
+}
+
+func (p *Preflight) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		WriteProblem(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "expected GET /preflight")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !p.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(p)
+}
+
+// CheckWritable checks a file can be appended to, or a file created in a dir
+func CheckWritable(path string) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		f, err := ioutil.TempFile(path, ".preflight")
+		if err != nil {
+			return err
+		}
+		f.Close()
+		return os.Remove(f.Name())
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
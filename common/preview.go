@@ -0,0 +1,282 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var boxColors = []color.RGBA{
+	{255, 0, 0, 255}, {0, 215, 0, 255}, {0, 135, 255, 255}, {255, 255, 0, 255},
+	{255, 0, 255, 255}, {0, 255, 255, 255}, {255, 135, 0, 255}, {135, 255, 0, 255},
+	{175, 0, 255, 255}, {255, 175, 0, 255}, {0, 175, 255, 255}, {215, 0, 95, 255},
+}
+
+// BoxColor is the color used to draw a class
+func BoxColor(c CID) color.RGBA {
+	return boxColors[int(c)%len(boxColors)]
+}
+
+// Annotate draws the detection boxes over a copy of im
+func Annotate(im image.Image, detects []Detect, width int) *image.RGBA {
+	style := DefaultStyle()
+	style.Width = width
+	return style.Annotate(im, detects, nil, ScoreFormat{})
+}
+
+// AnnotateLabels draws the detection boxes over a copy of im, tagged with
+// their labels and scores
+func AnnotateLabels(im image.Image, detects []Detect, width int, labels Namer, scores ScoreFormat) *image.RGBA {
+	style := DefaultStyle()
+	style.Width = width
+	return style.Annotate(im, detects, labels, scores)
+}
+
+// DrawTag writes text on a box of color c, above p or below it when there
+// is no room above
+func DrawTag(dst draw.Image, p image.Point, text string, c color.RGBA) {
+	drawTag(dst, p, text, c, basicfont.Face7x13)
+}
+
+func drawTag(dst draw.Image, p image.Point, text string, c color.RGBA, face font.Face) {
+	w := font.MeasureString(face, text).Ceil() + 4
+	h := face.Metrics().Height.Ceil() + 2
+	r := image.Rect(p.X, p.Y-h, p.X+w, p.Y)
+	if r.Min.Y < dst.Bounds().Min.Y {
+		r = r.Add(image.Pt(0, h))
+	}
+	draw.Draw(dst, r, image.NewUniform(c), image.ZP, draw.Src)
+	// dark text on light colors
+	ink := color.Color(color.White)
+	if 299*int(c.R)+587*int(c.G)+114*int(c.B) > 150000 {
+		ink = color.Black
+	}
+	d := font.Drawer{Dst: dst, Src: image.NewUniform(ink), Face: face,
+		Dot: fixed.P(r.Min.X+2, r.Max.Y-1-face.Metrics().Descent.Ceil())}
+	d.DrawString(text)
+}
+
+// ImageWriter saves an annotated copy of each frame. A dir path, existing or
+// ending in /, gets the frames by name, otherwise frames after the first get
+// their index before the extension of the path.
+type ImageWriter struct {
+	path   string
+	labels Namer
+	scores ScoreFormat
+	// how detections are drawn, DefaultStyle unless set
+	Style *Style
+	n     int
+}
+
+func NewImageWriter(path string, labels Namer) *ImageWriter {
+	return &ImageWriter{path: path, labels: labels, scores: ScoreFormat{Precision: 2}, Style: DefaultStyle()}
+}
+
+// Dir is the dir frames are saved to, empty when saving to a file
+func (w *ImageWriter) Dir() string {
+	if info, err := os.Stat(w.path); strings.HasSuffix(w.path, "/") || (err == nil && info.IsDir()) {
+		return w.path
+	}
+	return ""
+}
+
+func (w *ImageWriter) setScoreFormat(f ScoreFormat) { w.scores = f }
+
+func (w *ImageWriter) Write(frame *Frame, detects []Detect) error {
+	file := w.path
+	if dir := w.Dir(); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		name := filepath.Base(frame.Name)
+		file = filepath.Join(dir, strings.TrimSuffix(name, filepath.Ext(name))+"-detects.jpg")
+	} else if w.n > 0 {
+		ext := filepath.Ext(w.path)
+		file = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(w.path, ext), frame.Index, ext)
+	}
+	w.n++
+	return SaveImage(file, w.Style.Annotate(frame.Im, detects, w.labels, w.scores))
+}
+
+// SaveImage writes im as png when the file ends in .png, otherwise as jpeg
+func SaveImage(file string, im image.Image) error {
+	if Private() {
+		return ErrPrivate
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(filepath.Ext(file)) == ".png" {
+		err = png.Encode(f, im)
+	} else {
+		err = jpeg.Encode(f, im, &jpeg.Options{Quality: 90})
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func StrokeRect(dst draw.Image, r image.Rectangle, width int, c color.Color) {
+	u := image.NewUniform(c)
+	draw.Draw(dst, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+width), u, image.ZP, draw.Src)
+	draw.Draw(dst, image.Rect(r.Min.X, r.Max.Y-width, r.Max.X, r.Max.Y), u, image.ZP, draw.Src)
+	draw.Draw(dst, image.Rect(r.Min.X, r.Min.Y, r.Min.X+width, r.Max.Y), u, image.ZP, draw.Src)
+	draw.Draw(dst, image.Rect(r.Max.X-width, r.Min.Y, r.Max.X, r.Max.Y), u, image.ZP, draw.Src)
+}
+
+// Preview writes a downscaled copy of im for display in the terminal
+//  sixel  sixel graphics, eg. xterm -ti vt340, mlterm, foot, wezterm
+//  kitty  the kitty graphics protocol
+//  ascii  colored half blocks, works in any 24-bit color terminal
+func Preview(w io.Writer, mode string, im image.Image) error {
+	switch mode {
+	case "sixel":
+		return writeSixel(w, fit(im, 640))
+	case "kitty":
+		return writeKitty(w, fit(im, 640))
+	case "ascii":
+		cols := 80
+		if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+			cols = n
+		}
+		return writeBlocks(w, fit(im, cols))
+	}
+	return fmt.Errorf("unsupported preview %q, expected sixel, kitty or ascii", mode)
+}
+
+// scale im down to at most width pixels wide
+func fit(im image.Image, width int) image.Image {
+	b := im.Bounds()
+	if b.Dx() <= width {
+		return im
+	}
+	h := b.Dy() * width / b.Dx()
+	scaled := image.NewRGBA(image.Rect(0, 0, width, h))
+	draw.BiLinear.Scale(scaled, scaled.Bounds(), im, b, draw.Src, nil)
+	return scaled
+}
+
+func writeKitty(w io.Writer, im image.Image) error {
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, im); err != nil {
+		return err
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	// payload is sent in chunks of at most 4096 bytes
+	bw := bufio.NewWriter(w)
+	for first := true; len(data) > 0; first = false {
+		n := len(data)
+		if n > 4096 {
+			n = 4096
+		}
+		more := 0
+		if n < len(data) {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(bw, "\x1b_Ga=T,f=100,m=%d;%s\x1b\\", more, data[:n])
+		} else {
+			fmt.Fprintf(bw, "\x1b_Gm=%d;%s\x1b\\", more, data[:n])
+		}
+		data = data[n:]
+	}
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+func writeBlocks(w io.Writer, im image.Image) error {
+	b := im.Bounds()
+	bw := bufio.NewWriter(w)
+	// each cell is two pixels high; the top in the foreground, the bottom in the background
+	for y := b.Min.Y; y < b.Max.Y; y += 2 {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			tr, tg, tb, _ := im.At(x, y).RGBA()
+			br, bg, bb := tr, tg, tb
+			if y+1 < b.Max.Y {
+				br, bg, bb, _ = im.At(x, y+1).RGBA()
+			}
+			fmt.Fprintf(bw, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", tr>>8, tg>>8, tb>>8, br>>8, bg>>8, bb>>8)
+		}
+		bw.WriteString("\x1b[0m\n")
+	}
+	return bw.Flush()
+}
+
+func writeSixel(w io.Writer, im image.Image) error {
+	b := im.Bounds()
+	p := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), palette.Plan9)
+	draw.FloydSteinberg.Draw(p, p.Bounds(), im, b.Min)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "\x1bPq\"1;1;%d;%d", p.Rect.Dx(), p.Rect.Dy())
+	for i, c := range p.Palette {
+		r, g, b, _ := c.RGBA()
+		fmt.Fprintf(bw, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+
+	width, height := p.Rect.Dx(), p.Rect.Dy()
+	row := make([]byte, width)
+	for band := 0; band < height; band += 6 {
+		used := make(map[uint8]bool)
+		for y := band; y < band+6 && y < height; y++ {
+			for x := 0; x < width; x++ {
+				used[p.ColorIndexAt(x, y)] = true
+			}
+		}
+		for ci := range used {
+			for x := 0; x < width; x++ {
+				var bits byte
+				for dy := 0; dy < 6 && band+dy < height; dy++ {
+					if p.ColorIndexAt(x, band+dy) == ci {
+						bits |= 1 << uint(dy)
+					}
+				}
+				row[x] = 63 + bits
+			}
+			fmt.Fprintf(bw, "#%d", ci)
+			writeSixelRow(bw, row)
+			bw.WriteByte('$')
+		}
+		bw.WriteByte('-')
+	}
+	bw.WriteString("\x1b\\\n")
+	return bw.Flush()
+}
+
+// run length encode a row of sixels
+func writeSixelRow(w *bufio.Writer, row []byte) {
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			fmt.Fprintf(w, "!%d%c", n, row[i])
+		} else {
+			for k := 0; k < n; k++ {
+				w.WriteByte(row[i])
+			}
+		}
+		i = j
+	}
+}
@@ -0,0 +1,126 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"net/url"
+	"strings"
+)
+
+// private mode keeps personal data out of what is written: the sinks of
+// detections are put behind a PrivateWriter, which redacts image names, and
+// SaveImage, NewVideoWriter and the detector's debug chips refuse to write
+// images or crops
+var private struct {
+	on   bool
+	salt string
+}
+
+// ErrPrivate is returned by writers of images in private mode
+var ErrPrivate = errors.New("images and crops are not written in private mode")
+
+// SetPrivate turns private mode on, names are hashed with salt so they can
+// only be matched against by whoever knows it
+func SetPrivate(salt string) {
+	private.on, private.salt = true, salt
+}
+
+// Private reports whether private mode is on
+func Private() bool {
+	return private.on
+}
+
+// PrivacyFlags adds the -private and -private-salt flags of a tool, the
+// returned func turns on private mode once parsed when asked for
+func PrivacyFlags() func() {
+	on := flag.Bool("private", false, "Keep personal data out of the output and logs: hash file paths, drop url query strings and credentials, and refuse to write images or crops")
+	salt := flag.String("private-salt", "", "Secret mixed into the -private hashes of file paths, so they can't be matched by hashing guessed paths")
+	return func() {
+		if *on {
+			SetPrivate(*salt)
+		}
+	}
+}
+
+// RedactName is the name of an image as it may be written: as is, or in
+// private mode urls without their query, fragment and credentials, and
+// other names, file paths, as a hash of them
+func RedactName(name string) string {
+	if !private.on || name == "" {
+		return name
+	}
+	if strings.Contains(name, "://") {
+		if u, err := url.Parse(name); err == nil {
+			u.User, u.RawQuery, u.ForceQuery, u.Fragment = nil, "", false, ""
+			return u.String()
+		}
+	}
+	sum := sha256.Sum256([]byte(private.salt + name))
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// StripCredentials drops the user and password of an url, such as those of
+// an rtsp camera, whether in private mode or not. Other names are returned
+// as they are.
+func StripCredentials(name string) string {
+	i := strings.Index(name, "://")
+	if i < 0 {
+		return name
+	}
+	rest := name[i+3:]
+	host := rest
+	if j := strings.IndexAny(rest, "/?#"); j >= 0 {
+		host = rest[:j]
+	}
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		return name[:i+3] + rest[at+1:]
+	}
+	return name
+}
+
+// PrivateWriter puts a sink behind private mode, it is given frames named by
+// RedactName. The sinks of a tool are all put behind one, rather than each
+// redacting names on its own.
+func PrivateWriter(w DetectWriter) DetectWriter {
+	if !private.on {
+		return w
+	}
+	return &privateWriter{w: w}
+}
+
+type privateWriter struct {
+	w DetectWriter
+	// frames written by a sink that doesn't hold them back, for Checkpoint
+	written []*frameMark
+}
+
+func (p *privateWriter) Write(frame *Frame, detects []Detect) error {
+	redacted := *frame
+	redacted.Name = RedactName(frame.Name)
+	if err := p.w.Write(&redacted, detects); err != nil {
+		return err
+	}
+	if _, ok := p.w.(frameHolder); !ok && frame.mark != nil {
+		p.written = append(p.written, frame.mark)
+	}
+	return nil
+}
+
+// Flush the sink, when it holds frames back
+func (p *privateWriter) Flush() error {
+	if f, ok := p.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+func (p *privateWriter) released() []*frameMark {
+	if h, ok := p.w.(frameHolder); ok {
+		return h.released()
+	}
+	written := p.written
+	p.written = nil
+	return written
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Stable codes of the problems the servers answer with, for clients to
+// handle failures by rather than by message
+const (
+	CodeInvalidRequest    = "INVALID_REQUEST"
+	CodeMethodNotAllowed  = "METHOD_NOT_ALLOWED"
+	CodeNotFound          = "NOT_FOUND"
+	CodeImageDecodeFailed = "IMAGE_DECODE_FAILED"
+	CodePayloadTooLarge   = "PAYLOAD_TOO_LARGE"
+	CodeOverrideRefused   = "OVERRIDE_REFUSED"
+	CodeModelNotLoaded    = "MODEL_NOT_LOADED"
+	CodeTimeout           = "TIMEOUT"
+	CodeInferenceFailed   = "INFERENCE_FAILED"
+	CodeJobConflict       = "JOB_CONFLICT"
+	CodeListenFailed      = "LISTEN_FAILED"
+	CodeInternal          = "INTERNAL_ERROR"
+)
+
+// Problem is an rfc 7807 application/problem+json body, with the stable
+// Code of the failure. It is an error too, for failures deep in a request
+// to be answered with their own status.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code   string `json:"code"`
+}
+
+// NewProblem of a status and code, typed by the code, eg.
+// urn:detect:problem:image-decode-failed
+func NewProblem(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   "urn:detect:problem:" + strings.ToLower(strings.Replace(code, "_", "-", -1)),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func (p *Problem) Error() string {
+	return p.Detail
+}
+
+// WriteProblem answers with a problem
+func WriteProblem(w http.ResponseWriter, status int, code, detail string) {
+	writeProblem(w, NewProblem(status, code, detail))
+}
+
+func writeProblem(w http.ResponseWriter, p *Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// answer with the problem of a failed detection; problems as they are,
+// refused overrides as bad requests and anything else as a failed inference
+func writeDetectError(w http.ResponseWriter, err error) {
+	switch err := err.(type) {
+	case *Problem:
+		writeProblem(w, err)
+	case OverrideError:
+		WriteProblem(w, http.StatusBadRequest, CodeOverrideRefused, err.Error())
+	default:
+		WriteProblem(w, http.StatusInternalServerError, CodeInferenceFailed, err.Error())
+	}
+}
+
+var errBodyTooLarge = errors.New("http: request body too large")
+
+// limitedBody cuts a request body off past n bytes, as http.MaxBytesReader
+// does, remembering it did so the error is told apart from others however
+// the readers over it wrap it
+type limitedBody struct {
+	io.ReadCloser
+	n   int64
+	cut bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.cut {
+		return 0, errBodyTooLarge
+	}
+	// one byte past n tells a body of exactly n from a longer one
+	if int64(len(p)) > b.n+1 {
+		p = p[:b.n+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.n {
+		n, b.n, b.cut = int(b.n), 0, true
+		return n, errBodyTooLarge
+	}
+	b.n -= int64(n)
+	return n, err
+}
+
+// whether err came of the body being cut off
+func (b *limitedBody) tooLarge(err error) bool {
+	return err != nil && b.cut
+}
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// PerspectiveTransform solves the homography that maps the 4 src points onto
+// the 4 dst points
+func PerspectiveTransform(src, dst [4][2]float64) (Homography, error) {
+	// 8 equations in the 8 unknowns h0..h7, h8 is fixed at 1
+	var a [8][9]float64
+	for i := 0; i < 4; i++ {
+		x, y := src[i][0], src[i][1]
+		u, v := dst[i][0], dst[i][1]
+		a[2*i] = [9]float64{x, y, 1, 0, 0, 0, -u * x, -u * y, u}
+		a[2*i+1] = [9]float64{0, 0, 0, x, y, 1, -v * x, -v * y, v}
+	}
+
+	// gauss-jordan with partial pivoting
+	for c := 0; c < 8; c++ {
+		p := c
+		for r := c + 1; r < 8; r++ {
+			if math.Abs(a[r][c]) > math.Abs(a[p][c]) {
+				p = r
+			}
+		}
+		if math.Abs(a[p][c]) < 1e-12 {
+			return Identity, fmt.Errorf("degenerate quad, 3 of the points are collinear")
+		}
+		a[c], a[p] = a[p], a[c]
+		for r := 0; r < 8; r++ {
+			if r == c {
+				continue
+			}
+			f := a[r][c] / a[c][c]
+			for k := c; k < 9; k++ {
+				a[r][k] -= f * a[c][k]
+			}
+		}
+	}
+
+	var h Homography
+	for i := 0; i < 8; i++ {
+		h[i] = a[i][8] / a[i][i]
+	}
+	h[8] = 1
+	return h, nil
+}
+
+// Rectify warps the quad of im, clockwise from its top left corner, onto an
+// upright image of size
+func Rectify(im image.Image, quad [4]image.Point, size image.Point) (*image.RGBA, error) {
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	w, h := float64(size.X), float64(size.Y)
+	// map output pixels back into the source
+	var corners, src [4][2]float64
+	corners = [4][2]float64{{0, 0}, {w, 0}, {w, h}, {0, h}}
+	for i, p := range quad {
+		src[i] = [2]float64{float64(p.X), float64(p.Y)}
+	}
+	back, err := PerspectiveTransform(corners, src)
+	if err != nil {
+		return nil, err
+	}
+
+	b := im.Bounds()
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			sx, sy := back.Apply(float64(x)+.5, float64(y)+.5)
+			p := image.Pt(int(math.Floor(sx)), int(math.Floor(sy)))
+			if p.In(b) {
+				dst.Set(x, y, im.At(p.X, p.Y))
+			}
+		}
+	}
+	return dst, nil
+}
+
+// BoxQuad is the corners of r as a quad for Rectify
+func BoxQuad(r image.Rectangle) [4]image.Point {
+	return [4]image.Point{r.Min, {r.Max.X, r.Min.Y}, r.Max, {r.Min.X, r.Max.Y}}
+}
+
+// Crop copies r out of im
+func Crop(im image.Image, r image.Rectangle) *image.RGBA {
+	crop := image.NewRGBA(image.Rectangle{Max: r.Size()})
+	draw.Draw(crop, crop.Bounds(), im, r.Min, draw.Src)
+	return crop
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"image"
+	"image/draw"
+)
+
+// Blur box blurs r of im in place, three passes approximate a gaussian of
+// the radius
+func Blur(im draw.Image, r image.Rectangle, radius int) {
+	r = r.Intersect(im.Bounds())
+	if r.Empty() || radius < 1 {
+		return
+	}
+	// blur with the surroundings in view, so the edges of r aren't smeared
+	// copies of its border
+	ctx := r.Inset(-3 * radius).Intersect(im.Bounds())
+	region := image.NewRGBA(image.Rectangle{Max: ctx.Size()})
+	draw.Draw(region, region.Bounds(), im, ctx.Min, draw.Src)
+	tmp := make([]uint8, len(region.Pix))
+	for pass := 0; pass < 3; pass++ {
+		boxBlur(region.Pix, tmp, region.Stride, ctx.Dx(), ctx.Dy(), radius, 4, region.Stride)
+		boxBlur(tmp, region.Pix, region.Stride, ctx.Dy(), ctx.Dx(), radius, region.Stride, 4)
+	}
+	draw.Draw(im, r, region, r.Min.Sub(ctx.Min), draw.Src)
+}
+
+// a running sum blur along one axis; step moves along the line, skip between
+// lines
+func boxBlur(src, dst []uint8, stride, n, lines, radius, step, skip int) {
+	for l := 0; l < lines; l++ {
+		base := l * skip
+		for c := 0; c < 4; c++ {
+			at := func(i int) int {
+				if i < 0 {
+					i = 0
+				} else if i >= n {
+					i = n - 1
+				}
+				return int(src[base+i*step+c])
+			}
+			sum := 0
+			for i := -radius; i <= radius; i++ {
+				sum += at(i)
+			}
+			for i := 0; i < n; i++ {
+				dst[base+i*step+c] = uint8(sum / (2*radius + 1))
+				sum += at(i+radius+1) - at(i-radius)
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package common
+
+import "math"
+
+// Gallery matches embeddings to the identities seen so far by cosine similarity
+type Gallery struct {
+	// minimum similarity to be considered the same identity
+	Threshold float32
+
+	ids   []int
+	means [][]float32
+	count []int
+}
+
+// Match returns the identity of the embedding, adding a new identity when
+// nothing in the gallery is similar enough. The matched identity's embedding
+// is updated with a running mean.
+func (g *Gallery) Match(e []float32) (int, float32) {
+	best, sim := -1, float32(-1)
+	for i, m := range g.means {
+		if s := Cosine(m, e); s > sim {
+			best, sim = i, s
+		}
+	}
+	if best < 0 || sim < g.Threshold {
+		g.ids = append(g.ids, len(g.ids)+1)
+		g.means = append(g.means, append([]float32(nil), e...))
+		g.count = append(g.count, 1)
+		return len(g.ids), 1
+	}
+
+	g.count[best]++
+	n := float32(g.count[best])
+	for i := range g.means[best] {
+		g.means[best][i] += (e[i] - g.means[best][i]) / n
+	}
+	return g.ids[best], sim
+}
+
+func Cosine(a, b []float32) float32 {
+	var dot, na, nb float64
+	for i := range a {
+		if i >= len(b) {
+			break
+		}
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return float32(dot / math.Sqrt(na*nb))
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"sort"
+	"time"
+)
+
+// ReorderWriter holds frames back for Window, writing them in timestamp order
+// across sources whose clocks or latencies differ by up to Window. Frames
+// later than that are written as they arrive.
+type ReorderWriter struct {
+	w      DetectWriter
+	window time.Duration
+
+	pending []pendingFrame
+	newest  time.Time
+	// frames written out, for a Checkpoint
+	written []*frameMark
+}
+
+type pendingFrame struct {
+	frame   *Frame
+	detects []Detect
+}
+
+func NewReorderWriter(w DetectWriter, window time.Duration) *ReorderWriter {
+	return &ReorderWriter{w: w, window: window}
+}
+
+func (r *ReorderWriter) Write(frame *Frame, detects []Detect) error {
+	i := sort.Search(len(r.pending), func(i int) bool {
+		return r.pending[i].frame.Time.After(frame.Time)
+	})
+	r.pending = append(r.pending, pendingFrame{})
+	copy(r.pending[i+1:], r.pending[i:])
+	r.pending[i] = pendingFrame{frame, detects}
+	if frame.Time.After(r.newest) {
+		r.newest = frame.Time
+	}
+	return r.release(r.newest.Add(-r.window))
+}
+
+// Flush writes every pending frame, at the end of the sources, and flushes
+// the underlying writer
+func (r *ReorderWriter) Flush() error {
+	if err := r.release(r.newest); err != nil {
+		return err
+	}
+	if f, ok := r.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// write the frames up to and including until
+func (r *ReorderWriter) release(until time.Time) error {
+	n := 0
+	for ; n < len(r.pending) && !r.pending[n].frame.Time.After(until); n++ {
+		if err := r.w.Write(r.pending[n].frame, r.pending[n].detects); err != nil {
+			return err
+		}
+		r.written = append(r.written, r.pending[n].frame.mark)
+	}
+	r.pending = r.pending[n:]
+	return nil
+}
+
+// the frames written out, or let go by the writer underneath when it holds
+// frames back too
+func (r *ReorderWriter) released() []*frameMark {
+	if h, ok := r.w.(frameHolder); ok {
+		r.written = nil
+		return h.released()
+	}
+	written := r.written
+	r.written = nil
+	return written
+}
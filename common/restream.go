@@ -0,0 +1,149 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Restream serves the annotated frames of a live run to browsers, as mjpeg
+// at /stream.mjpeg, and once StartHLS is called as hls at /hls/index.m3u8,
+// with a page showing the mjpeg at /
+type Restream struct {
+	labels Namer
+	scores ScoreFormat
+	// how detections are drawn, DefaultStyle unless set
+	Style *Style
+
+	mu      sync.Mutex
+	clients map[chan []byte]bool
+	hls     *VideoWriter
+	dir     string
+}
+
+func NewRestream(labels Namer) (*Restream, error) {
+	if Private() {
+		return nil, ErrPrivate
+	}
+	return &Restream{labels: labels, scores: ScoreFormat{Precision: 2}, Style: DefaultStyle(), clients: make(map[chan []byte]bool)}, nil
+}
+
+func (r *Restream) setScoreFormat(f ScoreFormat) { r.scores = f }
+
+// StartHLS encodes the frames into 2s hls segments at fps, the last 6 kept
+// in a temporary dir
+func (r *Restream) StartHLS(fps float64, encoder string) error {
+	dir, err := ioutil.TempDir("", "restream")
+	if err != nil {
+		return err
+	}
+	hls, err := newVideoWriter(filepath.Join(dir, "index.m3u8"), fps, encoder, nil,
+		"-f", "hls", "-hls_time", "2", "-hls_list_size", "6", "-hls_flags", "delete_segments")
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	r.hls, r.dir = hls, dir
+	return nil
+}
+
+// Write a frame to the hls stream and the mjpeg clients, drawn only when
+// someone watches
+func (r *Restream) Write(frame *Frame, detects []Detect) error {
+	r.mu.Lock()
+	watched := len(r.clients) > 0
+	r.mu.Unlock()
+	if !watched && r.hls == nil {
+		return nil
+	}
+	im := r.Style.Annotate(frame.Im, detects, r.labels, r.scores)
+	if r.hls != nil {
+		if err := r.hls.WriteFrame(im, frame); err != nil {
+			return err
+		}
+	}
+	if !watched {
+		return nil
+	}
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, im, &jpeg.Options{Quality: 80}); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for c := range r.clients {
+		// slow clients skip frames rather than hold up detection
+		select {
+		case c <- b.Bytes():
+		default:
+		}
+	}
+	return nil
+}
+
+func (r *Restream) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch {
+	case req.URL.Path == "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<!doctype html><title>detect</title><body style="margin:0;background:#000"><img src="/stream.mjpeg" style="max-width:100%">`)
+	case req.URL.Path == "/stream.mjpeg":
+		r.serveMJPEG(w, req)
+	case r.dir != "" && filepath.Dir(req.URL.Path) == "/hls":
+		// the playlist changes every segment
+		w.Header().Set("Cache-Control", "no-cache")
+		http.StripPrefix("/hls/", http.FileServer(http.Dir(r.dir))).ServeHTTP(w, req)
+	default:
+		WriteProblem(w, http.StatusNotFound, CodeNotFound, "expected /, /stream.mjpeg or /hls/index.m3u8")
+	}
+}
+
+func (r *Restream) serveMJPEG(w http.ResponseWriter, req *http.Request) {
+	c := make(chan []byte, 1)
+	r.mu.Lock()
+	r.clients[c] = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.clients, c)
+		r.mu.Unlock()
+	}()
+
+	const boundary = "frame"
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+boundary)
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case b := <-c:
+			if _, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", boundary, len(b)); err != nil {
+				return
+			}
+			if _, err := w.Write(b); err != nil {
+				return
+			}
+			if _, err := fmt.Fprint(w, "\r\n"); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// Close ends the hls stream and removes its segments
+func (r *Restream) Close() error {
+	if r.hls == nil {
+		return nil
+	}
+	err := r.hls.Close()
+	os.RemoveAll(r.dir)
+	return err
+}
@@ -0,0 +1,108 @@
+package common
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ResultCache keeps the responses of the last Size distinct POST /detect
+// requests by their ETag, so a repeated image isn't run through the model
+// again. Tags are of the image's pixels, the model, its labels and the
+// request's settings, so a swapped model, reloaded labels or changed bounds
+// never answer from it.
+type ResultCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	byTag map[string]*list.Element
+}
+
+type cachedResult struct {
+	tag, contentType string
+	body             []byte
+	// the detections of the body, audited again on each hit
+	detects []Detect
+}
+
+func NewResultCache(size int) *ResultCache {
+	return &ResultCache{size: size, order: list.New(), byTag: make(map[string]*list.Element)}
+}
+
+// Get the response of a tag, and the detections it's of
+func (c *ResultCache) Get(tag string) (contentType string, body []byte, detects []Detect, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.byTag[tag]
+	if !ok {
+		return "", nil, nil, false
+	}
+	c.order.MoveToFront(e)
+	r := e.Value.(*cachedResult)
+	return r.contentType, r.body, r.detects, true
+}
+
+// Put the response of a tag, forgetting the least recently used past Size
+func (c *ResultCache) Put(tag, contentType string, body []byte, detects []Detect) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.byTag[tag]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+	c.byTag[tag] = c.order.PushFront(&cachedResult{tag, contentType, body, detects})
+	for c.order.Len() > c.size {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.byTag, last.Value.(*cachedResult).tag)
+	}
+}
+
+// SetModelVersion names the model answering requests, eg. the FileHash of
+// the model, for the ETags of responses to change when it's swapped
+func (h *DetectHandler) SetModelVersion(v string) {
+	h.mu.Lock()
+	h.version = v
+	h.mu.Unlock()
+}
+
+// the ETag of the response to im with the overrides, as the server's
+// settings are now. It's weak, as the time of a response rerun differs.
+func (h *DetectHandler) etag(im image.Image, o Overrides, coords string) string {
+	classes := append([]CID(nil), o.Classes...)
+	sort.Slice(classes, func(i, j int) bool { return classes[i] < classes[j] })
+	o.Classes = classes
+	overrides, _ := json.Marshal(o)
+	h.mu.RLock()
+	served := make([]string, 0, len(h.Classes))
+	for c := range h.Classes {
+		served = append(served, fmt.Sprint(c))
+	}
+	sort.Strings(served)
+	labels := 0
+	if l, ok := h.Labels.(interface{ Generation() int }); ok {
+		labels = l.Generation()
+	}
+	settings := fmt.Sprintf("%s %d\n%v %d %v %s\n%s\n%s", h.version, labels, h.Min, h.Max, h.MinFloor, strings.Join(served, ","), overrides, coords)
+	h.mu.RUnlock()
+	sum := sha256.Sum256([]byte(ImageHash(im) + "\n" + settings))
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// whether an If-None-Match header lists tag, compared weakly
+func noneMatch(header, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
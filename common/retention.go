@@ -0,0 +1,109 @@
+package common
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// how often a Retention cleans up
+const cleanEvery = time.Minute
+
+// Retention bounds the files a long running tool writes to a dir, so it
+// doesn't fill the disk. Only the files named like the tool's outputs are
+// counted and removed, others in the dir are left alone. The files of each
+// subdir, eg. the verdicts of moderate, are a class for MaxFiles.
+type Retention struct {
+	// globs of the base names of the outputs, eg. *-detects.jpg
+	Match []string
+	// files older than this are removed, 0 for no max
+	MaxAge time.Duration
+	// the oldest files are removed past this many bytes in all, 0 for no max
+	MaxBytes int64
+	// the oldest files of a subdir are removed past this many, 0 for no max
+	MaxFiles int
+}
+
+// RetentionFlags adds the -retain-age, -retain-mb and -retain-files flags of
+// a tool, the returned func starts cleaning a dir by them in the background
+// once parsed, and does nothing when none is given. Only files of dir
+// matching one of the globs are removed.
+func RetentionFlags() func(dir string, match ...string) {
+	age := flag.Duration("retain-age", 0, "Remove written files older than this, eg. 168h; 0 to keep them")
+	mb := flag.Int64("retain-mb", 0, "Remove the oldest written files past this many megabytes; 0 for no max")
+	files := flag.Int("retain-files", 0, "Remove the oldest written files past this many per subdir, eg. per class; 0 for no max")
+	return func(dir string, match ...string) {
+		r := Retention{Match: match, MaxAge: *age, MaxBytes: *mb << 20, MaxFiles: *files}
+		if r.MaxAge > 0 || r.MaxBytes > 0 || r.MaxFiles > 0 {
+			go r.Run(dir)
+		}
+	}
+}
+
+// Run cleans dir now and every minute after
+func (r Retention) Run(dir string) {
+	for {
+		if err := r.Clean(dir); err != nil {
+			log.Println("retention:", err)
+		}
+		time.Sleep(cleanEvery)
+	}
+}
+
+type retained struct {
+	path string
+	info os.FileInfo
+}
+
+// Clean removes the files of dir past the policy, oldest first
+func (r Retention) Clean(dir string) error {
+	var files []retained
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// files removed under the walk
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() && r.matches(info.Name()) {
+			files = append(files, retained{path, info})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// newest first, so the files past a limit are at the end
+	sort.Slice(files, func(i, j int) bool { return files[i].info.ModTime().After(files[j].info.ModTime()) })
+
+	now := time.Now()
+	var total int64
+	perDir := make(map[string]int)
+	for _, f := range files {
+		d := filepath.Dir(f.path)
+		if (r.MaxAge > 0 && now.Sub(f.info.ModTime()) > r.MaxAge) ||
+			(r.MaxBytes > 0 && total+f.info.Size() > r.MaxBytes) ||
+			(r.MaxFiles > 0 && perDir[d] >= r.MaxFiles) {
+			if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		perDir[d]++
+		total += f.info.Size()
+	}
+	return nil
+}
+
+func (r Retention) matches(name string) bool {
+	for _, m := range r.Match {
+		if ok, _ := filepath.Match(m, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ScoreWriter formats the class scores of each input of a classifier
+type ScoreWriter interface {
+	Write(name string, scores []float32) error
+}
+
+// NewScoreWriter writes the top classes of each input, scoring at least
+// min, in the formats of NewDetectWriter
+//
+//	plain   name class confidence, a line per class
+//	pretty  the input, then colored lines with confidence bars
+//	json    one object per input
+func NewScoreWriter(format string, w io.Writer, labels Namer, top int, min float32) (ScoreWriter, error) {
+	switch format {
+	case "plain", "pretty", "json":
+		return &scoreWriter{w: w, format: format, labels: labels, top: top, min: min, enc: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("unsupported output format %q", format)
+}
+
+type scoreWriter struct {
+	w      io.Writer
+	format string
+	labels Namer
+	top    int
+	min    float32
+	enc    *json.Encoder
+	scores *ScoreFormat
+}
+
+func (s *scoreWriter) setScoreFormat(f ScoreFormat) { s.scores = &f }
+
+type jsonClassScore struct {
+	Class      CID         `json:"class"`
+	Label      string      `json:"label"`
+	Confidence json.Number `json:"confidence"`
+}
+
+type jsonScores struct {
+	Input  string           `json:"input"`
+	Scores []jsonClassScore `json:"scores"`
+}
+
+// TopScores are the indices of the n highest scores of at least min, highest
+// first, all of them when n is 0
+func TopScores(scores []float32, n int, min float32) []int {
+	idx := make([]int, 0, len(scores))
+	for i, s := range scores {
+		if s >= min {
+			idx = append(idx, i)
+		}
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return scores[idx[a]] > scores[idx[b]] })
+	if n > 0 && len(idx) > n {
+		idx = idx[:n]
+	}
+	return idx
+}
+
+func (s *scoreWriter) Write(name string, scores []float32) error {
+	top := TopScores(scores, s.top, s.min)
+	switch s.format {
+	case "json":
+		out := jsonScores{Input: name, Scores: make([]jsonClassScore, len(top))}
+		for i, c := range top {
+			out.Scores[i] = jsonClassScore{CID(c), s.labels.Name(CID(c)), jsonScore(s.scores, scores[c])}
+		}
+		return s.enc.Encode(out)
+	case "pretty":
+		fmt.Fprintf(s.w, "\x1b[1m%s\x1b[0m\n", name)
+		pretty := ScoreFormat{Precision: 3}
+		if s.scores != nil {
+			pretty = *s.scores
+		}
+		for _, c := range top {
+			color := classColors[c%len(classColors)]
+			_, err := fmt.Fprintf(s.w, "  \x1b[38;5;%dm%-24s\x1b[0m %s %s\n", color, s.labels.Name(CID(c)), ConfidenceBar(scores[c], 10), LocalizeDecimal(pretty.String(scores[c])))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, c := range top {
+		var score interface{} = scores[c]
+		if s.scores != nil {
+			score = s.scores.Number(scores[c])
+		}
+		if _, err := fmt.Fprintf(s.w, "%s %v %v\n", name, c, score); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SoftmaxWriter puts the scores through softmax before writing them, mode
+// always, never, or auto for scores that are logits rather than
+// probabilities in [0,1], as softmax and sigmoid outputs are
+func SoftmaxWriter(w ScoreWriter, mode string) (ScoreWriter, error) {
+	switch mode {
+	case "never":
+		return w, nil
+	case "auto", "always":
+		return &softmaxWriter{w, mode == "always"}, nil
+	}
+	return nil, fmt.Errorf("unsupported softmax %q, expected auto, always or never", mode)
+}
+
+type softmaxWriter struct {
+	ScoreWriter
+	always bool
+}
+
+func (s *softmaxWriter) setScoreFormat(f ScoreFormat) { SetScoreFormat(s.ScoreWriter, f) }
+
+func (s *softmaxWriter) Write(name string, scores []float32) error {
+	mode := "auto"
+	if s.always {
+		mode = "always"
+	}
+	return s.ScoreWriter.Write(name, SoftmaxScores(scores, mode))
+}
+
+// SoftmaxScores puts a copy of scores through softmax as the mode of
+// SoftmaxWriter says
+func SoftmaxScores(scores []float32, mode string) []float32 {
+	if mode == "always" || mode == "auto" && !probabilities(scores) {
+		return Softmax(append([]float32(nil), scores...))
+	}
+	return scores
+}
+
+func probabilities(scores []float32) bool {
+	for _, s := range scores {
+		if s < 0 || s > 1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package common
+
+import (
+	"fmt"
+	"image"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// NewScreenSource captures a display at fps, or a window of it, optionally
+// limited to a region; frames are grabbed by ffmpeg using the platform
+// capture device. Windows are picked by their title on windows, and by their
+// x11 window id, eg. 0x3a00007 from xwininfo, on linux; macs capture whole
+// displays only.
+func NewScreenSource(display int, fps float64, region image.Rectangle, window string) (FrameSource, error) {
+	uri := fmt.Sprintf("screen:%d", display)
+	if window != "" {
+		uri += "/window:" + window
+	}
+	rate := strconv.FormatFloat(fps, 'f', -1, 64)
+	size := fmt.Sprintf("%dx%d", region.Dx(), region.Dy())
+
+	switch runtime.GOOS {
+	case "linux":
+		args := []string{"-f", "x11grab", "-framerate", rate}
+		if window != "" {
+			// the region is then within the window
+			if _, err := strconv.ParseUint(strings.TrimPrefix(window, "0x"), 16, 32); err != nil {
+				return nil, fmt.Errorf("invalid window %q, expected an x11 window id such as 0x3a00007", window)
+			}
+			args = append(args, "-window_id", window)
+		}
+		if !region.Empty() {
+			args = append(args, "-video_size", size)
+		}
+		in := fmt.Sprintf(":%d.0+%d,%d", display, region.Min.X, region.Min.Y)
+		return NewFfmpegSource("screen", uri, append(args, "-i", in)...)
+	case "darwin":
+		if window != "" {
+			return nil, fmt.Errorf("window capture is not supported on darwin, only displays and regions of them")
+		}
+		args := []string{"-f", "avfoundation", "-framerate", rate, "-capture_cursor", "0", "-i", fmt.Sprintf("%d:none", display)}
+		if !region.Empty() {
+			args = append(args, "-vf", fmt.Sprintf("crop=%d:%d:%d:%d", region.Dx(), region.Dy(), region.Min.X, region.Min.Y))
+		}
+		return NewFfmpegSource("screen", uri, args...)
+	case "windows":
+		// gdigrab exposes the combined desktop, or a window by its title,
+		// the region then within the window
+		in := "desktop"
+		if window != "" {
+			in = "title=" + window
+		}
+		args := []string{"-f", "gdigrab", "-framerate", rate}
+		if !region.Empty() {
+			args = append(args,
+				"-offset_x", strconv.Itoa(region.Min.X),
+				"-offset_y", strconv.Itoa(region.Min.Y),
+				"-video_size", size)
+		}
+		return NewFfmpegSource("screen", uri, append(args, "-i", in)...)
+	}
+	return nil, fmt.Errorf("screen capture is not supported on %s", runtime.GOOS)
+}
+
+// x,y,w,h
+func ParseRegion(s string) (image.Rectangle, error) {
+	if s == "" {
+		return image.ZR, nil
+	}
+	splits := strings.Split(s, ",")
+	if len(splits) != 4 {
+		return image.ZR, fmt.Errorf("invalid region %q, expected x,y,w,h", s)
+	}
+	v := make([]int, 4)
+	for i, split := range splits {
+		n, err := strconv.Atoi(strings.TrimSpace(split))
+		if err != nil {
+			return image.ZR, fmt.Errorf("invalid region %q: %v", s, err)
+		}
+		v[i] = n
+	}
+	return image.Rect(v[0], v[1], v[0]+v[2], v[1]+v[3]), nil
+}
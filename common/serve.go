@@ -0,0 +1,578 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// largest upload accepted by DetectHandler by default
+const maxUpload = 64 << 20
+
+// DetectHandler runs detection on a single uploaded image, with the model
+// kept loaded between requests
+//
+//	POST /detect         an image as the body, or as the image field of a
+//	                     multipart form, answered with its detections in
+//	                     the json output format
+//	POST /detect?min=0.5 with a minimum confidence other than Min
+//
+// Requests override the server's settings with query parameters, fields of
+// the multipart form, or fields of a json body with the image in base64
+//
+//	{"image": "/9j/4AAQ...", "min": 0.5, "max": 10, "classes": [1, 3], "coords": "geo"}
+//
+// min no lower than MinFloor, max no higher than Max, classes among Classes,
+// and coords pixels for json, or geo for geojson in the crs of georeferenced
+// images. models=a,b&strategy=mean runs an ensemble of Models in place of
+// Predict, combined with a strategy of Ensemble, mean by default, if
+// Ensembles allows it. Requests past the bounds are refused. Failures are
+// answered with a Problem.
+type DetectHandler struct {
+	Predict Predictor
+	Labels  Namer
+	Min     float32
+	// most detections of an image, the highest scoring, 0 for all
+	Max int
+	// lowest min a request may ask for
+	MinFloor float32
+	// classes answered with, nil for all
+	Classes map[CID]bool
+	// models requests may ask for by name, alone or together, and the
+	// combinations they may ask for, names joined by +, nil for any
+	Models    map[string]Predictor
+	Ensembles map[string]bool
+	// counts of the requests served, nil for none
+	Metrics *Metrics
+	// longest an image may take to detect on, 0 for no limit
+	Timeout time.Duration
+	// most images detected on at once, runs given up on past the Timeout
+	// counting until they finish, 0 for no max. Past it a request waits for
+	// a run to finish within its Timeout, or fails right away without one.
+	MaxInFlight int
+	// largest upload in bytes, 0 for 64 MB, and most pixels of an image,
+	// checked before it's decoded, 0 for any
+	MaxUpload int64
+	MaxPixels int
+	// responses of repeated requests, nil for none. With it responses have
+	// an ETag, and If-None-Match is answered with 304 Not Modified.
+	Cache *ResultCache
+	// estimated flops of running an image of bounds on Predict, for the
+	// Metrics, nil for none
+	Cost func(b image.Rectangle) float64
+	// log every prediction is recorded in before it's answered, nil for
+	// none; an AuditLog behind a PrivateWriter
+	Audit DetectWriter
+
+	// the model's, in ETags
+	version string
+	// guards the settings above while SetBounds changes them
+	mu sync.RWMutex
+	// a slot for each run of MaxInFlight
+	slotsOnce sync.Once
+	slots     chan bool
+	// unix nanos of the last image Predict answered, and whether a probe of
+	// Alive is running
+	answered int64
+	probing  int32
+}
+
+// Overrides are the settings a request asks for in place of the server's,
+// nil for the server's
+type Overrides struct {
+	Min     *float32 `json:"min"`
+	Max     *int     `json:"max"`
+	Classes []CID    `json:"classes"`
+	// models of an ensemble and how their detections are combined
+	Models   []string `json:"models"`
+	Strategy string   `json:"strategy"`
+}
+
+// OverrideError refuses overrides past the server's bounds
+type OverrideError string
+
+func (e OverrideError) Error() string {
+	return string(e)
+}
+
+// the image and overrides of a request, from a json body or form values
+type detectRequest struct {
+	Image []byte `json:"image"`
+	Overrides
+	Coords string `json:"coords"`
+}
+
+// fill the overrides given as strings, query parameters or form fields
+func (d *detectRequest) parse(value func(string) string) error {
+	if v := value("min"); v != "" {
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return fmt.Errorf("invalid min %s", v)
+		}
+		min := float32(f)
+		d.Min = &min
+	}
+	if v := value("max"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid max %s", v)
+		}
+		d.Max = &n
+	}
+	if v := value("classes"); v != "" {
+		classes, err := ParseClasses(v)
+		if err != nil {
+			return err
+		}
+		for c := range classes {
+			d.Classes = append(d.Classes, c)
+		}
+	}
+	if v := value("models"); v != "" {
+		d.Models = strings.Split(v, ",")
+	}
+	if v := value("strategy"); v != "" {
+		d.Strategy = v
+	}
+	if v := value("coords"); v != "" {
+		d.Coords = v
+	}
+	return nil
+}
+
+// DecodeImage decodes an uploaded image, refusing images past MaxPixels as
+// POST /detect does with a PAYLOAD_TOO_LARGE problem
+func (h *DetectHandler) DecodeImage(r io.Reader) (image.Image, error) {
+	im, _, err := readImageLimited(r, h.MaxPixels)
+	if _, ok := err.(pixelsError); ok {
+		return nil, NewProblem(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, err.Error())
+	}
+	return im, err
+}
+
+func (h *DetectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		WriteProblem(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "expected POST /detect with an image")
+		return
+	}
+	var req detectRequest
+	if err := req.parse(r.URL.Query().Get); err != nil {
+		WriteProblem(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+
+	limit := h.MaxUpload
+	if limit <= 0 {
+		limit = maxUpload
+	}
+	tooBig := func() {
+		// the rest of the body isn't read
+		w.Header().Set("Connection", "close")
+		WriteProblem(w, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, fmt.Sprintf("uploads are limited to %d MB", limit>>20))
+	}
+	// chunked uploads have no length, and are cut off once past it
+	if r.ContentLength > limit {
+		tooBig()
+		return
+	}
+	limited := &limitedBody{ReadCloser: r.Body, n: limit}
+	r.Body = limited
+	var body io.Reader = r.Body
+	name := "upload"
+	// form fields, read around the image part
+	var form *multipart.Reader
+	fields := make(map[string]string)
+	switch t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); t {
+	case "multipart/form-data":
+		var err error
+		if form, err = r.MultipartReader(); err != nil {
+			WriteProblem(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+		part, err := nextImagePart(form, fields)
+		if limited.tooLarge(err) {
+			tooBig()
+			return
+		} else if err != nil {
+			WriteProblem(w, http.StatusBadRequest, CodeInvalidRequest, "multipart form without an image field: "+err.Error())
+			return
+		}
+		body, name = part, part.FileName()
+	case "application/json":
+		// base64 in json can't be decoded as it streams in
+		if err := json.NewDecoder(r.Body).Decode(&req); limited.tooLarge(err) {
+			tooBig()
+			return
+		} else if err != nil {
+			WriteProblem(w, http.StatusBadRequest, CodeInvalidRequest, "invalid json request: "+err.Error())
+			return
+		}
+		body = bytes.NewReader(req.Image)
+	}
+
+	// decoded as it streams in, never held whole
+	start := time.Now()
+	im, exif, err := readImageLimited(body, h.MaxPixels)
+	if limited.tooLarge(err) {
+		tooBig()
+		return
+	} else if _, ok := err.(pixelsError); ok {
+		WriteProblem(w, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, err.Error())
+		return
+	} else if err != nil {
+		h.Metrics.Failed("decode")
+		WriteProblem(w, http.StatusBadRequest, CodeImageDecodeFailed, "invalid image: "+err.Error())
+		return
+	}
+	h.Metrics.Preprocessed(time.Since(start))
+
+	if form != nil {
+		// fields after the image
+		if _, err := nextImagePart(form, fields); err != io.EOF {
+			if limited.tooLarge(err) {
+				tooBig()
+			} else {
+				WriteProblem(w, http.StatusBadRequest, CodeInvalidRequest, "multipart form with more than one image field")
+			}
+			return
+		}
+		if err := req.parse(func(k string) string { return fields[k] }); err != nil {
+			WriteProblem(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+	}
+	format := "json"
+	switch req.Coords {
+	case "", "pixels":
+	case "geo":
+		format = "geojson"
+	default:
+		WriteProblem(w, http.StatusBadRequest, CodeInvalidRequest, "unsupported coords "+req.Coords+", expected pixels or geo")
+		return
+	}
+
+	var tag string
+	if h.Cache != nil {
+		tag = h.etag(im, req.Overrides, req.Coords)
+		w.Header().Set("ETag", tag)
+		// caches in front may keep responses, asking again whether they
+		// are still the model's
+		w.Header().Set("Cache-Control", "no-cache")
+		// hits are audited and counted as the predictions they answer with
+		start := time.Now()
+		if contentType, body, detects, ok := h.Cache.Get(tag); ok {
+			if _, err := h.record(name, start, im, detects, nil); err != nil {
+				writeDetectError(w, err)
+				return
+			}
+			if noneMatch(r.Header.Get("If-None-Match"), tag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.Write(body)
+			return
+		}
+	}
+
+	detects, err := h.Detect(name, im, req.Overrides)
+	if err != nil {
+		writeDetectError(w, err)
+		return
+	}
+	frame := &Frame{Name: name, Time: time.Now(), Im: im, Taken: exif.taken(), Geo: exif.geo()}
+
+	buf := &strings.Builder{}
+	out, err := NewDetectWriter(format, buf, h.Labels)
+	if err != nil {
+		WriteProblem(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	if err := PrivateWriter(out).Write(frame, detects); err != nil {
+		WriteProblem(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	contentType := "application/json"
+	if f, ok := out.(*GeoJsonWriter); ok {
+		if err := f.Flush(); err != nil {
+			WriteProblem(w, http.StatusInternalServerError, CodeInternal, err.Error())
+			return
+		}
+		contentType = "application/geo+json"
+	}
+	if h.Cache != nil {
+		h.Cache.Put(tag, contentType, []byte(buf.String()), detects)
+		if noneMatch(r.Header.Get("If-None-Match"), tag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	io.WriteString(w, buf.String())
+}
+
+// Detect runs im with the overrides of a request, highest score first,
+// refusing overrides past the bounds with an OverrideError, and failing
+// with a Problem for models that aren't loaded and images past the Timeout.
+// The prediction is recorded in the Audit log under name.
+func (h *DetectHandler) Detect(name string, im image.Image, o Overrides) ([]Detect, error) {
+	start := time.Now()
+	detects, err := h.timed(im, o)
+	detects, err = h.record(name, start, im, detects, err)
+	if err == nil {
+		// only the model running counts for Alive, not the Cache
+		atomic.StoreInt64(&h.answered, time.Now().UnixNano())
+		if h.Cost != nil && len(o.Models) == 0 {
+			h.Metrics.Spent(h.Cost(im.Bounds()))
+		}
+	}
+	return detects, err
+}
+
+// record a prediction started at start, whether run or answered from the
+// Cache, in the Audit log and the Metrics
+func (h *DetectHandler) record(name string, start time.Time, im image.Image, detects []Detect, err error) ([]Detect, error) {
+	if err == nil && h.Audit != nil {
+		if aerr := h.Audit.Write(&Frame{Name: name, Time: start, Im: im}, detects); aerr != nil {
+			detects, err = nil, NewProblem(http.StatusInternalServerError, CodeInternal, "audit log: "+aerr.Error())
+		}
+	}
+	if p, ok := err.(*Problem); ok && p.Code == CodeTimeout {
+		h.Metrics.Failed("timeout")
+	} else if ok && p.Code == CodeInternal {
+		h.Metrics.Failed("audit")
+	} else if _, ok := err.(OverrideError); ok || p != nil {
+		h.Metrics.Failed("request")
+	} else if err != nil {
+		h.Metrics.Failed("inference")
+	} else {
+		h.Metrics.Detected(time.Since(start), detects)
+	}
+	return detects, err
+}
+
+// Alive reports whether the model still answers, for a watchdog: it has
+// answered a request within the deadline, or a blank image run on Predict
+// now finishes within it. A probe that hangs isn't run again until it
+// finishes, the server is dead meanwhile.
+func (h *DetectHandler) Alive(deadline time.Duration) bool {
+	if time.Since(time.Unix(0, atomic.LoadInt64(&h.answered))) < deadline {
+		return true
+	}
+	if !atomic.CompareAndSwapInt32(&h.probing, 0, 1) {
+		return false
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer atomic.StoreInt32(&h.probing, 0)
+		_, err := h.Predict(image.NewRGBA(image.Rect(0, 0, 64, 64)))
+		done <- err
+	}()
+	timer := time.NewTimer(deadline)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			return false
+		}
+		atomic.StoreInt64(&h.answered, time.Now().UnixNano())
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// detect within the Timeout, the session run is left to finish on its own
+// past it, holding its slot of MaxInFlight until it does
+func (h *DetectHandler) timed(im image.Image, o Overrides) ([]Detect, error) {
+	var timeout <-chan time.Time
+	if h.Timeout > 0 {
+		timer := time.NewTimer(h.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	h.slotsOnce.Do(func() {
+		if h.MaxInFlight > 0 {
+			h.slots = make(chan bool, h.MaxInFlight)
+		}
+	})
+	if h.slots != nil {
+		busy := NewProblem(http.StatusServiceUnavailable, CodeTimeout, fmt.Sprintf("%d images are being detected on already", h.MaxInFlight))
+		if timeout == nil {
+			select {
+			case h.slots <- true:
+			default:
+				return nil, busy
+			}
+		} else {
+			select {
+			case h.slots <- true:
+			case <-timeout:
+				return nil, busy
+			}
+		}
+	}
+	release := func() {
+		if h.slots != nil {
+			<-h.slots
+		}
+	}
+	if timeout == nil {
+		defer release()
+		return h.detect(im, o)
+	}
+	type result struct {
+		detects []Detect
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer release()
+		detects, err := h.detect(im, o)
+		done <- result{detects, err}
+	}()
+	select {
+	case r := <-done:
+		return r.detects, r.err
+	case <-timeout:
+		return nil, NewProblem(http.StatusGatewayTimeout, CodeTimeout, fmt.Sprintf("detection took longer than %v", h.Timeout))
+	}
+}
+
+// SetBounds changes the settings and bounds of requests while serving
+func (h *DetectHandler) SetBounds(min float32, max int, minFloor float32, classes map[CID]bool) {
+	h.mu.Lock()
+	h.Min, h.Max, h.MinFloor, h.Classes = min, max, minFloor, classes
+	h.mu.Unlock()
+}
+
+func (h *DetectHandler) detect(im image.Image, o Overrides) ([]Detect, error) {
+	h.mu.RLock()
+	min, max, minFloor, served := h.Min, h.Max, h.MinFloor, h.Classes
+	h.mu.RUnlock()
+	classes, serverMax := served, max
+	if o.Min != nil {
+		if *o.Min < minFloor {
+			return nil, OverrideError(fmt.Sprintf("min %v is below the server's %v", *o.Min, minFloor))
+		}
+		min = *o.Min
+	}
+	if o.Max != nil {
+		if *o.Max < 0 || (serverMax > 0 && (*o.Max == 0 || *o.Max > serverMax)) {
+			return nil, OverrideError(fmt.Sprintf("max %d is past the server's %d", *o.Max, serverMax))
+		}
+		max = *o.Max
+	}
+	if o.Classes != nil {
+		classes = make(map[CID]bool)
+		for _, c := range o.Classes {
+			if served != nil && !served[c] {
+				return nil, OverrideError(fmt.Sprintf("class %d is not served", c))
+			}
+			classes[c] = true
+		}
+	}
+
+	detects, err := h.predict(im, o.Models, o.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(detects, func(i, j int) bool {
+		return detects[i].Confidence > detects[j].Confidence
+	})
+	n := 0
+	for _, d := range detects {
+		if d.Confidence > min && (classes == nil || classes[d.Class]) {
+			detects[n] = d
+			n++
+		}
+	}
+	if max > 0 && n > max {
+		n = max
+	}
+	return detects[:n], nil
+}
+
+// the next image part of a form, reading the fields before it into fields;
+// io.EOF once there are no more parts
+func nextImagePart(form *multipart.Reader, fields map[string]string) (*multipart.Part, error) {
+	for {
+		part, err := form.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "image" {
+			return part, nil
+		}
+		// overrides are short
+		v, err := ioutil.ReadAll(io.LimitReader(part, 4<<10))
+		if err != nil {
+			return nil, err
+		}
+		fields[part.FormName()] = string(v)
+	}
+}
+
+// run im on Predict, or on an ensemble of Models
+func (h *DetectHandler) predict(im image.Image, models []string, strategy string) ([]Detect, error) {
+	if models == nil {
+		if h.Predict == nil {
+			return nil, NewProblem(http.StatusServiceUnavailable, CodeModelNotLoaded, "no model is loaded")
+		}
+		return h.Predict(im)
+	}
+	if strategy == "" {
+		strategy = "mean"
+	}
+	if strategy != "mean" && strategy != "max" && strategy != "vote" {
+		return nil, OverrideError(fmt.Sprintf("unsupported strategy %q, expected mean, max or vote", strategy))
+	}
+	sorted := append([]string(nil), models...)
+	sort.Strings(sorted)
+	predictors := make([]Predictor, len(sorted))
+	for i, name := range sorted {
+		if i > 0 && name == sorted[i-1] {
+			return nil, OverrideError(fmt.Sprintf("model %s is asked for twice", name))
+		}
+		if predictors[i] = h.Models[name]; predictors[i] == nil {
+			return nil, NewProblem(http.StatusNotFound, CodeModelNotLoaded, fmt.Sprintf("model %s is not loaded", name))
+		}
+	}
+	if key := strings.Join(sorted, "+"); h.Ensembles != nil && !h.Ensembles[key] {
+		return nil, OverrideError(fmt.Sprintf("ensemble %s is not served", key))
+	}
+
+	// the models run at once, sessions are safe for concurrent runs
+	views := make([][]Detect, len(predictors))
+	errs := make([]error, len(predictors))
+	var wg sync.WaitGroup
+	for i, predict := range predictors {
+		wg.Add(1)
+		go func(i int, predict Predictor) {
+			defer wg.Done()
+			views[i], errs[i] = predict(im)
+		}(i, predict)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", sorted[i], err)
+		}
+	}
+	if len(views) == 1 {
+		return views[0], nil
+	}
+	return Ensemble(views, strategy)
+}
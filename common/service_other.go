@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package common
+
+import "fmt"
+
+// StartService does nothing outside windows, where services are run by
+// systemd or launchd as they are
+func StartService(name string) error {
+	return nil
+}
+
+func InstallService(name string, args []string) error {
+	return fmt.Errorf("services are installed on windows only, see the README for systemd and launchd")
+}
+
+func RemoveService(name string) error {
+	return InstallService(name, nil)
+}
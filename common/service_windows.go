@@ -0,0 +1,120 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// StartService hands the process to the windows service control manager
+// when it was started as a service, answering stop and shutdown by exiting,
+// and logs to the event log of the service. Does nothing otherwise.
+func StartService(name string) error {
+	ok, err := svc.IsWindowsService()
+	if err != nil || !ok {
+		return err
+	}
+	events, err := eventlog.Open(name)
+	if err != nil {
+		return err
+	}
+	log.SetFlags(0)
+	log.SetOutput(eventWriter{events})
+	go func() {
+		if err := svc.Run(name, service{}); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}()
+	return nil
+}
+
+type service struct{}
+
+func (service) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for r := range requests {
+		switch r.Cmd {
+		case svc.Interrogate:
+			status <- r.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending}
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// log lines as event log entries, ERROR: lines as errors
+type eventWriter struct {
+	events *eventlog.Log
+}
+
+func (w eventWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	var err error
+	if strings.HasPrefix(msg, "ERROR:") {
+		err = w.events.Error(1, msg)
+	} else {
+		err = w.events.Info(1, msg)
+	}
+	return len(p), err
+}
+
+// InstallService installs this executable as an automatically started
+// windows service run with args, restarted a few seconds after it fails,
+// with an event log source of the same name
+func InstallService(name string, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s is already installed", name)
+	}
+	s, err := m.CreateService(name, exe, mgr.Config{DisplayName: name, StartType: mgr.StartAutomatic}, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	restart := []mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: 5 * time.Second}}
+	if err := s.SetRecoveryActions(restart, 60); err != nil {
+		s.Delete()
+		return err
+	}
+	if err := eventlog.InstallAsEventCreate(name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return err
+	}
+	return nil
+}
+
+// RemoveService removes a service InstallService installed
+func RemoveService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", name)
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	return eventlog.Remove(name)
+}
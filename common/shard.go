@@ -0,0 +1,67 @@
+package common
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Shard is the Index'th of Count deterministic subsets of a corpus, so a
+// corpus can be split across machines running the same command
+type Shard struct {
+	Index, Count int
+}
+
+// ParseShard reads i/n, where i counts from 1
+func ParseShard(s string) (Shard, error) {
+	if s == "" {
+		return Shard{1, 1}, nil
+	}
+	splits := strings.Split(s, "/")
+	if len(splits) == 2 {
+		i, err1 := strconv.Atoi(splits[0])
+		n, err2 := strconv.Atoi(splits[1])
+		if err1 == nil && err2 == nil && i >= 1 && i <= n {
+			return Shard{i, n}, nil
+		}
+	}
+	return Shard{}, fmt.Errorf("invalid shard %q, expected i/n with 1 <= i <= n", s)
+}
+
+// Has reports whether the image named name, relative to its dir or archive,
+// belongs to the shard. Names rather than positions are hashed so every
+// machine agrees without listing the corpus in the same order.
+func (s Shard) Has(name string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	io.WriteString(h, name)
+	return int(h.Sum32()%uint32(s.Count)) == s.Index-1
+}
+
+// filtered sources skip images by name before decoding them
+type filtered interface {
+	setFilter(keep func(name string) bool)
+}
+
+// filters added later only see the names earlier ones keep
+func chain(prev, keep func(name string) bool) func(name string) bool {
+	if prev == nil {
+		return keep
+	}
+	return func(name string) bool {
+		return prev(name) && keep(name)
+	}
+}
+
+// ShardSource limits src to the images in the shard. Single images, streams
+// and live sources are not sharded.
+func ShardSource(src FrameSource, shard Shard) FrameSource {
+	if f, ok := src.(filtered); ok && shard.Count > 1 {
+		f.setFilter(shard.Has)
+	}
+	return src
+}
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// the objects of the smoke images a model should find, by the label names
+// they may go by
+var smokeObjects = map[string][]string{
+	"people": {"person", "people", "pedestrian"},
+}
+
+// SmokeImages are small images built into the binary, so a smoke test needs
+// no files next to it: a photo of two people, and flat, a gradient, a
+// checkerboard and seeded noise drawn in code
+func SmokeImages() map[string]image.Image {
+	const w, h = 320, 240
+	flat := image.NewRGBA(image.Rect(0, 0, w, h))
+	gradient := image.NewRGBA(flat.Bounds())
+	checker := image.NewRGBA(flat.Bounds())
+	noise := image.NewRGBA(flat.Bounds())
+	rnd := rand.New(rand.NewSource(1))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			flat.Set(x, y, color.RGBA{128, 128, 128, 255})
+			gradient.Set(x, y, color.RGBA{uint8(x * 255 / w), uint8(y * 255 / h), 128, 255})
+			c := uint8(0)
+			if (x/32+y/32)%2 == 0 {
+				c = 255
+			}
+			checker.Set(x, y, color.RGBA{c, c, c, 255})
+			noise.Set(x, y, color.RGBA{uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), 255})
+		}
+	}
+	people, err := jpeg.Decode(strings.NewReader(smokePeopleJpeg))
+	if err != nil {
+		panic(err)
+	}
+	return map[string]image.Image{"people": people, "flat": flat, "gradient": gradient, "checker": checker, "noise": noise}
+}
+
+// CheckFinds checks a model finds the objects of the smoke image name in its
+// detections, when the labels name a class of them. A model whose classes
+// aren't in the image is only held to the invariants of CheckDetects.
+func CheckFinds(name string, detects []Detect, labels Labels) error {
+	classes := make(map[CID]bool)
+	for _, object := range smokeObjects[name] {
+		for c, label := range labels {
+			if strings.EqualFold(label, object) {
+				classes[c] = true
+			}
+		}
+	}
+	if len(classes) == 0 {
+		return nil
+	}
+	for _, d := range detects {
+		if classes[d.Class] {
+			return nil
+		}
+	}
+	return fmt.Errorf("found no %s", strings.Join(smokeObjects[name], " or "))
+}
+
+// CheckDetects checks the invariants of the detections of im: finite scores
+// in [0,1], boxes within the image, and classes the labels name
+func CheckDetects(im image.Image, detects []Detect, labels Labels) error {
+	for _, d := range detects {
+		if err := checkScore(d.Confidence); err != nil {
+			return fmt.Errorf("class %d at %v: %v", d.Class, d.Bounds, err)
+		}
+		if d.Bounds.Empty() || !d.Bounds.In(im.Bounds()) {
+			return fmt.Errorf("class %d: box %v outside the image %v", d.Class, d.Bounds, im.Bounds())
+		}
+		if _, ok := labels[d.Class]; !ok && len(labels) > 0 {
+			return fmt.Errorf("class %d has no label, the labels don't match the model", d.Class)
+		}
+	}
+	return nil
+}
+
+// CheckScores checks the invariants of the class scores of a classifier:
+// finite scores in [0,1], one per label
+func CheckScores(scores []float32, labels Labels) error {
+	if len(labels) > 0 && len(scores) != len(labels) {
+		return fmt.Errorf("%d class scores for %d labels, the labels don't match the model", len(scores), len(labels))
+	}
+	for c, s := range scores {
+		if err := checkScore(s); err != nil {
+			return fmt.Errorf("class %d: %v", c, err)
+		}
+	}
+	return nil
+}
+
+// CheckFinite checks the raw outputs of a model are finite, logits or
+// scores
+func CheckFinite(scores []float32) error {
+	for c, s := range scores {
+		if math.IsNaN(float64(s)) || math.IsInf(float64(s), 0) {
+			return fmt.Errorf("class %d: %v is not finite", c, s)
+		}
+	}
+	return nil
+}
+
+func checkScore(s float32) error {
+	if math.IsNaN(float64(s)) || math.IsInf(float64(s), 0) {
+		return fmt.Errorf("score %v is not finite", s)
+	}
+	if s < 0 || s > 1 {
+		return fmt.Errorf("score %v is outside [0,1]", s)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package common
+
+// a frame of two people at a lectern, 150x103, from the testdata of
+// golang.org/x/image, Copyright 2009 The Go Authors, under its BSD license
+const smokePeopleJpeg = "" +
+	"\xff\xd8\xff\xdb\x00\x84\x00\x05\x03\x04\x04\x04\x03\x05\x04\x04\x04\x05\x05\x05\x06\x07\x0c\x08\x07\x07\x07\x07\x0f\x0b\x0b\x09" +
+	"\x0c\x11\x0f\x12\x12\x11\x0f\x11\x11\x13\x16\x1c\x17\x13\x14\x1a\x15\x11\x11\x18\x21\x18\x1a\x1d\x1d\x1f\x1f\x1f\x13\x17\x22\x24" +
+	"\x22\x1e\x24\x1c\x1e\x1f\x1e\x01\x05\x05\x05\x07\x06\x07\x0e\x08\x08\x0e\x1e\x14\x11\x14\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e" +
+	"\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e" +
+	"\x1e\x1e\x1e\x1e\x1e\x1e\x1e\x1e\xff\xc0\x00\x11\x08\x00\x67\x00\x96\x03\x01\x22\x00\x02\x11\x01\x03\x11\x01\xff\xc4\x01\xa2\x00" +
+	"\x00\x01\x05\x01\x01\x01\x01\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x10\x00\x02\x01" +
+	"\x03\x03\x02\x04\x03\x05\x05\x04\x04\x00\x00\x01\x7d\x01\x02\x03\x00\x04\x11\x05\x12\x21\x31\x41\x06\x13\x51\x61\x07\x22\x71\x14" +
+	"\x32\x81\x91\xa1\x08\x23\x42\xb1\xc1\x15\x52\xd1\xf0\x24\x33\x62\x72\x82\x09\x0a\x16\x17\x18\x19\x1a\x25\x26\x27\x28\x29\x2a\x34" +
+	"\x35\x36\x37\x38\x39\x3a\x43\x44\x45\x46\x47\x48\x49\x4a\x53\x54\x55\x56\x57\x58\x59\x5a\x63\x64\x65\x66\x67\x68\x69\x6a\x73\x74" +
+	"\x75\x76\x77\x78\x79\x7a\x83\x84\x85\x86\x87\x88\x89\x8a\x92\x93\x94\x95\x96\x97\x98\x99\x9a\xa2\xa3\xa4\xa5\xa6\xa7\xa8\xa9\xaa" +
+	"\xb2\xb3\xb4\xb5\xb6\xb7\xb8\xb9\xba\xc2\xc3\xc4\xc5\xc6\xc7\xc8\xc9\xca\xd2\xd3\xd4\xd5\xd6\xd7\xd8\xd9\xda\xe1\xe2\xe3\xe4\xe5" +
+	"\xe6\xe7\xe8\xe9\xea\xf1\xf2\xf3\xf4\xf5\xf6\xf7\xf8\xf9\xfa\x01\x00\x03\x01\x01\x01\x01\x01\x01\x01\x01\x01\x00\x00\x00\x00\x00" +
+	"\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x11\x00\x02\x01\x02\x04\x04\x03\x04\x07\x05\x04\x04\x00\x01\x02\x77\x00\x01\x02" +
+	"\x03\x11\x04\x05\x21\x31\x06\x12\x41\x51\x07\x61\x71\x13\x22\x32\x81\x08\x14\x42\x91\xa1\xb1\xc1\x09\x23\x33\x52\xf0\x15\x62\x72" +
+	"\xd1\x0a\x16\x24\x34\xe1\x25\xf1\x17\x18\x19\x1a\x26\x27\x28\x29\x2a\x35\x36\x37\x38\x39\x3a\x43\x44\x45\x46\x47\x48\x49\x4a\x53" +
+	"\x54\x55\x56\x57\x58\x59\x5a\x63\x64\x65\x66\x67\x68\x69\x6a\x73\x74\x75\x76\x77\x78\x79\x7a\x82\x83\x84\x85\x86\x87\x88\x89\x8a" +
+	"\x92\x93\x94\x95\x96\x97\x98\x99\x9a\xa2\xa3\xa4\xa5\xa6\xa7\xa8\xa9\xaa\xb2\xb3\xb4\xb5\xb6\xb7\xb8\xb9\xba\xc2\xc3\xc4\xc5\xc6" +
+	"\xc7\xc8\xc9\xca\xd2\xd3\xd4\xd5\xd6\xd7\xd8\xd9\xda\xe2\xe3\xe4\xe5\xe6\xe7\xe8\xe9\xea\xf2\xf3\xf4\xf5\xf6\xf7\xf8\xf9\xfa\xff" +
+	"\xda\x00\x0c\x03\x01\x00\x02\x11\x03\x11\x00\x3f\x00\xf9\xfd\x10\xf2\xa7\xf2\xeb\x4a\xc8\x87\xee\xaf\x15\x32\xef\xce\x7d\x7b\x53" +
+	"\xe3\xcf\xdd\x3c\x62\xbe\x45\xc9\x9f\xad\x29\x68\x55\x11\x95\x6f\x97\xb7\x43\x4e\x16\xec\x79\xdc\xb8\xab\x27\x68\x4c\xb9\x50\x83" +
+	"\xb9\xe0\x54\x6c\x23\x47\xe6\x45\xc1\x5c\x85\x3c\x64\x1a\x39\x9b\x34\x52\xe8\x45\xe4\x9d\xbc\x7f\x85\x4b\x0a\x15\xf9\x4f\x1f\x4a" +
+	"\x97\x60\x29\xb7\xee\x9a\x90\x26\x7f\x8b\xeb\x52\xe6\x27\x22\xb9\x05\x7e\xf2\xd2\x98\x83\x2d\x58\xd8\x77\x37\x3f\x43\x49\xc9\xe2" +
+	"\xa7\x9c\x9e\x62\x04\x5c\xf4\x5c\xfd\x2a\x35\x87\x6b\xfc\xdd\x3d\x2a\xcc\x68\x33\xf7\x58\x7d\x6a\x42\x87\xae\xfe\x45\x1c\xf6\x1d" +
+	"\xd9\x4e\x58\x46\xec\x7c\xa0\xfb\x66\xaa\x6a\xc8\xa9\x66\x87\xd2\x68\xff\x00\xf4\x2a\xd6\x20\x6e\xcf\x7f\x4a\xcd\xd7\x31\xf6\x15" +
+	"\x6d\xbf\xf2\xf1\x1f\x3f\xf0\x2a\xda\x8c\xdb\x9a\x39\xb1\xae\xf8\x79\xfa\x17\xe4\x4f\xde\x49\xdf\x04\xe2\x9a\xf1\x8d\xb9\xef\x52" +
+	"\x4a\x00\x9a\x4c\x74\xdc\x69\x92\x21\x6c\x36\xea\xc6\xe7\x44\x76\x44\x71\x82\x37\x7c\xd4\xe1\x1b\xef\xfb\xb9\x1b\x7a\xd5\xcb\x7b" +
+	"\x59\x0a\x46\xc5\x5b\x13\x3e\xc8\xce\xdf\xbc\xde\x95\xd7\x69\x5e\x06\x1a\x95\xc4\x16\xf0\xea\xd2\x23\x4a\x9b\x8c\x92\x5a\x9d\x83" +
+	"\xb7\xdd\x0d\xbc\xfe\x02\xa6\x55\x14\x75\x64\x4f\x11\x18\x3b\x33\x83\xc1\xe7\x77\x73\x4b\x80\xbc\x2e\xdf\xc2\xb6\xbc\x51\xe1\xed" +
+	"\x4b\xc3\x9a\xab\xe9\xba\x9a\x47\xe6\xaf\xcc\x8c\x87\x74\x72\xa6\x71\xb9\x58\x8e\x47\xd4\x02\x3a\x10\x2b\x23\x69\xda\xdf\x2a\xfb" +
+	"\x55\xb6\x5c\x64\xa4\xae\x8a\xe6\x24\x2d\x9c\x51\xe4\xa7\xa5\x58\x50\x78\xca\xf3\x8e\xb4\xec\x1f\xee\xd3\xe7\x66\x83\x44\x67\xae" +
+	"\xda\xb1\x6f\x67\x35\xcd\xc4\x10\xdb\xa7\x99\x2c\xf2\x2c\x71\xa1\xe3\x2c\xcc\x15\x46\x7e\xa6\xa6\x09\xf8\x93\xc8\xab\x56\x37\x26" +
+	"\xd3\x50\xb4\xbf\x78\xb2\xb6\xd3\xa4\xac\xa1\xb1\xc2\xb0\x27\xf4\x15\x8a\x9d\xda\x38\x9c\x9a\x8b\x68\xfa\xcf\xe1\x2f\xc3\xbf\x08" +
+	"\x78\x6f\x4b\x85\xed\xf4\x2b\x3d\x53\x53\x85\x47\xda\x75\x0b\x85\x12\x39\x93\xbe\xc0\x73\xb1\x73\xd0\x0e\xdd\x72\x73\x5d\x47\x8b" +
+	"\xac\xb4\x1d\x76\xdc\xe9\xfa\xd6\x93\x6d\xa8\x5a\x38\x2a\xd1\xc8\x80\xe0\x7b\x71\xc1\x1d\x88\xc1\x15\x87\xa0\xea\x72\x5a\x7d\x83" +
+	"\x43\x85\x52\xda\xe6\xf4\xb3\xc7\x2c\xa4\x11\x28\x51\x96\x01\x43\xe4\xed\x04\x1c\x9c\x0f\x4c\xd1\xae\xcb\xad\x4e\xd1\x8b\x6d\x4e" +
+	"\xd6\xde\x32\x42\x3b\x2d\xa3\x6f\x27\x27\x24\x7c\xe4\x01\xec\x71\xf5\xaf\x4a\xa2\x8a\x8e\x8c\xf9\x9a\x54\x67\x3a\x9c\xf5\x2f\x77" +
+	"\xae\xec\xf9\x7f\xe2\xdf\x83\x1b\xc1\xbe\x31\x97\x4d\x81\x65\x92\xc2\x64\x13\xd9\x49\x27\x2c\xd1\x13\x8d\xa4\xff\x00\x13\x29\x04" +
+	"\x13\xfe\xe9\xef\x5c\xa2\xc2\x77\xf3\xd7\xbe\x38\xaf\x60\xf8\xcd\xa6\x5c\x9d\x2a\x2b\x9d\x5f\x5c\x92\x4b\xbb\x39\x7c\x9b\x38\x64" +
+	"\x43\x27\x9a\xae\x10\xb9\x2c\x3e\x55\x7c\xe4\x85\xc9\xf9\x17\xb1\x15\xe5\x52\x42\xd9\x1f\x95\x79\xd5\xdf\x2c\xac\x7d\x2d\x09\xb9" +
+	"\x41\x5f\x72\xaf\x94\x7a\x6d\x5a\x24\x87\xd3\xbf\x51\x57\x04\x07\xa8\x6c\x1e\xe2\x93\xc8\x3e\x9f\x9d\x73\xfb\x43\x6b\x94\x9a\x11" +
+	"\xd3\xe5\xe2\x93\xc9\x07\xe4\xdd\xf4\x35\x70\xc0\x7f\xfa\xd4\xe5\x88\x0c\x53\xf6\x83\xe6\x28\x88\xfa\xaf\x51\x59\xfe\x21\x5c\x69" +
+	"\x79\xda\xdf\xeb\xa3\xff\x00\xd0\xab\x73\xcb\x19\xc1\xef\xd2\xb2\xbc\x54\xbf\xf1\x29\x6e\xc3\xce\x8b\x1f\xf7\xd5\x6f\x87\x9f\xef" +
+	"\x63\xea\x73\x63\x25\xfb\x89\x2f\x22\xe4\x91\x9f\x32\x4e\xc3\x71\xa6\x6c\x18\xdb\xf3\x55\xc9\x94\x09\x1b\x71\xee\x73\x51\x48\xbe" +
+	"\x95\x97\x3e\xa7\x44\x1d\xe2\x8f\x5f\xfd\x9a\xed\x74\x4d\x46\xfb\x56\x5d\x5e\xc6\xc2\x78\xec\x6c\xa2\x45\x7b\xa5\x52\xbb\xe5\x77" +
+	"\x2e\x49\x6e\x39\xd8\x31\xe8\x2b\xb4\xbc\xf8\x6f\xe1\xd4\xf1\x7c\x1a\xa5\x87\x8b\xad\x74\x78\x6e\x36\x89\x2d\x8c\xa1\xcc\x83\x18" +
+	"\x5f\x2c\xb3\x74\xf4\xce\xe1\xf8\x71\x58\xbf\xb3\xfd\xa7\x87\xf5\xbf\x87\xda\xa6\x87\x78\xb1\x4b\x72\x92\xbb\x4c\x88\xe5\x26\xf2" +
+	"\xd8\x0d\xb9\x61\x83\xb4\x9c\x81\xcf\x5c\xd7\x67\xa5\xe8\xbe\x13\xd5\x35\x4d\x39\x2d\x74\xdb\x44\x6d\x2d\x1a\x04\xf2\x6f\x54\xb8" +
+	"\x05\x48\x00\xec\x72\xdb\x7a\x83\x9e\x7a\x7a\x57\x7a\x84\x66\x95\xd5\xcf\x17\x10\xe4\xaa\x4e\xcd\xaf\xeb\xcc\xf1\x7f\x8f\x3a\x5c" +
+	"\xba\x6e\xb4\xfa\x73\xdc\xcf\x74\x96\x53\x05\x8b\xcc\xc3\x30\x8e\x45\xdc\x18\xb2\xae\x17\x71\x07\x0b\xfe\xc9\xc0\xe2\xbc\xc0\xe3" +
+	"\x77\x1f\x85\x7d\x23\xfb\x4c\xeb\xba\x3d\xb7\x87\x13\xc3\x11\x16\x5b\xe9\xfc\x89\x63\xb7\x48\xfe\x48\xe2\x47\x3f\xbc\x2f\x8e\xbf" +
+	"\x2b\x28\x5c\xe4\xe7\x3d\xab\xe7\x5f\x2f\x1c\x75\x15\x85\x64\xa2\xec\x77\xe5\xb2\x97\xb1\x5c\xc5\x64\x2c\x09\x01\x53\x1e\xfd\x69" +
+	"\xd9\x6f\xee\xc7\x4a\x62\x5c\xff\x00\x15\x1e\x52\xfa\xb5\x45\xd1\xe9\x5c\xd2\x44\xe4\x65\x32\x4d\x48\xf1\xb6\xd0\x3f\x4a\x95\x22" +
+	"\x6f\xa8\xab\x56\xf6\xa4\x88\xcc\x92\xac\x28\xf9\x0a\xef\xdf\x03\x27\x68\xea\x71\xdf\xb5\x71\x45\x4a\x72\xb4\x75\x67\x97\x3a\xd1" +
+	"\xa6\xaf\x27\x63\xe8\xff\x00\x82\xb7\x03\xc4\x1f\x0b\xf4\xb9\xae\x40\x17\xda\x63\xc9\x66\x97\x01\x03\x38\x11\xe3\x6b\x0d\xc0\xf2" +
+	"\x50\xa8\x35\xb7\xac\xdf\x49\xa6\xd8\xaf\x9e\x8a\xd9\x27\x6a\x44\x84\xb1\xe7\x8e\x31\xe9\xef\x5c\xa7\xec\xf3\xe2\xaf\x07\xc1\xa1" +
+	"\x8f\x05\xcb\x3f\xf6\x76\xb3\xe7\xc9\x2a\x7d\xaa\x40\x16\xfc\xb9\xe1\xa3\x63\xc6\xe0\x00\x05\x3a\xfc\xb9\x19\xae\xab\xc4\xd2\xda" +
+	"\x58\xfd\xbb\x51\xf1\x05\xc3\x5b\xe9\x7a\x6c\x66\x49\x8b\xf0\xb8\x1c\xf7\xe7\x27\xa0\x03\xa9\xaf\x79\xd2\x9a\x4b\x9d\x6a\x78\xb8" +
+	"\x7c\x44\x6a\x4a\x6e\x2f\x45\xa9\xe4\x9f\x19\x75\xbd\x13\x52\xd0\xe2\xb2\x82\xe2\x6f\xed\x44\xbb\x8e\x53\x6c\xc8\xc3\x6a\x84\x65" +
+	"\x3d\x78\x5f\xbc\x38\xef\x5e\x4c\xc9\x93\xcf\xe1\x9a\xcc\xb1\xf1\x15\x94\x93\x88\xee\x6d\x9a\xc6\x29\x5d\xb6\xc9\xcb\x79\x7b\x98" +
+	"\xec\xdf\xf5\xe8\x4f\xad\x6f\x88\xd1\xa3\x57\x8e\x48\xe5\x46\x5c\xab\x23\x02\x08\xf6\xaf\x2b\x1d\x4a\xad\x39\x73\x4d\x59\x1e\x96" +
+	"\x13\x19\x42\x7e\xec\x25\x76\x54\x11\xb6\x7e\xf7\x34\x08\x8e\xee\x79\x15\x6b\xec\xfd\x7e\x5e\x68\xf2\x09\x63\x9e\xdf\x9d\x79\xfe" +
+	"\xd0\xee\xe6\x2a\xf9\x78\x61\xc6\x3e\x9c\xd2\xb2\x80\x79\x5a\xb4\x20\xe8\x76\xfe\x14\xef\x2c\x90\x38\x5c\x8a\x3d\xa0\x5c\xa3\xb0" +
+	"\x75\xdb\xf9\xd6\x57\x8b\x14\x7f\x61\x31\x03\xfe\x5b\x45\xf8\x7c\xe2\xba\x2f\x23\x9f\xbd\x9a\xc5\xf1\x9c\x5b\x7c\x3d\x2f\xfd\x76" +
+	"\x88\x67\xfe\x06\x2b\xa3\x09\x34\xeb\xc3\xd5\x18\x62\xdb\x74\x65\xe8\x5d\x78\x46\xe7\xef\xf3\x1c\xd3\x1e\xdb\xb9\x5c\x8f\x4a\xbd" +
+	"\x7e\xf0\x5a\xa3\xc9\x2f\xc8\xa1\xc8\x1e\xa4\x93\xda\xb9\xcb\x8d\x66\x79\x9d\xd2\xc6\x0c\xc7\xd9\xcf\xf1\x56\x94\x30\xf5\xb1\x12" +
+	"\x7e\xcd\x19\x57\xcc\x29\x61\xa2\xb9\xde\xbd\x8f\x54\xfd\x9e\xc5\xd2\x7c\x48\x8a\xd2\xcd\x37\x9b\xbd\x3e\xe1\x24\x88\xfd\xd6\x0a" +
+	"\xbb\x86\xef\x41\xb8\x28\xc9\xee\xde\xf5\xed\x76\x3a\xca\x1f\x11\x4b\xa3\xdb\xe9\x1a\xa0\xbe\x88\x87\x26\x78\x82\xc4\x8a\x70\x38" +
+	"\x63\xc2\x03\xd3\x8e\xbd\xb3\x5f\x23\xf8\x13\xc5\xda\xdf\x81\x7c\x6d\x63\xe2\xcb\x55\x4b\xd9\xad\x8b\xc7\x25\xac\x8c\x55\x26\x89" +
+	"\xd4\x87\x42\xc3\x25\x7a\x64\x1c\x1c\x15\x5a\xf7\x5f\x13\x7e\xd2\x3a\x20\x66\x3e\x1f\xf0\x2e\xab\x3d\xeb\x46\x8a\x8f\xa9\xdd\x45" +
+	"\x14\x0a\x7a\x82\x44\x65\x99\xb1\x9f\x51\xf8\x57\xd0\x52\xcb\x2a\xf2\xc5\x45\xaf\x33\xe7\x6b\xe7\x94\xe7\x29\x4a\x4a\xc8\xcf\xfd" +
+	"\xa7\xb4\x3b\xab\x2f\x19\x69\xda\x84\xd3\x5b\x4a\xb7\xf6\x0b\x1c\x51\xab\xfe\xf4\x34\x4c\xdb\xf2\x87\xa2\xfc\xe3\x07\xeb\xde\xbc" +
+	"\x85\x93\x09\xf7\x38\x3d\xea\xd6\xbb\xaf\xeb\x1e\x29\xf1\x04\xba\xe7\x88\xaf\xda\xef\x52\xb9\x89\xc3\xb2\x2e\xd8\xe2\x8c\x0c\x2a" +
+	"\x46\xbf\xc0\xa3\x3c\x01\xf5\x39\x24\x9a\xcb\xd2\x6f\x98\xe9\x36\x73\x3b\x79\xcf\x22\xe2\x42\x72\x03\x10\x70\x59\xbd\xcf\xe7\x58" +
+	"\xe2\x32\xa9\xca\x57\x8b\xd7\xb1\xb6\x0b\x88\x15\x34\xa1\x52\x3a\x16\x0c\x19\xe4\x8c\x1f\x4c\x52\x79\x1e\xdf\xa5\x68\x08\xe1\x75" +
+	"\x59\xa3\x67\x74\x65\x1c\x7a\x1e\xb4\x79\x71\xff\x00\x71\xeb\xc6\xa9\x1a\x94\xe4\xe3\x25\xaa\x3e\x8e\x9e\x65\x46\xa4\x54\xa3\x2d" +
+	"\x19\xa7\x69\xfd\x9f\x0d\xcc\x27\x52\x65\x8d\x59\xf6\xc6\x87\x38\x76\xeb\x82\x47\x41\xf5\xac\x88\xa6\xb8\xd4\x3c\x6c\xd2\xce\xcc" +
+	"\xd1\xda\x69\x82\x20\xa7\xa0\x63\x29\x0d\xc7\x6e\x52\x93\xc6\x5b\x64\xd3\x64\x89\xf0\xbb\x13\x72\x3e\xdc\x85\x70\x73\xf3\x56\x0e" +
+	"\x87\xaa\xde\x44\xf7\x0c\x57\x33\x4d\x29\xca\x96\x3f\x29\x01\x47\x3f\x4f\xd6\xbe\x8b\x2c\xc2\x46\x95\x08\xca\xda\xbd\x4f\x86\xcc" +
+	"\x31\xd2\xad\x5a\x51\x6f\x44\x74\x9a\xd5\x9a\xde\xc6\xf0\xb2\xa3\x2b\x29\x53\xb9\x41\xc7\x3c\x70\x7d\xeb\x9b\xd4\x2f\xef\xe4\xd1" +
+	"\xed\xf4\x5d\x4f\x5e\xd4\x6e\x61\x4b\x9f\x36\xda\xd6\x7b\xe7\x92\xdd\x73\xc3\x10\x84\xfd\xec\x0e\x3d\x3e\x6f\x5a\xe8\x2c\xf5\x4b" +
+	"\x0b\x6d\x5e\xca\x3d\x76\x29\xee\x6c\x05\xc2\x7d\xb2\x1b\x79\x44\x52\x32\x77\x01\xbf\x85\xb1\xff\x00\xeb\x1d\x6b\xec\x6d\x37\xe0" +
+	"\xa7\xc3\x3b\x0b\x08\xec\x17\xc0\xf6\x1a\x95\xbc\xc0\xb4\xb3\xdd\x96\xb8\x93\x7b\x14\x3b\x89\x90\x9e\x30\xa3\x24\x1c\xfc\xaa\x30" +
+	"\x6b\xd2\x8d\x36\xf4\x67\x17\xb5\xb6\xb1\x67\xc3\x29\x1c\x33\x41\x34\x72\xc7\xba\x36\x4d\xa4\x1e\x72\x0d\x3e\xd2\xf1\xed\x24\x85" +
+	"\x20\x66\x6d\xac\x40\x07\xa6\xe3\xcf\x3e\xc7\xff\x00\x8a\xaf\x65\xfd\xac\x3c\x11\xe1\xef\x01\xb7\x85\xe4\xd0\xbc\x37\x61\xa3\x43" +
+	"\xa8\x41\x3c\x77\x2f\x64\xc4\x27\x9e\xa6\x36\x55\xc1\xeb\xf7\x9f\x0c\x79\xc7\x1d\x85\x7c\xfb\x7f\x76\x60\xba\x82\x66\xea\xd1\xf3" +
+	"\x8e\x9f\x2b\x82\x3f\x9d\x15\xb0\xeb\x96\xd2\xd9\x8e\x15\x5c\x24\xa5\x1d\xcf\x51\xb4\x65\xbc\xb0\x82\xe5\x3f\xe5\xa2\x06\x23\xd0" +
+	"\xf7\x1f\x81\xa9\x7c\x90\xa3\x1d\x6b\x33\xc1\x13\xf9\xfa\x58\x84\xfd\xf4\x66\x75\x1f\xec\xb3\x13\x8f\xc3\x35\xd0\x08\x46\x73\xd0" +
+	"\xd7\xe7\x38\xda\x6f\x0f\x5e\x54\xd9\xf7\x98\x3c\x4f\xb7\xa3\x19\xa2\x97\x94\x3b\x7c\xd4\x34\x47\x03\xe5\xe9\x57\x7c\xbc\x75\xeb" +
+	"\xda\x98\x11\xb1\x9a\xe5\xf6\x87\x52\x91\x53\xcb\xcf\xbf\xb5\x61\xf8\xea\xde\x63\xe1\x6b\x89\x52\x19\x5e\x38\xa7\x83\xcc\x70\x84" +
+	"\xac\x65\xa4\xc0\x0c\x40\xc2\xe7\x07\x19\xeb\x5d\x33\x21\xd8\x73\xd0\x75\x3b\x73\x8f\x7c\x0e\xb5\xea\xfe\x3c\xf8\x4a\x35\x6f\x03" +
+	"\x69\xde\x19\xd0\xf5\x34\xd2\x6d\x25\xbe\x13\x5d\x5c\x6a\x08\xde\x65\xcb\x8c\x81\x26\xdf\xe2\x3c\x0c\x2f\x0a\x01\xeb\x5e\xa6\x57" +
+	"\x4b\xda\xd5\x53\xe9\x16\x8e\x6c\x55\x54\xa0\xe3\xde\xe7\xcd\xfe\x3d\xb9\x2d\xa9\x47\x62\x8c\xa9\xb0\x89\x0f\xcd\xdc\xb6\x7f\xa5" +
+	"\x67\x41\x31\xda\x8b\x33\x22\x4a\xec\x76\x2b\x70\x58\x67\xb0\x35\xf4\x87\x89\x3c\x29\xe1\x4b\xef\x03\x18\x34\x3b\x0b\xd6\xd6\x74" +
+	"\x7d\x42\x4b\xdb\x0b\x9b\x76\x86\x39\xef\xe6\xb6\x29\xe7\x33\x4a\x51\xb1\xce\xe5\x0b\x9d\xbb\x90\x0e\xd5\xd5\x68\x1f\x11\xfc\x17" +
+	"\xe2\x3f\x0d\x58\xcb\xe2\x1b\xdf\x0f\xff\x00\x67\x5f\xc0\x1f\xec\xfa\xad\xdb\x5e\x5c\x06\x38\xc2\x48\xa6\x31\x1a\xb0\xef\x83\xc5" +
+	"\x7d\x66\x1a\x0f\x0d\x46\x31\x48\xf9\x6c\x5d\x05\x89\xaf\x29\x6b\x7f\xe9\x1f\x33\xf8\x63\xc1\x7e\x2f\xf1\x4c\xed\xff\x00\x08\xef" +
+	"\x87\x2f\x75\x45\x0f\xb1\xa4\x8d\x71\x0c\x6d\x8c\x90\xce\xdf\x28\x38\xe7\xaf\xf3\x15\xd9\x5e\x7e\xcf\x3f\x15\xa1\xb5\x8a\xec\x68" +
+	"\x7a\x5e\xe6\x75\x57\x89\x75\x74\x67\x8d\x4a\xa8\x27\x05\x40\x38\x39\x3d\x49\xaf\xac\x3c\x17\x61\xa0\x78\x7f\x4c\x2b\xe1\xcb\x0b" +
+	"\x6d\x3e\xc6\xe1\xcc\xeb\x14\x28\x63\x88\x96\x03\x25\x54\x9f\x97\x38\x19\xc6\x01\xc5\x69\x5f\xcb\x69\xff\x00\x08\xd5\xea\x5b\xc6" +
+	"\xd2\x4d\x0d\xa4\xe2\xdb\xfe\x5a\x32\x92\x8d\xf2\xa9\xc6\x47\xa0\x1e\x9c\x57\x75\x3c\x4d\x39\x69\xb1\xe7\x56\xc2\xca\x3b\x2b\xa3" +
+	"\xf3\xd6\xee\x1b\x8d\x37\x53\x99\x27\x85\x92\x5b\x72\x6d\xde\x12\xc3\x21\x96\x50\x19\x78\xc8\xc8\xd8\x45\x73\xb6\x87\xfd\x27\xec" +
+	"\xf0\x6e\x58\x16\x43\x23\x7a\x7c\xc0\x74\x1f\x8d\x5f\x33\xa8\xb5\x89\x0b\x30\x9a\x24\x11\x48\xaf\xc3\x23\x0e\x08\x60\x79\x04\x1e" +
+	"\xb9\xaa\xfa\x2b\x24\xb7\x52\xc9\xb7\x72\x16\x3f\x4e\x38\x38\xfc\xab\x6b\x27\xaa\x38\x97\x32\xd5\xab\x1d\x9f\x87\x6e\x08\x83\x07" +
+	"\x38\xc7\x19\x20\x1f\xf3\xed\x5a\xdf\x68\x1e\x87\xfe\xfb\x15\x97\xa4\xc7\xc6\xe5\x4c\x7c\x98\xc6\x71\xe9\xfe\x71\x5a\x1b\x4f\xf7" +
+	"\x47\xfd\xf7\x59\xca\x82\x93\xb9\xbc\x6a\xca\x2a\xc6\x5f\x8a\x1b\x29\x22\xab\x2f\x24\x82\x76\x83\x9f\xa6\x6b\x83\xb7\x9d\xec\xd1" +
+	"\xa2\xb2\x6c\xdc\xcf\x3f\x97\x6f\xfe\xc9\x6d\xbc\xfe\x15\xf5\xee\x9b\xfb\x3f\x69\x3e\x25\xf0\x7e\x9d\x7f\x7b\xe2\x7d\x62\xd6\xf2" +
+	"\xfa\xca\x3b\x89\x12\x15\x8b\xca\x89\x9d\x43\x6d\x0a\xca\xc4\xe3\x3b\x7e\xf7\x3e\xd4\xdd\x13\xf6\x55\xf0\x3e\x97\x75\x1d\xf6\xad" +
+	"\xe2\xbd\x72\xf1\xe2\x24\xa8\x06\x28\x17\x24\x7a\x85\x63\xd3\xde\xb0\xa7\x88\xa7\xc8\x92\xe8\x5c\xf0\xd2\x94\x9b\xbe\xe7\xca\xcf" +
+	"\x07\xfc\x7c\x19\xa4\x67\x8f\xcd\x58\xc3\xbc\x98\x2f\x80\x47\xde\x3d\xc9\xaf\xbe\xbc\x0d\xe2\x0f\x18\x6b\x3a\x3e\x9a\xb0\xb6\x9a" +
+	"\x6d\x25\xb5\x53\xfd\xa2\xb6\x8e\x00\x01\x17\x1f\x23\x49\x9d\xcc\x4e\x40\xe7\x01\x4e\xed\xa7\x00\xf3\xde\x1b\xd0\xfe\x13\xf8\x1e" +
+	"\x29\xae\xbc\x3f\xa1\x58\x07\xb6\x42\xf2\xea\x77\x8d\xe6\xb2\x63\xb9\x9a\x4c\x91\xf4\x5a\xcc\xf0\x0f\xc7\x6b\x6f\x1f\x7c\x4d\xb9" +
+	"\xf0\x9e\x87\x6b\x1b\x69\xb6\x96\x13\x5e\xcb\x7c\x4b\x6e\xb9\x08\x55\x76\x46\xbd\x76\xe5\xc1\xcf\x70\xa4\x01\x4d\x62\x65\xab\x84" +
+	"\x4d\x9e\x13\x91\x5e\x5d\x4e\xc3\xe2\xdf\x82\x7c\x3f\xf1\x23\xc3\x36\xda\x37\x88\xf5\x49\xc7\xd8\xa7\x12\xc5\x77\x68\xc9\x1b\xac" +
+	"\xdb\x4a\x67\x04\x15\xc1\xcf\xdd\xaf\x8b\x3e\x24\xfc\x28\xf1\xa6\x8f\xe2\x6b\xed\x1b\x4f\xd1\x6f\x75\x98\x34\xf9\x42\xb5\xd6\x9f" +
+	"\x09\x9f\x2a\xeb\x95\xde\x89\x93\x19\x65\x1b\xb6\x91\xfa\x73\x5f\xa1\x07\x4e\xb5\xd5\x2c\xe4\xb7\x9d\xa6\x44\x0c\xd1\xb2\x45\x21" +
+	"\x8c\x11\xe9\xc7\xb5\x33\x47\xf0\xb6\x8f\xa4\x9b\x96\xb2\x4b\x85\x7b\xa9\x8c\xf3\xbb\x5c\xbb\xb4\x8e\x54\x28\x24\x93\xd0\x05\x00" +
+	"\x0e\x80\x0e\x28\xa4\xab\xca\x29\xb9\x5d\x79\x8d\xca\x8a\x8b\x84\x91\xf9\xfd\xe1\x7b\x6d\x5b\x4d\xbd\xb3\x37\x9a\x4e\xa1\x6a\xa6" +
+	"\x58\xed\xe4\x37\x16\xd2\x42\x80\xc8\xc1\x17\x25\x97\x0b\x92\xc3\xad\x7a\xcb\x78\x46\xeb\x73\x0f\xed\x4d\x24\x95\x24\x70\xf3\x91" +
+	"\x91\xc7\x1f\xba\xaf\xaa\x75\xdf\x0c\x68\x9a\xee\x9b\x36\x9b\xab\x5a\x1b\xab\x49\xca\x19\x22\x69\x58\x06\x2a\xca\xca\x78\x3c\x10" +
+	"\x54\x1e\x2b\x26\x3f\x86\x9e\x0b\x8f\x71\x1a\x64\xc4\xb1\x2c\x73\x7d\x31\xe4\xf2\x7f\x8e\xb8\xf1\x59\x45\x3c\x54\xf9\xea\x47\x5f" +
+	"\x56\x75\xe1\x73\x2f\xab\x47\x92\x17\xb7\xc8\xf9\xa6\x4f\x0d\xb4\x7c\x49\xac\xe9\x2a\x47\x5c\xb4\xe7\xff\x00\x69\x54\x5f\xd8\x10" +
+	"\x8e\x5f\x5f\xd2\x78\xf4\x13\x9f\xfd\xa7\x5f\x48\xcd\xf0\x9b\xe1\xf4\xe7\x32\xf8\x7d\x9c\xfa\x9b\xe9\xff\x00\xf8\xba\x8f\xfe\x14" +
+	"\xff\x00\xc3\x70\x7f\xe4\x58\x8f\xff\x00\x02\xe7\xff\x00\xe2\xeb\x9d\x70\xe6\x1b\xb7\xe2\xce\x9f\xed\xc9\xa3\xe6\xd9\xb4\x7b\x56" +
+	"\x57\x43\xe2\x1d\x38\x96\x04\x71\x0c\xe7\x19\xfa\xa5\x75\x1f\x15\x75\x6d\x1e\xf7\x4f\xd2\xa0\xd1\xa0\xd4\x1f\xc4\x1a\x8a\x45\x61" +
+	"\xa7\x48\xd7\xfe\x44\x65\xdc\x0c\x31\x56\x04\x63\x2b\xc2\xe4\x63\x3d\x85\x7b\x48\xf8\x3b\xf0\xd3\xfe\x85\x58\x8f\xfd\xbc\xcf\xff" +
+	"\x00\xc5\xd3\xa4\xf8\x3d\xf0\xd2\x4d\x42\x1d\x46\x4f\x08\xd9\x35\xe4\x01\x04\x53\x19\x24\xde\x9b\x71\xb7\x07\x77\x51\x8e\xb5\xd1" +
+	"\x47\x26\xa7\x42\xea\x9f\x5f\x52\x65\x9c\xa9\xb4\xdd\xf4\xed\xff\x00\x0e\x78\x2e\x83\xa2\xeb\x1a\x35\xaf\x86\x2c\x75\x9d\x3c\x43" +
+	"\x7d\x69\xa6\x5f\x2c\x8b\x23\x79\xce\x1a\x4b\xa0\x40\xde\x91\xb0\x20\x0d\xa4\x6d\x73\x8e\xe0\x57\xcd\x5e\x0d\xd4\x22\xf0\xed\xce" +
+	"\x97\xa8\x6a\x5a\x55\x86\xa3\x12\x5c\x89\x7e\xc9\x7e\x48\x82\xe3\x6a\xe0\x17\xc6\x37\x28\x6c\x1c\x74\x3b\x70\x78\xaf\xd2\xfb\xcf" +
+	"\x08\x78\x5a\xf2\x63\x71\x77\xe1\xfd\x36\x69\xf0\x54\xcc\xf6\xea\x64\xc6\xed\xe4\x07\xfb\xc3\xe6\xe7\xaf\x5a\xf3\x1f\x8b\x7f\x0b" +
+	"\x3e\x1d\xf8\x6f\xe1\x47\x8b\x75\x7d\x07\xc1\xfa\x45\x8e\xa1\x16\x8f\x72\xb0\xdc\x47\x06\x5e\x3d\xd1\x90\x76\x93\x9c\x12\x09\x19" +
+	"\x15\xe9\xc2\x94\xa2\xcf\x2a\xbe\x22\x12\x49\xab\xa6\xae\x7c\xcb\x17\xed\x1b\xe2\xd3\x14\xc6\xfe\xd2\x0d\x46\xe6\x4b\x96\x95\x66" +
+	"\x17\x73\x40\xb1\x45\xd4\x20\x8a\x36\x0b\xb0\x1c\x0e\xb9\x2b\xf2\xe7\x3f\x35\x7a\x47\xc0\x0f\x8c\x32\x6b\x97\x73\x69\xd7\x93\xc3" +
+	"\x16\xb7\x92\xeb\x0e\xf6\x0b\x75\x1e\x33\x95\x04\xf2\xca\x38\x20\x1d\xd8\xe7\x9e\x71\xf2\x3b\x65\x77\x7c\xae\xa7\x68\x5f\xca\x96" +
+	"\xd2\xe2\x4b\x4b\x98\xaf\x21\x96\x48\xa6\x89\xc3\xc7\x2a\xb1\x56\x8d\xc1\xca\xb0\x61\x82\x08\x3d\x0d\x45\x5a\x31\x9a\xb1\x38\x7c" +
+	"\x64\xe9\xb5\xad\xd7\x54\x7d\xff\x00\xe2\xef\x00\x7c\x3c\xf8\xa2\x92\xcb\xab\x69\xad\x65\xac\x32\xed\x7b\xcb\x46\x11\x5c\x12\x3a" +
+	"\x6e\xe3\x6c\xa0\x7f\xb4\x0d\x70\x7a\x67\xec\xbf\xa7\xd8\x4f\x28\x9b\xc6\x37\xd2\xdb\x02\x3c\x85\x82\xce\x28\xe4\xc6\x4e\x43\x96" +
+	"\x25\x4f\xe1\x8a\xe3\x3e\x17\xfc\x77\xb4\xd5\x6d\x60\xb0\xf1\x7d\xc2\x58\x6b\x11\x61\x56\xf8\x61\x21\xb8\xe3\x86\x24\x70\x8e\x7b" +
+	"\xe7\xe5\x6f\x6e\x95\xf4\x67\x83\xfc\x53\x6f\xaa\x5a\x24\x57\x17\x11\x4b\xb8\x7c\x92\x02\x01\x23\xa7\x63\x5e\x6c\xbd\xb5\x17\xcb" +
+	"\x76\x7b\x11\xa5\x46\xba\xe6\x86\xbe\x47\x82\xfc\x5f\xf8\x6a\x3e\x1a\xe8\x56\x7a\xb6\x9d\xac\x4d\xac\x41\x73\x73\xf6\x63\x6f\x71" +
+	"\x08\x49\x10\xed\x66\xde\x0a\x64\x11\xf2\xe3\xa0\xc6\x7b\xd7\x97\xff\x00\x6f\xde\x7f\xd0\x20\xff\x00\xe3\xdf\xfc\x4d\x7b\xef\xed" +
+	"\x11\xae\x43\x75\xe2\x1b\x1d\x12\xde\x55\x9e\x3d\x3e\x02\xf3\x0c\x8c\x09\x64\xc1\x03\xea\x14\x29\xff\x00\x81\xd7\x97\xef\x8f\xfe" +
+	"\x78\x47\xf9\x8a\xf3\xab\x67\x35\x29\xcd\xc1\x3d\x8e\xaa\x19\x34\x2a\x43\x9d\xf5\xf2\x3d\x87\xc2\xbf\x1e\x7c\x17\xa7\xfc\x3c\x82" +
+	"\xd6\x5d\x4e\x29\x35\x1b\x00\x2d\x96\x11\xb9\x5a\x44\x51\x95\x75\x0c\x01\x23\x1c\x1c\x67\x04\x7d\x2b\xce\x3c\x4b\xfb\x42\xe9\x57" +
+	"\xb2\xba\xcb\xfd\xa1\x73\x22\x93\xb6\x18\xa0\x23\xbf\x4f\x98\x81\xf8\x91\x54\xa3\xf0\x3f\x84\x76\x90\x3c\x35\xa6\xf0\x0e\x0f\x97" +
+	"\x9e\x71\xf5\xaf\x24\x85\x23\x74\x85\x6c\xa1\xd9\x2d\xde\xd4\x57\x2b\xf3\x32\x28\xda\x18\xfa\x67\x9a\xef\xca\x6a\xe1\xf1\x94\xed" +
+	"\x4d\x3b\x47\x4d\x4f\x3f\x19\x3a\xd8\x34\x9a\xb6\xac\xbd\xf1\x03\xc6\x3e\x28\xf1\xb4\x69\x1c\xe9\xfd\x99\xa3\x2b\x6f\x8a\xd3\x79" +
+	"\x21\xf1\xfc\x4e\x7a\xbb\x7e\x18\x15\x4b\xe1\xd6\xbb\xad\xfc\x3f\xf1\x74\x3a\xfe\x87\x71\x6a\xd7\x82\x09\x21\x64\x9e\x36\x78\xe4" +
+	"\x47\x5c\x32\xb0\x56\x07\xdc\x60\xf5\x55\xa9\xb5\x0c\x3d\xef\xd9\xe1\xf9\xa2\x89\x42\xe7\xe9\xfe\x26\xb2\x64\x99\x3f\xb4\x65\x66" +
+	"\x1f\x73\xb5\x7b\xaa\x94\x52\xb5\x8f\x0e\x75\x67\x39\x73\x37\xa9\xf7\xa7\xec\x9d\xe2\xad\x67\xc5\xff\x00\x0a\x8e\xa9\xaf\x3c\x72" +
+	"\x5e\xc5\xa8\x4d\x6a\x64\x45\x2b\xe6\x2a\x2a\x6d\x63\x92\x7e\x6c\x71\x5e\xb9\xde\xbc\x13\xf6\x16\x9c\x4f\xf0\x66\xee\x45\xe8\x35" +
+	"\xbb\x90\x3f\xef\x98\xeb\xdd\x6f\xde\xe2\x2b\x1b\x99\xad\x22\x13\x5c\x24\x4e\xd1\x46\x4e\x03\xbe\x09\x51\xf8\x9e\x2a\x94\x52\xd1" +
+	"\x17\xcc\xe5\xab\xdc\x99\xa8\xae\x6b\xfb\x7e\xda\x3d\x2e\xc7\x50\x8e\x7b\xbb\xb9\xa6\x88\x6e\x40\xa7\x6a\xf2\xa1\xda\x45\xc7\xc8" +
+	"\x53\x24\x91\xc1\x18\x22\x89\xbc\x41\xa8\xa4\x97\xd0\x2e\x9a\xad\x2d\xae\x5a\x03\xce\xdb\xe5\xdc\x32\x23\x3d\x98\x02\x07\x7f\x9b" +
+	"\xda\xaf\xd9\x48\x8e\x64\x74\xb4\xb8\x24\xd7\x15\xaa\xf8\x87\x51\x37\x51\x32\x59\xb6\x6c\xa7\x06\x5b\x58\x58\x99\x43\x9b\x7b\x86" +
+	"\x31\xb8\x1f\x78\x71\x1b\x29\x1c\x31\x38\x1c\x8a\xad\xf1\x76\xea\x59\xbe\x11\xea\xd7\x03\x52\x48\x12\x53\x12\x8b\xab\x4d\xdf\x24" +
+	"\x4d\x3a\x02\xdc\x1c\x9f\x94\xf2\x01\xad\x69\x61\xa5\x3a\x90\x87\xf3\x34\xbe\xf3\x3a\x95\x95\x38\x4a\x76\xd9\x5c\xef\x82\x9a\x30" +
+	"\x6b\xe4\x7f\x87\x6b\x1c\x7e\x2f\x9e\x48\xaf\xe4\xbe\xfb\x0b\x41\x2d\xad\xcf\xce\x01\x6f\x3a\x20\x5b\x69\x6f\x46\x2b\xce\x69\x9a" +
+	"\x70\x17\xb7\x3e\x1b\x59\xb4\xb8\xb5\x85\xf1\x12\x89\xef\xef\x5d\x1c\xca\xf2\x4b\x3b\xac\xcb\x13\xa1\x0b\x17\x94\x06\x78\x1c\x1f" +
+	"\x99\xb8\xaf\x7a\xa7\x0d\xf2\x54\x70\xf6\x9b\x24\xf6\xb7\x46\xfa\xb5\xd1\x7d\xff\x00\x79\xe4\xd1\xcf\x23\x52\x9a\x9f\x25\xaf\x75" +
+	"\xdf\xaa\x5d\x3d\x4f\xaf\x0f\x1c\x57\x13\xf1\xe1\xfc\xbf\x83\x3e\x2f\x7f\xee\xe9\x53\x1f\xfc\x76\xae\x7c\x23\xf9\xbe\x1c\x68\x20" +
+	"\x5e\xbd\xe8\x5b\x50\x89\x70\xec\x58\xca\xaa\x48\x0d\x9f\x70\x05\x7c\xfd\xfb\x5e\x7c\x76\xb7\xb5\x8b\x55\xf8\x65\xe1\xb8\x96\x4b" +
+	"\x96\x06\xdb\x57\xbb\x91\x3e\x58\xd5\x80\x26\x28\xf9\xe5\xf0\x40\x27\xb7\xd6\xbe\x76\xac\x55\x2a\x92\x8b\xe8\xdf\xe0\x7b\x11\x4e" +
+	"\xac\x34\xea\x7c\xcd\x24\x31\x5c\x29\x79\x55\x1b\x0c\x7e\xf5\x45\x6b\x61\x66\x77\xb7\x94\x8a\xc1\xbf\x0c\x1a\xc4\x7b\xdb\xbf\xe3" +
+	"\x99\xd7\xd9\x6a\x28\xf5\x1b\xa8\x9f\x72\x5c\xb7\xb8\x66\xe1\xab\x89\x56\x8d\xf6\x3a\x67\x83\x94\x3a\x97\x75\x5d\x01\x24\x63\x25" +
+	"\x9c\x9b\x5b\x6f\xfa\xb7\xe8\xdf\x43\x5d\x27\xc3\x4d\x7b\xc5\xbe\x15\x9a\x58\x34\x9d\x55\xa0\x99\xd3\x30\x43\x22\xac\xd1\xa4\x98" +
+	"\xc8\xc2\xb0\x21\x58\xe3\x19\x5f\xa5\x66\xdb\x5e\x1b\xe8\x77\x45\x1f\xcc\x3a\x8f\xeb\x4b\x7a\xb7\xb1\x2a\xdd\x34\x36\xf9\xdc\x10" +
+	"\x30\x66\xdd\x9e\xc0\x0e\xe6\xba\x39\x61\x24\x71\xf3\xd4\xa5\x3d\x1d\x8e\xb6\xe7\xe2\x25\x83\x91\x36\xac\xb7\x92\xea\x33\x01\x2d" +
+	"\xcb\x31\x8c\x16\x91\x86\x58\xe0\xb0\xc7\x39\xe3\x8c\x7a\x62\xa1\xff\x00\x85\x87\xa0\x7f\xcf\xb5\xe7\xfd\xf7\x0f\xff\x00\x1c\xae" +
+	"\x93\xc2\x56\xb7\xe9\xa3\x05\xd5\x6d\xad\x9a\x76\x95\xa4\x57\x40\xad\xbd\x08\x5c\x13\xe8\x7a\xe6\xb5\xbe\xce\x9f\xf3\xe9\x1f\xfd" +
+	"\xfa\x5a\xf8\x3c\x5e\x23\x01\x46\xbc\xe0\xe9\xb7\x67\xbf\x31\xf7\x18\x6a\x58\xda\x94\xa3\x2f\x69\x6b\xaf\xe5\x37\xf7\x24\x29\x24" +
+	"\xf3\x02\x11\x10\x92\x47\x70\x01\x3f\xca\xbc\x5e\x15\x8e\xd0\xcd\x38\x53\x18\x82\x21\x0c\x40\x9c\x95\x50\x30\x31\xef\xdf\x35\xec" +
+	"\x9a\x9f\xfc\x82\xae\x7f\xeb\x93\x7f\xe8\x26\xbc\x73\x50\xff\x00\x8f\x6b\xbf\xaf\xf5\xaf\x4b\x84\xbf\x87\x53\xd4\xf2\xb3\xff\x00" +
+	"\x86\x25\x18\x7e\x48\xd9\xa5\xe1\x89\xe7\x1c\x92\x4f\xad\x73\x92\xca\x7f\xb4\x65\xc8\x39\x2b\xcf\x35\xd1\x4f\xf7\x0f\xfb\xc2\xb9" +
+	"\xa9\xbf\xe4\x27\x27\xfb\xb5\xf5\x92\x3e\x7e\x2a\xec\xfb\x77\xf6\x04\x3f\xf1\x65\x6f\x7f\xec\x3b\x73\xff\x00\xa0\x47\x5f\x42\xf1" +
+	"\x9a\xf9\xe7\xf6\x04\xff\x00\x92\x29\x79\xff\x00\x61\xcb\x9f\xfd\x02\x3a\xfa\x17\xbd\x0b\x63\x41\xe1\xb0\x49\x03\x93\x8c\xd0\xdc" +
+	"\xa9\x0c\x38\x23\x9f\xa6\x29\x07\xde\x3f\x85\x2b\xfd\xdf\xf8\x0f\xf4\xa6\x05\x0d\x37\x4d\xb4\xd3\xd1\x3c\x90\xec\x62\x84\x40\xaf" +
+	"\x21\xdc\xc2\x30\x4e\x17\x3d\xf0\x7d\x72\x69\xbe\x22\xd2\x34\xed\x7b\x44\xb9\xd1\xf5\x6b\x7f\xb4\x59\x5c\xa8\x49\xa3\xde\x54\x90" +
+	"\x08\x23\x90\x41\x07\x38\x39\xab\xcd\xf7\x5b\xfd\xe3\xff\x00\xa1\x1a\x1f\xa1\xfa\x8f\xe6\x29\xa9\x4a\x2d\x49\x3d\x48\x92\x4d\x34" +
+	"\xd1\xc2\xe9\x1f\x09\x3c\x0b\xa3\xea\x76\xda\x8d\x86\x9b\x79\x1d\xc5\xbc\x8b\x22\x1f\xed\x19\xd9\x4b\x2b\x06\x50\x54\xbe\x18\x6e" +
+	"\x00\xe0\x8c\x70\x2a\x59\xfe\x16\x78\x32\x66\xd4\x0f\xd9\x35\x18\xa3\xd4\x66\x79\x6e\xe2\x83\x55\xb9\x8a\x29\x59\x89\x2d\x94\x59" +
+	"\x02\xf3\xf4\xe9\xc7\x4a\xed\x9f\xb7\xd4\x7f\xe8\x42\x84\xfb\xad\xf5\x6a\xe9\x79\x86\x2e\x53\xe6\x75\x65\x7b\x6f\x76\x63\x4f\x0f" +
+	"\x46\x31\xb4\x60\x92\xd7\xa2\x38\x4f\x89\xfa\xcd\x8f\xc3\x2f\x83\x9a\x85\xe6\x90\xa2\xc2\x3d\x3a\xcf\xca\xd3\xc0\x43\x22\xc7\x26" +
+	"\x08\x8c\x10\x72\x48\xcf\x52\x73\xef\x5f\x9a\xfa\xcd\xfd\xe6\xa7\xaa\xdc\x6a\x7a\x94\xcd\x71\x7b\x77\x2b\xcf\x71\x21\x1f\x33\x48" +
+	"\x5b\x2e\x7d\x8e\x7d\x38\xaf\xd0\x3f\xdb\x13\xfe\x4d\xff\x00\x59\xff\x00\x7a\x1f\xfd\x0a\xbf\x3d\x67\xff\x00\x5e\xdf\x59\x3f\xf4" +
+	"\x2a\xe3\xae\xdb\x87\x33\x7a\xb6\xce\xca\x1d\x19\x63\x52\xd3\xef\x60\xb4\xb3\xb9\xb9\xb7\x31\x41\x78\xbb\xad\x9c\xba\x9f\x31\x7e" +
+	"\x83\xa7\xd0\x81\x54\xf6\x45\xb7\xbd\x75\xbe\x33\xff\x00\x91\x53\xc1\xdf\xf5\xee\x3f\x95\x72\x07\xee\x9f\xa5\x70\x35\x6d\x11\xe8" +
+	"\x5f\x99\xb6\xfb\x16\xb4\x8b\xd8\xe0\xb9\x56\x65\x0e\x85\x70\xc0\x03\x5d\x26\x9d\x78\xd2\x5f\x2f\x92\xb2\x45\x00\x19\x49\x77\x0d" +
+	"\xe7\xb9\x00\x73\xb4\x63\xd3\x9a\xe2\xed\x3e\xfd\x75\x9a\x2f\x48\x3f\xdc\x3f\xfa\x0d\x75\xd1\xd2\xe8\xf3\xb1\x1e\xf4\x91\xeb\x3e" +
+	"\x18\x9d\x64\xf0\xed\x9b\x15\xda\xb1\xaf\x94\xbb\x54\x0c\x85\x03\x9f\xe7\x5a\x1e\x64\x7f\xed\xfe\x42\xb2\x3c\x25\xff\x00\x22\xb5" +
+	"\xaf\xfb\xcd\x5a\x35\xf9\x86\x3d\x47\xeb\x55\x34\xea\xcf\xb7\xc3\xdf\xd8\xc3\x5e\x88\xff\xd9"
@@ -0,0 +1,69 @@
+package common
+
+import (
+	"math"
+)
+
+// SoftmaxLayer is a dense layer with softmax, logits = xW + b
+type SoftmaxLayer struct {
+	// [features][classes]
+	W [][]float32
+	B []float32
+}
+
+// Predict the class probabilities of x
+func (l *SoftmaxLayer) Predict(x []float32) []float32 {
+	p := make([]float32, len(l.B))
+	copy(p, l.B)
+	for i, v := range x {
+		for c, w := range l.W[i] {
+			p[c] += v * w
+		}
+	}
+	return Softmax(p)
+}
+
+// Softmax turns logits into probabilities, in place
+func Softmax(p []float32) []float32 {
+	if len(p) == 0 {
+		return p
+	}
+	// shift by the max to keep exp finite
+	max := p[0]
+	for _, v := range p {
+		if v > max {
+			max = v
+		}
+	}
+	var sum float64
+	for c := range p {
+		e := math.Exp(float64(p[c] - max))
+		p[c] = float32(e)
+		sum += e
+	}
+	for c := range p {
+		p[c] /= float32(sum)
+	}
+	return p
+}
+
+// Accuracy of the layer on x of classes y
+func (l *SoftmaxLayer) Accuracy(x [][]float32, y []int) float64 {
+	if len(x) == 0 {
+		return 0
+	}
+	right := 0
+	for n := range x {
+		p := l.Predict(x[n])
+		best := 0
+		for c := range p {
+			if p[c] > p[best] {
+				best = c
+			}
+		}
+		if best == y[n] {
+			right++
+		}
+	}
+	return float64(right) / float64(len(x))
+}
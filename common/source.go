@@ -0,0 +1,765 @@
+package common
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// video files decoded by ffmpeg
+var videoExts = map[string]bool{
+	".mp4":  true,
+	".mov":  true,
+	".avi":  true,
+	".mkv":  true,
+	".webm": true,
+}
+
+// extensions that are picked up when walking dirs and archives
+var imageExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".bmp":  true,
+	".webp": true,
+	".tif":  true,
+	".tiff": true,
+	".dcm":  true,
+}
+
+// A single image pulled from a FrameSource
+type Frame struct {
+	Name  string
+	Index int
+	// when the frame was captured, as well as the source knows; the file
+	// mtime, archive entry time or stream pts, see ClockSource
+	Time time.Time
+	Im   image.Image
+	// exif capture time of jpegs, zero when unknown
+	Taken time.Time
+	// georeferencing of geotiffs, nil when unknown
+	Geo *GeoTransform
+	// tracked objects in each zone of the scene
+	Occupancy map[string]int
+	// tracks that crossed each line of the scene so far
+	Crossings map[string]Crossings
+	// place in the sources read, see Checkpoint.Read
+	mark *frameMark
+	// position in its stream by ffmpeg's pts, when hasPTS; videos are
+	// written in its timing, see VideoWriter.WriteFrame
+	pts    time.Duration
+	hasPTS bool
+}
+
+type SourceMeta struct {
+	Kind string
+	URI  string
+	// unbounded sources, eg. rtsp, never return io.EOF on their own
+	Live bool
+}
+
+// FrameSource yields frames until io.EOF
+type FrameSource interface {
+	Next() (*Frame, error)
+	Close() error
+	Meta() SourceMeta
+}
+
+// OpenSource picks a FrameSource implementation from the uri
+//  -                  stdin, a single image or a stream of jpegs
+//  http(s)://...      a single remote image
+//  rtsp(s)://...      a live stream, decoded by ffmpeg
+//  /dev/videoN        a v4l2 camera, decoded by ffmpeg
+//  *.mp4, *.mov, ...  a video file, decoded by ffmpeg
+//  *.zip, *.tar(.gz)  every image in the archive
+//  dir                every image in the dir
+//  dir/*.jpg          every image matching the glob
+//  anything else      a single image file
+func OpenSource(uri string) (FrameSource, error) {
+	switch {
+	case uri == "-":
+		return NewStreamSource("stdin", uri, os.Stdin, nil), nil
+	case strings.HasPrefix(uri, "http://"), strings.HasPrefix(uri, "https://"):
+		return &httpSource{uri: uri}, nil
+	case strings.HasPrefix(uri, "rtsp://"), strings.HasPrefix(uri, "rtsps://"):
+		return NewFfmpegSource("rtsp", uri, "-rtsp_transport", "tcp", "-i", uri)
+	case strings.HasPrefix(uri, "/dev/video"):
+		return NewFfmpegSource("camera", uri, "-f", "v4l2", "-i", uri)
+	case videoExts[strings.ToLower(filepath.Ext(uri))]:
+		src, err := NewFfmpegSource("video", uri, "-i", uri)
+		if err != nil {
+			return nil, err
+		}
+		src.(*streamSource).meta.Live = false
+		return src, nil
+	case strings.HasSuffix(uri, ".zip"):
+		return newZipSource(uri)
+	case strings.HasSuffix(uri, ".tar"), strings.HasSuffix(uri, ".tar.gz"), strings.HasSuffix(uri, ".tgz"):
+		return newTarSource(uri)
+	}
+
+	info, err := os.Stat(uri)
+	if os.IsNotExist(err) && strings.ContainsAny(uri, "*?[") {
+		return newGlobSource(uri)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return newDirSource(uri)
+	}
+	return &fileSource{path: uri}, nil
+}
+
+// Loader decodes a frame, see NextLoader
+type Loader func() (*Frame, error)
+
+// NextLoader takes the next frame of src without decoding it yet, so
+// frames can be decoded on other goroutines than the one reading src. Only
+// dirs, globs and zips defer decoding, other sources decode right away.
+func NextLoader(src FrameSource) (Loader, error) {
+	if l, ok := src.(interface{ nextLoader() (Loader, error) }); ok {
+		return l.nextLoader()
+	}
+	frame, err := src.Next()
+	if err != nil {
+		return nil, err
+	}
+	return func() (*Frame, error) { return frame, nil }, nil
+}
+
+func isImage(name string) bool {
+	return imageExts[strings.ToLower(filepath.Ext(name))]
+}
+
+//
+// file
+//
+
+type fileSource struct {
+	path string
+	done bool
+}
+
+func (s *fileSource) Next() (*Frame, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+	s.done = true
+	return loadFrame(s.path, 0)
+}
+
+func (s *fileSource) Close() error     { return nil }
+func (s *fileSource) Meta() SourceMeta { return SourceMeta{Kind: "file", URI: s.path} }
+
+func loadFrame(path string, idx int) (*Frame, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	im, exif, err := readImage(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &Frame{Name: path, Index: idx, Time: info.ModTime(), Im: im, Taken: exif.taken(), Geo: exif.geo()}, nil
+}
+
+//
+// dir
+//
+
+type dirSource struct {
+	dir   string
+	files []string
+	i     int
+	n     int
+	keep  func(name string) bool
+}
+
+func newDirSource(dir string) (*dirSource, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if !info.IsDir() && isImage(info.Name()) {
+			files = append(files, filepath.Join(dir, info.Name()))
+		}
+	}
+	sort.Strings(files)
+	return &dirSource{dir: dir, files: files}, nil
+}
+
+// the images matching a glob, as a dir of them
+func newGlobSource(pattern string) (*dirSource, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", pattern, err)
+	}
+	var files []string
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && !info.IsDir() && isImage(m) {
+			files = append(files, m)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: no images match", pattern)
+	}
+	sort.Strings(files)
+	return &dirSource{dir: pattern, files: files}, nil
+}
+
+func (s *dirSource) Next() (*Frame, error) {
+	load, err := s.nextLoader()
+	if err != nil {
+		return nil, err
+	}
+	return load()
+}
+
+func (s *dirSource) nextLoader() (Loader, error) {
+	for ; s.i < len(s.files); s.i++ {
+		if s.keep != nil && !s.keep(filepath.Base(s.files[s.i])) {
+			continue
+		}
+		s.i++
+		s.n++
+		path, idx := s.files[s.i-1], s.n-1
+		return func() (*Frame, error) { return loadFrame(path, idx) }, nil
+	}
+	return nil, io.EOF
+}
+
+func (s *dirSource) setFilter(keep func(name string) bool) { s.keep = chain(s.keep, keep) }
+
+func (s *dirSource) Close() error     { return nil }
+func (s *dirSource) Meta() SourceMeta { return SourceMeta{Kind: "dir", URI: s.dir} }
+
+//
+// archives
+//
+
+type zipSource struct {
+	path string
+	r    *zip.ReadCloser
+	i    int
+	n    int
+	keep func(name string) bool
+}
+
+func newZipSource(path string) (*zipSource, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	return &zipSource{path: path, r: r}, nil
+}
+
+func (s *zipSource) Next() (*Frame, error) {
+	load, err := s.nextLoader()
+	if err != nil {
+		return nil, err
+	}
+	return load()
+}
+
+// zip entries read at their own offsets, so can be decoded in parallel
+func (s *zipSource) nextLoader() (Loader, error) {
+	for ; s.i < len(s.r.File); s.i++ {
+		f := s.r.File[s.i]
+		if f.FileInfo().IsDir() || !isImage(f.Name) || (s.keep != nil && !s.keep(f.Name)) {
+			continue
+		}
+		s.i++
+		s.n++
+		idx := s.n - 1
+		return func() (*Frame, error) {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			im, exif, err := readImage(rc)
+			rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f.Name, err)
+			}
+			return &Frame{Name: f.Name, Index: idx, Time: f.Modified, Im: im, Taken: exif.taken(), Geo: exif.geo()}, nil
+		}, nil
+	}
+	return nil, io.EOF
+}
+
+func (s *zipSource) setFilter(keep func(name string) bool) { s.keep = chain(s.keep, keep) }
+
+func (s *zipSource) Close() error     { return s.r.Close() }
+func (s *zipSource) Meta() SourceMeta { return SourceMeta{Kind: "archive", URI: s.path} }
+
+type tarSource struct {
+	path string
+	f    *os.File
+	r    *tar.Reader
+	n    int
+	keep func(name string) bool
+}
+
+func newTarSource(path string) (*tarSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader = f
+	if !strings.HasSuffix(path, ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		r = gz
+	}
+	return &tarSource{path: path, f: f, r: tar.NewReader(r)}, nil
+}
+
+func (s *tarSource) Next() (*Frame, error) {
+	for {
+		h, err := s.r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if h.Typeflag != tar.TypeReg || !isImage(h.Name) || (s.keep != nil && !s.keep(h.Name)) {
+			continue
+		}
+		im, exif, err := readImage(s.r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", h.Name, err)
+		}
+		s.n++
+		return &Frame{Name: h.Name, Index: s.n - 1, Time: h.ModTime, Im: im, Taken: exif.taken(), Geo: exif.geo()}, nil
+	}
+}
+
+func (s *tarSource) setFilter(keep func(name string) bool) { s.keep = chain(s.keep, keep) }
+
+func (s *tarSource) Close() error     { return s.f.Close() }
+func (s *tarSource) Meta() SourceMeta { return SourceMeta{Kind: "archive", URI: s.path} }
+
+//
+// http
+//
+
+type httpSource struct {
+	uri  string
+	done bool
+}
+
+func (s *httpSource) Next() (*Frame, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+	s.done = true
+
+	resp, err := http.Get(s.uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", s.uri, resp.Status)
+	}
+	im, exif, err := readImage(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", s.uri, err)
+	}
+	at := time.Now()
+	if lm, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		at = lm
+	}
+	return &Frame{Name: s.uri, Time: at, Im: im, Taken: exif.taken(), Geo: exif.geo()}, nil
+}
+
+func (s *httpSource) Close() error     { return nil }
+func (s *httpSource) Meta() SourceMeta { return SourceMeta{Kind: "http", URI: s.uri} }
+
+//
+// streams; stdin and anything ffmpeg can decode
+//
+
+type streamSource struct {
+	meta   SourceMeta
+	r      *bufio.Reader
+	closer io.Closer
+	n      int
+	// presentation time of each frame from ffmpeg, relative to start
+	pts   *ptsIndex
+	start time.Time
+}
+
+// NewStreamSource reads either a single image, or a stream of concatenated jpegs
+func NewStreamSource(kind, uri string, r io.Reader, closer io.Closer) FrameSource {
+	return &streamSource{
+		meta:   SourceMeta{Kind: kind, URI: uri},
+		r:      bufio.NewReader(r),
+		closer: closer,
+	}
+}
+
+func (s *streamSource) Next() (*Frame, error) {
+	soi, err := s.r.Peek(2)
+	if err == io.EOF && s.n == 0 && s.meta.Kind == "stdin" {
+		// eg. the curl before it in a pipeline failed
+		return nil, fmt.Errorf("stdin: no image, it was empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var im image.Image
+	var exif *Exif
+	if soi[0] == 0xFF && soi[1] == 0xD8 {
+		b, err := nextJpeg(s.r)
+		if err != nil {
+			return nil, err
+		}
+		im, exif, err = readImage(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// not a jpeg stream, only a single image is supported
+		if s.n > 0 {
+			return nil, io.EOF
+		}
+		im, exif, err = readImage(s.r)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	frame := &Frame{
+		Name:  fmt.Sprintf("%s-%d", s.meta.Kind, s.n),
+		Index: s.n,
+		Time:  time.Now(),
+		Im:    im,
+		Taken: exif.taken(),
+		Geo:   exif.geo(),
+	}
+	if s.pts != nil {
+		// showinfo output lost falls back to the wall clock
+		if pts, ok := s.pts.get(s.n, 100*time.Millisecond); ok {
+			frame.Time, frame.pts, frame.hasPTS = s.start.Add(pts), pts, true
+		}
+	}
+	s.n++
+	return frame, nil
+}
+
+func (s *streamSource) Close() error {
+	if s.closer != nil {
+		return s.closer.Close()
+	}
+	return nil
+}
+
+func (s *streamSource) Meta() SourceMeta { return s.meta }
+
+// read a single jpeg, SOI through EOI, off the stream. The segments of the
+// headers are skipped by their length, as an exif thumbnail in APP1 is a
+// jpeg with an EOI of its own, and the entropy coded data of each scan is
+// scanned for the marker ending it.
+func nextJpeg(r *bufio.Reader) ([]byte, error) {
+	buf := bytes.Buffer{}
+	read := func(n int) ([]byte, error) {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			if err == io.EOF && buf.Len() > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		buf.Write(b)
+		return b, nil
+	}
+	if _, err := read(2); err != nil {
+		return nil, err
+	}
+	// the marker after SOI, or after the data of a scan
+	var marker byte
+	for {
+		if marker == 0 {
+			b, err := read(2)
+			if err != nil {
+				return nil, err
+			}
+			if b[0] != 0xFF {
+				return nil, fmt.Errorf("bad jpeg marker %#x%02x", b[0], b[1])
+			}
+			marker = b[1]
+		}
+		switch {
+		case marker == 0xD9:
+			return buf.Bytes(), nil
+		case marker == 0xFF:
+			// fill byte before a marker
+			b, err := read(1)
+			if err != nil {
+				return nil, err
+			}
+			marker = b[0]
+			continue
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7:
+			// no length
+			marker = 0
+			continue
+		}
+		size, err := read(2)
+		if err != nil {
+			return nil, err
+		}
+		n := int(size[0])<<8 | int(size[1])
+		if n < 2 {
+			return nil, fmt.Errorf("bad jpeg segment length %d", n)
+		}
+		if _, err := read(n - 2); err != nil {
+			return nil, err
+		}
+		if marker != 0xDA {
+			marker = 0
+			continue
+		}
+		// the entropy coded data of the scan, up to the next marker that
+		// isn't a stuffed 0xFF or a restart
+		if marker, err = scanJpeg(r, &buf); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// scanJpeg copies entropy coded data to buf up to the next marker, returning
+// it; the marker is written too
+func scanJpeg(r *bufio.Reader, buf *bytes.Buffer) (byte, error) {
+	var prev byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		buf.WriteByte(b)
+		if prev == 0xFF && b != 0x00 && b != 0xFF && (b < 0xD0 || b > 0xD7) {
+			return b, nil
+		}
+		prev = b
+	}
+}
+
+type ffmpeg struct {
+	cmd *exec.Cmd
+}
+
+func (f *ffmpeg) Close() error {
+	f.cmd.Process.Kill()
+	f.cmd.Wait()
+	return nil
+}
+
+// NewFfmpegSource decodes the input described by args with ffmpeg and
+// reads it back as an mjpeg stream. Frames are timed by their pts, reported
+// by the showinfo filter, from when the stream was opened.
+func NewFfmpegSource(kind, uri string, args ...string) (FrameSource, error) {
+	args = append([]string{"-loglevel", "level+info"}, args...)
+	hasvf := false
+	for i := range args {
+		if args[i] == "-vf" && i+1 < len(args) {
+			args[i+1] += ",showinfo"
+			hasvf = true
+		}
+	}
+	if !hasvf {
+		args = append(args, "-vf", "showinfo")
+	}
+	// every frame showinfo numbers is written, once, so its n is the index
+	// of the frame read
+	args = append(args, "-vsync", "passthrough", "-f", "image2pipe", "-vcodec", "mjpeg", "-q:v", "2", "-")
+
+	cmd := exec.Command("ffmpeg", args...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	src := NewStreamSource(kind, uri, stdout, &ffmpeg{cmd}).(*streamSource)
+	src.meta.Live = true
+	src.start = time.Now()
+	src.pts = &ptsIndex{pts: map[int]time.Duration{}, added: make(chan struct{})}
+	go readShowinfo(stderr, src.pts)
+	return src, nil
+}
+
+// ptsIndex holds the pts showinfo reports by the index of the frame, so a
+// line read late or lost never times another frame
+type ptsIndex struct {
+	mu  sync.Mutex
+	pts map[int]time.Duration
+	// closed and replaced on each put
+	added chan struct{}
+}
+
+func (p *ptsIndex) put(n int, pts time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pts[n] = pts
+	close(p.added)
+	p.added = make(chan struct{})
+}
+
+// get the pts of frame n, waiting up to wait for it; the pts of frames up to
+// n are dropped, they are read
+func (p *ptsIndex) get(n int, wait time.Duration) (time.Duration, bool) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		p.mu.Lock()
+		pts, ok := p.pts[n]
+		added := p.added
+		if ok {
+			p.drop(n)
+		}
+		p.mu.Unlock()
+		if ok {
+			return pts, true
+		}
+		select {
+		case <-added:
+		case <-timer.C:
+			p.mu.Lock()
+			p.drop(n)
+			p.mu.Unlock()
+			return 0, false
+		}
+	}
+}
+
+func (p *ptsIndex) drop(n int) {
+	for k := range p.pts {
+		if k <= n {
+			delete(p.pts, k)
+		}
+	}
+}
+
+// pass on the pts_time of each frame by its n, and any warnings or errors
+func readShowinfo(r io.Reader, pts *ptsIndex) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "pts_time:"); i >= 0 && strings.Contains(line, "showinfo") {
+			n, nok := showinfoField(line, "n:")
+			f := strings.Fields(line[i+len("pts_time:"):])
+			if nok && len(f) > 0 {
+				if sec, err := strconv.ParseFloat(f[0], 64); err == nil {
+					pts.put(n, time.Duration(sec*float64(time.Second)))
+				}
+			}
+			continue
+		}
+		if strings.Contains(line, "[warning]") || strings.Contains(line, "[error]") || strings.Contains(line, "[fatal]") {
+			fmt.Fprintln(os.Stderr, line)
+		}
+	}
+}
+
+// the integer after key in a showinfo line, eg. n:  12, padded by spaces
+func showinfoField(line, key string) (int, bool) {
+	i := strings.Index(line, " "+key)
+	if i < 0 {
+		return 0, false
+	}
+	f := strings.Fields(line[i+1+len(key):])
+	if len(f) == 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(f[0])
+	return n, err == nil
+}
+
+// ClockSource picks the timestamp of the frames of src
+//
+//	source  the time the source gives, see Frame.Time
+//	exif    the exif capture time of jpegs, falling back to source
+//	wall    the time the frame was read
+func ClockSource(src FrameSource, clock string) (FrameSource, error) {
+	switch clock {
+	case "", "source":
+		return src, nil
+	case "exif", "wall":
+		return &clockSource{src, clock}, nil
+	}
+	return nil, fmt.Errorf("unsupported clock %q, expected source, exif or wall", clock)
+}
+
+type clockSource struct {
+	FrameSource
+	clock string
+}
+
+func (s *clockSource) Next() (*Frame, error) {
+	frame, err := s.FrameSource.Next()
+	if err != nil {
+		return nil, err
+	}
+	return s.stamp(frame), nil
+}
+
+func (s *clockSource) nextLoader() (Loader, error) {
+	load, err := NextLoader(s.FrameSource)
+	if err != nil {
+		return nil, err
+	}
+	return func() (*Frame, error) {
+		frame, err := load()
+		if err != nil {
+			return nil, err
+		}
+		return s.stamp(frame), nil
+	}, nil
+}
+
+func (s *clockSource) stamp(frame *Frame) *Frame {
+	switch {
+	case s.clock == "wall":
+		frame.Time = time.Now()
+	case s.clock == "exif" && !frame.Taken.IsZero():
+		frame.Time = frame.Taken
+	}
+	return frame
+}
@@ -0,0 +1,221 @@
+package common
+
+import (
+	"fmt"
+	"image/jpeg"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// frames of a SpillSource kept in memory before spilling to disk
+const spillMemory = 8
+
+// SpillSource reads a live source as fast as it produces frames, queueing
+// them while detection falls behind rather than leaving the stream to back
+// up and drop frames. The first frames of a backlog are held in memory and
+// the rest spilled to dir as jpegs, bounded by maxBytes on disk, dropping
+// the oldest spilled frames past it, and by maxAge, dropping frames queued
+// longer than it. The queue drains once the burst is over. Frames aren't
+// spilled in private mode, it returns ErrPrivate. Frames left spilled by a
+// run that crashed are removed, they're too late to run.
+func SpillSource(src FrameSource, dir string, maxBytes int64, maxAge time.Duration) (FrameSource, error) {
+	if Private() {
+		return nil, ErrPrivate
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	left, err := filepath.Glob(filepath.Join(dir, "spill-*.jpg"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range left {
+		if err := os.Remove(file); err != nil {
+			return nil, err
+		}
+	}
+	if len(left) > 0 {
+		log.Printf("spill: removed %d frames left in %s", len(left), dir)
+	}
+	s := &spillSource{FrameSource: src, dir: dir, maxBytes: maxBytes, maxAge: maxAge}
+	s.ready = sync.NewCond(&s.mu)
+	go s.read()
+	return s, nil
+}
+
+type spillSource struct {
+	FrameSource
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+
+	mu      sync.Mutex
+	ready   *sync.Cond
+	queue   []*spilled
+	inMem   int
+	onDisk  int64
+	err     error
+	seq     int
+	dropped int
+	closed  bool
+}
+
+// a queued frame, with its image either in memory or in file
+type spilled struct {
+	frame  *Frame
+	file   string
+	size   int64
+	queued time.Time
+}
+
+func (s *spillSource) read() {
+	for {
+		frame, err := s.FrameSource.Next()
+		s.mu.Lock()
+		if err != nil {
+			s.err = err
+			s.ready.Broadcast()
+			s.mu.Unlock()
+			return
+		}
+		q := &spilled{frame: frame, queued: time.Now()}
+		spill := s.inMem >= spillMemory
+		if spill {
+			s.seq++
+			q.file = filepath.Join(s.dir, fmt.Sprintf("spill-%09d.jpg", s.seq))
+		} else {
+			s.inMem++
+		}
+		s.mu.Unlock()
+
+		// encoded without holding up pop, frames are only queued here so
+		// they stay in order
+		if spill {
+			if err := spillFrame(q); err != nil {
+				log.Println("spill:", err)
+				s.mu.Lock()
+				s.dropped++
+				s.mu.Unlock()
+				continue
+			}
+		}
+		s.mu.Lock()
+		if s.closed {
+			if spill {
+				os.Remove(q.file)
+			}
+			s.mu.Unlock()
+			return
+		}
+		s.onDisk += q.size
+		s.queue = append(s.queue, q)
+		s.trim()
+		s.ready.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+// write the image of q to its file
+func spillFrame(q *spilled) error {
+	f, err := os.Create(q.file)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(f, q.frame.Im, &jpeg.Options{Quality: 95}); err != nil {
+		f.Close()
+		os.Remove(q.file)
+		return err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		q.size = info.Size()
+	}
+	f.Close()
+	q.frame.Im = nil
+	return nil
+}
+
+// drop the oldest spilled frames past maxBytes, called with mu held
+func (s *spillSource) trim() {
+	for i := 0; i < len(s.queue) && s.maxBytes > 0 && s.onDisk > s.maxBytes; {
+		if s.queue[i].file == "" {
+			i++
+			continue
+		}
+		s.drop(i)
+	}
+}
+
+func (s *spillSource) drop(i int) {
+	q := s.queue[i]
+	if q.file != "" {
+		os.Remove(q.file)
+		s.onDisk -= q.size
+	} else {
+		s.inMem--
+	}
+	s.queue = append(s.queue[:i], s.queue[i+1:]...)
+	s.dropped++
+}
+
+func (s *spillSource) Next() (*Frame, error) {
+	q, err := s.pop()
+	if err != nil || q.file == "" {
+		return q.frame, err
+	}
+	// decoded without holding up the reader
+	defer os.Remove(q.file)
+	im, err := LoadJpeg(q.file)
+	if err != nil {
+		return nil, err
+	}
+	q.frame.Im = im
+	return q.frame, nil
+}
+
+// the oldest queued frame, waiting for one
+func (s *spillSource) pop() (*spilled, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		for len(s.queue) > 0 && s.maxAge > 0 && time.Since(s.queue[0].queued) > s.maxAge {
+			s.drop(0)
+		}
+		if len(s.queue) > 0 {
+			break
+		}
+		if s.err != nil {
+			return &spilled{}, s.err
+		}
+		s.ready.Wait()
+	}
+	if s.dropped > 0 {
+		log.Printf("spill: dropped %d frames past the size or age limits", s.dropped)
+		s.dropped = 0
+	}
+	q := s.queue[0]
+	s.queue = s.queue[1:]
+	if q.file == "" {
+		s.inMem--
+	} else {
+		s.onDisk -= q.size
+	}
+	return q, nil
+}
+
+// Close stops the source and removes the frames still spilled
+func (s *spillSource) Close() error {
+	err := s.FrameSource.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, q := range s.queue {
+		if q.file != "" {
+			os.Remove(q.file)
+		}
+	}
+	s.queue, s.closed = nil, true
+	return err
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// ReconnectSource keeps a live stream, such as an rtsp camera or a
+// /dev/videoN device, open for good: when it fails, ends, or yields no frame
+// for stall, as cameras drop off the network and rtsp servers restart, it's
+// reopened after a second, backing off to a minute while it keeps failing.
+// It never returns io.EOF, frames are numbered on across reconnects.
+func ReconnectSource(uri string, stall time.Duration) (FrameSource, error) {
+	src, err := OpenSource(uri)
+	if err != nil {
+		return nil, err
+	}
+	return &reconnectSource{uri: uri, stall: stall, src: src, meta: src.Meta()}, nil
+}
+
+// StreamNames names the frames of a stream after it rather than its kind,
+// eg. rtsp://cam1/live#12 rather than rtsp-12, the credentials of the uri
+// stripped, so the output of several cameras kept apart can be told apart.
+// Other sources, whose frames are named by their files, are returned as
+// they are.
+func StreamNames(src FrameSource) FrameSource {
+	switch src.Meta().Kind {
+	case "rtsp", "camera", "video", "screen":
+		return &streamNames{src, StripCredentials(src.Meta().URI)}
+	}
+	return src
+}
+
+type streamNames struct {
+	FrameSource
+	name string
+}
+
+func (s *streamNames) Next() (*Frame, error) {
+	frame, err := s.FrameSource.Next()
+	if frame != nil {
+		frame.Name = fmt.Sprintf("%s#%d", s.name, frame.Index)
+	}
+	return frame, err
+}
+
+type reconnectSource struct {
+	uri     string
+	stall   time.Duration
+	src     FrameSource
+	meta    SourceMeta
+	n       int
+	backoff time.Duration
+	// the pts of a reopened stream start over, they're carried on from the
+	// last frame by the time the stream was down
+	rebase  bool
+	offset  time.Duration
+	lastPTS time.Duration
+	lastAt  time.Time
+}
+
+func (r *reconnectSource) Next() (*Frame, error) {
+	for {
+		if r.src == nil {
+			if r.backoff == 0 {
+				r.backoff = time.Second
+			} else if r.backoff *= 2; r.backoff > time.Minute {
+				r.backoff = time.Minute
+			}
+			time.Sleep(r.backoff)
+			src, err := OpenSource(r.uri)
+			if err != nil {
+				log.Printf("%s: %v, reconnecting in %v", RedactName(StripCredentials(r.uri)), err, r.backoff)
+				continue
+			}
+			r.src = src
+		}
+		// a stalled stream is closed under Next, which makes it fail
+		src := r.src
+		stalled := time.AfterFunc(r.stall, func() { src.Close() })
+		frame, err := src.Next()
+		fired := !stalled.Stop()
+		if err == nil {
+			r.backoff = 0
+			frame.Name, frame.Index = fmt.Sprintf("%s-%d", r.meta.Kind, r.n), r.n
+			r.n++
+			if frame.hasPTS {
+				if r.rebase {
+					r.offset, r.rebase = r.lastPTS+time.Since(r.lastAt)-frame.pts, false
+				}
+				frame.pts += r.offset
+				r.lastPTS, r.lastAt = frame.pts, time.Now()
+			}
+			return frame, nil
+		}
+		if fired {
+			err = fmt.Errorf("no frame for %v", r.stall)
+		} else {
+			src.Close()
+			if err == io.EOF {
+				err = errors.New("stream ended")
+			}
+		}
+		log.Printf("%s: %v, reconnecting", RedactName(StripCredentials(r.uri)), err)
+		r.src, r.rebase = nil, r.n > 0
+	}
+}
+
+func (r *reconnectSource) Close() error {
+	if r.src == nil {
+		return nil
+	}
+	return r.src.Close()
+}
+
+func (r *reconnectSource) Meta() SourceMeta {
+	meta := r.meta
+	meta.Live = true
+	return meta
+}
@@ -0,0 +1,258 @@
+package common
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/colornames"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+// Style is how annotated images draw detections
+type Style struct {
+	// colors of classes, BoxColor for the rest
+	Colors map[CID]color.RGBA
+	// box outline in pixels
+	Width int
+	// size of the tag font in points, 0 for the 7x13 bitmap font
+	FontSize float64
+	// what boxes are tagged with, class, score and track joined by +, or
+	// none
+	Label string
+	// opacity boxes are shaded with, 0 for outlines only. The detection
+	// models here predict no instance masks, the box is the mask.
+	Fill float64
+	// color boxes by the bucket of their confidence rather than their
+	// class, with a legend of the buckets in the top left corner
+	ByConfidence bool
+	// draw the histogram of the frame's scores in the bottom right corner
+	Histogram bool
+
+	once sync.Once
+	face font.Face
+}
+
+// DefaultStyle outlines boxes in 2 pixels tagged with class and score
+func DefaultStyle() *Style {
+	return &Style{Width: 2, Label: "class+score"}
+}
+
+// ParseColors reads class colors, eg. car=red,3=#00ff88, naming classes by
+// label or id and colors by name or hex
+func ParseColors(s string, labels Labels) (map[CID]color.RGBA, error) {
+	colors := make(map[CID]color.RGBA)
+	if s == "" {
+		return colors, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		i := strings.LastIndex(kv, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("invalid class color %q, expected class=color", kv)
+		}
+		class, err := findClass(kv[:i], labels)
+		if err != nil {
+			return nil, err
+		}
+		c, err := ParseColor(kv[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		colors[class] = c
+	}
+	return colors, nil
+}
+
+func findClass(name string, labels Labels) (CID, error) {
+	for c, l := range labels {
+		if l == name {
+			return c, nil
+		}
+	}
+	id, err := strconv.Atoi(name)
+	if err != nil {
+		return 0, fmt.Errorf("unknown class %q, expected a label or id", name)
+	}
+	return CID(id), nil
+}
+
+// ParseColor reads a color by name, eg. red, or as #rrggbb
+func ParseColor(s string) (color.RGBA, error) {
+	if c, ok := colornames.Map[strings.ToLower(s)]; ok {
+		return c, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, expected a name or #rrggbb", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
+
+// Check the label format
+func (s *Style) Check() error {
+	if s.Label == "none" {
+		return nil
+	}
+	for _, part := range strings.Split(s.Label, "+") {
+		if part != "class" && part != "score" && part != "track" {
+			return fmt.Errorf("unsupported label %q, expected class, score and track joined by +, or none", s.Label)
+		}
+	}
+	return nil
+}
+
+// Color of a class
+func (s *Style) Color(c CID) color.RGBA {
+	if col, ok := s.Colors[c]; ok {
+		return col
+	}
+	return BoxColor(c)
+}
+
+// confidence buckets of ByConfidence, from the lowest
+var confidenceColors = []color.RGBA{colornames.Red, colornames.Orangered, colornames.Orange, colornames.Yellowgreen, colornames.Lime}
+
+func confidenceBucket(c float32) int {
+	b := int(c * float32(len(confidenceColors)))
+	if b < 0 {
+		return 0
+	}
+	if b >= len(confidenceColors) {
+		return len(confidenceColors) - 1
+	}
+	return b
+}
+
+// the color of a detection's box
+func (s *Style) boxColor(d Detect) color.RGBA {
+	if s.ByConfidence {
+		return confidenceColors[confidenceBucket(d.Confidence)]
+	}
+	return s.Color(d.Class)
+}
+
+// Tag is the text a detection is tagged with, empty for none
+func (s *Style) Tag(d Detect, labels Namer, scores ScoreFormat) string {
+	if s.Label == "none" {
+		return ""
+	}
+	var parts []string
+	for _, part := range strings.Split(s.Label, "+") {
+		switch {
+		case part == "class":
+			parts = append(parts, labels.Name(d.Class))
+		case part == "score":
+			parts = append(parts, scores.String(d.Confidence))
+		case part == "track" && d.Track > 0:
+			parts = append(parts, fmt.Sprintf("#%d", d.Track))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Annotate draws the detection boxes over a copy of im in the style
+func (s *Style) Annotate(im image.Image, detects []Detect, labels Namer, scores ScoreFormat) *image.RGBA {
+	rgba := image.NewRGBA(im.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), im, im.Bounds().Min, draw.Src)
+	for _, d := range detects {
+		if s.Fill > 0 {
+			c := s.boxColor(d)
+			a := uint8(s.Fill * 255)
+			// premultiplied
+			shade := color.RGBA{uint8(int(c.R) * int(a) / 255), uint8(int(c.G) * int(a) / 255), uint8(int(c.B) * int(a) / 255), a}
+			draw.Draw(rgba, d.Bounds, image.NewUniform(shade), image.ZP, draw.Over)
+		}
+		StrokeRect(rgba, d.Bounds, s.Width, s.boxColor(d))
+	}
+	if labels != nil {
+		for _, d := range detects {
+			if tag := s.Tag(d, labels, scores); tag != "" {
+				drawTag(rgba, d.Bounds.Min, tag, s.boxColor(d), s.fontFace())
+			}
+		}
+	}
+	if s.ByConfidence {
+		s.drawLegend(rgba, scores)
+	}
+	if s.Histogram {
+		drawHistogram(rgba, detects)
+	}
+	return rgba
+}
+
+// the confidence buckets, highest first, down the top left corner
+func (s *Style) drawLegend(dst *image.RGBA, scores ScoreFormat) {
+	face := s.fontFace()
+	h := face.Metrics().Height.Ceil() + 2
+	n := len(confidenceColors)
+	// bucket bounds in the percents or probabilities of the tags, to a
+	// digit
+	bounds := ScoreFormat{Precision: 1, Percent: scores.Percent}
+	if scores.Percent {
+		bounds.Precision = 0
+	}
+	for i := range confidenceColors {
+		b := n - 1 - i
+		lo, hi := float32(b)/float32(n), float32(b+1)/float32(n)
+		p := dst.Bounds().Min.Add(image.Pt(4, 4+(i+1)*h))
+		drawTag(dst, p, bounds.String(lo)+"-"+bounds.String(hi), confidenceColors[b], face)
+	}
+}
+
+// a histogram of the scores in 10 bins over [0,1], bars in the colors of
+// their buckets, over a shaded panel in the bottom right corner
+func drawHistogram(dst *image.RGBA, detects []Detect) {
+	const bins, bar, height, pad = 10, 6, 40, 4
+	var counts [bins]int
+	most := 0
+	for _, d := range detects {
+		b := int(d.Confidence * bins)
+		if b < 0 {
+			b = 0
+		} else if b >= bins {
+			b = bins - 1
+		}
+		if counts[b]++; counts[b] > most {
+			most = counts[b]
+		}
+	}
+	max := dst.Bounds().Max
+	panel := image.Rect(max.X-bins*bar-2*pad, max.Y-height-2*pad, max.X, max.Y).Intersect(dst.Bounds())
+	draw.Draw(dst, panel, image.NewUniform(color.RGBA{0, 0, 0, 160}), image.ZP, draw.Over)
+	if most == 0 {
+		return
+	}
+	for b, n := range counts {
+		h := n * height / most
+		x := panel.Min.X + pad + b*bar
+		r := image.Rect(x, panel.Max.Y-pad-h, x+bar-1, panel.Max.Y-pad)
+		c := confidenceColors[confidenceBucket((float32(b)+.5)/bins)]
+		draw.Draw(dst, r.Intersect(dst.Bounds()), image.NewUniform(c), image.ZP, draw.Src)
+	}
+}
+
+// the face of FontSize, the bitmap font when it can't be had
+func (s *Style) fontFace() font.Face {
+	s.once.Do(func() {
+		s.face = basicfont.Face7x13
+		if s.FontSize <= 0 {
+			return
+		}
+		f, err := opentype.Parse(goregular.TTF)
+		if err != nil {
+			return
+		}
+		if face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: s.FontSize, DPI: 72, Hinting: font.HintingFull}); err == nil {
+			s.face = face
+		}
+	})
+	return s.face
+}
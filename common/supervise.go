@@ -0,0 +1,178 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// set in the environment of the child a supervisor runs, to the number of
+// listening sockets it passes from fd 3 on
+const supervisedEnv = "DETECT_SUPERVISED_FDS"
+
+// Supervised tells whether this process is the child of a supervisor
+func Supervised() bool {
+	return os.Getenv(supervisedEnv) != ""
+}
+
+// Listen on addr, or take over the i'th socket a supervisor or systemd
+// socket activation passes, or launchd's socket of the name, so restarts
+// keep the address and the connections waiting on it
+func Listen(name, addr string, i int) (net.Listener, error) {
+	f, err := inherited(name, addr, i)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return net.Listen("tcp", addr)
+	}
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// NotifySupervisor tells the supervisor this child is serving, so it is
+// restarted should it crash from now on. Does nothing when not supervised.
+func NotifySupervisor() {
+	if !Supervised() {
+		return
+	}
+	// the pipe follows the sockets
+	n, _ := strconv.Atoi(os.Getenv(supervisedEnv))
+	f := os.NewFile(uintptr(3+n), "supervisor")
+	f.Write([]byte{1})
+	f.Close()
+}
+
+// the socket passed to this process for addr, nil for none
+func inherited(name, addr string, i int) (*os.File, error) {
+	n, by := activated(), "systemd"
+	if Supervised() {
+		n, _ = strconv.Atoi(os.Getenv(supervisedEnv))
+		by = "the supervisor"
+	}
+	if n > 0 || Supervised() {
+		if i >= n {
+			return nil, fmt.Errorf("no socket %d passed by %s for %s", i, by, addr)
+		}
+		return os.NewFile(uintptr(3+i), addr), nil
+	}
+	return launchdSocket(name), nil
+}
+
+// the socket passed for addr, or a new one listening on it
+func listenFile(name, addr string, i int) (*os.File, error) {
+	f, err := inherited(name, addr, i)
+	if f != nil || err != nil {
+		return f, err
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer lis.Close()
+	return lis.(*net.TCPListener).File()
+}
+
+// Supervise listens on addrs, or takes the sockets of systemd, or of
+// launchd by their names, and reruns this command as a child that serves on
+// them, restarting it when it crashes, eg. on a segfault or cuda fault
+// inside libtensorflow. Restarts back off from a second to 30s, unless the
+// child had run a minute. A child that fails before it is serving, see
+// NotifySupervisor, eg. on a bad flag or a model that doesn't load, would
+// fail again and isn't restarted. SIGHUP and SIGUSR1 are passed on to the
+// child, SIGINT and SIGTERM stop it and return once it exited.
+func Supervise(names, addrs []string) error {
+	if runtime.GOOS == "windows" {
+		return fmt.Errorf("-supervise is not supported on windows, install a service, which restarts on failure")
+	}
+	files := make([]*os.File, 0, len(addrs))
+	for i, addr := range addrs {
+		f, err := listenFile(names[i], addr, i)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, ReloadSignal, syscall.SIGINT, syscall.SIGTERM)
+	backoff := time.Second
+	for {
+		// the child writes to the pipe once it is serving
+		ready, readyw, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command(os.Args[0], os.Args[1:]...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		cmd.ExtraFiles = append(files[:len(files):len(files)], readyw)
+		cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%d", supervisedEnv, len(files)))
+		start := time.Now()
+		err = cmd.Start()
+		readyw.Close()
+		if err != nil {
+			ready.Close()
+			return err
+		}
+		log.Printf("supervising pid %d", cmd.Process.Pid)
+		serving := make(chan bool, 1)
+		go func() {
+			n, _ := ready.Read(make([]byte, 1))
+			serving <- n > 0
+		}()
+
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+		stopping := false
+	wait:
+		for {
+			select {
+			case sig := <-signals:
+				cmd.Process.Signal(sig)
+				stopping = stopping || sig == syscall.SIGINT || sig == syscall.SIGTERM
+			case err = <-done:
+				break wait
+			}
+		}
+		// what the child wrote is read by now, unless a process it started
+		// holds the pipe open
+		served := false
+		select {
+		case served = <-serving:
+		case <-time.After(time.Second):
+		}
+		ready.Close()
+		if stopping {
+			return err
+		}
+		if err == nil {
+			// exiting 0 is the child's choice, log.Fatal exits 1
+			return nil
+		}
+		if !served {
+			return fmt.Errorf("pid %d %v before it was serving, not restarting", cmd.Process.Pid, err)
+		}
+
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("pid %d %v after %v, restarting in %v", cmd.Process.Pid, err, time.Since(start).Round(time.Millisecond), backoff)
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGINT || sig == syscall.SIGTERM {
+				return nil
+			}
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sockets systemd passes from fd 3 on to a socket activated service, 0
+// when not activated or they are meant for another process
+func activated() int {
+	if pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID")); pid != os.Getpid() {
+		return 0
+	}
+	n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	return n
+}
+
+// NotifySystemd sends a state to systemd for a Type=notify service, eg.
+// READY=1, doing nothing when not run by systemd. Under -supervise the
+// child notifies, which needs NotifyAccess=all.
+func NotifySystemd(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// abstract sockets are given with a leading @
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogSystemd pings systemd at half the WatchdogSec of the service for
+// as long as alive says so within the deadline it is given, a quarter of
+// WatchdogSec, so a hung server is restarted. Does nothing without a
+// watchdog.
+func WatchdogSystemd(alive func(deadline time.Duration) bool) {
+	usec, _ := strconv.Atoi(os.Getenv("WATCHDOG_USEC"))
+	if usec <= 0 {
+		return
+	}
+	if pid, err := strconv.Atoi(os.Getenv("WATCHDOG_PID")); err == nil && pid != os.Getpid() && !Supervised() {
+		return
+	}
+	go func() {
+		interval := time.Duration(usec) * time.Microsecond
+		for range time.Tick(interval / 2) {
+			if alive(interval / 4) {
+				NotifySystemd("WATCHDOG=1")
+			}
+		}
+	}()
+}
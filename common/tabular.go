@@ -0,0 +1,112 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// FeatureSpec maps csv columns to the float feature vector of a tabular
+// model, in order
+//
+//	{"features": [
+//	  {"column": "age", "mean": 38.6, "std": 13.7},
+//	  {"column": "hours", "default": 40},
+//	  {"column": "workclass", "categories": ["private", "self-emp", "gov"]}
+//	]}
+//
+// Numeric columns are standardized when std is set, and blank values take
+// the default. Categorical columns are one-hot encoded, a value per category.
+type FeatureSpec struct {
+	Features []FeatureColumn `json:"features"`
+
+	index []int
+}
+
+type FeatureColumn struct {
+	Column     string   `json:"column"`
+	Mean       float64  `json:"mean"`
+	Std        float64  `json:"std"`
+	Default    float64  `json:"default"`
+	Categories []string `json:"categories"`
+}
+
+func LoadFeatureSpec(specfile string) (*FeatureSpec, error) {
+	b, err := ioutil.ReadFile(specfile)
+	if err != nil {
+		return nil, err
+	}
+	spec := &FeatureSpec{}
+	if err := json.Unmarshal(b, spec); err != nil {
+		return nil, fmt.Errorf("%s: %v", specfile, err)
+	}
+	if len(spec.Features) == 0 {
+		return nil, fmt.Errorf("%s: no features", specfile)
+	}
+	return spec, nil
+}
+
+// Size of the feature vector
+func (s *FeatureSpec) Size() int {
+	n := 0
+	for _, f := range s.Features {
+		if len(f.Categories) > 0 {
+			n += len(f.Categories)
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// Header finds the columns of the features in the csv header
+func (s *FeatureSpec) Header(header []string) error {
+	s.index = make([]int, len(s.Features))
+	for i, f := range s.Features {
+		s.index[i] = -1
+		for j, h := range header {
+			if strings.TrimSpace(h) == f.Column {
+				s.index[i] = j
+			}
+		}
+		if s.index[i] < 0 {
+			return fmt.Errorf("no %q column", f.Column)
+		}
+	}
+	return nil
+}
+
+// Vector is the features of a csv row, after Header
+func (s *FeatureSpec) Vector(row []string) ([]float32, error) {
+	vec := make([]float32, 0, s.Size())
+	for i, f := range s.Features {
+		v := ""
+		if s.index[i] < len(row) {
+			v = strings.TrimSpace(row[s.index[i]])
+		}
+		if len(f.Categories) > 0 {
+			for _, c := range f.Categories {
+				hot := float32(0)
+				if strings.EqualFold(v, c) {
+					hot = 1
+				}
+				vec = append(vec, hot)
+			}
+			continue
+		}
+		x := f.Default
+		if v != "" {
+			var err error
+			if x, err = strconv.ParseFloat(v, 64); err != nil {
+				return nil, fmt.Errorf("%s: %v", f.Column, err)
+			}
+		}
+		if f.Std != 0 {
+			x = (x - f.Mean) / f.Std
+		}
+		vec = append(vec, float32(x))
+	}
+	return vec, nil
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// how often the thermal zones are read
+const thermalEvery = 5 * time.Second
+
+// Throttle paces a run to at most MaxFPS frames a second, and slows it down
+// further while the device is hotter than Limit degrees C, by the hottest of
+// the linux thermal zones. Over the limit frames are a second apart, plus a
+// second for each degree over it, so fanless edge devices running streams
+// continuously cool down rather than throttle or shut down.
+type Throttle struct {
+	MaxFPS float64
+	Limit  float64
+
+	zones   []string
+	last    time.Time
+	checked time.Time
+	temp    float64
+}
+
+// NewThrottle paces to maxfps, 0 for no max, and limit degrees C, 0 for no
+// limit or when there are no thermal zones to read
+func NewThrottle(maxfps, limit float64) *Throttle {
+	t := &Throttle{MaxFPS: maxfps, Limit: limit}
+	if limit > 0 {
+		t.zones, _ = filepath.Glob("/sys/class/thermal/thermal_zone*/temp")
+	}
+	return t
+}
+
+// Temperature is the last read of the hottest thermal zone, in degrees C
+func (t *Throttle) Temperature() float64 {
+	return t.temp
+}
+
+// Wait until the next frame is due
+func (t *Throttle) Wait() {
+	if wait := t.interval() - time.Since(t.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	t.last = time.Now()
+}
+
+// Due reports whether the next frame is due already, counting it as run
+// when it is
+func (t *Throttle) Due() bool {
+	if t.interval() > time.Since(t.last) {
+		return false
+	}
+	t.last = time.Now()
+	return true
+}
+
+// time due between frames, by MaxFPS and the temperature
+func (t *Throttle) interval() time.Duration {
+	var interval time.Duration
+	if t.MaxFPS > 0 {
+		interval = time.Duration(float64(time.Second) / t.MaxFPS)
+	}
+	if len(t.zones) > 0 {
+		if time.Since(t.checked) > thermalEvery {
+			t.temp, t.checked = hottestZone(t.zones), time.Now()
+		}
+		if over := t.temp - t.Limit; over > 0 {
+			if hot := time.Second + time.Duration(over*float64(time.Second)); hot > interval {
+				interval = hot
+			}
+		}
+	}
+	return interval
+}
+
+// ThrottleSource paces a live source by t, dropping the frames that come
+// before the next one is due rather than waiting, which would leave the
+// stream to back up in ffmpeg
+func ThrottleSource(src FrameSource, t *Throttle) FrameSource {
+	return &throttledSource{FrameSource: src, throttle: t}
+}
+
+type throttledSource struct {
+	FrameSource
+	throttle *Throttle
+}
+
+func (s *throttledSource) Next() (*Frame, error) {
+	for {
+		frame, err := s.FrameSource.Next()
+		if err != nil || s.throttle.Due() {
+			return frame, err
+		}
+	}
+}
+
+// sysfs temps are in millidegrees
+func hottestZone(zones []string) float64 {
+	hottest := 0.0
+	for _, zone := range zones {
+		b, err := ioutil.ReadFile(zone)
+		if err != nil {
+			continue
+		}
+		milli, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+		if err == nil && milli/1000 > hottest {
+			hottest = milli / 1000
+		}
+	}
+	return hottest
+}
@@ -0,0 +1,224 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"math"
+	"sort"
+	"time"
+)
+
+type TrackPoint struct {
+	Time   time.Time
+	Bounds image.Rectangle
+}
+
+type Track struct {
+	ID      int
+	Class   CID
+	History []TrackPoint
+	// frames since the track was last matched
+	Missed int
+
+	// secondary model results carried between classifications
+	Identity           int
+	Embedding          []float32
+	Plate              string
+	PlateConfidence    float32
+	Subclass           string
+	SubclassConfidence float32
+	// frames since the last classification, consecutive agreeing
+	// classifications
+	age, agreed int
+	classified  bool
+}
+
+func (t *Track) Last() TrackPoint {
+	return t.History[len(t.History)-1]
+}
+
+// Tracker follows detections from frame to frame by greedy IoU matching
+type Tracker struct {
+	IoU float32
+	// frames a track survives without a match
+	MaxMissed int
+	// history kept per track
+	MaxHistory int
+
+	tracks []*Track
+	next   int
+}
+
+// Update matches the detections of a frame to the live tracks, setting the
+// Track of each detection and starting new tracks for unmatched detections
+func (t *Tracker) Update(at time.Time, detects []Detect) {
+	type pair struct {
+		t, d int
+		iou  float32
+	}
+	pairs := make([]pair, 0)
+	for ti, tr := range t.tracks {
+		for di, d := range detects {
+			if d.Class != tr.Class {
+				continue
+			}
+			if iou := IoU(tr.Last().Bounds, d.Bounds); iou >= t.IoU {
+				pairs = append(pairs, pair{ti, di, iou})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].iou > pairs[j].iou })
+
+	matchedT := make(map[int]bool)
+	matchedD := make(map[int]bool)
+	for _, p := range pairs {
+		if matchedT[p.t] || matchedD[p.d] {
+			continue
+		}
+		matchedT[p.t], matchedD[p.d] = true, true
+		tr := t.tracks[p.t]
+		tr.Missed = 0
+		tr.History = append(tr.History, TrackPoint{at, detects[p.d].Bounds})
+		if t.MaxHistory > 0 && len(tr.History) > t.MaxHistory {
+			tr.History = tr.History[len(tr.History)-t.MaxHistory:]
+		}
+		detects[p.d].Track = tr.ID
+	}
+
+	live := t.tracks[:0]
+	for ti, tr := range t.tracks {
+		if !matchedT[ti] {
+			tr.Missed++
+		}
+		if tr.Missed <= t.MaxMissed {
+			live = append(live, tr)
+		}
+	}
+	t.tracks = live
+
+	for di := range detects {
+		if matchedD[di] {
+			continue
+		}
+		t.next++
+		t.tracks = append(t.tracks, &Track{
+			ID:      t.next,
+			Class:   detects[di].Class,
+			History: []TrackPoint{{at, detects[di].Bounds}},
+		})
+		detects[di].Track = t.next
+	}
+}
+
+// Get the live track by id
+func (t *Tracker) Get(id int) *Track {
+	for _, tr := range t.tracks {
+		if tr.ID == id {
+			return tr
+		}
+	}
+	return nil
+}
+
+// Schedule decides which tracked detections have their secondary models run
+// on a frame. A track is classified every Every frames, and not again once
+// Stable consecutive classifications agree.
+type Schedule struct {
+	Every  int
+	Stable int
+}
+
+// Due splits off copies of the detections that are due for classification,
+// with their indices. The rest get the results carried on their track.
+func (t *Tracker) Due(s Schedule, detects []Detect) ([]Detect, []int) {
+	due := make([]Detect, 0, len(detects))
+	idx := make([]int, 0, len(detects))
+	for i := range detects {
+		d := &detects[i]
+		tr := t.Get(d.Track)
+		if tr != nil && tr.classified {
+			tr.age++
+			stable := s.Stable > 0 && tr.agreed >= s.Stable
+			if stable || tr.age < s.Every {
+				d.Identity, d.Embedding = tr.Identity, tr.Embedding
+				d.Plate, d.PlateConfidence = tr.Plate, tr.PlateConfidence
+				d.Subclass, d.SubclassConfidence = tr.Subclass, tr.SubclassConfidence
+				continue
+			}
+		}
+		due = append(due, *d)
+		idx = append(idx, i)
+	}
+	return due, idx
+}
+
+// Classified copies the results of the due detections back, and onto their tracks
+func (t *Tracker) Classified(detects, due []Detect, idx []int) {
+	for i, d := range due {
+		detects[idx[i]] = d
+		tr := t.Get(d.Track)
+		if tr == nil {
+			continue
+		}
+		if tr.classified && tr.Identity == d.Identity && tr.Plate == d.Plate && tr.Subclass == d.Subclass {
+			tr.agreed++
+		} else {
+			tr.agreed = 1
+		}
+		tr.Identity, tr.Embedding = d.Identity, d.Embedding
+		tr.Plate, tr.PlateConfidence = d.Plate, d.PlateConfidence
+		tr.Subclass, tr.SubclassConfidence = d.Subclass, d.SubclassConfidence
+		tr.age, tr.classified = 0, true
+	}
+}
+
+// Calibration maps image pixels onto the ground plane in real units
+type Calibration struct {
+	Homography Homography `json:"homography"`
+	Units      string     `json:"units"`
+}
+
+var PixelCalibration = &Calibration{Homography: Identity, Units: "px"}
+
+// LoadCalibration reads {"homography": [9 values], "units": "m"}
+func LoadCalibration(file string) (*Calibration, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	c := &Calibration{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	if c.Units == "" {
+		c.Units = "m"
+	}
+	return c, nil
+}
+
+// ground contact point of a box, the middle of its bottom edge
+func (c *Calibration) Ground(r image.Rectangle) (float64, float64) {
+	return c.Homography.Apply(float64(r.Min.X+r.Max.X)/2, float64(r.Max.Y))
+}
+
+// Speed of the track in calibration units per second, over the history
+// within window of its last point. Zero until there are two points.
+func (c *Calibration) Speed(tr *Track, window time.Duration) float64 {
+	last := tr.Last()
+	first := last
+	for i := len(tr.History) - 1; i >= 0; i-- {
+		if last.Time.Sub(tr.History[i].Time) > window {
+			break
+		}
+		first = tr.History[i]
+	}
+	dt := last.Time.Sub(first.Time).Seconds()
+	if dt <= 0 {
+		return 0
+	}
+	x0, y0 := c.Ground(first.Bounds)
+	x1, y1 := c.Ground(last.Bounds)
+	return math.Hypot(x1-x0, y1-y0) / dt
+}
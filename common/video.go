@@ -0,0 +1,186 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VideoEncoders are the -video-encoder choices, as the ffmpeg arguments of
+// each; the hardware ones keep the cpu free for detection
+var VideoEncoders = map[string][]string{
+	// whatever ffmpeg picks for the extension
+	"auto":         {"-pix_fmt", "yuv420p"},
+	"x264":         {"-c:v", "libx264", "-preset", "veryfast", "-pix_fmt", "yuv420p"},
+	"nvenc":        {"-c:v", "h264_nvenc", "-pix_fmt", "yuv420p"},
+	"vaapi":        {"-vf", "format=nv12,hwupload", "-c:v", "h264_vaapi"},
+	"qsv":          {"-c:v", "h264_qsv", "-pix_fmt", "nv12"},
+	"videotoolbox": {"-c:v", "h264_videotoolbox", "-pix_fmt", "yuv420p"},
+}
+
+// VideoWriter encodes frames into a video file with ffmpeg
+type VideoWriter struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	fps   float64
+	// pts of the first frame, once a frame is written with WriteAt, and
+	// frames written
+	first   time.Duration
+	started bool
+	n       int
+}
+
+// NewVideoWriter starts encoding to path, the container and codec follow
+// from its extension
+func NewVideoWriter(path string, fps float64) (*VideoWriter, error) {
+	return NewEncodedVideoWriter(path, fps, "auto")
+}
+
+// NewEncodedVideoWriter starts encoding to path with one of the
+// VideoEncoders, vaapi on /dev/dri/renderD128 unless given another device
+// as vaapi:/dev/dri/renderD129
+func NewEncodedVideoWriter(path string, fps float64, encoder string) (*VideoWriter, error) {
+	return newVideoWriter(path, fps, encoder, nil)
+}
+
+// NewDubbedVideoWriter starts encoding to path with the audio of the video
+// file audio, when it has any, copied in alongside the frames written
+func NewDubbedVideoWriter(path string, fps float64, audio string) (*VideoWriter, error) {
+	return newVideoWriter(path, fps, "auto", []string{"-i", audio}, "-map", "0:v", "-map", "1:a?", "-c:a", "copy")
+}
+
+// a video writer with more ffmpeg inputs after the frames, and output
+// options, eg. of the muxer
+func newVideoWriter(path string, fps float64, encoder string, in []string, out ...string) (*VideoWriter, error) {
+	if Private() {
+		return nil, ErrPrivate
+	}
+	name, device := encoder, "/dev/dri/renderD128"
+	if i := strings.Index(encoder, ":"); i >= 0 {
+		name, device = encoder[:i], encoder[i+1:]
+	}
+	codec, ok := VideoEncoders[name]
+	if !ok || (name != "vaapi" && name != encoder) {
+		return nil, fmt.Errorf("unsupported video encoder %q, expected one of %s", encoder, strings.Join(sortedKeys(VideoEncoders), ", "))
+	}
+	if fps <= 0 {
+		return nil, fmt.Errorf("video frame rate %v, expected a positive one", fps)
+	}
+	args := []string{"-loglevel", "error", "-y"}
+	if name == "vaapi" {
+		args = append(args, "-vaapi_device", device)
+	}
+	args = append(args, "-f", "image2pipe", "-vcodec", "mjpeg", "-framerate", strconv.FormatFloat(fps, 'f', -1, 64), "-i", "-")
+	args = append(append(append(append(args, in...), codec...), out...), path)
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &VideoWriter{cmd: cmd, stdin: stdin, fps: fps}, nil
+}
+
+// Write the next frame
+func (v *VideoWriter) Write(im image.Image) error {
+	v.n++
+	return jpeg.Encode(v.stdin, im, &jpeg.Options{Quality: 95})
+}
+
+// WriteFrame writes im, drawn on frame, at the pts of the frame, or as the
+// next frame when its source has none. The wall clock is never used, frames
+// read or detected on late aren't late in the video.
+func (v *VideoWriter) WriteFrame(im image.Image, frame *Frame) error {
+	if frame.hasPTS {
+		return v.WriteAt(im, frame.pts)
+	}
+	return v.Write(im)
+}
+
+// WriteAt writes a frame at pts, its position in the stream, repeating it
+// over frames that were skipped and dropping it when the video is ahead, so
+// the video keeps the timing of its source
+func (v *VideoWriter) WriteAt(im image.Image, pts time.Duration) error {
+	if !v.started {
+		// after the frames written without a pts
+		v.first = pts - time.Duration(float64(v.n)/v.fps*float64(time.Second))
+		v.started = true
+	}
+	due := int(math.Round((pts-v.first).Seconds()*v.fps)) + 1
+	if due <= v.n {
+		return nil
+	}
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, im, &jpeg.Options{Quality: 95}); err != nil {
+		return err
+	}
+	for v.n < due {
+		if _, err := v.stdin.Write(b.Bytes()); err != nil {
+			return err
+		}
+		v.n++
+	}
+	return nil
+}
+
+// Close finishes the file
+func (v *VideoWriter) Close() error {
+	v.stdin.Close()
+	return v.cmd.Wait()
+}
+
+// ProbeFPS is the frame rate of the first video stream of uri, from ffprobe
+func ProbeFPS(uri string) (float64, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream=avg_frame_rate", "-of", "csv=p=0", uri).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe %s: %v", uri, err)
+	}
+	rate := strings.TrimSpace(string(out))
+	num, den := rate, "1"
+	if i := strings.Index(rate, "/"); i >= 0 {
+		num, den = rate[:i], rate[i+1:]
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || n <= 0 || d <= 0 {
+		return 0, fmt.Errorf("ffprobe %s: no frame rate in %q", uri, rate)
+	}
+	return n / d, nil
+}
+
+// VideoAnnotator writes frames with their detections drawn on them into a
+// video, in the timing of the source
+type VideoAnnotator struct {
+	video  *VideoWriter
+	labels Namer
+	scores ScoreFormat
+	// how detections are drawn, DefaultStyle unless set
+	Style *Style
+}
+
+func NewVideoAnnotator(video *VideoWriter, labels Namer) *VideoAnnotator {
+	return &VideoAnnotator{video: video, labels: labels, scores: ScoreFormat{Precision: 2}, Style: DefaultStyle()}
+}
+
+func (a *VideoAnnotator) setScoreFormat(f ScoreFormat) { a.scores = f }
+
+func (a *VideoAnnotator) Write(frame *Frame, detects []Detect) error {
+	return a.video.WriteFrame(a.Style.Annotate(frame.Im, detects, a.labels, a.scores), frame)
+}
+
+// Close finishes the video
+func (a *VideoAnnotator) Close() error {
+	return a.video.Close()
+}
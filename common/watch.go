@@ -0,0 +1,181 @@
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchSource yields the images dropped into a dir
+type watchSource struct {
+	dir     string
+	watcher *fsnotify.Watcher
+	settle  time.Duration
+	// globs of the names of files left alone
+	ignore []string
+	// files written to, by when they were last, and those settled
+	pending map[string]time.Time
+	queue   []string
+	n       int
+}
+
+// NewWatchSource yields the images dropped into dir as fsnotify reports
+// them, once they went settle without being written to, so half uploaded
+// files aren't read. The images already in dir come first. It is live,
+// never ending on its own, and images that don't decode are logged and
+// skipped rather than ending it. Images whose names match an ignore glob,
+// eg. results written back to the dir, are left alone.
+func NewWatchSource(dir string, settle time.Duration, ignore ...string) (FrameSource, error) {
+	if settle <= 0 {
+		return nil, fmt.Errorf("%s: settle time of %v, expected more than 0", dir, settle)
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("%s: %v", dir, err)
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	s := &watchSource{dir: dir, watcher: watcher, settle: settle, ignore: ignore, pending: make(map[string]time.Time)}
+	for _, info := range infos {
+		if !info.IsDir() && s.wanted(info.Name()) {
+			s.queue = append(s.queue, filepath.Join(dir, info.Name()))
+		}
+	}
+	sort.Strings(s.queue)
+	return s, nil
+}
+
+func (s *watchSource) Next() (*Frame, error) {
+	tick := time.NewTicker(s.settle / 2)
+	defer tick.Stop()
+	for {
+		for len(s.queue) > 0 {
+			path := s.queue[0]
+			s.queue = s.queue[1:]
+			if _, err := os.Stat(path); err != nil {
+				// moved away again before it settled
+				continue
+			}
+			frame, err := loadFrame(path, s.n)
+			if err != nil {
+				log.Println(err, "- skipped")
+				continue
+			}
+			s.n++
+			return frame, nil
+		}
+
+		select {
+		case e, ok := <-s.watcher.Events:
+			if !ok {
+				return nil, fmt.Errorf("%s: watch closed", s.dir)
+			}
+			// dot files are the temporaries of uploads, eg. rsync's
+			if e.Op&(fsnotify.Create|fsnotify.Write) != 0 && s.wanted(e.Name) && !strings.HasPrefix(filepath.Base(e.Name), ".") {
+				s.pending[e.Name] = time.Now()
+			}
+		case err := <-s.watcher.Errors:
+			return nil, fmt.Errorf("%s: %v", s.dir, err)
+		case <-tick.C:
+			var settled []string
+			for path, at := range s.pending {
+				if time.Since(at) >= s.settle {
+					settled = append(settled, path)
+					delete(s.pending, path)
+				}
+			}
+			sort.Strings(settled)
+			s.queue = append(s.queue, settled...)
+		}
+	}
+}
+
+// an image not ignored
+func (s *watchSource) wanted(name string) bool {
+	for _, glob := range s.ignore {
+		if ok, _ := filepath.Match(glob, filepath.Base(name)); ok {
+			return false
+		}
+	}
+	return isImage(name)
+}
+
+func (s *watchSource) Close() error { return s.watcher.Close() }
+func (s *watchSource) Meta() SourceMeta {
+	return SourceMeta{Kind: "watch", URI: s.dir, Live: true}
+}
+
+// WatchWriter writes the results of each image of a watched dir next to
+// each other in a dir, as <name>.json and an annotated <name>-detects.jpg,
+// and moves the image itself to a done dir once written, if given
+type WatchWriter struct {
+	dir, done string
+	labels    Namer
+	scores    ScoreFormat
+	// how detections are drawn, DefaultStyle unless set
+	Style *Style
+}
+
+func NewWatchWriter(dir, done string, labels Namer) (*WatchWriter, error) {
+	for _, d := range []string{dir, done} {
+		if d == "" {
+			continue
+		}
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &WatchWriter{dir: dir, done: done, labels: labels, scores: ScoreFormat{Precision: 2}, Style: DefaultStyle()}, nil
+}
+
+func (w *WatchWriter) setScoreFormat(f ScoreFormat) { w.scores = f }
+
+func (w *WatchWriter) Write(frame *Frame, detects []Detect) error {
+	name := filepath.Base(frame.Name)
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if w.dir != "" {
+		// written under a temporary name, so whatever watches the dir
+		// never reads half a file
+		tmp := filepath.Join(w.dir, "."+stem+".json")
+		f, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		out, _ := NewJsonWriter(f, w.labels, SchemaVersion)
+		SetScoreFormat(out, w.scores)
+		err = PrivateWriter(out).Write(frame, detects)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(tmp, filepath.Join(w.dir, stem+".json"))
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		if !Private() {
+			if err := SaveImage(filepath.Join(w.dir, stem+"-detects.jpg"), w.Style.Annotate(frame.Im, detects, w.labels, w.scores)); err != nil {
+				return err
+			}
+		}
+	}
+	if w.done != "" {
+		return os.Rename(frame.Name, filepath.Join(w.done, name))
+	}
+	return nil
+}
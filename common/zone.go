@@ -0,0 +1,302 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scene describes the named areas and tripwire lines of a camera view
+//
+//	{"zones": {"entrance": [[0,0], [100,0], [100,80], [0,80]]},
+//	 "lines": {"door": [[40,80], [60,80]]}}
+type Scene struct {
+	Zones map[string][]image.Point  `json:"-"`
+	Lines map[string][2]image.Point `json:"-"`
+}
+
+func LoadScene(file string) (*Scene, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		Zones map[string][][2]int `json:"zones"`
+		Lines map[string][][2]int `json:"lines"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	scene := &Scene{Zones: make(map[string][]image.Point), Lines: make(map[string][2]image.Point)}
+	for name, pts := range raw.Zones {
+		if len(pts) < 3 {
+			return nil, fmt.Errorf("%s: zone %q needs at least 3 points", file, name)
+		}
+		for _, p := range pts {
+			scene.Zones[name] = append(scene.Zones[name], image.Pt(p[0], p[1]))
+		}
+	}
+	for name, pts := range raw.Lines {
+		if len(pts) != 2 {
+			return nil, fmt.Errorf("%s: line %q needs 2 points", file, name)
+		}
+		scene.Lines[name] = [2]image.Point{image.Pt(pts[0][0], pts[0][1]), image.Pt(pts[1][0], pts[1][1])}
+	}
+	return scene, nil
+}
+
+// InPolygon tests p by ray casting
+func InPolygon(p image.Point, poly []image.Point) bool {
+	in := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		a, b := poly[i], poly[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) &&
+			p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			in = !in
+		}
+	}
+	return in
+}
+
+// the ground contact point of a box, the middle of its bottom edge
+func Foot(r image.Rectangle) image.Point {
+	return image.Pt((r.Min.X+r.Max.X)/2, r.Max.Y)
+}
+
+// ZoneCounter keeps the dwell time of each track in each zone, and the
+// occupancy of each zone
+type ZoneCounter struct {
+	Scene *Scene
+	// Stats, nil for none, of Camera
+	Stats  *ZoneStats
+	Camera string
+
+	// zone -> track -> time entered
+	entered map[string]map[int]time.Time
+	// track -> time last seen
+	seen map[int]time.Time
+}
+
+// Update takes the tracked detections of a frame, setting the Dwell of each
+// and returning the current occupancy of every zone
+func (c *ZoneCounter) Update(at time.Time, detects []Detect) map[string]int {
+	if c.entered == nil {
+		c.entered = make(map[string]map[int]time.Time)
+	}
+	if c.seen == nil {
+		c.seen = make(map[int]time.Time)
+	}
+	occupancy := make(map[string]int, len(c.Scene.Zones))
+	for name, poly := range c.Scene.Zones {
+		inside := make(map[int]time.Time)
+		for i := range detects {
+			d := &detects[i]
+			if d.Track == 0 || !InPolygon(Foot(d.Bounds), poly) {
+				continue
+			}
+			since, ok := c.entered[name][d.Track]
+			if !ok {
+				since = at
+			}
+			inside[d.Track] = since
+			if d.Dwell == nil {
+				d.Dwell = make(map[string]float64)
+			}
+			d.Dwell[name] = at.Sub(since).Seconds()
+		}
+		for track, since := range c.entered[name] {
+			if _, ok := inside[track]; !ok {
+				c.Stats.left(c.Camera, name, c.seen[track].Sub(since))
+			}
+		}
+		// tracks that left the zone, or were lost, start over on return
+		c.entered[name] = inside
+		occupancy[name] = len(inside)
+	}
+	seen := make(map[int]time.Time, len(detects))
+	for _, d := range detects {
+		if d.Track > 0 {
+			seen[d.Track] = at
+		}
+	}
+	c.seen = seen
+	c.Stats.occupied(c.Camera, occupancy)
+	return occupancy
+}
+
+// dwell buckets in seconds, from passing through to staying an hour
+var dwellBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}
+
+// ZoneStats are the occupancy of the zones of each camera and the time
+// tracks spent in them, answering GET /metrics in the prometheus text
+// format. Methods on a nil ZoneStats do nothing.
+type ZoneStats struct {
+	mu sync.Mutex
+	// camera -> zone -> tracks in it
+	occupancy map[string]map[string]int
+	// camera -> zone -> dwell of the tracks that left it
+	dwell map[string]map[string]*histogram
+}
+
+func NewZoneStats() *ZoneStats {
+	return &ZoneStats{occupancy: make(map[string]map[string]int), dwell: make(map[string]map[string]*histogram)}
+}
+
+// occupied sets the occupancy of the zones of camera, zones of an old scene
+// are dropped
+func (s *ZoneStats) occupied(camera string, occupancy map[string]int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	zones := make(map[string]int, len(occupancy))
+	for zone, n := range occupancy {
+		zones[zone] = n
+	}
+	s.occupancy[camera] = zones
+}
+
+// left records a track leaving a zone of camera, or lost in it, after dwell
+func (s *ZoneStats) left(camera, zone string, dwell time.Duration) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dwell[camera] == nil {
+		s.dwell[camera] = make(map[string]*histogram)
+	}
+	h := s.dwell[camera][zone]
+	if h == nil {
+		h = newHistogram(dwellBuckets)
+		s.dwell[camera][zone] = h
+	}
+	h.observe(dwell.Seconds())
+}
+
+func (s *ZoneStats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.WriteTo(w)
+}
+
+// WriteTo writes the stats in the prometheus text format
+func (s *ZoneStats) WriteTo(w io.Writer) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "# HELP detect_zone_occupancy Tracked objects in the zone.\n# TYPE detect_zone_occupancy gauge\n")
+	for _, camera := range sortedKeys(s.occupancy) {
+		for _, zone := range sortedKeys(s.occupancy[camera]) {
+			fmt.Fprintf(b, "detect_zone_occupancy{camera=\"%s\",zone=\"%s\"} %d\n", escapeLabel(camera), escapeLabel(zone), s.occupancy[camera][zone])
+		}
+	}
+	fmt.Fprintf(b, "# HELP detect_zone_dwell_seconds Time tracks spent in the zone before leaving it or being lost.\n# TYPE detect_zone_dwell_seconds histogram\n")
+	for _, camera := range sortedKeys(s.dwell) {
+		for _, zone := range sortedKeys(s.dwell[camera]) {
+			s.dwell[camera][zone].writeLabelled(b, "detect_zone_dwell_seconds", fmt.Sprintf("camera=\"%s\",zone=\"%s\"", escapeLabel(camera), escapeLabel(zone)))
+		}
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (s *Scene) ZoneNames() []string {
+	names := make([]string, 0, len(s.Zones))
+	for name := range s.Zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Crossings of a tripwire line. Looking along the line from its first point
+// to its second, AB counts crossings from the left side to the right side and
+// BA from right to left.
+type Crossings struct {
+	AB int `json:"ab"`
+	BA int `json:"ba"`
+}
+
+// LineCounter counts the tracks crossing each line of the scene, by direction
+type LineCounter struct {
+	Scene *Scene
+	// Tracker of the detections, whose tracks are followed across the
+	// frames they're missed in until it drops them
+	Tracker *Tracker
+
+	counts map[string]Crossings
+	// track -> foot in the last frame it was seen
+	last map[int]image.Point
+}
+
+// Update takes the tracked detections of a frame, returning the running
+// crossing counts of every line
+func (c *LineCounter) Update(detects []Detect) map[string]Crossings {
+	if c.counts == nil {
+		c.counts = make(map[string]Crossings, len(c.Scene.Lines))
+		for name := range c.Scene.Lines {
+			c.counts[name] = Crossings{}
+		}
+	}
+	seen := make(map[int]image.Point, len(detects))
+	for _, d := range detects {
+		if d.Track == 0 {
+			continue
+		}
+		foot := Foot(d.Bounds)
+		seen[d.Track] = foot
+		prev, ok := c.last[d.Track]
+		if !ok {
+			continue
+		}
+		for name, line := range c.Scene.Lines {
+			from, to := side(line[0], line[1], prev), side(line[0], line[1], foot)
+			// the step has to cross the line segment, not just its extension
+			if from == to || from == 0 || side(prev, foot, line[0]) == side(prev, foot, line[1]) {
+				continue
+			}
+			n := c.counts[name]
+			if from < 0 {
+				n.AB++
+			} else {
+				n.BA++
+			}
+			c.counts[name] = n
+		}
+	}
+	// tracks missed in the frame keep their last foot, so a crossing while
+	// missed counts once they're seen again; lost tracks are forgotten
+	for track, foot := range c.last {
+		if _, ok := seen[track]; !ok && c.Tracker != nil && c.Tracker.Get(track) != nil {
+			seen[track] = foot
+		}
+	}
+	c.last = seen
+
+	counts := make(map[string]Crossings, len(c.counts))
+	for name, n := range c.counts {
+		counts[name] = n
+	}
+	return counts
+}
+
+// which side of the line a->b p is on, -1 on the left with y pointing down,
+// 1 on the right and 0 on the line
+func side(a, b, p image.Point) int {
+	cross := (b.X-a.X)*(p.Y-a.Y) - (b.Y-a.Y)*(p.X-a.X)
+	switch {
+	case cross < 0:
+		return -1
+	case cross > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	"github.com/jw3/example-tensorflow-golang/detector"
+)
+
+// a model of the comparison, its detections drawn in one color and tagged
+// with its name
+type compared struct {
+	name   string
+	det    *detector.Detector
+	labels Labels
+	color  color.RGBA
+	style  *Style
+}
+
+// class names prefixed with the model's
+type modelNamer struct {
+	model  string
+	labels Namer
+}
+
+func (n modelNamer) Name(c CID) string { return n.model + " " + n.labels.Name(c) }
+
+func main() {
+	var modelfiles, labelfiles, imagefiles Strings
+	flag.Var(&modelfiles, "model", "Detection model to compare, name=model.pb or a path named by its file. Give two or more")
+	flag.Var(&labelfiles, "labels", "Class labels, one file for all models or one per model in the order of -model")
+	flag.Var(&imagefiles, "image", "Image or video to run the models on; file, dir, glob, archive, video file, http(s) or rtsp url. Repeatable")
+	outdir := flag.String("outdir", "compared", "Dir to write the annotated images and videos to")
+	min := flag.Float64("min", .5, "Minimum confidence of the detections drawn")
+	colors := flag.String("colors", "red,deepskyblue,gold,lime", "Color of each model's boxes, in the order of -model, by name or hex")
+	split := flag.Bool("split", false, "Draw each model on its own copy of the image side by side, rather than all of them over one")
+	chipsize := flag.Int("chip-size", 544, "Size of the square chips images are cut into")
+	encoder := flag.String("video-encoder", "auto", "Encoder of the compared videos; auto, x264, nvenc, vaapi, qsv or videotoolbox")
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
+
+	SetUsage("compare", `Run two or more detection models on the same images and draw their detections in different colors, on one image or side by side, writing them to -outdir.`,
+		"compare -model old=multires.pb -model new=multires-v2.pb -image xview/2122.jpg",
+		"compare -model a.pb -model b.pb -image dashcam.mp4 -split")
+
+	flag.Parse()
+	if *completion != "" {
+		PrintCompletion(*completion, "compare")
+		return
+	}
+	if len(modelfiles) < 2 || len(imagefiles) == 0 {
+		flag.Usage()
+		return
+	}
+	if len(labelfiles) == 0 {
+		labelfiles = Strings{"labels.txt"}
+	}
+	if len(labelfiles) != 1 && len(labelfiles) != len(modelfiles) {
+		log.Fatalf("%d -labels for %d models, expected one for all or one each", len(labelfiles), len(modelfiles))
+	}
+	palette := strings.Split(*colors, ",")
+	if len(palette) < len(modelfiles) {
+		log.Fatalf("%d -colors for %d models", len(palette), len(modelfiles))
+	}
+
+	models := make([]*compared, len(modelfiles))
+	for i, spec := range modelfiles {
+		m := &compared{name: strings.TrimSuffix(filepath.Base(spec), filepath.Ext(spec)), style: DefaultStyle()}
+		file := spec
+		if j := strings.Index(spec, "="); j > 0 {
+			m.name, file = spec[:j], spec[j+1:]
+		}
+		var err error
+		if m.labels, err = LoadLabels(labelfiles[i%len(labelfiles)]); err != nil {
+			log.Fatal(err)
+		}
+		if m.color, err = ParseColor(palette[i]); err != nil {
+			log.Fatal(err)
+		}
+		m.style.Colors = map[CID]color.RGBA{}
+		m.det = detector.New()
+		m.det.ChipSize = *chipsize
+		if err := m.det.Load(file); err != nil {
+			log.Fatal(err)
+		}
+		defer m.det.Close()
+		models[i] = m
+	}
+
+	if err := os.MkdirAll(*outdir, 0755); err != nil {
+		log.Fatal(err)
+	}
+	for _, uri := range imagefiles {
+		src, err := OpenSource(uri)
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = compareSource(src, models, float32(*min), *split, *outdir, *encoder)
+		src.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", uri, err)
+		}
+	}
+}
+
+// draw the detections of every model on the frames of src, videos and
+// streams are written back out as a video, anything else as jpegs
+func compareSource(src FrameSource, models []*compared, min float32, split bool, outdir, encoder string) error {
+	meta := src.Meta()
+	var video *VideoWriter
+	if meta.Kind == "video" || meta.Kind == "rtsp" {
+		fps, err := ProbeFPS(meta.URI)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(meta.URI), filepath.Ext(meta.URI)) + "-compared.mp4"
+		if video, err = NewEncodedVideoWriter(filepath.Join(outdir, name), fps, encoder); err != nil {
+			return err
+		}
+		defer video.Close()
+	}
+
+	for {
+		frame, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		drawn := make([]image.Image, len(models))
+		var over image.Image = frame.Im
+		for i, m := range models {
+			detects, err := m.det.DetectImage(frame.Im)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %v", m.name, RedactName(frame.Name), err)
+			}
+			detects = above(detects, min)
+			for _, d := range detects {
+				// every class in the model's color
+				m.style.Colors[d.Class] = m.color
+			}
+			namer := modelNamer{m.name, m.labels}
+			if split {
+				drawn[i] = m.style.Annotate(frame.Im, detects, namer, ScoreFormat{Precision: 2})
+			} else {
+				over = m.style.Annotate(over, detects, namer, ScoreFormat{Precision: 2})
+			}
+		}
+		if split {
+			over = sideBySide(drawn)
+		}
+
+		if video != nil {
+			if err := video.WriteFrame(over, frame); err != nil {
+				return err
+			}
+			continue
+		}
+		_, name, _ := SplitPath(frame.Name)
+		out, err := os.Create(filepath.Join(outdir, strings.Replace(name, ":", "_", -1)+"-compared.jpg"))
+		if err != nil {
+			return err
+		}
+		err = jpeg.Encode(out, over, &jpeg.Options{Quality: 95})
+		out.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func above(detects []Detect, min float32) []Detect {
+	kept := detects[:0]
+	for _, d := range detects {
+		if d.Confidence >= min {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// ims next to each other, left to right, with a gap of 4 black pixels
+func sideBySide(ims []image.Image) image.Image {
+	const gap = 4
+	w, h := 0, 0
+	for _, im := range ims {
+		w += im.Bounds().Dx() + gap
+		if im.Bounds().Dy() > h {
+			h = im.Bounds().Dy()
+		}
+	}
+	out := image.NewRGBA(image.Rect(0, 0, w-gap, h))
+	draw.Draw(out, out.Bounds(), image.Black, image.ZP, draw.Src)
+	x := 0
+	for _, im := range ims {
+		r := image.Rect(x, 0, x+im.Bounds().Dx(), im.Bounds().Dy())
+		draw.Draw(out, r, im, im.Bounds().Min, draw.Src)
+		x = r.Max.X + gap
+	}
+	return out
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+)
+
+func main() {
+	in := flag.String("in", "-", "Path to json detect output, or - for stdin")
+	to := flag.Int("to", SchemaVersion, "Schema version to convert to")
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
+
+	SetUsage("convert", `Convert json detect output between schema versions, writing to stdout.`,
+		"convert -in results.jsonl -to 1 > results-v1.jsonl",
+		"detect -model m.pb -image frames/ -output json | convert -to 1")
+
+	flag.Parse()
+	if *completion != "" {
+		PrintCompletion(*completion, "convert")
+		return
+	}
+
+	f := os.Stdin
+	if *in != "-" {
+		var err error
+		f, err = os.Open(*in)
+		if err != nil {
+			log.Fatalf("%s: %v\n", *in, err)
+		}
+		defer f.Close()
+	}
+	if err := ConvertSchema(f, os.Stdout, *to); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,240 +1,2024 @@
 package main
 
 import (
-	. "./common"
 	"bufio"
-	"bytes"
 	"flag"
 	"fmt"
-	tf "github.com/tensorflow/tensorflow/tensorflow/go"
-	"github.com/tensorflow/tensorflow/tensorflow/go/op"
-	"golang.org/x/image/draw"
 	"image"
-	"image/jpeg"
-	"io/ioutil"
+	"image/draw"
+	"io"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
-)
-
-// Some constants specific to the pre-trained model at:
-// https://storage.googleapis.com/download.tensorflow.org/models/inception5h.zip
-// - The model was trained after with images scaled to 224x224 pixels.
-// - The colors, represented as R, G, B in 1-byte each were converted to
-//   float using (value - Mean)/Scale.
+	"syscall"
+	"time"
 
-// trained chip size
-const (
-	H, W = 544, 544
+	"github.com/jw3/example-tensorflow-golang/api"
+	. "github.com/jw3/example-tensorflow-golang/common"
+	"github.com/jw3/example-tensorflow-golang/detector"
+	"github.com/jw3/example-tensorflow-golang/model"
 )
 
 func main() {
 	modelfile := flag.String("model", "", "Path to the trained model")
-	labelfile := flag.String("labels", "labels.txt", "Path of a class mapping dict")
-	imagefile := flag.String("image", "", "Image to be processed")
+	warmup := flag.Int("warmup", 0, "Run the model this many times on a blank batch once loaded, so the slow first runs of kernel compilation and allocation are over before the images, or before a reloaded model is swapped in")
+	costflag := flag.Bool("cost", false, "Estimate the flops of a chip from the graph once loaded, logging them with the device, and adding the flops spent to -bench and the metrics of -serve")
+	benchflag := flag.Bool("bench", false, "Report the latency min, mean, p50, p95, p99 and max of the images of a run, and their throughput, on stderr once it's over")
+	smoke := flag.Bool("smoke", false, "Run a few images drawn in code through the model and check its outputs are finite scores in [0,1] of labelled classes, exiting 1 when not; a container health gate")
+	mode := flag.String("mode", "detect", "detect to find objects with an object detection model, or classify to score whole images with a classification model, printing the top classes of each")
+	preprocess := flag.String("preprocess", "", "Preprocessing profile of the model; "+model.ProfileNames()+". Defaults to xview, uint8 544x544 chips, or with -mode classify to unit, float pixels in [0,1]")
+	inputsize := flag.String("input-size", "", "Size the model takes images at, over the profile's, eg. 300 or 640x480; read from the input shape when static")
+	inputmean := flag.String("input-mean", "", "Mean subtracted from float pixels, over the profile's, eg. 127.5 or 123.7,116.3,103.5 per channel")
+	inputscale := flag.Float64("input-scale", 0, "Float pixels are divided by this after the mean, over the profile's, eg. 127.5 for [-1,1]")
+	inputdtype := flag.String("input-dtype", "", "Type of the model's pixels, over the profile's; uint8 or float32")
+	classifysize := flag.Int("classify-size", 224, "Size images are scaled to for -mode classify")
+	top := flag.Int("top", 5, "Number of classes to output per image with -mode classify, 0 for all")
+	flag.IntVar(top, "topk", 5, "Alias of -top")
+	softmax := flag.String("softmax", "auto", "Put the -mode classify and -crop-classifier scores through softmax; auto when they are logits rather than probabilities in [0,1], always or never")
+	inputop := flag.String("input-op", "", "Name of the image input op, found by name or as the single uint8 placeholder when not given; input with -mode classify")
+	outputops := flag.String("output-ops", "", "Comma separated names of the boxes, scores, classes and num_detections output ops, found by their object detection api names when not given; the [N,C] scores op, scores by default, with -mode classify")
+	savedmodel := flag.String("saved-model", "", "SavedModel dir to load rather than a frozen -model")
+	tflite := flag.String("tflite", "", "TensorFlow Lite detection model to run rather than a frozen -model, eg. a mobilenet ssd converted with its postprocessing, float or quantized uint8; needs a build with -tags tflite")
+	tags := flag.String("tags", "serve", "Comma separated tags of the -saved-model graph")
+	signature := flag.String("signature", "serving_default", "Signature of the -saved-model naming its input and detection outputs")
+	restore := flag.String("restore", "", "Checkpoint to initialize the variables of a -model that isn't frozen from, eg. model.ckpt-1000 or a training dir")
+	labelfile := flag.String("labels", "labels.txt", "Path of a class mapping dict; when not given and missing, a label_map.pbtxt or labels.txt next to the model is used")
+	labelformat := flag.String("label-format", "auto", "Labels file format; txt, pbtxt, csv or auto")
+	lang := flag.String("lang", "", "Label language, read from labels.<lang>.txt or the <lang> column of a csv labels file")
+	var imagefiles Strings
+	flag.Var(&imagefiles, "image", "Image to be processed; file, dir, glob, archive, http(s) or rtsp url, or - for stdin. Repeat for multiple cameras of the same scene")
 	debugmode := flag.Bool("debug", false, "Enable debug mode")
 	minbounds := flag.Float64("min", 0.0, "Minimum confidence to output (WARNING: Will impact ppc)")
+	flag.Float64Var(minbounds, "min-score", 0.0, "Alias of -min")
+	maxdetects := flag.Int("max-detections", 0, "Most detections to output per image, the highest scoring; 0 for all of them")
 	chipsize := flag.Int("chip", 544, "Chip dimension")
+	overlap := flag.Int("overlap", 0, "Pixels of overlap between neighbouring chips")
+	nmsiou := flag.Float64("nms-iou", 0, "Suppress same class detections overlapping a more confident one by this IoU, eg. .5, for graphs that output raw boxes without nms; 0 keeps them")
+	mergeios := flag.Float64("merge", .5, "Merge same class detections overlapping by this fraction of the smaller box, across overlapping chips and cameras")
+	mergecameras := flag.Bool("merge-cameras", true, "Merge the detections of repeated -image cameras into one view, otherwise each camera is output and tracked on its own")
+	burst := flag.Duration("burst", 0, "Group images taken less than this apart into events, eg. camera trap bursts, and output an event per burst with the highest confidence and count of each label")
+	burstmin := flag.Float64("burst-min", 0.5, "Minimum confidence of a detection for its label to be present in a -burst event")
+	reorder := flag.Duration("reorder", 0, "Hold frames back this long to output them in timestamp order across cameras, eg. 2s")
+	homographies := flag.String("homographies", "", "Json of image uri to the 3x3 homography that maps it onto the first image")
+	stream := flag.String("stream", "", "Watch a live rtsp(s):// stream or /dev/videoN camera for good, reconnecting when it drops, and output the start and end -events of the objects tracked in it; implies -track")
+	streamtimeout := flag.Duration("stream-timeout", 30*time.Second, "Reconnect a -stream that yields no frame for this long")
+	events := flag.String("events", "", "Output the start and end event of each track as json lines rather than a line per frame: - for stdout, an http(s) url each event is POSTed to, or a file appended to; - with -stream")
+	screen := flag.Int("screen", -1, "Capture frames from this screen instead of -image, eg. 0")
+	fps := flag.Float64("fps", 1, "Screen capture frame rate")
+	spill := flag.String("spill", "", "Queue the frames of live streams in this dir while detection falls behind, rather than letting the stream drop them")
+	spillmb := flag.Int64("spill-mb", 1024, "Most megabytes of frames to -spill, the oldest are dropped past it")
+	spillage := flag.Duration("spill-age", 10*time.Minute, "Drop -spill frames queued longer than this; 0 for no limit")
+	maxfps := flag.Float64("max-fps", 0, "Most frames a second to run detection on, 0 for as fast as possible")
+	thermal := flag.Float64("thermal-limit", 0, "Slow down while the hottest linux thermal zone is above this many degrees C, eg. 75; 0 for no limit")
+	region := flag.String("region", "", "Screen capture region x,y,w,h, defaults to the whole screen or -window")
+	window := flag.String("window", "", "Capture this window rather than the whole -screen, by its title on windows or its x11 window id on linux, eg. 0x3a00007 from xwininfo; not supported on macs")
+	batchsize := flag.Int("batch-size", 1, "Number of chips per session run, and of images read ahead from a dir, archive or video to share runs. Models with a static batch dimension override this")
+	interactive := flag.Bool("interactive", false, "Keep the model loaded and read commands from stdin")
+	shardflag := flag.String("shard", "", "Only process shard i/n of the images in dirs and archives, eg. 3/8, to split a corpus across machines")
+	exiforient := flag.Bool("exif-orient", true, "Turn jpegs upright by their exif orientation as they are decoded, boxes then in the pixels as displayed rather than as stored")
+	clock := flag.String("clock", "source", "Frame timestamps; source for file mtimes, archive entry times and stream pts, exif for the jpeg capture time, or wall for when the frame was read")
+	checkpointfile := flag.String("checkpoint", "", "Record progress through dirs and archives in this file, a restarted run resumes after the last frame output")
+	serve := flag.String("serve", "", "Keep the model loaded and serve the jobs api and POST /detect on this address, eg. :8080")
+	grpcaddr := flag.String("grpc", "", "Keep the model loaded and serve the grpc Detector service of api/detector.proto on this address, eg. :9090, or on the -serve address to serve both on one port")
+	admin := flag.String("admin", "", "Serve the admin api on this address, eg. localhost:9091, adding and removing -serve and -grpc listeners at runtime with POST and DELETE /listeners")
+	var servemodels Strings
+	flag.Var(&servemodels, "serve-model", "Another model requests may ask for by name with ?models=, alone or in an ensemble with others and the default one, as name=model.pb or name=model.tflite; can be repeated")
+	canarydir := flag.String("canary", "", "Dir of golden images, each with a .txt of its \"xmin ymin xmax ymax class\" objects, run on a model reloaded with SIGUSR1 before it replaces the serving one")
+	canarydrop := flag.Float64("canary-max-drop", 0.02, "Most the f1 on the -canary images may drop by for a reloaded model to be swapped in")
+	canaryslowdown := flag.Float64("canary-max-slowdown", 0.25, "Most the mean latency on the -canary images may grow by, as a fraction, for a reloaded model to be swapped in")
+	serveensembles := flag.String("serve-ensembles", "", "Comma separated combinations of models requests may ask for, names joined by +, eg. default,default+b; defaults to any")
+	service := flag.String("service", "detect", "Name of the windows service the server runs as, and that -install-service and -remove-service act on")
+	installservice := flag.Bool("install-service", false, "Install the server as a windows service run with the other flags given, and exit")
+	removeservice := flag.Bool("remove-service", false, "Remove the windows service and exit")
+	supervise := flag.Bool("supervise", false, "Run -serve and -grpc in a child process, restarted when it crashes, eg. on a fault inside libtensorflow, keeping the listening sockets")
+	servemin := flag.Float64("serve-min", 0, "Lowest min a POST /detect or grpc request may ask for")
+	serveclassesflag := flag.String("serve-classes", "", "Comma separated class ids POST /detect and grpc answer with, and requests may ask for some of, defaults to all")
+	jobsdir := flag.String("jobs-dir", "jobs", "Dir the jobs db and spooled results are kept in")
+	jobsparallel := flag.Int("jobs-parallel", 1, "Number of images processed at once, across all jobs")
+	jobsallow := flag.String("jobs-allow", "", "Comma separated url schemes, eg. https or rtsp, and dirs jobs may read images from beyond -jobs-dir; http(s) also lets results be POSTed")
+	reidfile := flag.String("reid", "", "Path to a re-identification embedding model, run on the crops of detections")
+	reidinput := flag.String("reid-input", "input", "Input op of the re-identification model")
+	reidoutput := flag.String("reid-output", "embeddings", "Output op of the re-identification model")
+	reidclasses := flag.String("reid-classes", "", "Comma separated class ids to re-identify, eg. people and vehicles, defaults to all")
+	reidthreshold := flag.Float64("reid-threshold", .7, "Cosine similarity to match a known identity")
+	reidembed := flag.Bool("reid-embeddings", false, "Include the raw embeddings in json output")
+	track := flag.Bool("track", false, "Track detections from frame to frame")
+	speed := flag.Bool("speed", false, "Estimate the speed of tracked objects, implies -track")
+	calibration := flag.String("calibration", "", "Json of a homography from pixels to the ground plane and its units, speeds are in px/s without one")
+	scenefile := flag.String("scene", "", "Json of named zones and tripwire lines, reports the dwell time of tracked objects in each zone, the zone occupancy and line crossings by direction, implies -track")
+	statsaddr := flag.String("stats", "", "Serve the zone occupancy and dwell times of -scene on this address, eg. :9100, as prometheus metrics at /metrics")
+	platesfile := flag.String("plates", "", "Path to a license plate detection model, run on the crops of vehicle detections")
+	platesmin := flag.Float64("plates-min", .5, "Lowest score of a plate detection to be read")
+	ocrfile := flag.String("ocr", "", "Path to a ctc text recognition model, run on the rectified plates")
+	ocrinput := flag.String("ocr-input", "input", "Input op of the text recognition model")
+	ocroutput := flag.String("ocr-output", "logits", "Output op of the text recognition model, [N,T,C] class scores")
+	charset := flag.String("charset", "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ", "Characters of the text recognition classes, the ctc blank follows the last")
+	vehicleclasses := flag.String("vehicle-classes", "", "Comma separated class ids to read plates on, defaults to all")
+	cropclassifier := flag.String("crop-classifier", "", "Path to a classification model run on the crops of detections for a finer label, eg. the make and model of cars")
+	cropinput := flag.String("crop-classifier-input", "input", "Input op of the crop classifier")
+	cropoutput := flag.String("crop-classifier-output", "scores", "Output op of the crop classifier, [N,C] or [N,1,1,C] class scores")
+	croppreprocess := flag.String("crop-classifier-preprocess", "unit", "Preprocessing profile of the crop classifier; "+model.ProfileNames())
+	croplabels := flag.String("crop-classifier-labels", "", "Labels of the crop classifier classes")
+	cropsize := flag.Int("crop-classifier-size", 224, "Side the crops are resized to, when the crop classifier's input shape doesn't say")
+	cropclasses := flag.String("crop-classes", "", "Comma separated class ids to classify the crops of, defaults to all")
+	cropmin := flag.Float64("crop-classifier-min", 0, "Lowest score of a finer label, crops scoring less keep only the detection's")
+	classifyevery := flag.Int("classify-every", 1, "Run the re-identification, plate and crop classifier models on each track every this many frames, carrying the results forward in between, implies -track")
+	classifystable := flag.Int("classify-stable", 0, "Stop classifying a track once this many consecutive results agree, 0 never stops")
+	cacheframes := flag.Int("cache-frames", 0, "Reuse re-identification, plate and crop classifier results for near identical crops seen within this many frames, 0 disables")
+	cachedistance := flag.Int("cache-distance", 4, "Bits two crop hashes may differ by and still be reused")
+	preview := flag.String("preview", "", "Show the annotated image in the terminal; sixel, kitty or ascii")
+	workers := flag.Int("workers", 1, "Number of images of a dir, glob or zip decoded and detected on at once, sharing the session, output in order")
+	timeout := flag.Duration("per-image-timeout", 0, "Give up on an image taking longer than this, eg. 10s, logging it as failed and going on with the next")
+	cpubudget := flag.String("cpu-budget", "", "Share of the machine to use, eg. 4 cores or 50%, sizing GOMAXPROCS and the tf thread pools and running at nice 10, or nice=N, eg. 4,nice=15")
+	boxcolors := flag.String("box-colors", "", "Colors of classes in -out-image and -preview, by label or id, as a name or hex, eg. car=red,3=#00ff88, or confidence to color boxes by their confidence, with a legend")
+	scorehistogram := flag.Bool("score-histogram", false, "Draw the histogram of each frame's scores in the corner of -out-image and -preview")
+	boxwidth := flag.Int("box-width", 2, "Width of the box outlines drawn in pixels")
+	boxlabel := flag.String("box-label", "class+score", "What boxes drawn are tagged with; class, score and track joined by +, or none")
+	fontsize := flag.Float64("font-size", 0, "Size of the box tags in points, 0 for the small bitmap font")
+	boxfill := flag.Float64("box-fill", 0, "Opacity boxes drawn are shaded with in their class color, 0 to 1, eg. 0.3")
+	watch := flag.String("watch", "", "Run detection on each image dropped into this dir, as well as those already in it, until stopped")
+	watchsettle := flag.Duration("watch-settle", time.Second, "How long a dropped image must go unwritten before it's read, so half uploaded files aren't")
+	watchout := flag.String("watch-out", "", "Dir to write a <name>.json and annotated <name>-detects.jpg to for each image of -watch")
+	watchdone := flag.String("watch-done", "", "Dir to move the images of -watch to once their results are written, so a restart doesn't run them again")
+	outvideo := flag.String("out-video", "", "Encode the frames of a video, stream or screen capture with the boxes drawn on them into a video file, eg. annotated.mp4, keeping the source's frame rate and timing")
+	videoencoder := flag.String("video-encoder", "auto", "Encoder of -out-video; auto, x264, or hardware nvenc, vaapi, vaapi:/dev/dri/renderD129, qsv or videotoolbox")
+	restreamaddr := flag.String("restream", "", "Serve the annotated frames of the first camera on this address, eg. :8090, as mjpeg at /stream.mjpeg with a page showing it at /")
+	restreamhls := flag.Bool("restream-hls", false, "Also serve -restream as hls at /hls/index.m3u8, encoded with -video-encoder")
+	outimage := flag.String("out-image", "", "Save a copy of each image with the boxes, labels and scores drawn on it; a .jpg or .png file, or a dir")
+	retain := RetentionFlags()
+	private := PrivacyFlags()
+	auditlog := flag.String("audit-log", "", "Append a record of every prediction to this file, with the hash of the model and the input image, each record chained to the previous by hash")
+	verifyaudit := flag.String("verify-audit", "", "Check the hash chain of an -audit-log file and exit")
+	output := flag.String("output", "pretty", "Output format; pretty, plain, json, geojson or coco. pretty falls back to plain when stdout is not a terminal")
+	format := flag.String("format", "", "Alias of -output")
+	scoreFormat := ScoreFlags()
+	setLocale := LocaleFlags()
+	configureSessions := model.SessionFlags()
+	servemaxmb := flag.Int64("serve-max-mb", 64, "Largest upload POST /detect accepts in MB, streamed to the decoder rather than buffered")
+	servecache := flag.Int("serve-cache", 0, "Keep the responses of this many distinct POST /detect requests to answer repeats from, with ETags of the image, model and settings and 304 Not Modified for a matching If-None-Match; 0 disables")
+	servemaxinflight := flag.Int("serve-max-inflight", 2*runtime.NumCPU(), "Most images POST /detect and grpc detect on at once, counting those given up on past -per-image-timeout until they finish; 0 for no max")
+	servemaxmp := flag.Float64("serve-max-mp", 0, "Most megapixels of an image POST /detect and grpc accept, checked from its header before decoding; 0 for any")
+	cocoannotations := flag.String("coco-annotations", "", "Coco annotations, eg. instances_val2017.json, whose image ids -output coco gives the images by file name; without it images are named by their ids")
+	geometry := flag.String("geometry", "polygon", "Geojson geometry of each detection; polygon for the box, or point for its center")
+	schema := flag.Int("schema", SchemaVersion, "Json output schema version, older versions are kept for consumers that haven't caught up")
+	preset := flag.String("preset", "", "Flag values for a use of detect, under those given and -config's; "+PresetNames()+". anpr reads the plates of coco vehicles with the -plates and -ocr models")
+	configfile := flag.String("config", "", "Json, yaml or toml file of flag values, eg. min: 0.5, overridden by flags given. Changes to -min, -max-detections, -serve-min, -serve-classes and -scene are applied while running")
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
+
+	SetUsage("detect", `Run object detection over images, printing a "xmin ymin xmax ymax class confidence" line per detection.`,
+		"detect -model xview-models/multires.pb -image xview/2122.jpg > predictions.txt",
+		"detect -model xview-models/multires.pb -interactive",
+		"curl -s https://example.com/a.jpg | detect -model xview-models/multires.pb -image - -output json | jq .",
+		"detect -model xview-models/multires.pb -serve :8080")
 
 	flag.Parse()
-	if *modelfile == "" || *imagefile == "" || *labelfile == "" {
+	var config *Config
+	if *configfile != "" {
+		var err error
+		if config, err = LoadConfig(*configfile); err != nil {
+			log.Fatal(err)
+		}
+		if err := config.Apply(flag.CommandLine); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *preset != "" {
+		p, err := NewPreset(*preset)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := p.Apply(flag.CommandLine); err != nil {
+			log.Fatal(err)
+		}
+		if *preset == "anpr" && (*platesfile == "" || *ocrfile == "") {
+			log.Fatal("-preset anpr needs its -plates and -ocr models")
+		}
+	}
+	ExifOrient = *exiforient
+	if *format != "" {
+		*output = *format
+	}
+	private()
+	if err := setLocale(); err != nil {
+		log.Fatal(err)
+	}
+	if *completion != "" {
+		PrintCompletion(*completion, "detect")
+		return
+	}
+	if *installservice {
+		if err := InstallService(*service, serviceArgs()); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("installed service", *service)
+		return
+	}
+	if *removeservice {
+		if err := RemoveService(*service); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("removed service", *service)
+		return
+	}
+	if err := StartService(*service); err != nil {
+		log.Fatal(err)
+	}
+	if *verifyaudit != "" {
+		f, err := os.Open(*verifyaudit)
+		if err != nil {
+			log.Fatal(err)
+		}
+		n, err := VerifyAudit(f)
+		if err != nil {
+			log.Fatalf("%s: %v", *verifyaudit, err)
+		}
+		fmt.Printf("%s: %d records, chain intact\n", *verifyaudit, n)
+		return
+	}
+	if (*modelfile == "" && *savedmodel == "" && *tflite == "") || (len(imagefiles) == 0 && *stream == "" && *watch == "" && *screen < 0 && *window == "" && !*interactive && *serve == "" && *grpcaddr == "" && !*smoke) || *labelfile == "" {
 		flag.Usage()
 		return
 	}
+	stdin := 0
+	for _, uri := range imagefiles {
+		if uri == "-" {
+			stdin++
+		}
+	}
+	if stdin > 1 || (stdin > 0 && *interactive) {
+		log.Fatal("-image - reads stdin, which can only be read once, and not alongside -interactive")
+	}
+	if *supervise && !Supervised() {
+		if *serve == "" && *grpcaddr == "" {
+			log.Fatal("-supervise needs -serve or -grpc")
+		}
+		log.Fatal(Supervise(listeners(*serve, *grpcaddr)))
+	}
 
-	chipW := *chipsize
-	chipH := *chipsize
+	modelpath := *modelfile
+	if *savedmodel != "" {
+		modelpath = *savedmodel
+	} else if *tflite != "" {
+		modelpath = *tflite
+	}
+	if *tflite != "" && (*mode != "detect" || *restore != "") {
+		log.Fatal("-tflite runs detection models, with no -mode classify or -restore")
+	}
 
-	model, err := ioutil.ReadFile(*modelfile)
+	labelsGiven := false
+	flag.Visit(func(f *flag.Flag) { labelsGiven = labelsGiven || f.Name == "labels" })
+	// the labels of the model are only picked over a labels.txt in the
+	// working dir when there's none, as before models shipped theirs
+	if _, err := os.Stat(*labelfile); !labelsGiven && os.IsNotExist(err) {
+		if found := modelLabels(modelpath, ""); found != "" {
+			log.Printf("labels: %s, shipped with the model", found)
+			*labelfile = found
+		}
+	}
+	labels, err := NewLiveLabels(*labelfile, *labelformat, *lang)
 	if err != nil {
 		log.Fatal(err)
 	}
-	im, err := LoadJpeg(*imagefile)
+	reloadLabelsOnHup(labels)
+	if *output == "pretty" && !IsTerminal(os.Stdout) {
+		*output = "plain"
+	}
+	var out DetectWriter
+	if *output == "json" {
+		out, err = NewJsonWriter(os.Stdout, labels, *schema)
+	} else if *output == "geojson" {
+		out, err = NewGeoJsonWriter(os.Stdout, labels, *geometry)
+	} else {
+		out, err = NewDetectWriter(*output, os.Stdout, labels)
+	}
 	if err != nil {
-		log.Fatalf("%v", err)
+		log.Fatal(err)
+	}
+	if coco, ok := out.(*CocoWriter); ok && *cocoannotations != "" {
+		if err := coco.LoadImageIDs(*cocoannotations); err != nil {
+			log.Fatal(err)
+		}
+	}
+	scoreFormat(out)
+	style := &Style{Width: *boxwidth, FontSize: *fontsize, Label: *boxlabel, Fill: *boxfill, Histogram: *scorehistogram}
+	if *boxcolors == "confidence" {
+		style.ByConfidence = true
+	} else if style.Colors, err = ParseColors(*boxcolors, labels.Labels()); err != nil {
+		log.Fatal(err)
+	}
+	if err := style.Check(); err != nil {
+		log.Fatal(err)
+	}
+	var annotated *ImageWriter
+	if *outimage != "" {
+		if Private() {
+			log.Fatal("-out-image: ", ErrPrivate)
+		}
+		annotated = NewImageWriter(*outimage, labels)
+		annotated.Style = style
+		scoreFormat(annotated)
+		if dir := annotated.Dir(); dir != "" {
+			retain(dir, "*-detects.jpg", "*-detects.png")
+		}
+	}
+	var audit DetectWriter
+	if *auditlog != "" {
+		a, err := OpenAuditLog(*auditlog, modelpath, labels)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer a.Close()
+		audit = PrivateWriter(a)
 	}
 
 	//
 	// all files are open, fire up TF
 	//
 
-	graph := tf.NewGraph()
-	if err := graph.Import(model, ""); err != nil {
+	budget, err := ParseCPUBudget(*cpubudget)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := budget.Apply(); err != nil {
+		log.Fatal(err)
+	}
+	model.SetThreads(budget.Threads())
+	if err := configureSessions(); err != nil {
+		log.Fatal(err)
+	}
+
+	if *mode == "classify" {
+		in, scores := *inputop, *outputops
+		if in == "" {
+			in = "input"
+		}
+		if scores == "" {
+			scores = "scores"
+		}
+		p, err := preprocessing(*preprocess, "unit", *inputsize, *inputmean, *inputscale, *inputdtype)
+		if err != nil {
+			log.Fatal(err)
+		}
+		size := image.Pt(*classifysize, *classifysize)
+		if *preprocess != "" || *inputsize != "" {
+			size = p.Size
+		}
+		classifier, err := model.NewClassifier(*modelfile, in, scores, size)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer classifier.Close()
+		p.Size = classifier.Preprocess.Size
+		if err := p.Check(); err != nil {
+			log.Fatal(err)
+		}
+		classifier.Preprocess = p
+		if err := classifier.Warmup(*warmup); err != nil {
+			log.Fatal(err)
+		}
+		if *smoke {
+			smokeTest(func(name string, im image.Image) error {
+				scores, err := classifier.Classify([]image.Image{im})
+				if err != nil {
+					return err
+				}
+				// logits needn't be in [0,1] but must be finite, a softmax
+				// would hide an overflow
+				if err := CheckFinite(scores[0]); err != nil {
+					return fmt.Errorf("raw output: %v", err)
+				}
+				return CheckScores(SoftmaxScores(scores[0], *softmax), labels.Labels())
+			})
+			return
+		}
+		if err := classifyImages(classifier, imagefiles, labels, *output, *top, float32(*minbounds), *softmax, scoreFormat); err != nil {
+			log.Fatal(err)
+		}
+		return
+	} else if *mode != "detect" {
+		log.Fatalf("unsupported mode %q, expected detect or classify", *mode)
+	}
+
+	prep, err := preprocessing(*preprocess, "xview", *inputsize, *inputmean, *inputscale, *inputdtype)
+	if err != nil {
 		log.Fatal(err)
 	}
-	session, err := tf.NewSession(graph, nil)
+	load := func() (*detector.Detector, error) {
+		det := detector.New()
+		det.ChipSize, det.Overlap, det.BatchSize = *chipsize, *overlap, *batchsize
+		det.Preprocess = prep
+		det.Merge, det.NMS, det.Debug = float32(*mergeios), float32(*nmsiou), *debugmode
+		det.InputOp = *inputop
+		det.OutputOps = splitOps(*outputops)
+		var err error
+		if *tflite != "" {
+			err = det.LoadTFLite(*tflite, budget.Cores)
+		} else if *savedmodel != "" {
+			err = det.LoadSavedModel(*savedmodel, strings.Split(*tags, ","), *signature)
+		} else {
+			err = det.Load(*modelfile)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if *restore != "" {
+			m := &model.Model{Graph: det.Graph, Session: det.Session}
+			if err := m.Restore(*restore); err != nil {
+				det.Close()
+				return nil, err
+			}
+		}
+		if *warmup > 0 {
+			start := time.Now()
+			if err := det.Warmup(*warmup); err != nil {
+				det.Close()
+				return nil, err
+			}
+			log.Printf("warmed up with %d runs in %v", *warmup, time.Since(start).Round(time.Millisecond))
+		}
+		return det, nil
+	}
+	loadStart := time.Now()
+	det, err := load()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer session.Close()
+	defer det.Close()
+	loaded := time.Since(loadStart)
+	if *smoke {
+		smokeTest(func(name string, im image.Image) error {
+			detects, err := det.DetectImage(im)
+			if err != nil {
+				return err
+			}
+			if err := CheckDetects(im, detects, labels.Labels()); err != nil {
+				return err
+			}
+			return CheckFinds(name, detects, labels.Labels())
+		})
+		return
+	}
 
-	// width-number and height-number
-	// TODO;; this leaves an offset that is not included
-	wn := im.Bounds().Dx() / chipW
-	hn := im.Bounds().Dy() / chipH
+	// estimated flops of an image, 0 without -cost
+	var chipCost float64
+	var device string
+	if *costflag {
+		cost, err := det.Cost()
+		if err != nil {
+			log.Fatal(err)
+		}
+		chipCost, device = cost.FLOPs, model.Device(det.Session)
+		log.Printf("cost: %s a chip on %s, %d ops, %d convolutions and products of unknown shape left out", FormatFLOPs(chipCost), device, cost.Ops, cost.Unknown)
+	}
+	imageCost := func(b image.Rectangle) float64 { return chipCost * float64(det.Chips(b)) }
+
+	ratio := float32(*chipsize) / float32(detector.W)
+	if ratio != 1.0 {
+		log.Println("Scaling ratio:", ratio)
+	}
 
-	chips := make([]Chip, wn*hn)
-	for i := 0; i < wn*hn; i++ {
-		x := i % wn
-		y := i / wn
-		w := x * chipW
-		h := y * chipH
+	if *overlap < 0 || *overlap >= *chipsize {
+		log.Fatalf("overlap must be between 0 and the chip size, %v", *overlap)
+	}
+	merger := &Merger{Homographies: map[string]Homography{}, IoS: float32(*mergeios)}
+	if *homographies != "" {
+		merger.Homographies, err = LoadHomographies(*homographies)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-		chipBounds := image.Rect(w, h, w+chipW, h+chipH)
-		chip := im.(interface {
-			SubImage(r image.Rectangle) image.Image
-		}).SubImage(chipBounds)
+	predict := det.DetectImage
 
-		if chipW != W {
-			scaled := image.NewRGBA(image.Rect(0, 0, W, H))
-			draw.BiLinear.Scale(scaled, scaled.Bounds(), chip, chip.Bounds(), draw.Over, nil)
-			chip = scaled
+	if *interactive {
+		repl(predict, PrivateWriter(out), labels, float32(*minbounds), *maxdetects)
+		return
+	}
+	if *serve != "" || *grpcaddr != "" {
+		serveclasses, err := ParseClasses(*serveclassesflag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var canary *Canary
+		if *canarydir != "" {
+			if canary, err = LoadCanary(*canarydir); err != nil {
+				log.Fatal(err)
+			}
+			canary.Min, canary.MaxDrop, canary.MaxSlowdown = float32(*minbounds), *canarydrop, *canaryslowdown
+		}
+		swap := NewHotSwap(predict)
+		predict = swap.Predict
+		handler := &DetectHandler{Predict: predict, Labels: labels, Min: float32(*minbounds), Max: *maxdetects, MinFloor: float32(*servemin), Classes: serveclasses, Metrics: NewMetrics(labels), Timeout: *timeout,
+			MaxUpload: *servemaxmb << 20, MaxPixels: int(*servemaxmp * 1e6), MaxInFlight: *servemaxinflight, Audit: audit}
+		if chipCost > 0 {
+			handler.Cost = imageCost
+			handler.Metrics.SetCost(chipCost, device)
+		}
+		// the hashes of the model and the -serve-model ones in ETags, read
+		// again once a reload is swapped in
+		version := func() {}
+		if *servecache > 0 {
+			handler.Cache = NewResultCache(*servecache)
+			version = func() {
+				v, err := FileHash(modelpath)
+				for _, spec := range servemodels {
+					if i := strings.Index(spec, "="); i > 0 && err == nil {
+						var h string
+						h, err = FileHash(spec[i+1:])
+						v += " " + spec[:i] + "=" + h
+					}
+				}
+				if err != nil {
+					log.Printf("ERROR: no model version for ETags: %v", err)
+				}
+				handler.SetModelVersion(v)
+			}
+			version()
+		}
+		reloadModelOnUsr1(swap, det, load, canary, version)
+		if config != nil {
+			go func() {
+				for range time.Tick(time.Second) {
+					if config.Changed() {
+						reloadConfig(config, func() error {
+							classes, err := ParseClasses(*serveclassesflag)
+							if err != nil {
+								return err
+							}
+							handler.SetBounds(float32(*minbounds), *maxdetects, float32(*servemin), classes)
+							return nil
+						})
+					}
+				}
+			}()
+		}
+		// more models for requests to ask for, with the chip settings of
+		// the default one
+		handler.Models = map[string]Predictor{"default": predict}
+		for _, spec := range servemodels {
+			i := strings.Index(spec, "=")
+			if i < 1 {
+				log.Fatalf("invalid -serve-model %q, expected name=model.pb", spec)
+			}
+			d := detector.New()
+			d.ChipSize, d.Overlap, d.BatchSize, d.Merge, d.NMS = *chipsize, *overlap, *batchsize, float32(*mergeios), float32(*nmsiou)
+			d.InputOp, d.OutputOps = *inputop, splitOps(*outputops)
+			load := d.Load
+			if strings.HasSuffix(spec, ".tflite") {
+				load = func(file string) error { return d.LoadTFLite(file, budget.Cores) }
+			}
+			if err := load(spec[i+1:]); err != nil {
+				log.Fatal(err)
+			}
+			defer d.Close()
+			handler.Models[spec[:i]] = d.DetectImage
+		}
+		if *serveensembles != "" {
+			handler.Ensembles = make(map[string]bool)
+			for _, e := range strings.Split(*serveensembles, ",") {
+				names := strings.Split(e, "+")
+				sort.Strings(names)
+				handler.Ensembles[strings.Join(names, "+")] = true
+			}
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/detect", handler)
+		mux.Handle("/metrics", handler.Metrics)
+		// http listeners may be added through the admin api later
+		if *serve != "" || *admin != "" {
+			access, err := ParseJobAccess(*jobsallow)
+			if err != nil {
+				log.Fatal(err)
+			}
+			jobs, err := NewJobServer(predict, labels, *jobsdir, access, audit, *jobsparallel)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer jobs.Close()
+			mux.Handle("/jobs", jobs)
+			mux.Handle("/jobs/", jobs)
+		}
+		// sinks written to while serving
+		var sinks []string
+		if *auditlog != "" {
+			sinks = append(sinks, *auditlog)
+		}
+		if *serve != "" || *admin != "" {
+			sinks = append(sinks, *jobsdir)
+		}
+		pf := preflight(det, modelpath, loaded, labels, handler.Models, sinks)
+		if err := pf.Err(); err != nil {
+			log.Fatal(err)
+		}
+		mux.Handle("/preflight", pf)
+		servers := NewListeners(mux, api.NewServer(handler).Serve)
+		// -serve then -grpc when a supervisor passes both
+		names, addrs := listeners(*serve, *grpcaddr)
+		for i, name := range names {
+			lis, err := Listen(name, addrs[i], i)
+			if err != nil {
+				log.Fatal(err)
+			}
+			protocol := "grpc"
+			if name == "serve" {
+				protocol = "http"
+				if *grpcaddr == *serve {
+					protocol = "both"
+				}
+			}
+			servers.Serve(lis, addrs[i], protocol)
 		}
-		chips[i] = Chip{x, y, chip}
+		if *admin != "" {
+			go func() {
+				log.Println("admin api on", *admin)
+				log.Fatal(http.ListenAndServe(*admin, servers))
+			}()
+		}
+		WatchdogSystemd(handler.Alive)
+		NotifySupervisor()
+		NotifySystemd("READY=1")
+		log.Fatal(servers.Wait())
 	}
 
-	if *debugmode {
-		writeChips(chips)
+	var embedder *model.Embedder
+	if *reidfile != "" {
+		embedder, err = model.NewEmbedder(*reidfile, *reidinput, *reidoutput, image.Pt(128, 256))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer embedder.Close()
+	}
+	reid, err := ParseClasses(*reidclasses)
+	if err != nil {
+		log.Fatal(err)
 	}
+	gallery := &Gallery{Threshold: float32(*reidthreshold)}
+	embeddings := &CropCache{Window: *cacheframes, Distance: *cachedistance}
 
-	ratio := float32(chipW) / float32(W)
-	if ratio != 1.0 {
-		log.Println("Scaling ratio:", ratio)
+	var anpr *plateReader
+	if *platesfile != "" {
+		if *ocrfile == "" {
+			log.Fatal("-plates needs an -ocr model to read them")
+		}
+		anpr = &plateReader{min: float32(*platesmin), cache: &CropCache{Window: *cacheframes, Distance: *cachedistance}}
+		if anpr.plates, err = model.NewDetector(*platesfile, *inputop, splitOps(*outputops)); err != nil {
+			log.Fatal(err)
+		}
+		defer anpr.plates.Close()
+		if anpr.ocr, err = model.NewOCR(*ocrfile, *ocrinput, *ocroutput, *charset, image.Pt(94, 24)); err != nil {
+			log.Fatal(err)
+		}
+		defer anpr.ocr.Close()
+		if anpr.classes, err = ParseClasses(*vehicleclasses); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var subclassifier *cropClassifier
+	if *cropclassifier != "" {
+		if *croplabels == "" {
+			log.Fatal("-crop-classifier needs its -crop-classifier-labels")
+		}
+		p, err := preprocessing(*croppreprocess, "unit", "", "", 0, "")
+		if err != nil {
+			log.Fatal(err)
+		}
+		p.Size = image.Pt(*cropsize, *cropsize)
+		if subclassifier, err = newCropClassifier(*cropclassifier, *cropinput, *cropoutput, *croplabels, p); err != nil {
+			log.Fatal(err)
+		}
+		defer subclassifier.Close()
+		subclassifier.min, subclassifier.softmax = float32(*cropmin), *softmax
+		subclassifier.cache = &CropCache{Window: *cacheframes, Distance: *cachedistance}
+		if subclassifier.classes, err = ParseClasses(*cropclasses); err != nil {
+			log.Fatal(err)
+		}
+		if err := subclassifier.Warmup(*warmup); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *stream != "" && *events == "" {
+		*events = "-"
+	}
+	tracking := *events != "" || *track || *speed || *scenefile != "" || *classifyevery > 1 || *classifystable > 0
+	schedule := Schedule{Every: *classifyevery, Stable: *classifystable}
+	calib := PixelCalibration
+	if *calibration != "" {
+		if calib, err = LoadCalibration(*calibration); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	detects := make([]Detect, 1)
-	for _, chip := range chips {
-		buf := bytes.Buffer{}
-		jpeg.Encode(&buf, chip.Im, nil)
+	var scene *Scene
+	if *scenefile != "" {
+		if scene, err = LoadScene(*scenefile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var stats *ZoneStats
+	if *statsaddr != "" {
+		if scene == nil {
+			log.Fatal("-stats needs the zones of a -scene")
+		}
+		stats = NewZoneStats()
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", stats)
+		go func() {
+			log.Println("serving zone stats on", *statsaddr)
+			log.Fatal(http.ListenAndServe(*statsaddr, mux))
+		}()
+	}
+	// cameras are numbered in the order of -image, the merged view is 0
+	newCamera := func(n int) *camera {
+		c := &camera{}
+		if tracking {
+			c.tracker = &Tracker{IoU: .3, MaxMissed: 5, MaxHistory: 100}
+		}
+		if scene != nil {
+			c.zones = &ZoneCounter{Scene: scene, Stats: stats, Camera: strconv.Itoa(n)}
+			c.lines = &LineCounter{Scene: scene, Tracker: c.tracker}
+		}
+		return c
+	}
 
-		tensor, err := loadImageTensor(buf.Bytes())
+	shard, err := ParseShard(*shardflag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srcs, err := openSources(imagefiles, *screen, *fps, *region, *window)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if *stream != "" {
+		src, err := ReconnectSource(*stream, *streamtimeout)
 		if err != nil {
 			log.Fatal(err)
 		}
-		output, err := session.Run(
-			map[tf.Output]*tf.Tensor{
-				graph.Operation("image_tensor").Output(0): tensor,
-			},
-			[]tf.Output{
-				graph.Operation("detection_boxes").Output(0),
-				graph.Operation("detection_scores").Output(0),
-				graph.Operation("detection_classes").Output(0),
-				graph.Operation("num_detections").Output(0),
-			},
-			nil)
+		srcs = append(srcs, src)
+	}
+	// the frames of -watch are the ones the watch writer writes
+	watchsrc := -1
+	if *watch != "" {
+		var ignore []string
+		if *watchout != "" && filepath.Clean(*watchout) == filepath.Clean(*watch) {
+			ignore = append(ignore, "*-detects.jpg")
+		}
+		src, err := NewWatchSource(*watch, *watchsettle, ignore...)
 		if err != nil {
+			log.Fatal("-watch: ", err)
+		}
+		watchsrc = len(srcs)
+		srcs = append(srcs, src)
+	}
+	for i := range srcs {
+		srcs[i] = ShardSource(srcs[i], shard)
+	}
+	var checkpoint *Checkpoint
+	if *checkpointfile != "" {
+		if checkpoint, err = LoadCheckpoint(*checkpointfile); err != nil {
+			log.Fatal(err)
+		}
+		for i := range srcs {
+			srcs[i] = checkpoint.Resume(srcs[i])
+		}
+	}
+	for i := range srcs {
+		if srcs[i], err = ClockSource(srcs[i], *clock); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// live sources are read on, dropping the frames the throttle has no
+	// time for, others wait for it
+	throttled := false
+	for i := range srcs {
+		if srcs[i].Meta().Live && (*maxfps > 0 || *thermal > 0) {
+			srcs[i], throttled = ThrottleSource(srcs[i], NewThrottle(*maxfps, *thermal)), true
+		}
+	}
+	for i := range srcs {
+		if *spill != "" && srcs[i].Meta().Live {
+			dir := filepath.Join(*spill, strconv.Itoa(i))
+			if srcs[i], err = SpillSource(srcs[i], dir, *spillmb<<20, *spillage); err != nil {
+				log.Fatal("-spill: ", err)
+			}
+		}
+	}
+	if !*mergecameras && len(srcs) > 1 {
+		for i := range srcs {
+			srcs[i] = StreamNames(srcs[i])
+		}
+	}
+	for _, src := range srcs {
+		defer src.Close()
+	}
+	var video *VideoAnnotator
+	if *outvideo != "" {
+		if video, err = openVideo(*outvideo, *videoencoder, srcs, *fps, labels); err != nil {
+			log.Fatal("-out-video: ", err)
+		}
+		video.Style = style
+		scoreFormat(video)
+		defer video.Close()
+	}
+	var watched *WatchWriter
+	if *watchout != "" || *watchdone != "" {
+		if *watch == "" {
+			log.Fatal("-watch-out and -watch-done need a -watch dir")
+		}
+		if *mergecameras && len(srcs) > 1 {
+			log.Fatal("-watch-out and -watch-done need -merge-cameras=false with other sources, to write the results of -watch alone")
+		}
+		if watched, err = NewWatchWriter(*watchout, *watchdone, labels); err != nil {
 			log.Fatal(err)
 		}
+		watched.Style = style
+		scoreFormat(watched)
+	}
+	var restream *Restream
+	if *restreamaddr != "" {
+		if restream, err = NewRestream(labels); err != nil {
+			log.Fatal("-restream: ", err)
+		}
+		restream.Style = style
+		scoreFormat(restream)
+		if *restreamhls {
+			fps, err := sourceFPS(srcs[:1], *fps)
+			if err == nil {
+				err = restream.StartHLS(fps, *videoencoder)
+			}
+			if err != nil {
+				log.Fatal("-restream-hls: ", err)
+			}
+		}
+		defer restream.Close()
+		go func() {
+			log.Println("restreaming on", *restreamaddr)
+			log.Fatal(http.ListenAndServe(*restreamaddr, restream))
+		}()
+	}
+	cameras := make([]*camera, len(srcs))
+	for i := range cameras {
+		cameras[i] = newCamera(i)
+	}
+	if *burst > 0 {
+		out = NewBurstWriter(os.Stdout, *output, labels, *burst, float32(*burstmin))
+	}
+	if *events != "" {
+		source := *stream
+		if source == "" && len(srcs) > 0 {
+			source = srcs[0].Meta().URI
+		}
+		ev, err := NewTrackEventWriter(*events, source, labels)
+		if err != nil {
+			log.Fatal("-events: ", err)
+		}
+		if tracker := cameras[0].tracker; tracker != nil {
+			ev.Missed = tracker.MaxMissed
+		}
+		defer ev.Close()
+		out = ev
+	}
+	if *reorder > 0 {
+		out = NewReorderWriter(out, *reorder)
+	}
+	// the names of frames are redacted in private mode, for every sink
+	out = PrivateWriter(out)
 
-		boxes := output[0].Value().([][][]float32)[0]
-		scores := output[1].Value().([][]float32)[0]
-		classes := output[2].Value().([][]float32)[0]
+	detectImages := det.DetectImages
+	var bench *Bench
+	if *benchflag {
+		bench = NewBench()
+		bench.SetCost(chipCost, device)
+		timed, timedImages := predict, detectImages
+		predict = func(im image.Image) (detects []Detect, err error) {
+			err = bench.Time(1, func() error {
+				detects, err = timed(im)
+				return err
+			})
+			if err == nil {
+				bench.Spent(imageCost(im.Bounds()))
+			}
+			return detects, err
+		}
+		detectImages = func(ims []image.Image) (detects [][]Detect, err error) {
+			err = bench.Time(len(ims), func() error {
+				detects, err = timedImages(ims)
+				return err
+			})
+			for _, im := range ims {
+				if err == nil {
+					bench.Spent(imageCost(im.Bounds()))
+				}
+			}
+			return detects, err
+		}
+	}
+	// decoding counts against the timeout of an image too
+	decoded := make([]FrameSource, len(srcs))
+	for i, src := range srcs {
+		decoded[i] = src
+		if *timeout > 0 && !src.Meta().Live {
+			decoded[i] = &timedSource{FrameSource: src, timeout: *timeout}
+		}
+	}
+	next := func() ([]*Frame, [][]Detect, error) { return nextDetects(decoded, predict, *timeout) }
+	if *workers > 1 && len(srcs) == 1 && !srcs[0].Meta().Live {
+		abandoned = make(chan bool, *workers+maxAbandoned)
+		next = newPooledDetects(srcs[0], *workers, predict, *timeout).next
+	} else if *batchsize > 1 && len(srcs) == 1 && !srcs[0].Meta().Live {
+		next = (&batchedDetects{src: decoded[0], n: *batchsize, detect: detectImages, timeout: *timeout}).next
+	}
+	failed := 0
+	throttle := NewThrottle(*maxfps, *thermal)
 
-		for i, score := range scores {
-			class := classes[i]
-			bounds := transformBox(chip.X, chip.Y, boxes[i])
-			detects = append(detects,
-				Detect{
-					Bounds:     ResizeRect(bounds, ratio),
-					Class:      CID(class),
-					Chip:       &chip,
-					Confidence: score,
-				})
+	for {
+		if !throttled {
+			throttle.Wait()
+		}
+		if config != nil && config.Changed() {
+			loaded := *scenefile
+			reloadConfig(config, func() error {
+				if *scenefile == loaded {
+					return nil
+				}
+				if scene == nil || *scenefile == "" {
+					return fmt.Errorf("-scene %q to %q turns tracking on or off, which needs a restart", loaded, *scenefile)
+				}
+				next, err := LoadScene(*scenefile)
+				if err != nil {
+					return err
+				}
+				// zone counts start over in the new scene
+				scene = next
+				for i, c := range cameras {
+					c.zones, c.lines = &ZoneCounter{Scene: scene, Stats: stats, Camera: strconv.Itoa(i)}, &LineCounter{Scene: scene, Tracker: c.tracker}
+				}
+				return nil
+			})
+		}
+		// one frame from each camera, merged into the view of the first
+		// unless the cameras are kept apart
+		frames, views, err := next()
+		if err == io.EOF {
+			break
+		}
+		if t, ok := err.(*timeoutError); ok {
+			log.Println(err)
+			failed += t.images
+			continue
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if *mergecameras && len(srcs) > 1 {
+			frames, views = frames[:1], [][]Detect{mergeViews(srcs, views, merger)}
+			if checkpoint != nil {
+				checkpoint.Read(frames[0], srcs...)
+			}
+		} else if checkpoint != nil {
+			for v, frame := range frames {
+				checkpoint.Read(frame, srcs[v])
+			}
+		}
+		for v, frame := range frames {
+			detects := filterDetections(views[v], float32(*minbounds), *maxdetects)
+			tracker, zones, lines := cameras[v].tracker, cameras[v].zones, cameras[v].lines
+			if tracker != nil {
+				tracker.Update(frame.Time, detects)
+			}
+			if *speed {
+				for i := range detects {
+					detects[i].Speed = float32(calib.Speed(tracker.Get(detects[i].Track), time.Second))
+				}
+			}
+			if zones != nil {
+				frame.Occupancy = zones.Update(frame.Time, detects)
+				frame.Crossings = lines.Update(detects)
+			}
+			// secondary models only run on the tracks due for classification
+			due, idx := detects, []int(nil)
+			if tracker != nil {
+				due, idx = tracker.Due(schedule, detects)
+			}
+			if embedder != nil {
+				embeddings.Next()
+				if err := identify(embedder, gallery, embeddings, frame.Im, due, reid, *reidembed); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if anpr != nil {
+				anpr.cache.Next()
+				if err := anpr.read(frame.Im, due); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if subclassifier != nil {
+				subclassifier.cache.Next()
+				if err := subclassifier.classify(frame.Im, due); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if tracker != nil {
+				tracker.Classified(detects, due, idx)
+			}
+			if audit != nil {
+				if err := audit.Write(frame, detects); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if err := out.Write(frame, detects); err != nil {
+				log.Fatal(err)
+			}
+			if annotated != nil {
+				if err := annotated.Write(frame, detects); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if video != nil {
+				if err := video.Write(frame, detects); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if restream != nil && v == 0 {
+				if err := restream.Write(frame, detects); err != nil {
+					log.Fatal(err)
+				}
+			}
+			// last, as it moves the image away
+			if watched != nil && v == watchsrc {
+				if err := watched.Write(frame, detects); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if *preview != "" {
+				if err := Preview(os.Stderr, *preview, style.Annotate(frame.Im, detects, nil, ScoreFormat{})); err != nil {
+					log.Fatal(err)
+				}
+			}
+			// once every output of the frame is written
+			if checkpoint != nil {
+				if err := checkpoint.Commit(out, frame); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+	}
+	if failed > 0 {
+		log.Print(T("%d images timed out", failed))
+	}
+	if bench != nil {
+		bench.Report(os.Stderr)
+	}
+	// writers holding frames back write them out
+	if f, ok := out.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if checkpoint != nil {
+		if err := checkpoint.Commit(out, nil); err != nil {
+			log.Fatal(err)
 		}
 	}
-	printDetections(detects, *labelfile, float32(*minbounds))
 }
 
-func transformBox(chipX, chipY int, box []float32) image.Rectangle {
-	//     chip pos   ->  world pos
-	mx := int(box[1]*W) + (chipX * W)
-	Mx := int(box[3]*W) + (chipX * W)
-	my := int(box[0]*H) + (chipY * H)
-	My := int(box[2]*H) + (chipY * H)
+// tracking state of a camera, or of the merged view of all cameras
+type camera struct {
+	tracker *Tracker
+	zones   *ZoneCounter
+	lines   *LineCounter
+}
 
-	return image.Rectangle{
-		Min: image.Point{X: mx, Y: my},
-		Max: image.Point{X: Mx, Y: My},
+// read commands from stdin until eof or quit
+func repl(predict func(image.Image) ([]Detect, error), out DetectWriter, labels *LiveLabels, min float32, max int) {
+	prompt := func() { fmt.Fprint(os.Stderr, "> ") }
+	scanner := bufio.NewScanner(os.Stdin)
+	for prompt(); scanner.Scan(); prompt() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "predict":
+			for _, uri := range fields[1:] {
+				if err := replPredict(uri, predict, out, min, max); err != nil {
+					log.Printf("%s: %v", uri, err)
+				}
+			}
+			if f, ok := out.(interface{ Flush() error }); ok {
+				if err := f.Flush(); err != nil {
+					log.Println(err)
+				}
+			}
+		case "threshold":
+			if len(fields) > 1 {
+				v, err := strconv.ParseFloat(fields[1], 32)
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				min = float32(v)
+			}
+			fmt.Println(min)
+		case "labels":
+			current := labels.Labels()
+			for _, id := range current.IDs() {
+				fmt.Printf("%v:%v\n", id, current[id])
+			}
+		case "reload":
+			if err := labels.Reload(); err != nil {
+				log.Println(err)
+			}
+		case "help":
+			fmt.Println("predict <image>...  run detection on images, dirs, archives or urls")
+			fmt.Println("threshold [min]     show or set the minimum confidence")
+			fmt.Println("labels              list the class labels")
+			fmt.Println("reload              reread the labels file")
+			fmt.Println("quit")
+		case "quit", "exit":
+			return
+		default:
+			log.Printf("unknown command %q, try help", fields[0])
+		}
 	}
 }
 
-func printDetections(detects []Detect, labelsFile string, min float32) {
-	file, err := os.Open(labelsFile)
+func replPredict(uri string, predict func(image.Image) ([]Detect, error), out DetectWriter, min float32, max int) error {
+	src, err := OpenSource(uri)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
-	if err := scanner.Err(); err != nil {
-		log.Printf("ERROR: failed to read %s: %v", labelsFile, err)
+	defer src.Close()
+	for {
+		frame, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		detects, err := predict(frame.Im)
+		if err != nil {
+			return err
+		}
+		if err := out.Write(frame, filterDetections(detects, min, max)); err != nil {
+			return err
+		}
 	}
+}
 
-	labels := make(map[int]string)
-	for scanner.Scan() {
-		splits := strings.Split(scanner.Text(), ":")
-		id, _ := strconv.Atoi(splits[0])
-		labels[id] = splits[1]
+// embed the crops of detections of the reid classes, matching them against
+// the identities seen so far
+func identify(embedder *model.Embedder, gallery *Gallery, cache *CropCache, im image.Image, detects []Detect, classes map[CID]bool, keep bool) error {
+	embeddings := make([][]float32, len(detects))
+	idx := make([]int, 0, len(detects))
+	crops := make([]image.Image, 0, len(detects))
+	for i, d := range detects {
+		if classes != nil && !classes[d.Class] {
+			continue
+		}
+		crop := Crop(im, d.Bounds)
+		if e, ok := cache.Get(crop); ok {
+			embeddings[i] = e.([]float32)
+			continue
+		}
+		idx = append(idx, i)
+		crops = append(crops, crop)
 	}
 
+	var embedded [][]float32
+	if len(crops) > 0 {
+		var err error
+		if embedded, err = embedder.Embed(crops); err != nil {
+			return err
+		}
+	}
+	for i, e := range embedded {
+		embeddings[idx[i]] = e
+		cache.Put(crops[i], e)
+	}
+	for i, e := range embeddings {
+		if e == nil {
+			continue
+		}
+		d := &detects[i]
+		d.Identity, _ = gallery.Match(e)
+		if keep {
+			d.Embedding = e
+		}
+	}
+	return nil
+}
+
+// plateReader chains plate detection within vehicles, rectification of the
+// plate and ocr
+type plateReader struct {
+	plates  *model.Detector
+	ocr     *model.OCR
+	classes map[CID]bool
+	min     float32
+	// vehicle crop -> plateRead
+	cache *CropCache
+}
+
+type plateRead struct {
+	text string
+	conf float32
+}
+
+// read the most confident plate of each vehicle
+func (r *plateReader) read(im image.Image, detects []Detect) error {
+	idx := make([]int, 0, len(detects))
+	vehicles := make([]image.Image, 0, len(detects))
+	crops := make([]image.Image, 0, len(detects))
+	for i, d := range detects {
+		if r.classes != nil && !r.classes[d.Class] {
+			continue
+		}
+		vehicle := Crop(im, d.Bounds)
+		if v, ok := r.cache.Get(vehicle); ok {
+			detects[i].Plate, detects[i].PlateConfidence = v.(plateRead).text, v.(plateRead).conf
+			continue
+		}
+		plates, err := r.plates.Detect(vehicle, r.min)
+		if err != nil {
+			return err
+		}
+		if len(plates) == 0 {
+			continue
+		}
+		best := plates[0]
+		for _, p := range plates[1:] {
+			if p.Confidence > best.Confidence {
+				best = p
+			}
+		}
+		// corner keypoints straighten plates seen at an angle, otherwise
+		// the box is only scaled
+		quad := BoxQuad(best.Bounds.Add(d.Bounds.Min))
+		if len(best.Keypoints) == 4 {
+			for k, p := range best.Keypoints {
+				quad[k] = p.Add(d.Bounds.Min)
+			}
+		}
+		plate, err := Rectify(im, quad, image.Pt(94, 24))
+		if err != nil {
+			continue
+		}
+		idx = append(idx, i)
+		vehicles = append(vehicles, vehicle)
+		crops = append(crops, plate)
+	}
+
+	texts, confs, err := r.ocr.Read(crops)
+	if err != nil {
+		return err
+	}
+	for i, text := range texts {
+		detects[idx[i]].Plate = text
+		detects[idx[i]].PlateConfidence = confs[i]
+		r.cache.Put(vehicles[i], plateRead{text, confs[i]})
+	}
+	return nil
+}
+
+// cropClassifier gives detections the finer label of a second model, run
+// on their boxes cropped out of the whole image in a graph of its own
+type cropClassifier struct {
+	*model.CropClassifier
+	labels  Labels
+	classes map[CID]bool
+	min     float32
+	softmax string
+	// crop -> subclass
+	cache *CropCache
+}
+
+type subclass struct {
+	name string
+	conf float32
+}
+
+// newCropClassifier preprocesses crops by p, at the size of the model's
+// input shape when it has one, p's otherwise
+func newCropClassifier(modelfile, input, output, labelfile string, p model.Preprocess) (*cropClassifier, error) {
+	labels, err := LoadLabels(labelfile)
+	if err != nil {
+		return nil, err
+	}
+	classifier, err := model.NewClassifier(modelfile, input, output, p.Size)
+	if err != nil {
+		return nil, err
+	}
+	p.Size = classifier.Preprocess.Size
+	if err := p.Check(); err != nil {
+		classifier.Close()
+		return nil, err
+	}
+	classifier.Preprocess = p
+	c, err := model.NewCropClassifier(classifier)
+	if err != nil {
+		classifier.Close()
+		return nil, err
+	}
+	return &cropClassifier{CropClassifier: c, labels: labels}, nil
+}
+
+// label each detection of the classes with the best scoring class of its crop
+func (c *cropClassifier) classify(im image.Image, detects []Detect) error {
+	sub, ok := im.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		// crops of images without SubImage are cut from an rgba copy
+		rgba := image.NewRGBA(im.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), im, im.Bounds().Min, draw.Src)
+		im, sub = rgba, rgba
+	}
+	idx := make([]int, 0, len(detects))
+	boxes := make([]image.Rectangle, 0, len(detects))
+	crops := make([]image.Image, 0, len(detects))
+	for i, d := range detects {
+		if c.classes != nil && !c.classes[d.Class] {
+			continue
+		}
+		box := d.Bounds.Intersect(im.Bounds())
+		if box.Empty() {
+			continue
+		}
+		crop := sub.SubImage(box)
+		if v, ok := c.cache.Get(crop); ok {
+			detects[i].Subclass, detects[i].SubclassConfidence = v.(subclass).name, v.(subclass).conf
+			continue
+		}
+		idx = append(idx, i)
+		boxes = append(boxes, box)
+		crops = append(crops, crop)
+	}
+
+	scores, err := c.ClassifyBoxes(im, boxes)
+	if err != nil {
+		return err
+	}
+	if len(scores) != len(boxes) {
+		return fmt.Errorf("crop classifier: %d scores for %d boxes", len(scores), len(boxes))
+	}
+	for i, s := range scores {
+		s = SoftmaxScores(s, c.softmax)
+		best := 0
+		for k := range s {
+			if s[k] > s[best] {
+				best = k
+			}
+		}
+		var sub subclass
+		if len(s) > 0 && s[best] >= c.min {
+			sub = subclass{c.labels.Name(CID(best)), s[best]}
+		}
+		detects[idx[i]].Subclass, detects[idx[i]].SubclassConfidence = sub.name, sub.conf
+		c.cache.Put(crops[i], sub)
+	}
+	return nil
+}
+
+// the checks run before serving: the model loaded with its input and
+// outputs, labels matching it, the smoke images run through every model
+// served, and sinks writable
+func preflight(det *detector.Detector, modelpath string, loaded time.Duration, labels *LiveLabels, models map[string]Predictor, sinks []string) *Preflight {
+	p := NewPreflight()
+	p.Check("model", func() (string, error) {
+		return fmt.Sprintf("%s loaded in %v", modelpath, loaded.Round(time.Millisecond)), nil
+	})
+	p.Check("ops", func() (string, error) {
+		input, outputs := det.Ops()
+		return fmt.Sprintf("input %s, outputs %s", input, strings.Join(outputs, ", ")), nil
+	})
+	p.Check("labels", func() (string, error) {
+		current := labels.Labels()
+		if len(current) == 0 {
+			return "", fmt.Errorf("%s: no labels", labels.File())
+		}
+		// labels shipped with the model should name the same classes
+		shipped := modelLabels(modelpath, "")
+		if shipped == "" || shipped == labels.File() {
+			return fmt.Sprintf("%d labels in %s", len(current), labels.File()), nil
+		}
+		theirs, err := LoadLabels(shipped)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", shipped, err)
+		}
+		for _, c := range theirs.IDs() {
+			if _, ok := current[c]; !ok {
+				return "", fmt.Errorf("class %d of %s has no label in %s", c, shipped, labels.File())
+			}
+		}
+		return fmt.Sprintf("%d labels in %s, covering the %d of %s", len(current), labels.File(), len(theirs), shipped), nil
+	})
+	p.Check("inference", func() (string, error) {
+		ims := SmokeImages()
+		n := 0
+		for name, predict := range models {
+			for im, smoke := range ims {
+				detects, err := predict(smoke)
+				if err == nil {
+					err = CheckDetects(smoke, detects, labels.Labels())
+				}
+				if err == nil {
+					err = CheckFinds(im, detects, labels.Labels())
+				}
+				if err != nil {
+					return "", fmt.Errorf("model %s, smoke image %s: %v", name, im, err)
+				}
+				n++
+			}
+		}
+		return fmt.Sprintf("%d smoke images through %d models", n, len(models)), nil
+	})
+	p.Check("sinks", func() (string, error) {
+		for _, sink := range sinks {
+			if err := CheckWritable(sink); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("%d writable: %s", len(sinks), strings.Join(sinks, ", ")), nil
+	})
+	return p
+}
+
+// run the smoke images, exiting on the first broken invariant
+func smokeTest(run func(name string, im image.Image) error) {
+	ims := SmokeImages()
+	names := make([]string, 0, len(ims))
+	for name := range ims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		start := time.Now()
+		if err := run(name, ims[name]); err != nil {
+			log.Fatalf("smoke: %s: %v", name, err)
+		}
+		log.Print(T("smoke: %s ok in %v", name, time.Since(start)))
+	}
+	fmt.Println(T("smoke: ok"))
+}
+
+// score each image of the sources with a classifier
+func classifyImages(classifier *model.Classifier, uris []string, labels Namer, format string, top int, min float32, softmax string, scoreFormat func(interface{})) error {
+	out, err := NewScoreWriter(format, os.Stdout, labels, top, min)
+	if err != nil {
+		return err
+	}
+	if out, err = SoftmaxWriter(out, softmax); err != nil {
+		return err
+	}
+	scoreFormat(out)
+	for _, uri := range uris {
+		src, err := OpenSource(uri)
+		if err != nil {
+			return err
+		}
+		for {
+			frame, err := src.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				src.Close()
+				return err
+			}
+			scores, err := classifier.Classify([]image.Image{frame.Im})
+			if err != nil {
+				src.Close()
+				return fmt.Errorf("%s: %v", RedactName(frame.Name), err)
+			}
+			if err := out.Write(RedactName(frame.Name), scores[0]); err != nil {
+				src.Close()
+				return err
+			}
+		}
+		src.Close()
+	}
+	return nil
+}
+
+// SIGUSR1 reloads the model from disk, eg. after a new version is copied
+// over it, and swaps it in for the serving one once it does as well on the
+// canary set, if there is one; the serving model is kept otherwise.
+// swapped is called once a reloaded model is serving.
+func reloadModelOnUsr1(swap *HotSwap, serving *detector.Detector, load func() (*detector.Detector, error), canary *Canary, swapped func()) {
+	var baseline CanaryResult
+	if canary != nil {
+		var err error
+		if baseline, err = canary.Run(serving.DetectImage); err != nil {
+			log.Fatal("canary: ", err)
+		}
+		log.Println("canary: serving model,", baseline)
+	}
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, ReloadSignal)
+	go func() {
+		for range usr1 {
+			NotifySystemd("RELOADING=1")
+			next, err := load()
+			if err != nil {
+				log.Printf("ERROR: failed to reload the model, keeping the serving one: %v", err)
+				NotifySystemd("READY=1")
+				continue
+			}
+			if canary != nil {
+				result, err := canary.Run(next.DetectImage)
+				if err == nil {
+					err = canary.Check(baseline, result)
+				}
+				if err != nil {
+					log.Printf("ERROR: canary refused the reloaded model, keeping the serving one: %v", err)
+					next.Close()
+					NotifySystemd("READY=1")
+					continue
+				}
+				log.Println("canary: reloaded model,", result)
+				baseline = result
+			}
+			swap.Swap(next.DetectImage)
+			serving.Close()
+			serving = next
+			swapped()
+			log.Println("swapped in the reloaded model")
+			NotifySystemd("READY=1")
+		}
+	}()
+}
+
+// flags a -config file can change while running, the rest need a restart
+var liveFlags = map[string]bool{"min": true, "min-score": true, "max-detections": true, "serve-min": true, "serve-classes": true, "scene": true}
+
+// apply the changes to the -config file, logging them
+func reloadConfig(config *Config, apply func() error) {
+	diff, err := config.Reload(flag.CommandLine, liveFlags, apply)
+	for _, d := range diff {
+		log.Println("config:", d)
+	}
+	if err != nil {
+		log.Printf("ERROR: config: %v", err)
+	}
+}
+
+// SIGHUP rereads the labels without reloading the model
+func reloadLabelsOnHup(labels *LiveLabels) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := labels.Reload(); err != nil {
+				log.Printf("ERROR: failed to reload labels: %v", err)
+			} else {
+				log.Println("reloaded labels from", labels.File())
+			}
+		}
+	}()
+}
+
+func openSources(uris []string, screen int, fps float64, region, window string) ([]FrameSource, error) {
+	if screen >= 0 || window != "" {
+		r, err := ParseRegion(region)
+		if err != nil {
+			return nil, err
+		}
+		if screen < 0 {
+			screen = 0
+		}
+		src, err := NewScreenSource(screen, fps, r, window)
+		return []FrameSource{src}, err
+	}
+
+	srcs := make([]FrameSource, 0, len(uris))
+	for _, uri := range uris {
+		src, err := OpenSource(uri)
+		if err != nil {
+			for _, s := range srcs {
+				s.Close()
+			}
+			return nil, err
+		}
+		srcs = append(srcs, src)
+	}
+	return srcs, nil
+}
+
+// detect on the next frame of every source, ending with the shortest source
+func nextDetects(srcs []FrameSource, predict func(image.Image) ([]Detect, error), timeout time.Duration) ([]*Frame, [][]Detect, error) {
+	frames := make([]*Frame, len(srcs))
+	views := make([][]Detect, len(srcs))
+	for i, src := range srcs {
+		frame, err := src.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if src.Meta().Kind != "file" {
+			log.Println("frame:", RedactName(frame.Name))
+		}
+		var detects []Detect
+		err = withTimeout(timeout, RedactName(frame.Name), func() (err error) {
+			detects, err = predict(frame.Im)
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		frames[i], views[i] = frame, detects
+	}
+	return frames, views, nil
+}
+
+// the op names of -output-ops, nil to find them
+func splitOps(ops string) []string {
+	if ops == "" {
+		return nil
+	}
+	return strings.Split(ops, ",")
+}
+
+// labels shipped with a model, in its dir or the dir of the model file
+func modelLabels(modelfile, savedmodel string) string {
+	dir := savedmodel
+	if dir == "" {
+		dir = modelfile
+		if info, err := os.Stat(modelfile); err == nil && !info.IsDir() {
+			dir = filepath.Dir(modelfile)
+		}
+	}
+	// the label map of an object detection api export names its sparse ids,
+	// over a list that may be ordered by them
+	for _, name := range []string{"label_map.pbtxt", "labels.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return filepath.Join(dir, name)
+		}
+	}
+	return ""
+}
+
+// frames of a single source that isn't live, read n at a time so the chips
+// of small images share session runs, and handed out one at a time
+type batchedDetects struct {
+	src     FrameSource
+	n       int
+	detect  func([]image.Image) ([][]Detect, error)
+	timeout time.Duration
+	frames  []*Frame
+	views   [][]Detect
+	// an image that timed out decoding after the batch was started, failed
+	// once the batch is handed out
+	timedOut error
+}
+
+func (b *batchedDetects) next() ([]*Frame, [][]Detect, error) {
+	if len(b.frames) == 0 && b.timedOut != nil {
+		err := b.timedOut
+		b.timedOut = nil
+		return nil, nil, err
+	}
+	if len(b.frames) == 0 {
+		for len(b.frames) < b.n {
+			frame, err := b.src.Next()
+			if err == io.EOF && len(b.frames) > 0 {
+				break
+			}
+			if _, ok := err.(*timeoutError); ok && len(b.frames) > 0 {
+				b.timedOut = err
+				break
+			}
+			if err != nil {
+				b.frames = nil
+				return nil, nil, err
+			}
+			if b.src.Meta().Kind != "file" {
+				log.Println("frame:", RedactName(frame.Name))
+			}
+			b.frames = append(b.frames, frame)
+		}
+		ims := make([]image.Image, len(b.frames))
+		for i, frame := range b.frames {
+			ims[i] = frame.Im
+		}
+		// the whole batch fails together
+		var views [][]Detect
+		err := withTimeout(b.timeout*time.Duration(len(ims)), RedactName(b.frames[0].Name), func() (err error) {
+			views, err = b.detect(ims)
+			return err
+		})
+		if t, ok := err.(*timeoutError); ok && len(b.frames) > 1 {
+			t.name = fmt.Sprintf("%s and %d more", t.name, len(b.frames)-1)
+			t.images = len(b.frames)
+		}
+		if err != nil {
+			b.frames = nil
+			return nil, nil, err
+		}
+		b.views = views
+	}
+	frame, view := b.frames[0], b.views[0]
+	b.frames, b.views = b.frames[1:], b.views[1:]
+	return []*Frame{frame}, [][]Detect{view}, nil
+}
+
+// frames of a single source that isn't live, decoded and detected on by n
+// workers and handed out in order. At most 2n frames are read ahead, so a
+// huge dir isn't held in memory when output is slower than detection.
+type pooledDetects struct {
+	results chan chan pooledResult
+}
+
+type pooledResult struct {
+	frame   *Frame
+	detects []Detect
+	err     error
+}
+
+func newPooledDetects(src FrameSource, n int, predict func(image.Image) ([]Detect, error), timeout time.Duration) *pooledDetects {
+	p := &pooledDetects{results: make(chan chan pooledResult, 2*n)}
+	busy := make(chan bool, n)
+	go func() {
+		defer close(p.results)
+		for i := 0; ; {
+			load, err := NextLoader(src)
+			r := make(chan pooledResult, 1)
+			p.results <- r
+			if err != nil {
+				r <- pooledResult{err: err}
+				return
+			}
+			busy <- true
+			i++
+			go func(i int) {
+				defer func() { <-busy }()
+				// decoded under the timeout too, named by the source
+				// until the frame is
+				var frame *Frame
+				var detects []Detect
+				err := withTimeout(timeout, fmt.Sprintf("%s: image %d", RedactName(src.Meta().URI), i), func() (err error) {
+					if frame, err = load(); err != nil {
+						return err
+					}
+					detects, err = predict(frame.Im)
+					return err
+				})
+				if t, ok := err.(*timeoutError); ok && frame != nil {
+					t.name = RedactName(frame.Name)
+				}
+				r <- pooledResult{frame, detects, err}
+			}(i)
+		}
+	}()
+	return p
+}
+
+func (p *pooledDetects) next() ([]*Frame, [][]Detect, error) {
+	r, ok := <-p.results
+	if !ok {
+		return nil, nil, io.EOF
+	}
+	res := <-r
+	if res.err != nil {
+		return nil, nil, res.err
+	}
+	return []*Frame{res.frame}, [][]Detect{res.detects}, nil
+}
+
+// an image that took longer than -per-image-timeout
+type timeoutError struct {
+	name    string
+	images  int
+	timeout time.Duration
+	// never started, for the runs given up on before it
+	waited bool
+}
+
+func (e *timeoutError) Error() string {
+	if e.waited {
+		return fmt.Sprintf("%s: failed, timed out after %v waiting for images given up on to finish", e.name, e.timeout)
+	}
+	return fmt.Sprintf("%s: failed, timed out after %v", e.name, e.timeout)
+}
+
+// runs of withTimeout going at once, whether waited for or given up on;
+// past maxAbandoned more than the workers, a new run waits for one to
+// finish, within its own timeout, so stuck runs can't pile up
+const maxAbandoned = 4
+
+var abandoned = make(chan bool, 1+maxAbandoned)
+
+// run f, giving up on it after timeout, 0 for never. A session run or a
+// decode can't be cancelled, it carries on in the background and its
+// results are dropped.
+func withTimeout(timeout time.Duration, name string, f func() error) error {
+	if timeout <= 0 {
+		return f()
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case abandoned <- true:
+	case <-timer.C:
+		return &timeoutError{name: name, images: 1, timeout: timeout, waited: true}
+	}
+	done := make(chan error, 1)
+	go func() { done <- f() }()
+	select {
+	case err := <-done:
+		<-abandoned
+		return err
+	case <-timer.C:
+		// its slot is freed once it finishes
+		go func() {
+			<-done
+			<-abandoned
+		}()
+		return &timeoutError{name: name, images: 1, timeout: timeout}
+	}
+}
+
+// timedSource decodes each frame within timeout. A frame given up on is
+// waited for by the next calls, within their timeouts, the source can't be
+// read from twice at once, and dropped.
+type timedSource struct {
+	FrameSource
+	timeout time.Duration
+	// the result of a Next given up on
+	late chan timedFrame
+}
+
+type timedFrame struct {
+	frame *Frame
+	err   error
+}
+
+func (s *timedSource) Next() (*Frame, error) {
+	timeout := s.timeout
+	if s.late != nil {
+		// a source still hung on the frame given up on times out again
+		start := time.Now()
+		timer := time.NewTimer(timeout)
+		select {
+		case r := <-s.late:
+			timer.Stop()
+			s.late = nil
+			if r.err != nil {
+				return nil, r.err
+			}
+		case <-timer.C:
+			return nil, &timeoutError{name: RedactName(s.Meta().URI), images: 1, timeout: s.timeout, waited: true}
+		}
+		if timeout -= time.Since(start); timeout <= 0 {
+			timeout = time.Nanosecond
+		}
+	}
+	done := make(chan timedFrame, 1)
+	err := withTimeout(timeout, RedactName(s.Meta().URI), func() error {
+		frame, err := s.FrameSource.Next()
+		done <- timedFrame{frame, err}
+		return err
+	})
+	if t, ok := err.(*timeoutError); ok {
+		if !t.waited {
+			s.late = done
+		}
+		return nil, err
+	}
+	r := <-done
+	return r.frame, r.err
+}
+
+// merge the detections of every camera into the view of the first
+func mergeViews(srcs []FrameSource, views [][]Detect, merger *Merger) []Detect {
+	keyed := make(map[string][]Detect, len(srcs))
+	for i, src := range srcs {
+		keyed[src.Meta().URI] = views[i]
+	}
+	return merger.Merge(keyed)
+}
+
+// sort by confidence, dropping anything at or below min and past the max
+// highest, 0 for no max
+func filterDetections(detects []Detect, min float32, max int) []Detect {
 	sort.SliceStable(detects, func(i, j int) bool {
 		return detects[i].Confidence > detects[j].Confidence
 	})
+	// squeeze is default; eliminating the 0 entries that inflate ppc
+	n := 0
 	for _, d := range detects {
-		// squeeze is default; eliminating the 0 entries that inflate ppc
 		if d.Confidence > min {
-			fmt.Printf("%v %v %v %v %v %v\n", d.Bounds.Min.X, d.Bounds.Min.Y, d.Bounds.Max.X, d.Bounds.Max.Y, d.Class, d.Confidence)
+			detects[n] = d
+			n++
 		}
 	}
+	if max > 0 && n > max {
+		n = max
+	}
+	return detects[:n]
 }
 
-func loadImageTensor(im []byte) (*tf.Tensor, error) {
-	// DecodeJpeg uses a scalar String-valued tensor as input.
-	tensor, err := tf.NewTensor(string(im))
-	if err != nil {
-		return nil, err
+// the sockets served on, -serve then -grpc, named as the sockets of a
+// launchd job; one socket serves both when they are the same address
+func listeners(serve, grpc string) (names, addrs []string) {
+	if serve != "" {
+		names, addrs = append(names, "serve"), append(addrs, serve)
+	}
+	if grpc != "" && grpc != serve {
+		names, addrs = append(names, "grpc"), append(addrs, grpc)
 	}
+	return names, addrs
+}
 
-	// Construct a graph to normalize the image
-	graph, input, output, err := prepareImageTensor()
-	if err != nil {
-		return nil, err
+// the arguments to run a service with, those given without -install-service
+func serviceArgs() []string {
+	var args []string
+	for _, arg := range os.Args[1:] {
+		if name := strings.TrimLeft(strings.SplitN(arg, "=", 2)[0], "-"); name != "install-service" {
+			args = append(args, arg)
+		}
 	}
-	// Execute that graph to normalize this one image
-	session, err := tf.NewSession(graph, nil)
+	return args
+}
+
+// a video of the single ffmpeg source
+func openVideo(path, encoder string, srcs []FrameSource, fps float64, labels Namer) (*VideoAnnotator, error) {
+	fps, err := sourceFPS(srcs, fps)
 	if err != nil {
 		return nil, err
 	}
-	defer session.Close()
-	normalized, err := session.Run(
-		map[tf.Output]*tf.Tensor{input: tensor},
-		[]tf.Output{output},
-		nil)
+	video, err := NewEncodedVideoWriter(path, fps, encoder)
 	if err != nil {
 		return nil, err
 	}
-	return normalized[0], nil
+	return NewVideoAnnotator(video, labels), nil
 }
 
-func prepareImageTensor() (graph *tf.Graph, input, output tf.Output, err error) {
-	s := op.NewScope()
-	input = op.Placeholder(s, tf.String)
-	// inception 4D tensor of shape
-	// [BatchSize, Height, Width, Colors=3]
-	// https://github.com/DIUx-xView/xview2018-baseline/blob/master/inference/det_util.py#L39
-	output = op.ExpandDims(s,
-		op.DecodeJpeg(s, input, op.DecodeJpegChannels(3)),
-		op.Const(s.SubScope("make_batch"), int32(0)))
-
-	graph, err = s.Finalize()
-	return graph, input, output, err
+// the frame rate of the single ffmpeg source, as ffprobe finds it, as a
+// camera is set to, or the capture rate of the screen
+func sourceFPS(srcs []FrameSource, fps float64) (float64, error) {
+	if len(srcs) != 1 {
+		return 0, fmt.Errorf("encodes a single source, not %d", len(srcs))
+	}
+	meta := srcs[0].Meta()
+	switch meta.Kind {
+	case "video", "rtsp":
+		return ProbeFPS(meta.URI)
+	case "camera":
+		return CameraFPS(meta.URI)
+	case "screen":
+		return fps, nil
+	}
+	return 0, fmt.Errorf("encodes videos, streams, cameras and screen captures, not a %s", meta.Kind)
 }
 
-func writeChips(chips []Chip) {
-	for i, chip := range chips {
-		outputFile, _ := os.Create(fmt.Sprintf("/tmp/chip-%v.jpg", i))
-		jpeg.Encode(outputFile, chip.Im, &jpeg.Options{Quality: 100})
-		outputFile.Close()
+// the preprocessing profile named, or def, with the -input-* flags given
+// set over it
+func preprocessing(name, def, size, mean string, scale float64, dtype string) (model.Preprocess, error) {
+	if name == "" {
+		name = def
+	}
+	p, ok := model.Profiles[name]
+	if !ok {
+		return p, fmt.Errorf("unknown -preprocess %q, expected one of %s", name, model.ProfileNames())
+	}
+	if size != "" {
+		wh := strings.SplitN(size, "x", 2)
+		w, err := strconv.Atoi(wh[0])
+		h := w
+		if err == nil && len(wh) == 2 {
+			h, err = strconv.Atoi(wh[1])
+		}
+		if err != nil {
+			return p, fmt.Errorf("invalid -input-size %q, expected N or WxH", size)
+		}
+		p.Size = image.Pt(w, h)
+	}
+	if mean != "" {
+		parts := strings.Split(mean, ",")
+		if len(parts) != 1 && len(parts) != 3 {
+			return p, fmt.Errorf("invalid -input-mean %q, expected one value or r,g,b", mean)
+		}
+		for c := range p.Mean {
+			v, err := strconv.ParseFloat(strings.TrimSpace(parts[c%len(parts)]), 32)
+			if err != nil {
+				return p, fmt.Errorf("invalid -input-mean %q: %v", mean, err)
+			}
+			p.Mean[c] = float32(v)
+		}
+	}
+	if scale != 0 {
+		p.Scale = float32(scale)
+	}
+	if dtype != "" {
+		p.DType = dtype
 	}
+	return p, nil
 }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/jw3/example-tensorflow-golang/api"
+	. "github.com/jw3/example-tensorflow-golang/common"
+	"google.golang.org/grpc"
+)
+
+func main() {
+	var imagefiles Strings
+	flag.Var(&imagefiles, "image", "Path to an image to run, can be repeated")
+	addr := flag.String("addr", "localhost:9090", "Address of a detect -grpc server")
+	min := flag.Float64("min", 0, "Minimum confidence, 0 for the server's")
+	max := flag.Int("max", 0, "Most detections per image, 0 for the server's")
+	classes := flag.String("classes", "", "Comma separated class ids to answer with, defaults to the server's")
+	stream := flag.Bool("stream", false, "Send the images over a single DetectStream call rather than a Detect call each")
+	timeout := flag.Duration("timeout", time.Minute, "Give up on a call taking longer than this")
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
+
+	SetUsage("detect-client", `Run images on a detect -grpc server, printing a "image xmin ymin xmax ymax class confidence" line per detection. An example client of the Detector service of api/detector.proto.`,
+		"detect-client -addr localhost:9090 -image street.jpg",
+		"detect-client -stream -min 0.5 -image a.jpg -image b.jpg")
+
+	flag.Parse()
+	if *completion != "" {
+		PrintCompletion(*completion, "detect-client")
+		return
+	}
+	if len(imagefiles) == 0 {
+		flag.Usage()
+		return
+	}
+	ids, err := ParseClasses(*classes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	conn, err := grpc.Dial(*addr, grpc.WithInsecure())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	client := api.NewClient(conn)
+
+	requests := make([]*api.DetectRequest, len(imagefiles))
+	for i, file := range imagefiles {
+		im, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		requests[i] = &api.DetectRequest{Image: im, Name: file, Min: float32(*min), Max: int32(*max)}
+		for id := range ids {
+			requests[i].Classes = append(requests[i].Classes, int32(id))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if !*stream {
+		for _, req := range requests {
+			resp, err := client.Detect(ctx, req)
+			if err != nil {
+				log.Fatalf("%s: %v", req.Name, err)
+			}
+			printResponse(resp)
+		}
+		return
+	}
+
+	s, err := client.DetectStream(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		for _, req := range requests {
+			if err := s.Send(req); err != nil {
+				return
+			}
+		}
+		s.CloseSend()
+	}()
+	for {
+		resp, err := s.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		printResponse(resp)
+	}
+}
+
+func printResponse(resp *api.DetectResponse) {
+	for _, d := range resp.Detections {
+		fmt.Printf("%s %v %v %v %v %v %v\n", resp.Name, d.Xmin, d.Ymin, d.Xmax, d.Ymax, d.Class, d.Confidence)
+	}
+}
@@ -0,0 +1,605 @@
+// Package detector runs object detection api models over images of any
+// size, by cutting them into chips of the size the model was trained on, for
+// use by the detect tool and other programs
+package detector // import "github.com/jw3/example-tensorflow-golang/detector"
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	"github.com/jw3/example-tensorflow-golang/model"
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+	"github.com/tensorflow/tensorflow/tensorflow/go/op"
+	"golang.org/x/image/draw"
+)
+
+// Chips are fed to the object detection graph as uint8 [N,H,W,3] rgb, as
+// the xview baseline models take them, with no mean or scale applied; the
+// graph normalizes them itself. Graphs that don't are given another
+// Preprocess profile. Classification models are run by detect -mode
+// classify instead.
+
+// trained chip size
+const (
+	H, W = 544, 544
+)
+
+// Detector holds a loaded model and how images are cut into chips for it
+type Detector struct {
+	Graph   *tf.Graph
+	Session *tf.Session
+	// labels next to the model, class ids are used without
+	Labels Labels
+
+	// square chips of ChipSize pixels, overlapping their neighbours by
+	// Overlap, are scaled to the trained size and run BatchSize at a time
+	ChipSize, Overlap, BatchSize int
+	// same class detections from overlapping chips are merged when they
+	// cover Merge of the smaller box
+	Merge float32
+	// same class detections overlapping a more confident one by NMS IoU
+	// are suppressed, for graphs without nms of their own; 0 keeps them
+	NMS float32
+	// Debug writes each chip to /tmp/chip-N.jpg
+	Debug bool
+	// how chips are fed, the size read from the input shape when it is
+	// static
+	Preprocess model.Preprocess
+	// op or op:index names of the image input, and the boxes, scores,
+	// classes and num_detections outputs, of graphs that name them other
+	// than the object detection api does and that Load can't find them in
+	InputOp   string
+	OutputOps []string
+
+	// image_tensor, and detection_boxes, scores, classes and num_detections,
+	// or the tensors a SavedModel signature maps them to
+	input   tf.Output
+	outputs []tf.Output
+	// decodes chips, opened with the model
+	normalizer *imageNormalizer
+	// the interpreter of a LoadTFLite model, run in place of the session,
+	// one chip at a time
+	lite   liteModel
+	liteMu sync.Mutex
+}
+
+// object detection api op names, and the signature keys of its SavedModels
+var (
+	inputName   = "image_tensor"
+	outputNames = []string{"detection_boxes", "detection_scores", "detection_classes", "num_detections"}
+)
+
+// Detection is a box found by Detect
+type Detection struct {
+	Box   image.Rectangle
+	Class CID
+	Label string
+	Score float32
+}
+
+// New returns a Detector with the chip settings of the trained model
+func New() *Detector {
+	return &Detector{Labels: Labels{}, ChipSize: W, BatchSize: 1, Merge: .5, Preprocess: model.Profiles["xview"]}
+}
+
+// Load reads a frozen graph, either a file or the frozen_inference_graph.pb
+// of an object detection api export dir. A labels.txt or label_map.pbtxt in
+// the dir is read as the labels.
+func (d *Detector) Load(modeldir string) error {
+	modelfile := modeldir
+	if info, err := os.Stat(modeldir); err == nil && info.IsDir() {
+		modelfile = filepath.Join(modeldir, "frozen_inference_graph.pb")
+		for _, name := range []string{"labels.txt", "label_map.pbtxt"} {
+			if _, err := os.Stat(filepath.Join(modeldir, name)); err == nil {
+				if d.Labels, err = LoadLabels(filepath.Join(modeldir, name)); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	def, err := ioutil.ReadFile(modelfile)
+	if err != nil {
+		return err
+	}
+	graph := tf.NewGraph()
+	if err := model.ImportGraph(graph, def); err != nil {
+		return fmt.Errorf("%s: %v", modelfile, err)
+	}
+	session, err := tf.NewSession(graph, model.SessionOptions())
+	if err != nil {
+		return err
+	}
+	d.Graph, d.Session = graph, session
+	// only the side not named is looked for
+	input, outputs := d.InputOp, d.OutputOps
+	m := &model.Model{Graph: graph}
+	if input == "" {
+		if input, err = m.DetectionInput(); err != nil {
+			d.Close()
+			return fmt.Errorf("%s: %v, not an object detection api model, name its input with -input-op", modelfile, err)
+		}
+	}
+	if outputs == nil {
+		if outputs, err = m.DetectionOutputs(); err != nil {
+			d.Close()
+			return fmt.Errorf("%s: %v, not an object detection api model, name its outputs with -output-ops", modelfile, err)
+		}
+	}
+	if err := d.resolve(input, outputs); err != nil {
+		d.Close()
+		return fmt.Errorf("%s: %v", modelfile, err)
+	}
+	return d.openNormalizer()
+}
+
+// LoadSavedModel opens a SavedModel dir, the meta graph with the tags, eg.
+// serve, and finds the image input and detection outputs by the keys of the
+// signature, eg. serving_default, falling back to the op names of a frozen
+// graph for keys it lacks
+func (d *Detector) LoadSavedModel(dir string, tags []string, signature string) error {
+	sigs, err := model.LoadSignatures(dir, tags)
+	if err != nil {
+		return err
+	}
+	sig, ok := sigs[signature]
+	if !ok {
+		return fmt.Errorf("%s: no signature %q", dir, signature)
+	}
+	m, err := model.LoadSavedModel(dir, tags)
+	if err != nil {
+		return err
+	}
+	d.Graph, d.Session = m.Graph, m.Session
+	if labels, err := LoadLabels(filepath.Join(dir, "labels.txt")); err == nil {
+		d.Labels = labels
+	}
+
+	input := inputName
+	if name, ok := sig.Inputs["inputs"]; ok {
+		input = name
+	} else if len(sig.Inputs) == 1 {
+		for _, name := range sig.Inputs {
+			input = name
+		}
+	}
+	outputs := make([]string, len(outputNames))
+	for i, key := range outputNames {
+		outputs[i] = key
+		if name, ok := sig.Outputs[key]; ok {
+			outputs[i] = name
+		}
+	}
+	if d.InputOp != "" {
+		input = d.InputOp
+	}
+	if d.OutputOps != nil {
+		outputs = d.OutputOps
+	}
+	if err := d.resolve(input, outputs); err != nil {
+		d.Close()
+		return fmt.Errorf("%s: signature %s: %v", dir, signature, err)
+	}
+	return d.openNormalizer()
+}
+
+func (d *Detector) openNormalizer() error {
+	normalizer, err := newImageNormalizer(d.Preprocess)
+	if err != nil {
+		d.Close()
+		return err
+	}
+	d.normalizer = normalizer
+	return nil
+}
+
+// resolve the input and outputs, op or op:index names
+func (d *Detector) resolve(input string, outputs []string) error {
+	if len(outputs) != len(outputNames) {
+		return fmt.Errorf("%d outputs, expected %s", len(outputs), strings.Join(outputNames, ", "))
+	}
+	m := &model.Model{Graph: d.Graph}
+	var err error
+	if d.input, err = m.Output(input); err != nil {
+		return err
+	}
+	d.outputs = make([]tf.Output, len(outputs))
+	for i, name := range outputs {
+		if d.outputs[i], err = m.Output(name); err != nil {
+			return err
+		}
+	}
+	if shape := d.input.Shape(); shape.NumDimensions() == 4 && shape.Size(1) > 0 && shape.Size(2) > 0 {
+		d.Preprocess.Size = image.Pt(int(shape.Size(2)), int(shape.Size(1)))
+	}
+	if want := map[string]tf.DataType{"uint8": tf.Uint8, "float32": tf.Float}[d.Preprocess.DType]; d.input.DataType() != want {
+		return fmt.Errorf("input %s takes %v rather than the %s of the preprocessing, pick a profile for the model", input, d.input.DataType(), d.Preprocess.DType)
+	}
+	return d.Preprocess.Check()
+}
+
+func (d *Detector) Close() error {
+	if d.lite != nil {
+		d.lite.close()
+		d.lite = nil
+		return nil
+	}
+	if d.normalizer != nil {
+		d.normalizer.Close()
+		d.normalizer = nil
+	}
+	return d.Session.Close()
+}
+
+// Detect decodes an image, jpeg or any other supported format, and returns
+// its detections highest score first
+func (d *Detector) Detect(im []byte) ([]Detection, error) {
+	decoded, err := ReadJpeg(bytes.NewReader(im))
+	if err != nil {
+		return nil, err
+	}
+	detects, err := d.DetectImage(decoded)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(detects, func(i, j int) bool {
+		return detects[i].Confidence > detects[j].Confidence
+	})
+	out := make([]Detection, len(detects))
+	for i, det := range detects {
+		out[i] = Detection{Box: det.Bounds, Class: det.Class, Label: d.Labels.Name(det.Class), Score: det.Confidence}
+	}
+	return out, nil
+}
+
+// DetectImage returns the detections of im, in its coordinates, along with
+// the chips they were found in
+func (d *Detector) DetectImage(im image.Image) ([]Detect, error) {
+	detects, err := d.DetectImages([]image.Image{im})
+	if err != nil {
+		return nil, err
+	}
+	return detects[0], nil
+}
+
+// DetectImages is DetectImage of several images, with the chips of all of
+// them run BatchSize at a time, so small images share session runs
+func (d *Detector) DetectImages(ims []image.Image) ([][]Detect, error) {
+	if d.Overlap < 0 || d.Overlap >= d.ChipSize {
+		return nil, fmt.Errorf("overlap must be between 0 and the chip size, %v", d.Overlap)
+	}
+	var chips []Chip
+	var owner []int
+	bounds := make([]image.Rectangle, len(ims))
+	for i, im := range ims {
+		c := chipImage(im, d.ChipSize, d.ChipSize, d.Overlap, d.Preprocess.Size)
+		chips = append(chips, c...)
+		for range c {
+			owner = append(owner, i)
+		}
+		bounds[i] = im.Bounds()
+	}
+	if d.Debug && !Private() {
+		writeChips(chips)
+	}
+	var detects [][]Detect
+	var err error
+	if d.lite != nil {
+		detects, err = detectLiteChips(d.lite, &d.liteMu, d.Preprocess, chips, owner, bounds)
+	} else {
+		detects, err = detectChips(d.Session, d.normalizer, d.input, d.outputs, chips, owner, d.BatchSize, bounds)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.NMS > 0 {
+		for i := range detects {
+			detects[i] = NMS(detects[i], d.NMS)
+		}
+	}
+	if d.Overlap == 0 {
+		return detects, nil
+	}
+	for i := range detects {
+		detects[i] = MergeDetects(detects[i], d.Merge)
+	}
+	return detects, nil
+}
+
+// Cost estimates the compute of running a single chip from the graph
+func (d *Detector) Cost() (model.Cost, error) {
+	if d.lite != nil {
+		return model.Cost{}, fmt.Errorf("no cost estimate of tflite models")
+	}
+	m := &model.Model{Graph: d.Graph, Session: d.Session}
+	input := fmt.Sprintf("%s:%d", d.input.Op.Name(), d.input.Index)
+	return m.EstimateCost(input, []int64{1, int64(d.Preprocess.Size.Y), int64(d.Preprocess.Size.X), 3})
+}
+
+// Ops the input and outputs were resolved to, as op:index names
+func (d *Detector) Ops() (input string, outputs []string) {
+	if d.lite != nil {
+		return d.lite.ops()
+	}
+	name := func(o tf.Output) string { return fmt.Sprintf("%s:%d", o.Op.Name(), o.Index) }
+	for _, o := range d.outputs {
+		outputs = append(outputs, name(o))
+	}
+	return name(d.input), outputs
+}
+
+// Chips an image of bounds b is cut into
+func (d *Detector) Chips(b image.Rectangle) int {
+	wn, hn := chipGrid(b, d.ChipSize, d.ChipSize, d.Overlap)
+	return wn * hn
+}
+
+// Warmup runs a blank batch of chips through the graph n times, so kernel
+// compilation and the allocations of the first runs are over before
+// anything is timed
+func (d *Detector) Warmup(n int) error {
+	ims := make([]image.Image, d.BatchSize)
+	for i := range ims {
+		ims[i] = image.NewRGBA(image.Rect(0, 0, d.ChipSize, d.ChipSize))
+	}
+	for i := 0; i < n; i++ {
+		if _, err := d.DetectImages(ims); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// width-number and height-number of the chips of an image of bounds b;
+// partial chips along the right and bottom edges are padded out to size
+func chipGrid(b image.Rectangle, chipW, chipH, overlap int) (int, int) {
+	strideW, strideH := chipW-overlap, chipH-overlap
+	wn := 1 + (b.Dx()-chipW+strideW-1)/strideW
+	hn := 1 + (b.Dy()-chipH+strideH-1)/strideH
+	if wn < 1 {
+		wn = 1
+	}
+	if hn < 1 {
+		hn = 1
+	}
+	return wn, hn
+}
+
+// cut im into chips, scaled to size
+func chipImage(im image.Image, chipW, chipH, overlap int, size image.Point) []Chip {
+	strideW, strideH := chipW-overlap, chipH-overlap
+	wn, hn := chipGrid(im.Bounds(), chipW, chipH, overlap)
+
+	chips := make([]Chip, wn*hn)
+	for i := 0; i < wn*hn; i++ {
+		x := i % wn
+		y := i / wn
+		w := x * strideW
+		h := y * strideH
+
+		chipBounds := image.Rect(w, h, w+chipW, h+chipH)
+		chip := im.(interface {
+			SubImage(r image.Rectangle) image.Image
+		}).SubImage(chipBounds)
+
+		if chip.Bounds().Size() != chipBounds.Size() {
+			padded := image.NewRGBA(image.Rect(0, 0, chipW, chipH))
+			draw.Draw(padded, chip.Bounds().Sub(chipBounds.Min), chip, chip.Bounds().Min, draw.Src)
+			chip = padded
+		}
+
+		if chip.Bounds().Size() != size {
+			scaled := image.NewRGBA(image.Rectangle{Max: size})
+			draw.BiLinear.Scale(scaled, scaled.Bounds(), chip, chip.Bounds(), draw.Over, nil)
+			chip = scaled
+		}
+		chips[i] = Chip{X: x, Y: y, Im: chip, Bounds: chipBounds}
+	}
+	return chips
+}
+
+// run the chips through the graph batch chips at a time, a model exported
+// with a static batch dimension overrides the batch size and the last batch
+// is padded out with blank chips. Chips may come from several images, owner
+// is the image of each chip and the detections are returned per image, with
+// boxes clipped to the bounds of their image, dropping any that only cover
+// the padding.
+func detectChips(session *tf.Session, normalizer *imageNormalizer, input tf.Output, outputs []tf.Output, chips []Chip, owner []int, batch int, bounds []image.Rectangle) ([][]Detect, error) {
+	static := 0
+	if shape := input.Shape(); shape.NumDimensions() > 0 && shape.Size(0) > 0 {
+		static = int(shape.Size(0))
+		batch = static
+	}
+	if batch < 1 {
+		batch = 1
+	}
+
+	detects := make([][]Detect, len(bounds))
+	for i := range detects {
+		detects[i] = make([]Detect, 0)
+	}
+	for start := 0; start < len(chips); start += batch {
+		end := start + batch
+		if end > len(chips) {
+			end = len(chips)
+		}
+
+		ims := make([]image.Image, 0, batch)
+		for i := start; i < end; i++ {
+			ims = append(ims, chips[i].Im)
+		}
+		for len(ims) < static {
+			ims = append(ims, image.NewRGBA(chips[start].Im.Bounds()))
+		}
+		tensor, err := normalizer.Batch(ims)
+		if err != nil {
+			return nil, err
+		}
+		output, err := session.Run(
+			map[tf.Output]*tf.Tensor{
+				input: tensor,
+			},
+			outputs,
+			nil)
+		if err != nil {
+			return nil, err
+		}
+
+		allBoxes, ok0 := output[0].Value().([][][]float32)
+		allScores, ok1 := output[1].Value().([][]float32)
+		allClasses, ok2 := output[2].Value().([][]float32)
+		allNum, ok3 := output[3].Value().([]float32)
+		if !ok0 || !ok1 || !ok2 || !ok3 {
+			return nil, fmt.Errorf("expected float32 boxes [N,D,4], scores [N,D], classes [N,D] and num_detections [N], got %v %v, %v %v, %v %v and %v %v",
+				output[0].DataType(), output[0].Shape(), output[1].DataType(), output[1].Shape(),
+				output[2].DataType(), output[2].Shape(), output[3].DataType(), output[3].Shape())
+		}
+		if len(allBoxes) < end-start || len(allScores) < end-start || len(allClasses) < end-start || len(allNum) < end-start {
+			return nil, fmt.Errorf("expected outputs for %d chips, got %v, %v, %v and %v", end-start,
+				output[0].Shape(), output[1].Shape(), output[2].Shape(), output[3].Shape())
+		}
+
+		// padded slots at the end of the batch are ignored, as are the
+		// slots past num_detections in the fixed size outputs
+		for b := 0; b < end-start; b++ {
+			chip, im := &chips[start+b], owner[start+b]
+			boxes, scores, classes := allBoxes[b], allScores[b], allClasses[b]
+			num := int(allNum[b])
+			if num < len(scores) {
+				scores = scores[:num]
+			}
+			if len(boxes) < len(scores) || len(classes) < len(scores) || len(scores) > 0 && len(boxes[0]) != 4 {
+				return nil, fmt.Errorf("expected a box and class per score, got %v, %v and %v",
+					output[0].Shape(), output[1].Shape(), output[2].Shape())
+			}
+
+			for i, score := range scores {
+				class := classes[i]
+				box := transformBox(chip.Bounds, boxes[i]).Intersect(bounds[im])
+				if box.Empty() {
+					continue
+				}
+				detects[im] = append(detects[im],
+					Detect{
+						Bounds:     box,
+						Class:      CID(class),
+						Chip:       chip,
+						Confidence: score,
+					})
+			}
+		}
+	}
+	return detects, nil
+}
+
+// normalized ymin,xmin,ymax,xmax box within a chip to source image pixels
+func transformBox(chip image.Rectangle, box []float32) image.Rectangle {
+	//     chip pos   ->  world pos
+	w, h := float32(chip.Dx()), float32(chip.Dy())
+	mx := int(box[1]*w) + chip.Min.X
+	Mx := int(box[3]*w) + chip.Min.X
+	my := int(box[0]*h) + chip.Min.Y
+	My := int(box[2]*h) + chip.Min.Y
+
+	return image.Rectangle{
+		Min: image.Point{X: mx, Y: my},
+		Max: image.Point{X: Mx, Y: My},
+	}
+}
+
+// imageNormalizer decodes jpeg chips into the [1, height, width, 3] tensors
+// the model takes, uint8 or float32 with the mean and scale of the
+// preprocessing applied. Its graph is built and its session opened once,
+// and Normalize is safe for concurrent use as session runs are.
+type imageNormalizer struct {
+	session       *tf.Session
+	input, output tf.Output
+}
+
+func newImageNormalizer(p model.Preprocess) (*imageNormalizer, error) {
+	s := op.NewScope()
+	input := op.Placeholder(s, tf.String)
+	// 4D tensor of shape [BatchSize, Height, Width, Colors=3]
+	// https://github.com/DIUx-xView/xview2018-baseline/blob/master/inference/det_util.py#L39
+	output := op.ExpandDims(s,
+		op.DecodeJpeg(s, input, op.DecodeJpegChannels(3)),
+		op.Const(s.SubScope("make_batch"), int32(0)))
+	if p.DType == "float32" {
+		output = op.Div(s,
+			op.Sub(s, op.Cast(s, output, tf.Float), op.Const(s.SubScope("mean"), p.Mean[:])),
+			op.Const(s.SubScope("scale"), p.Scale))
+	}
+
+	graph, err := s.Finalize()
+	if err != nil {
+		return nil, err
+	}
+	session, err := tf.NewSession(graph, model.SessionOptions())
+	if err != nil {
+		return nil, err
+	}
+	return &imageNormalizer{session: session, input: input, output: output}, nil
+}
+
+// Normalize im, a jpeg, chips of images in any format are encoded as jpeg
+// for it
+func (n *imageNormalizer) Normalize(im []byte) (*tf.Tensor, error) {
+	// DecodeJpeg uses a scalar String-valued tensor as input.
+	tensor, err := tf.NewTensor(string(im))
+	if err != nil {
+		return nil, err
+	}
+	normalized, err := n.session.Run(
+		map[tf.Output]*tf.Tensor{n.input: tensor},
+		[]tf.Output{n.output},
+		nil)
+	if err != nil {
+		return nil, err
+	}
+	return normalized[0], nil
+}
+
+// Batch normalizes ims into a [N, height, width, 3] tensor
+func (n *imageNormalizer) Batch(ims []image.Image) (*tf.Tensor, error) {
+	var pixels [][][][]uint8
+	var floats [][][][]float32
+	for _, im := range ims {
+		buf := bytes.Buffer{}
+		jpeg.Encode(&buf, im, nil)
+		tensor, err := n.Normalize(buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		switch v := tensor.Value().(type) {
+		case [][][][]uint8:
+			pixels = append(pixels, v[0])
+		case [][][][]float32:
+			floats = append(floats, v[0])
+		}
+	}
+	if floats != nil {
+		return tf.NewTensor(floats)
+	}
+	return tf.NewTensor(pixels)
+}
+
+func (n *imageNormalizer) Close() error {
+	return n.session.Close()
+}
+
+func writeChips(chips []Chip) {
+	for i, chip := range chips {
+		outputFile, _ := os.Create(fmt.Sprintf("/tmp/chip-%v.jpg", i))
+		jpeg.Encode(outputFile, chip.Im, &jpeg.Options{Quality: 100})
+		outputFile.Close()
+	}
+}
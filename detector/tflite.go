@@ -0,0 +1,75 @@
+package detector
+
+import (
+	"fmt"
+	"image"
+	"sync"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	"github.com/jw3/example-tensorflow-golang/model"
+)
+
+// a tensorflow lite interpreter of a detection model whose outputs are
+// those of the TFLite_Detection_PostProcess op: boxes [1,N,4], classes
+// [1,N], scores [1,N] and num_detections [1]. Only built in with -tags
+// tflite, as it needs libtensorflowlite_c.
+type liteModel interface {
+	// size and dtype of the image input, uint8 or float32
+	input() (image.Point, string)
+	// run a chip of the input size, with the preprocessing of float inputs
+	run(chip image.Image, p model.Preprocess) (boxes [][]float32, classes, scores []float32, err error)
+	// names of the input and the outputs in the order above
+	ops() (string, []string)
+	close()
+}
+
+// LoadTFLite reads a tensorflow lite model, such as a mobilenet ssd
+// converted with its postprocessing, float or quantized uint8, run on
+// threads threads, 0 for all cores. The input size and dtype are the
+// model's, float inputs taking the mean and scale of the Preprocess, or
+// pixels in [-1,1] when it was uint8.
+func (d *Detector) LoadTFLite(file string, threads int) error {
+	lite, err := openLite(file, threads)
+	if err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	size, dtype := lite.input()
+	if dtype == "float32" && d.Preprocess.DType != "float32" {
+		ssd := model.Profiles["ssd"]
+		d.Preprocess.Mean, d.Preprocess.Scale = ssd.Mean, ssd.Scale
+	}
+	d.Preprocess.Size, d.Preprocess.DType = size, dtype
+	if err := d.Preprocess.Check(); err != nil {
+		lite.close()
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	d.lite = lite
+	return nil
+}
+
+// run the chips through the interpreter one at a time, as it runs a single
+// invocation at once. Class ids of the postprocessing count from 0 with no
+// background class, they're shifted by one to the ids of label maps.
+func detectLiteChips(lite liteModel, mu *sync.Mutex, p model.Preprocess, chips []Chip, owner []int, bounds []image.Rectangle) ([][]Detect, error) {
+	detects := make([][]Detect, len(bounds))
+	for i := range detects {
+		detects[i] = make([]Detect, 0)
+	}
+	for i := range chips {
+		chip, im := &chips[i], owner[i]
+		mu.Lock()
+		boxes, classes, scores, err := lite.run(chip.Im, p)
+		mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		for k, score := range scores {
+			box := transformBox(chip.Bounds, boxes[k]).Intersect(bounds[im])
+			if box.Empty() {
+				continue
+			}
+			detects[im] = append(detects[im], Detect{Bounds: box, Class: CID(classes[k]) + 1, Chip: chip, Confidence: score})
+		}
+	}
+	return detects, nil
+}
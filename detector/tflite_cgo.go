@@ -0,0 +1,166 @@
+//go:build tflite
+// +build tflite
+
+package detector
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"runtime"
+	"strings"
+
+	"github.com/jw3/example-tensorflow-golang/model"
+	"github.com/mattn/go-tflite"
+)
+
+type liteInterpreter struct {
+	model       *tflite.Model
+	options     *tflite.InterpreterOptions
+	interpreter *tflite.Interpreter
+	// output indexes of boxes, classes, scores and num_detections
+	outputs [4]int
+}
+
+func openLite(file string, threads int) (liteModel, error) {
+	m := tflite.NewModelFromFile(file)
+	if m == nil {
+		return nil, errors.New("not a tflite model")
+	}
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+	options := tflite.NewInterpreterOptions()
+	options.SetNumThread(threads)
+	l := &liteInterpreter{model: m, options: options, interpreter: tflite.NewInterpreter(m, options)}
+	if l.interpreter == nil {
+		l.close()
+		return nil, errors.New("no interpreter for the model, it may use ops this tflite lacks")
+	}
+	if status := l.interpreter.AllocateTensors(); status != tflite.OK {
+		l.close()
+		return nil, fmt.Errorf("allocating tensors: %v", status)
+	}
+	if err := l.check(); err != nil {
+		l.close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// check the model takes one [1,H,W,3] image and has the outputs of the
+// postprocessing, ordered by the index of their TFLite_Detection_PostProcess
+// name or else as they come
+func (l *liteInterpreter) check() error {
+	if n := l.interpreter.GetInputTensorCount(); n != 1 {
+		return fmt.Errorf("%d inputs, expected a single image", n)
+	}
+	in := l.interpreter.GetInputTensor(0)
+	if in.NumDims() != 4 || in.Dim(0) != 1 || in.Dim(3) != 3 {
+		return fmt.Errorf("input %s of shape %v, expected [1,H,W,3]", in.Name(), dims(in))
+	}
+	if t := in.Type(); t != tflite.UInt8 && t != tflite.Float32 {
+		return fmt.Errorf("input %s of type %v, expected uint8 or float32", in.Name(), t)
+	}
+	if n := l.interpreter.GetOutputTensorCount(); n != 4 {
+		return fmt.Errorf("%d outputs, expected the boxes, classes, scores and num_detections of TFLite_Detection_PostProcess", n)
+	}
+	for i := range l.outputs {
+		l.outputs[i] = i
+	}
+	for i := 0; i < 4; i++ {
+		name := l.interpreter.GetOutputTensor(i).Name()
+		if !strings.HasPrefix(name, "TFLite_Detection_PostProcess") {
+			continue
+		}
+		k := 0
+		fmt.Sscanf(strings.TrimPrefix(name, "TFLite_Detection_PostProcess"), ":%d", &k)
+		if k >= 0 && k < 4 {
+			l.outputs[k] = i
+		}
+	}
+	for k, i := range l.outputs {
+		out := l.interpreter.GetOutputTensor(i)
+		if out.Type() != tflite.Float32 || (k == 0 && (out.NumDims() != 3 || out.Dim(2) != 4)) {
+			return fmt.Errorf("output %s of type %v and shape %v, expected the float outputs of TFLite_Detection_PostProcess", out.Name(), out.Type(), dims(out))
+		}
+	}
+	return nil
+}
+
+func dims(t *tflite.Tensor) []int {
+	d := make([]int, t.NumDims())
+	for i := range d {
+		d[i] = t.Dim(i)
+	}
+	return d
+}
+
+func (l *liteInterpreter) input() (image.Point, string) {
+	in := l.interpreter.GetInputTensor(0)
+	dtype := "uint8"
+	if in.Type() == tflite.Float32 {
+		dtype = "float32"
+	}
+	return image.Pt(in.Dim(2), in.Dim(1)), dtype
+}
+
+func (l *liteInterpreter) run(chip image.Image, p model.Preprocess) ([][]float32, []float32, []float32, error) {
+	in := l.interpreter.GetInputTensor(0)
+	b := chip.Bounds()
+	if in.Type() == tflite.UInt8 {
+		pixels := in.UInt8s()
+		i := 0
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, bl, _ := chip.At(x, y).RGBA()
+				pixels[i], pixels[i+1], pixels[i+2] = uint8(r>>8), uint8(g>>8), uint8(bl>>8)
+				i += 3
+			}
+		}
+	} else {
+		floats := in.Float32s()
+		i := 0
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, bl, _ := chip.At(x, y).RGBA()
+				for c, v := range []uint32{r, g, bl} {
+					floats[i+c] = (float32(v>>8) - p.Mean[c]) / p.Scale
+				}
+				i += 3
+			}
+		}
+	}
+	if status := l.interpreter.Invoke(); status != tflite.OK {
+		return nil, nil, nil, fmt.Errorf("tflite invoke: %v", status)
+	}
+	out := func(k int) []float32 {
+		return l.interpreter.GetOutputTensor(l.outputs[k]).Float32s()
+	}
+	flat, classes, scores := out(0), out(1), out(2)
+	num := int(out(3)[0])
+	if num > len(scores) {
+		num = len(scores)
+	}
+	boxes := make([][]float32, num)
+	for i := range boxes {
+		boxes[i] = append([]float32(nil), flat[i*4:i*4+4]...)
+	}
+	return boxes, append([]float32(nil), classes[:num]...), append([]float32(nil), scores[:num]...), nil
+}
+
+func (l *liteInterpreter) ops() (string, []string) {
+	outputs := make([]string, len(l.outputs))
+	for k, i := range l.outputs {
+		outputs[k] = l.interpreter.GetOutputTensor(i).Name()
+	}
+	return l.interpreter.GetInputTensor(0).Name(), outputs
+}
+
+func (l *liteInterpreter) close() {
+	if l.interpreter != nil {
+		l.interpreter.Delete()
+	}
+	l.options.Delete()
+	l.model.Delete()
+}
@@ -0,0 +1,10 @@
+//go:build !tflite
+// +build !tflite
+
+package detector
+
+import "errors"
+
+func openLite(file string, threads int) (liteModel, error) {
+	return nil, errors.New("tflite models need a build with -tags tflite and libtensorflowlite_c")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	"github.com/jw3/example-tensorflow-golang/model"
+)
+
+func main() {
+	in := flag.String("in", "", "SavedModel dir, or a GraphDef with -restore")
+	restore := flag.String("restore", "", "Checkpoint of the variables of an -in GraphDef, eg. model.ckpt-1000 or a training dir")
+	tags := flag.String("tags", "serve", "Comma separated tags of the SavedModel graph")
+	out := flag.String("out", "frozen.pb", "Path to write the frozen graph to")
+	outputs := flag.String("outputs", "", "Comma separated output ops to keep, with everything they depend on")
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
+
+	SetUsage("freeze", `Freeze a SavedModel, or a GraphDef and checkpoint, into a GraphDef with its
+variables as constants, as detect and the other tools load. Nodes the outputs
+don't depend on, eg. training and saving, are left out.`,
+		"freeze -in saved_model/ -out frozen.pb -outputs detection_boxes,detection_scores,detection_classes,num_detections",
+		"freeze -in graph.pb -restore train/ -out frozen.pb -outputs logits")
+
+	flag.Parse()
+	if *completion != "" {
+		PrintCompletion(*completion, "freeze")
+		return
+	}
+	if *in == "" || *outputs == "" {
+		flag.Usage()
+		return
+	}
+
+	var m *model.Model
+	info, err := os.Stat(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if info.IsDir() {
+		m, err = model.LoadSavedModel(*in, strings.Split(*tags, ","))
+	} else {
+		m, err = model.Load(*in)
+		if err == nil && *restore != "" {
+			err = m.Restore(*restore)
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer m.Close()
+
+	frozen, err := m.Freeze(strings.Split(*outputs, ","))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*out, frozen, 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %s, %d bytes", *out, len(frozen))
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	"github.com/jw3/example-tensorflow-golang/model"
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+func main() {
+	modelfile := flag.String("model", "", "Path to a frozen graph")
+	suggest := flag.Bool("suggest-io", false, "Suggest the likely input and output ops, and the flags to run the model with")
+	checkOps := flag.Bool("check-ops", false, "List the op types of the graph the linked tensorflow doesn't register, without loading it")
+	input := flag.String("input", "", "Input op to propagate -input-shape from, the likeliest input if empty")
+	inputShape := flag.String("input-shape", "", "Shape to propagate from the input through the graph, eg. 1,300,300,3 with ? for unknown dims")
+	cost := flag.Bool("cost", false, "With -input-shape, also estimate the flops of a run on an input of that shape, for capacity planning")
+	var outputOps Strings
+	flag.Var(&outputOps, "output", "Output to report the shape of, op:index for outputs past the first; the likely outputs if none. Repeatable")
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
+
+	SetUsage("inspect", `List the ops of a graph with their types and shapes, or with -suggest-io
+the ops that look like its inputs and outputs, for unfamiliar models. With
+-input-shape the shapes of the outputs are propagated from the input, and
+an input shape the graph can't take is reported.`,
+		"inspect -model model.pb",
+		"inspect -model model.pb -suggest-io",
+		"inspect -model model.pb -check-ops",
+		"inspect -model model.pb -input image_tensor -input-shape 1,300,300,3 -output detection_boxes",
+		"inspect -model model.pb -input-shape 1,544,544,3 -cost")
+
+	flag.Parse()
+	if *completion != "" {
+		PrintCompletion(*completion, "inspect")
+		return
+	}
+	if *modelfile == "" {
+		flag.Usage()
+		return
+	}
+
+	if *checkOps {
+		def, err := ioutil.ReadFile(*modelfile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		missing, err := model.MissingOps(def)
+		if err != nil {
+			log.Fatalf("%s: %v", *modelfile, err)
+		}
+		if len(missing) == 0 {
+			fmt.Printf("every op is registered in tensorflow %s\n", tf.Version())
+			return
+		}
+		fmt.Printf("not registered in tensorflow %s:\n", tf.Version())
+		for _, op := range missing {
+			fmt.Printf("  %s\n", op)
+		}
+		os.Exit(1)
+	}
+
+	m, err := model.Load(*modelfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer m.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if *inputShape != "" {
+		shape, err := model.ParseShape(*inputShape)
+		if err != nil {
+			log.Fatal(err)
+		}
+		inputs, suggested := m.SuggestIO()
+		if *input == "" {
+			if len(inputs) == 0 {
+				log.Fatal("no placeholders in graph, give the -input")
+			}
+			*input = inputs[0].Op
+		}
+		if len(outputOps) == 0 {
+			for _, s := range suggested {
+				outputOps = append(outputOps, s.Op)
+			}
+		}
+		in, err := m.Output(*input)
+		if err != nil {
+			log.Fatal(err)
+		}
+		reports, err := m.PropagateShapes(*input, shape, outputOps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(w, "op\tdtype\tdeclared\tpropagated\n")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", *input, model.TypeName(in.DataType()), model.ShapeString(in.Shape()), *inputShape)
+		for _, r := range reports {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Output, r.DType, r.Declared, r.Propagated)
+		}
+		w.Flush()
+		if *cost {
+			c, err := m.EstimateCost(*input, shape)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Printf("\ncost: %s a run, %d ops", FormatFLOPs(c.FLOPs), c.Ops)
+			if c.Unknown > 0 {
+				fmt.Printf(", %d convolutions and products of unknown shape left out", c.Unknown)
+			}
+			fmt.Println()
+		}
+		return
+	}
+	if !*suggest {
+		ops := m.Graph.Operations()
+		for i := range ops {
+			op := &ops[i]
+			if op.NumOutputs() == 0 {
+				fmt.Fprintf(w, "%s\t%s\t\t\n", op.Name(), op.Type())
+				continue
+			}
+			out := op.Output(0)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", op.Name(), op.Type(), model.TypeName(out.DataType()), model.ShapeString(out.Shape()))
+		}
+		w.Flush()
+		return
+	}
+
+	inputs, outputs := m.SuggestIO()
+	for _, section := range []struct {
+		title       string
+		suggestions []model.Suggestion
+	}{{"inputs", inputs}, {"outputs", outputs}} {
+		fmt.Fprintf(w, "%s\n", section.title)
+		for _, s := range section.suggestions {
+			fmt.Fprintf(w, "  %s\t%s\t%s %s\t%s\n", s.Op, s.Type, s.DType, s.Shape, s.Reason)
+		}
+	}
+	w.Flush()
+	if len(inputs) > 0 && len(outputs) > 0 {
+		fmt.Printf("\nsuggested: -input %s -output %s\n", inputs[0].Op, outputs[0].Op)
+	}
+}
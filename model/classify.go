@@ -0,0 +1,66 @@
+package model
+
+import (
+	"image"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// Classifier is a model scoring each class for whole images, it is run the
+// same way as an Embedder with the scores in place of the embedding
+type Classifier struct {
+	*Embedder
+}
+
+// NewClassifier loads a model taking [N,H,W,3] float pixels in [0,1] and
+// producing [N,C] or [N,1,1,C] class scores
+func NewClassifier(modelfile, input, output string, size image.Point) (*Classifier, error) {
+	e, err := NewEmbedder(modelfile, input, output, size)
+	if err != nil {
+		return nil, err
+	}
+	return &Classifier{e}, nil
+}
+
+func (c *Classifier) Classify(ims []image.Image) ([][]float32, error) {
+	return c.Embed(ims)
+}
+
+// CropClassifier classifies boxes of whole images, eg. the make and model of
+// detected cars: the boxes are cut out by a BoxCropper in its session and
+// the crops fed to the classifier's
+type CropClassifier struct {
+	*Classifier
+	cropper *BoxCropper
+}
+
+// NewCropClassifier crops for c as it is preprocessed, which is to be set
+// before
+func NewCropClassifier(c *Classifier) (*CropClassifier, error) {
+	cropper, err := NewBoxCropper(c.Preprocess)
+	if err != nil {
+		return nil, err
+	}
+	return &CropClassifier{Classifier: c, cropper: cropper}, nil
+}
+
+// ClassifyBoxes scores the classes of each box of im, boxes in its pixels
+func (c *CropClassifier) ClassifyBoxes(im image.Image, boxes []image.Rectangle) ([][]float32, error) {
+	if len(boxes) == 0 {
+		return nil, nil
+	}
+	crops, err := c.cropper.Crop(im, boxes)
+	if err != nil {
+		return nil, err
+	}
+	output, err := c.Session.Run(map[tf.Output]*tf.Tensor{c.input: crops}, []tf.Output{c.output}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.features(output[0])
+}
+
+func (c *CropClassifier) Close() error {
+	c.cropper.Close()
+	return c.Classifier.Close()
+}
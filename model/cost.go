@@ -0,0 +1,156 @@
+package model
+
+import (
+	"strings"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// Cost is an estimate of the compute of one run of a model, from the
+// shapes of its graph propagated from the input
+type Cost struct {
+	// ops of the graph
+	Ops int
+	// floating point operations of its convolutions and matrix products, a
+	// multiply-add counting as two; the rest of a graph is cheap beside them
+	FLOPs float64
+	// convolutions and products whose shapes aren't static, left out of FLOPs
+	Unknown int
+}
+
+// EstimateCost of running the model on an input of shape. An unknown batch
+// dim of an op is taken as the input's.
+func (m *Model) EstimateCost(input string, shape []int64) (Cost, error) {
+	p, err := m.propagate(input, shape)
+	if err != nil {
+		return Cost{}, err
+	}
+	batch := int64(1)
+	if len(shape) > 0 && shape[0] > 0 {
+		batch = shape[0]
+	}
+	ops := p.Graph.Operations()
+	// the inputs of each op, from the consumers of every output
+	inputs := make(map[string][]tf.Output)
+	for i := range ops {
+		for k := 0; k < ops[i].NumOutputs(); k++ {
+			out := ops[i].Output(k)
+			for _, c := range out.Consumers() {
+				in := inputs[c.Op.Name()]
+				for len(in) <= c.Index {
+					in = append(in, tf.Output{})
+				}
+				in[c.Index] = out
+				inputs[c.Op.Name()] = in
+			}
+		}
+	}
+	cost := Cost{Ops: len(m.Graph.Operations())}
+	for i := range ops {
+		flops, ok := opFLOPs(&ops[i], inputs[ops[i].Name()], batch)
+		if !ok {
+			cost.Unknown++
+		}
+		cost.FLOPs += flops
+	}
+	return cost, nil
+}
+
+// flops of an op, false when they can't be told from its shapes
+func opFLOPs(o *tf.Operation, in []tf.Output, batch int64) (float64, bool) {
+	dims := func(i int) []int64 {
+		if i >= len(in) || in[i].Op == nil {
+			return nil
+		}
+		return shapeDims(in[i].Shape(), batch)
+	}
+	switch o.Type() {
+	case "Conv2D", "DepthwiseConv2dNative", "Conv2DBackpropInput", "MatMul", "BatchMatMul", "BatchMatMulV2":
+	default:
+		return 0, true
+	}
+	out := shapeDims(o.Output(0).Shape(), batch)
+	if out == nil {
+		return 0, false
+	}
+	n := float64(1)
+	for _, d := range out {
+		n *= float64(d)
+	}
+	switch o.Type() {
+	case "Conv2D":
+		// filter [kh,kw,in,out], each output a sum over kh*kw*in
+		if f := dims(1); len(f) == 4 {
+			return 2 * n * float64(f[0]*f[1]*f[2]), true
+		}
+	case "DepthwiseConv2dNative":
+		if f := dims(1); len(f) == 4 {
+			return 2 * n * float64(f[0]*f[1]), true
+		}
+	case "Conv2DBackpropInput":
+		// a transposed convolution, each of the out_backprop values
+		// spread over kh*kw*in
+		f, g := dims(1), dims(2)
+		if len(f) == 4 && g != nil {
+			m := float64(1)
+			for _, d := range g {
+				m *= float64(d)
+			}
+			return 2 * m * float64(f[0]*f[1]*f[2]), true
+		}
+	case "MatMul", "BatchMatMul", "BatchMatMulV2":
+		// the shared dim, the last of a unless it's transposed
+		a := dims(0)
+		if len(a) < 2 {
+			break
+		}
+		k := a[len(a)-1]
+		for _, attr := range []string{"transpose_a", "adj_x"} {
+			if t, err := o.Attr(attr); err == nil && t == true {
+				k = a[len(a)-2]
+			}
+		}
+		return 2 * n * float64(k), true
+	}
+	return 0, false
+}
+
+// the dims of a static shape, nil when any but the batch is unknown
+func shapeDims(s tf.Shape, batch int64) []int64 {
+	rank := s.NumDimensions()
+	if rank < 0 {
+		return nil
+	}
+	dims := make([]int64, rank)
+	for i := range dims {
+		dims[i] = s.Size(i)
+		if dims[i] < 0 && i == 0 {
+			dims[i] = batch
+		}
+		if dims[i] < 0 {
+			return nil
+		}
+	}
+	return dims
+}
+
+// Device a session runs on: the first gpu when there is one, as tf places
+// ops there by default, or the cpu, eg. GPU:0
+func Device(s *tf.Session) string {
+	devices, err := s.ListDevices()
+	if err != nil || len(devices) == 0 {
+		return "unknown"
+	}
+	device := devices[0]
+	for _, d := range devices {
+		if d.Type == "GPU" {
+			device = d
+			break
+		}
+	}
+	// /job:localhost/replica:0/task:0/device:GPU:0
+	if i := strings.LastIndex(device.Name, "device:"); i >= 0 {
+		return device.Name[i+len("device:"):]
+	}
+	return device.Name
+}
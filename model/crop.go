@@ -0,0 +1,115 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+	"github.com/tensorflow/tensorflow/tensorflow/go/op"
+)
+
+// BoxCropper cuts boxes out of an image with CropAndResize, in a graph and
+// session of its own, into the [N,H,W,3] input of a model taking crops with
+// the mean and scale of its preprocessing applied
+type BoxCropper struct {
+	session                       *tf.Session
+	image, boxes, indices, output tf.Output
+}
+
+func NewBoxCropper(p Preprocess) (*BoxCropper, error) {
+	if err := p.Check(); err != nil {
+		return nil, err
+	}
+	s := op.NewScope()
+	im := op.Placeholder(s.SubScope("image"), tf.Uint8, op.PlaceholderShape(tf.MakeShape(1, -1, -1, 3)))
+	// normalized ymin,xmin,ymax,xmax and the image of each, always 0
+	boxes := op.Placeholder(s.SubScope("boxes"), tf.Float, op.PlaceholderShape(tf.MakeShape(-1, 4)))
+	indices := op.Placeholder(s.SubScope("indices"), tf.Int32, op.PlaceholderShape(tf.MakeShape(-1)))
+	// bilinear crops, always float
+	output := op.CropAndResize(s, im, boxes, indices,
+		op.Const(s.SubScope("crop_size"), []int32{int32(p.Size.Y), int32(p.Size.X)}))
+	if p.DType == "uint8" {
+		output = op.Cast(s, op.Round(s, output), tf.Uint8)
+	} else {
+		output = op.Div(s,
+			op.Sub(s, output, op.Const(s.SubScope("mean"), p.Mean[:])),
+			op.Const(s.SubScope("scale"), p.Scale))
+	}
+
+	graph, err := s.Finalize()
+	if err != nil {
+		return nil, err
+	}
+	session, err := tf.NewSession(graph, SessionOptions())
+	if err != nil {
+		return nil, err
+	}
+	return &BoxCropper{session: session, image: im, boxes: boxes, indices: indices, output: output}, nil
+}
+
+// Crop the boxes out of im, boxes in the pixels of im
+func (c *BoxCropper) Crop(im image.Image, boxes []image.Rectangle) (*tf.Tensor, error) {
+	b := im.Bounds()
+	if b.Empty() {
+		return nil, fmt.Errorf("crop: empty image")
+	}
+	// CropAndResize maps 0 to the first row or column and 1 to the last
+	h, w := float32(b.Dy()-1), float32(b.Dx()-1)
+	if h == 0 {
+		h = 1
+	}
+	if w == 0 {
+		w = 1
+	}
+	norm := make([][]float32, len(boxes))
+	indices := make([]int32, len(boxes))
+	for i, r := range boxes {
+		r = r.Sub(b.Min)
+		norm[i] = []float32{float32(r.Min.Y) / h, float32(r.Min.X) / w, float32(r.Max.Y-1) / h, float32(r.Max.X-1) / w}
+	}
+	pixels, err := imageTensor(im)
+	if err != nil {
+		return nil, err
+	}
+	boxesTensor, err := tf.NewTensor(norm)
+	if err != nil {
+		return nil, err
+	}
+	indicesTensor, err := tf.NewTensor(indices)
+	if err != nil {
+		return nil, err
+	}
+	output, err := c.session.Run(
+		map[tf.Output]*tf.Tensor{c.image: pixels, c.boxes: boxesTensor, c.indices: indicesTensor},
+		[]tf.Output{c.output},
+		nil)
+	if err != nil {
+		return nil, err
+	}
+	return output[0], nil
+}
+
+func (c *BoxCropper) Close() error {
+	return c.session.Close()
+}
+
+// [1,H,W,3] uint8 rgb of im, read in one go from its packed pixels rather
+// than built up as nested slices, as whole frames are large
+func imageTensor(im image.Image) (*tf.Tensor, error) {
+	b := im.Bounds()
+	rgba, ok := im.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(b)
+		draw.Draw(rgba, b, im, b.Min, draw.Src)
+	}
+	rgb := make([]byte, 0, 3*b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := rgba.Pix[rgba.PixOffset(b.Min.X, y):rgba.PixOffset(b.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			rgb = append(rgb, row[i], row[i+1], row[i+2])
+		}
+	}
+	return tf.ReadTensor(tf.Uint8, []int64{1, int64(b.Dy()), int64(b.Dx()), 3}, bytes.NewReader(rgb))
+}
@@ -0,0 +1,124 @@
+package model
+
+import (
+	"fmt"
+	"image"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+	"golang.org/x/image/draw"
+)
+
+// Detector runs an object detection api model over whole images, for the
+// secondary models of a pipeline such as plate detection within vehicles
+type Detector struct {
+	*Model
+	input   tf.Output
+	outputs []tf.Output
+	// models trained with keypoints also predict detection_keypoints
+	keypoints bool
+}
+
+// NewDetector loads an object detection model, its image input and boxes,
+// scores, classes and num_detections outputs named by input and outputs, or
+// found by their object detection api names when "" and nil
+func NewDetector(modelfile, input string, outputs []string) (*Detector, error) {
+	m, err := Load(modelfile)
+	if err != nil {
+		return nil, err
+	}
+	d := &Detector{Model: m}
+	if input == "" {
+		if input, err = m.DetectionInput(); err != nil {
+			m.Close()
+			return nil, fmt.Errorf("%s: %v", modelfile, err)
+		}
+	}
+	if outputs == nil {
+		if outputs, err = m.DetectionOutputs(); err != nil {
+			m.Close()
+			return nil, fmt.Errorf("%s: %v", modelfile, err)
+		}
+	} else if len(outputs) != len(detectionOutputs) {
+		m.Close()
+		return nil, fmt.Errorf("%s: %d outputs, expected boxes, scores, classes and num_detections", modelfile, len(outputs))
+	}
+	if d.input, err = m.Output(input); err != nil {
+		m.Close()
+		return nil, err
+	}
+	for _, name := range outputs {
+		out, err := m.Output(name)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		d.outputs = append(d.outputs, out)
+	}
+	if out, err := m.Output("detection_keypoints"); err == nil {
+		d.outputs = append(d.outputs, out)
+		d.keypoints = true
+	}
+	return d, nil
+}
+
+// Detect returns the detections of im above min, in the coordinates of im
+func (d *Detector) Detect(im image.Image, min float32) ([]Detect, error) {
+	tensor, err := tf.NewTensor([][][][]uint8{BytePixels(im)})
+	if err != nil {
+		return nil, err
+	}
+	output, err := d.Session.Run(map[tf.Output]*tf.Tensor{d.input: tensor}, d.outputs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	b := im.Bounds()
+	w, h := float32(b.Dx()), float32(b.Dy())
+	pt := func(y, x float32) image.Point {
+		return image.Pt(b.Min.X+int(x*w), b.Min.Y+int(y*h))
+	}
+
+	boxes := output[0].Value().([][][]float32)[0]
+	scores := output[1].Value().([][]float32)[0]
+	classes := output[2].Value().([][]float32)[0]
+	num := int(output[3].Value().([]float32)[0])
+	detects := make([]Detect, 0)
+	for i := 0; i < num && i < len(scores); i++ {
+		if scores[i] <= min {
+			continue
+		}
+		det := Detect{
+			Bounds:     image.Rectangle{Min: pt(boxes[i][0], boxes[i][1]), Max: pt(boxes[i][2], boxes[i][3])},
+			Class:      CID(classes[i]),
+			Confidence: scores[i],
+		}
+		if d.keypoints {
+			// normalized y,x per keypoint
+			for _, kp := range output[4].Value().([][][][]float32)[0][i] {
+				det.Keypoints = append(det.Keypoints, pt(kp[0], kp[1]))
+			}
+		}
+		detects = append(detects, det)
+	}
+	return detects, nil
+}
+
+// BytePixels is im as [H][W][3] rgb bytes, the image_tensor layout
+func BytePixels(im image.Image) [][][]uint8 {
+	b := im.Bounds()
+	rgba, ok := im.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(b)
+		draw.Draw(rgba, b, im, b.Min, draw.Src)
+	}
+	px := make([][][]uint8, b.Dy())
+	for y := range px {
+		px[y] = make([][]uint8, b.Dx())
+		for x := range px[y] {
+			o := rgba.PixOffset(b.Min.X+x, b.Min.Y+y)
+			px[y][x] = []uint8{rgba.Pix[o], rgba.Pix[o+1], rgba.Pix[o+2]}
+		}
+	}
+	return px
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"fmt"
+	"image"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// Embedder runs a re-identification model over crops, producing one
+// embedding vector per crop
+type Embedder struct {
+	*Model
+	input, output tf.Output
+	// how crops are fed, float pixels in [0,1] unless set
+	Preprocess Preprocess
+}
+
+// NewEmbedder loads an embedding model taking [N,H,W,3] float pixels in [0,1],
+// the crop size is read from the input shape when it is static
+func NewEmbedder(modelfile, input, output string, size image.Point) (*Embedder, error) {
+	m, err := Load(modelfile)
+	if err != nil {
+		return nil, err
+	}
+	e := &Embedder{Model: m, Preprocess: Profiles["unit"]}
+	e.Preprocess.Size = size
+	if e.input, err = m.Output(input); err != nil {
+		return nil, err
+	}
+	if e.output, err = m.Output(output); err != nil {
+		return nil, err
+	}
+	if shape := e.input.Shape(); shape.NumDimensions() == 4 && shape.Size(1) > 0 && shape.Size(2) > 0 {
+		e.Preprocess.Size = image.Pt(int(shape.Size(2)), int(shape.Size(1)))
+	}
+	return e, nil
+}
+
+// Warmup runs a blank image through the graph n times, before anything is
+// timed
+func (e *Embedder) Warmup(n int) error {
+	blank := image.NewRGBA(image.Rectangle{Max: e.Preprocess.Size})
+	for i := 0; i < n; i++ {
+		if _, err := e.Embed([]image.Image{blank}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Embedder) Embed(crops []image.Image) ([][]float32, error) {
+	if len(crops) == 0 {
+		return nil, nil
+	}
+	tensor, err := e.Preprocess.Tensor(crops)
+	if err != nil {
+		return nil, err
+	}
+	output, err := e.Session.Run(map[tf.Output]*tf.Tensor{e.input: tensor}, []tf.Output{e.output}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return e.features(output[0])
+}
+
+// features of the output tensor, [N,D] or squeezed from [N,1,1,D]
+func (e *Embedder) features(output *tf.Tensor) ([][]float32, error) {
+	switch v := output.Value().(type) {
+	case [][]float32:
+		return v, nil
+	case [][][][]float32:
+		// pooling outputs [N,1,1,D], squeezed to [N,D]
+		features := make([][]float32, len(v))
+		for n := range v {
+			if len(v[n]) != 1 || len(v[n][0]) != 1 {
+				return nil, fmt.Errorf("%s: expected [N,D] or [N,1,1,D] features, got %v", e.output.Op.Name(), output.Shape())
+			}
+			features[n] = v[n][0][0]
+		}
+		return features, nil
+	}
+	return nil, fmt.Errorf("%s: expected [N,D] or [N,1,1,D] float features, got %v %v", e.output.Op.Name(), output.DataType(), output.Shape())
+}
+
+// FloatPixels scales im to size, as [H][W][3] rgb values in [0,1]
+func FloatPixels(im image.Image, size image.Point) [][][]float32 {
+	p := Profiles["unit"]
+	p.Size = size
+	return p.Floats(im)
+}
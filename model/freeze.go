@@ -0,0 +1,113 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// ops that call functions of the graph library, as tf2 exports use for
+// everything, and can't be frozen by rewriting nodes
+var functionCalls = map[string]bool{"StatefulPartitionedCall": true, "PartitionedCall": true}
+
+// Freeze serializes the graph with its variables replaced by constants of
+// their current values, keeping only the nodes the outputs depend on. Ref
+// variables become constants, and so do the reads of resource variables.
+func (m *Model) Freeze(outputs []string) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if _, err := m.Graph.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	nodes, rest, err := parseGraphDef(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("graph: %v", err)
+	}
+	for i := range outputs {
+		outputs[i] = inputNode(outputs[i])
+	}
+
+	keep, err := reachable(nodes, outputs)
+	if err != nil {
+		return nil, err
+	}
+	var fetches []tf.Output
+	var frozen []*graphNode
+	for _, n := range nodes {
+		if !keep[n.name] {
+			continue
+		}
+		switch n.op {
+		case "VariableV2", "Variable", "ReadVariableOp":
+			op := m.Graph.Operation(n.name)
+			if op == nil {
+				return nil, fmt.Errorf("no operation %q in graph", n.name)
+			}
+			fetches, frozen = append(fetches, op.Output(0)), append(frozen, n)
+		}
+	}
+	values, err := m.Session.Run(nil, fetches, nil)
+	if err != nil {
+		return nil, err
+	}
+	for i, n := range frozen {
+		t := values[i]
+		if t.DataType() == tf.String {
+			return nil, fmt.Errorf("%s: string variables can't be frozen", n.name)
+		}
+		content := bytes.Buffer{}
+		if _, err := t.WriteContentsTo(&content); err != nil {
+			return nil, err
+		}
+		n.op, n.inputs = "Const", nil
+		n.raw = constNode(n.name, int(t.DataType()), t.Shape(), content.Bytes())
+	}
+
+	// the resource variables read by now constant reads drop out
+	if keep, err = reachable(nodes, outputs); err != nil {
+		return nil, err
+	}
+	var out []byte
+	for _, n := range nodes {
+		if !keep[n.name] {
+			continue
+		}
+		switch {
+		case n.op == "VarHandleOp":
+			return nil, fmt.Errorf("resource variable %s is used other than by reads, and can't be frozen", n.name)
+		case functionCalls[n.op]:
+			return nil, fmt.Errorf("%s calls a function, graphs of tf2 functions can't be frozen", n.name)
+		}
+		out = appendBytesField(out, 1, n.raw)
+	}
+	for _, f := range rest {
+		out = append(out, f...)
+	}
+	return out, nil
+}
+
+// names of the nodes outputs depend on, through data and control inputs
+func reachable(nodes []*graphNode, outputs []string) (map[string]bool, error) {
+	byName := make(map[string]*graphNode, len(nodes))
+	for _, n := range nodes {
+		byName[n.name] = n
+	}
+	keep := make(map[string]bool)
+	queue := append([]string{}, outputs...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if keep[name] {
+			continue
+		}
+		n, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("no node %q in graph", name)
+		}
+		keep[name] = true
+		for _, in := range n.inputs {
+			queue = append(queue, inputNode(in))
+		}
+	}
+	return keep, nil
+}
@@ -0,0 +1,164 @@
+package model
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// protobuf wire format, enough of it to rewrite the nodes of a GraphDef
+// without the generated protos
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// a field of a message, its value is the varint or the bytes
+type field struct {
+	num    int
+	wire   int
+	varint uint64
+	bytes  []byte
+	// the whole field, key included
+	raw []byte
+}
+
+func parseFields(b []byte) ([]field, error) {
+	var fields []field
+	for len(b) > 0 {
+		start := b
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("bad field key")
+		}
+		b = b[n:]
+		f := field{num: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case wireVarint:
+			if f.varint, n = binary.Uvarint(b); n <= 0 {
+				return nil, fmt.Errorf("bad varint of field %d", f.num)
+			}
+			b = b[n:]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if f.wire == wireFixed32 {
+				size = 4
+			}
+			if len(b) < size {
+				return nil, fmt.Errorf("short field %d", f.num)
+			}
+			f.bytes, b = b[:size], b[size:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return nil, fmt.Errorf("bad length of field %d", f.num)
+			}
+			f.bytes, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d of field %d", f.wire, f.num)
+		}
+		f.raw = start[:len(start)-len(b)]
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendBytesField(b []byte, num int, v []byte) []byte {
+	b = appendVarint(b, uint64(num)<<3|wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendVarintField(b []byte, num int, v uint64) []byte {
+	b = appendVarint(b, uint64(num)<<3|wireVarint)
+	return appendVarint(b, v)
+}
+
+func appendFixed64Field(b []byte, num int, v uint64) []byte {
+	b = appendVarint(b, uint64(num)<<3|wireFixed64)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// graphNode is a NodeDef, with the fields needed to walk the graph
+type graphNode struct {
+	name, op string
+	inputs   []string
+	raw      []byte
+}
+
+// GraphDef fields; node = 1, and the rest is kept as is
+func parseGraphDef(b []byte) ([]*graphNode, [][]byte, error) {
+	fields, err := parseFields(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	var nodes []*graphNode
+	var rest [][]byte
+	for _, f := range fields {
+		if f.num != 1 || f.wire != wireBytes {
+			rest = append(rest, f.raw)
+			continue
+		}
+		// NodeDef name = 1, op = 2, input = 3
+		nf, err := parseFields(f.bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		n := &graphNode{raw: f.bytes}
+		for _, f := range nf {
+			switch f.num {
+			case 1:
+				n.name = string(f.bytes)
+			case 2:
+				n.op = string(f.bytes)
+			case 3:
+				n.inputs = append(n.inputs, string(f.bytes))
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, rest, nil
+}
+
+// node of an input, without the ^ of control inputs or :index of outputs
+func inputNode(input string) string {
+	input = strings.TrimPrefix(input, "^")
+	if i := strings.LastIndex(input, ":"); i > 0 {
+		return input[:i]
+	}
+	return input
+}
+
+// constNode encodes a NodeDef of a Const holding a tensor, dtype and shape
+// as in the tf DataType enum and content as written by WriteContentsTo
+func constNode(name string, dtype int, shape []int64, content []byte) []byte {
+	// TensorShapeProto dim = 2 of Dim size = 1
+	var shp []byte
+	for _, d := range shape {
+		shp = appendBytesField(shp, 2, appendVarintField(nil, 1, uint64(d)))
+	}
+	// TensorProto dtype = 1, tensor_shape = 2, tensor_content = 4
+	tensor := appendVarintField(nil, 1, uint64(dtype))
+	tensor = appendBytesField(tensor, 2, shp)
+	tensor = appendBytesField(tensor, 4, content)
+
+	// AttrValue type = 6, tensor = 8, in map entries of key = 1, value = 2
+	dtypeAttr := appendBytesField(appendBytesField(nil, 1, []byte("dtype")), 2, appendVarintField(nil, 6, uint64(dtype)))
+	valueAttr := appendBytesField(appendBytesField(nil, 1, []byte("value")), 2, appendBytesField(nil, 8, tensor))
+
+	// NodeDef name = 1, op = 2, attr = 5
+	node := appendBytesField(nil, 1, []byte(name))
+	node = appendBytesField(node, 2, []byte("Const"))
+	node = appendBytesField(node, 5, dtypeAttr)
+	return appendBytesField(node, 5, valueAttr)
+}
@@ -0,0 +1,195 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// Suggestion is an op that looks like an input or output of the model
+type Suggestion struct {
+	Op     string
+	Type   string
+	DType  string
+	Shape  string
+	Reason string
+	// higher is likelier
+	score int
+}
+
+// ops that are never a model output, though nothing consumes them
+var notOutputs = map[string]bool{
+	"NoOp": true, "Const": true, "Placeholder": true, "PlaceholderWithDefault": true,
+	"VariableV2": true, "VarHandleOp": true, "Assign": true, "AssignVariableOp": true,
+	"AssignAdd": true, "AssignSub": true, "SaveV2": true, "RestoreV2": true,
+	"MergeV2Checkpoints": true, "Assert": true, "Print": true, "PrintV2": true,
+}
+
+// op types that usually end a model
+var outputTypes = map[string]string{
+	"Softmax":   "class probabilities",
+	"Sigmoid":   "multi-label probabilities",
+	"ArgMax":    "predicted classes",
+	"TopKV2":    "top classes",
+	"BiasAdd":   "logits",
+	"MatMul":    "logits",
+	"Identity":  "named output",
+	"Reshape":   "reshaped output",
+	"Squeeze":   "squeezed output",
+	"ConcatV2":  "concatenated output",
+	"Transpose": "transposed output",
+}
+
+// object detection api outputs, as detect reads them
+var detectionOutputs = []string{"detection_boxes", "detection_scores", "detection_classes", "num_detections"}
+
+// SuggestIO ranks the placeholders of the graph as inputs, and ops nothing
+// consumes as outputs, likeliest first
+func (m *Model) SuggestIO() (inputs, outputs []Suggestion) {
+	ops := m.Graph.Operations()
+	for i := range ops {
+		op := &ops[i]
+		if op.NumOutputs() == 0 || strings.HasPrefix(op.Name(), "save/") {
+			continue
+		}
+		out := op.Output(0)
+		s := Suggestion{Op: op.Name(), Type: op.Type(), DType: TypeName(out.DataType()), Shape: ShapeString(out.Shape())}
+
+		switch op.Type() {
+		case "Placeholder", "PlaceholderWithDefault":
+			s.score, s.Reason = inputReason(out)
+			inputs = append(inputs, s)
+			continue
+		}
+		if notOutputs[op.Type()] || consumed(op) {
+			continue
+		}
+		s.score = 1
+		s.Reason = "nothing consumes it"
+		if r, ok := outputTypes[op.Type()]; ok {
+			s.score, s.Reason = 2, r
+		}
+		for _, d := range detectionOutputs {
+			if op.Name() == d {
+				s.score, s.Reason = 3, "object detection api output, run it with detect"
+			}
+		}
+		outputs = append(outputs, s)
+	}
+	rank := func(s []Suggestion) {
+		sort.SliceStable(s, func(i, j int) bool { return s[i].score > s[j].score })
+	}
+	rank(inputs)
+	rank(outputs)
+	return inputs, outputs
+}
+
+func inputReason(out tf.Output) (int, string) {
+	shape := out.Shape()
+	rank := shape.NumDimensions()
+	switch dt := out.DataType(); {
+	case rank == 4 && (dt == tf.Uint8 || dt == tf.Float) && (shape.Size(3) == 3 || shape.Size(3) == 1):
+		return 3, "batch of images, [N,H,W,C]"
+	case rank == 4:
+		return 2, "4-d batch, images or spectrograms"
+	case dt == tf.String:
+		return 2, "strings, encoded images or text"
+	case rank == 0 && (dt == tf.Bool || dt == tf.Float):
+		return 0, "scalar, probably a training switch such as keep_prob or is_training; feed a constant"
+	case rank < 0:
+		return 1, "unknown shape"
+	}
+	return 1, fmt.Sprintf("%d-d %s", rank, TypeName(out.DataType()))
+}
+
+func consumed(op *tf.Operation) bool {
+	for i := 0; i < op.NumOutputs(); i++ {
+		if len(op.Output(i).Consumers()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ShapeString formats a shape as [?,224,224,3]
+func ShapeString(s tf.Shape) string {
+	if s.NumDimensions() < 0 {
+		return "?"
+	}
+	dims := make([]string, s.NumDimensions())
+	for i := range dims {
+		dims[i] = "?"
+		if d := s.Size(i); d >= 0 {
+			dims[i] = fmt.Sprint(d)
+		}
+	}
+	return "[" + strings.Join(dims, ",") + "]"
+}
+
+// DetectionIO names the image input and the boxes, scores, classes and
+// num_detections outputs of an object detection model, by their object
+// detection api op names, or failing those by op names ending in them, eg.
+// import/detection_boxes, and the single uint8 image placeholder
+func (m *Model) DetectionIO() (input string, outputs []string, err error) {
+	if outputs, err = m.DetectionOutputs(); err != nil {
+		return "", nil, err
+	}
+	if input, err = m.DetectionInput(); err != nil {
+		return "", nil, err
+	}
+	return input, outputs, nil
+}
+
+// DetectionInput names the image input of an object detection model, see
+// DetectionIO
+func (m *Model) DetectionInput() (string, error) {
+	if input, err := m.findOp("image_tensor"); err == nil {
+		return input, nil
+	}
+	var images []string
+	for _, op := range m.Graph.Operations() {
+		if op.Type() == "Placeholder" && op.Output(0).DataType() == tf.Uint8 {
+			images = append(images, op.Name())
+		}
+	}
+	if len(images) != 1 {
+		return "", fmt.Errorf("no image_tensor op, and %d uint8 placeholders to take for it", len(images))
+	}
+	return images[0], nil
+}
+
+// DetectionOutputs names the outputs of an object detection model, see
+// DetectionIO
+func (m *Model) DetectionOutputs() ([]string, error) {
+	var outputs []string
+	for _, name := range detectionOutputs {
+		out, err := m.findOp(name)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, out)
+	}
+	return outputs, nil
+}
+
+// the op of name, or the single op whose name ends in /name
+func (m *Model) findOp(name string) (string, error) {
+	if m.Graph.Operation(name) != nil {
+		return name, nil
+	}
+	var found []string
+	for _, op := range m.Graph.Operations() {
+		if strings.HasSuffix(op.Name(), "/"+name) {
+			found = append(found, op.Name())
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("no %s op", name)
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("several %s ops, %s", name, strings.Join(found, ", "))
+}
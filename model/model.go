@@ -0,0 +1,66 @@
+package model // import "github.com/jw3/example-tensorflow-golang/model"
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// Model is a frozen graph with a session to run it
+type Model struct {
+	Graph   *tf.Graph
+	Session *tf.Session
+}
+
+// Load imports a frozen GraphDef and opens a session on it
+func Load(modelfile string) (*Model, error) {
+	def, err := ioutil.ReadFile(modelfile)
+	if err != nil {
+		return nil, err
+	}
+	graph := tf.NewGraph()
+	if err := ImportGraph(graph, def); err != nil {
+		return nil, fmt.Errorf("%s: %v", modelfile, err)
+	}
+	session, err := tf.NewSession(graph, SessionOptions())
+	if err != nil {
+		return nil, err
+	}
+	return &Model{Graph: graph, Session: session}, nil
+}
+
+// LoadSavedModel opens a SavedModel dir, with its variables restored
+func LoadSavedModel(dir string, tags []string) (*Model, error) {
+	saved, err := tf.LoadSavedModel(dir, tags, SessionOptions())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", dir, err)
+	}
+	return &Model{Graph: saved.Graph, Session: saved.Session}, nil
+}
+
+func (m *Model) Close() error {
+	return m.Session.Close()
+}
+
+// Output resolves an op:index name, the index defaults to 0
+func (m *Model) Output(name string) (tf.Output, error) {
+	idx := 0
+	if i := strings.LastIndex(name, ":"); i > 0 {
+		n, err := strconv.Atoi(name[i+1:])
+		if err != nil {
+			return tf.Output{}, fmt.Errorf("invalid output %q", name)
+		}
+		name, idx = name[:i], n
+	}
+	op := m.Graph.Operation(name)
+	if op == nil {
+		return tf.Output{}, fmt.Errorf("no operation %q in graph", name)
+	}
+	if idx >= op.NumOutputs() {
+		return tf.Output{}, fmt.Errorf("%s has %d outputs", name, op.NumOutputs())
+	}
+	return op.Output(idx), nil
+}
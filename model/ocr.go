@@ -0,0 +1,115 @@
+package model
+
+import (
+	"image"
+	"math"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// OCR runs a ctc text recognition model, eg. LPRNet, over crops
+type OCR struct {
+	*Model
+	input, output tf.Output
+	size          image.Point
+	// character of each class, the ctc blank is the class after the last
+	Charset []rune
+}
+
+// NewOCR loads a model taking [N,H,W,3] float pixels in [0,1] and producing
+// [N,T,C] per step class scores, the crop size is read from the input shape
+// when it is static
+func NewOCR(modelfile, input, output, charset string, size image.Point) (*OCR, error) {
+	m, err := Load(modelfile)
+	if err != nil {
+		return nil, err
+	}
+	o := &OCR{Model: m, size: size, Charset: []rune(charset)}
+	if o.input, err = m.Output(input); err != nil {
+		return nil, err
+	}
+	if o.output, err = m.Output(output); err != nil {
+		return nil, err
+	}
+	if shape := o.input.Shape(); shape.NumDimensions() == 4 && shape.Size(1) > 0 && shape.Size(2) > 0 {
+		o.size = image.Pt(int(shape.Size(2)), int(shape.Size(1)))
+	}
+	return o, nil
+}
+
+// Read returns the text of each crop, with the mean confidence of its characters
+func (o *OCR) Read(crops []image.Image) ([]string, []float32, error) {
+	if len(crops) == 0 {
+		return nil, nil, nil
+	}
+	batch := make([][][][]float32, len(crops))
+	for i, crop := range crops {
+		batch[i] = FloatPixels(crop, o.size)
+	}
+	tensor, err := tf.NewTensor(batch)
+	if err != nil {
+		return nil, nil, err
+	}
+	output, err := o.Session.Run(map[tf.Output]*tf.Tensor{o.input: tensor}, []tf.Output{o.output}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	steps := output[0].Value().([][][]float32)
+	texts := make([]string, len(steps))
+	confs := make([]float32, len(steps))
+	for i, s := range steps {
+		texts[i], confs[i] = o.decode(s)
+	}
+	return texts, confs, nil
+}
+
+// greedy ctc decoding; the best class per step, collapsing repeats and
+// dropping blanks
+func (o *OCR) decode(steps [][]float32) (string, float32) {
+	text := make([]rune, 0)
+	conf, n := float32(0), 0
+	prev := -1
+	for _, scores := range steps {
+		best, p := argmax(softmax(scores))
+		if best != prev && best < len(o.Charset) {
+			text = append(text, o.Charset[best])
+			conf += p
+			n++
+		}
+		prev = best
+	}
+	if n == 0 {
+		return "", 0
+	}
+	return string(text), conf / float32(n)
+}
+
+func softmax(v []float32) []float32 {
+	max := float32(math.Inf(-1))
+	for _, x := range v {
+		if x > max {
+			max = x
+		}
+	}
+	out := make([]float32, len(v))
+	sum := float32(0)
+	for i, x := range v {
+		out[i] = float32(math.Exp(float64(x - max)))
+		sum += out[i]
+	}
+	for i := range out {
+		out[i] /= sum
+	}
+	return out
+}
+
+func argmax(v []float32) (int, float32) {
+	best := 0
+	for i := range v {
+		if v[i] > v[best] {
+			best = i
+		}
+	}
+	return best, v[best]
+}
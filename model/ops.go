@@ -0,0 +1,108 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// MissingOps lists the op types of a GraphDef that the linked tensorflow
+// doesn't register, as models exported with a newer tf use, each once in the
+// order of the graph
+func MissingOps(def []byte) ([]string, error) {
+	nodes, rest, err := parseGraphDef(def)
+	if err != nil {
+		return nil, err
+	}
+	// functions of the library are called as ops
+	functions, err := libraryFunctions(rest)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var missing []string
+	for _, n := range nodes {
+		if seen[n.op] || functions[n.op] {
+			continue
+		}
+		seen[n.op] = true
+		if !registered(n.op) {
+			missing = append(missing, n.op)
+		}
+	}
+	return missing, nil
+}
+
+// an op is registered when importing a lone node of it fails for anything
+// but its type, such as its missing inputs and attrs
+func registered(opType string) bool {
+	// NodeDef name = 1, op = 2
+	node := appendBytesField(appendBytesField(nil, 1, []byte("probe")), 2, []byte(opType))
+	err := tf.NewGraph().Import(appendBytesField(nil, 1, node), "")
+	return err == nil || !strings.Contains(err.Error(), "not registered")
+}
+
+// names of the functions of GraphDef library = 2, of FunctionDefLibrary
+// function = 1, of FunctionDef signature = 1, of OpDef name = 1
+func libraryFunctions(rest [][]byte) (map[string]bool, error) {
+	names := make(map[string]bool)
+	for _, raw := range rest {
+		fields, err := parseFields(raw)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) != 1 || fields[0].num != 2 || fields[0].wire != wireBytes {
+			continue
+		}
+		library, err := parseFields(fields[0].bytes)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range library {
+			if f.num != 1 {
+				continue
+			}
+			name, err := nestedString(f.bytes, 1, 1)
+			if err != nil {
+				return nil, err
+			}
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+// the string at a path of field numbers of a message
+func nestedString(b []byte, path ...int) (string, error) {
+	for _, num := range path {
+		fields, err := parseFields(b)
+		if err != nil {
+			return "", err
+		}
+		var found []byte
+		for _, f := range fields {
+			if f.num == num && f.wire == wireBytes {
+				found = f.bytes
+			}
+		}
+		if found == nil {
+			return "", nil
+		}
+		b = found
+	}
+	return string(b), nil
+}
+
+// ImportGraph imports a GraphDef, naming every op the linked tensorflow
+// lacks when that's why it fails, rather than the first
+func ImportGraph(graph *tf.Graph, def []byte) error {
+	err := graph.Import(def, "")
+	if err == nil {
+		return nil
+	}
+	if missing, _ := MissingOps(def); len(missing) > 0 {
+		return fmt.Errorf("ops not registered in tensorflow %s: %s; the model was likely exported with a newer tf", tf.Version(), strings.Join(missing, ", "))
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// SessionConfig is the part of the ConfigProto of sessions the tools set
+type SessionConfig struct {
+	// sizes of the intra and inter op thread pools, 0 leaves them to tf
+	IntraThreads, InterThreads int
+	// GPUGrowth allocates gpu memory as it's needed rather than all of it
+	// up front, GPUMemory caps the share of each gpu taken, 0 for no cap
+	GPUGrowth bool
+	GPUMemory float64
+	// gpus to use, eg. "0,1", empty for all of them
+	GPUDevices string
+	// CPUOnly runs on no gpu at all
+	CPUOnly bool
+	// SoftPlacement runs ops on the cpu that have no gpu kernel, rather
+	// than failing
+	SoftPlacement bool
+}
+
+// config of every session the process opens, set by SetThreads and
+// Configure
+var sessionConfig SessionConfig
+
+// SetThreads sizes the intra and inter op thread pools of the sessions
+// opened after it, 0 leaves the size to tf
+func SetThreads(intra, inter int) {
+	sessionConfig.IntraThreads, sessionConfig.InterThreads = intra, inter
+}
+
+// Configure the sessions opened after it. CPUOnly also hides the gpus from
+// cuda, so that not even a context is created on them.
+func Configure(c SessionConfig) error {
+	if c.GPUMemory < 0 || c.GPUMemory > 1 {
+		return fmt.Errorf("gpu memory share %v, expected a fraction in (0,1]", c.GPUMemory)
+	}
+	if c.CPUOnly && c.GPUDevices != "" {
+		return fmt.Errorf("gpu devices %q given to run on the cpu only", c.GPUDevices)
+	}
+	if c.CPUOnly {
+		os.Setenv("CUDA_VISIBLE_DEVICES", "-1")
+	}
+	sessionConfig = c
+	return nil
+}
+
+// SessionFlags adds the session flags of a tool, the returned func
+// configures sessions from them once parsed, over the threads of SetThreads
+func SessionFlags() func() error {
+	intra := flag.Int("intra-op-threads", 0, "Threads each op is run on, over the size -cpu-budget picks; 0 for tf's default")
+	inter := flag.Int("inter-op-threads", 0, "Ops run in parallel, over the number -cpu-budget picks; 0 for tf's default")
+	growth := flag.Bool("gpu-growth", false, "Allocate gpu memory as it's needed rather than all of it up front, for gpus shared with other jobs")
+	memory := flag.Float64("gpu-memory", 0, "Most of each gpu's memory to take, eg. 0.3, 0 for all of it")
+	devices := flag.String("gpu-devices", "", "Gpus to run on, eg. 0,1, empty for all of them")
+	cpuonly := flag.Bool("cpu-only", false, "Run on the cpu even when there's a gpu")
+	soft := flag.Bool("soft-placement", false, "Run ops with no gpu kernel on the cpu rather than failing")
+	return func() error {
+		c := sessionConfig
+		if *intra > 0 {
+			c.IntraThreads = *intra
+		}
+		if *inter > 0 {
+			c.InterThreads = *inter
+		}
+		c.GPUGrowth, c.GPUMemory, c.GPUDevices, c.CPUOnly, c.SoftPlacement = *growth, *memory, *devices, *cpuonly, *soft
+		return Configure(c)
+	}
+}
+
+// ConfigProto device_count = 1, intra_op_parallelism_threads = 2,
+// inter_op_parallelism_threads = 5, gpu_options = 6, allow_soft_placement
+// = 7, and GPUOptions per_process_gpu_memory_fraction = 1, allow_growth =
+// 4, visible_device_list = 5
+func (c SessionConfig) proto() []byte {
+	var config []byte
+	if c.CPUOnly {
+		// the map entry "GPU": 0
+		config = appendBytesField(config, 1, appendVarintField(appendBytesField(nil, 1, []byte("GPU")), 2, 0))
+	}
+	if c.IntraThreads > 0 {
+		config = appendVarintField(config, 2, uint64(c.IntraThreads))
+	}
+	if c.InterThreads > 0 {
+		config = appendVarintField(config, 5, uint64(c.InterThreads))
+	}
+	var gpu []byte
+	if c.GPUMemory > 0 {
+		gpu = appendFixed64Field(gpu, 1, math.Float64bits(c.GPUMemory))
+	}
+	if c.GPUGrowth {
+		gpu = appendVarintField(gpu, 4, 1)
+	}
+	if c.GPUDevices != "" {
+		gpu = appendBytesField(gpu, 5, []byte(c.GPUDevices))
+	}
+	if len(gpu) > 0 {
+		config = appendBytesField(config, 6, gpu)
+	}
+	if c.SoftPlacement {
+		config = appendVarintField(config, 7, 1)
+	}
+	return config
+}
+
+// SessionOptions are the options to open sessions with, nil for the
+// defaults of tf until configured
+func SessionOptions() *tf.SessionOptions {
+	config := sessionConfig.proto()
+	if len(config) == 0 {
+		return nil
+	}
+	return &tf.SessionOptions{Config: config}
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+	"golang.org/x/image/draw"
+)
+
+// Preprocess is how images are made into the [N,H,W,3] rgb input of a
+// model: scaled to Size, and fed as uint8 pixels as they are, or as float32
+// (pixel - Mean) / Scale per channel
+type Preprocess struct {
+	Size  image.Point
+	DType string
+	Mean  [3]float32
+	Scale float32
+}
+
+// Profiles are the preprocessing of common model families
+var Profiles = map[string]Preprocess{
+	// the xview baseline and object detection api exports, which take
+	// uint8 and normalize in the graph
+	"xview":       {Size: image.Pt(544, 544), DType: "uint8"},
+	"faster_rcnn": {Size: image.Pt(640, 640), DType: "uint8"},
+	// mobilenet ssd graphs taking pixels in [-1,1]
+	"ssd": {Size: image.Pt(300, 300), DType: "float32", Mean: [3]float32{127.5, 127.5, 127.5}, Scale: 127.5},
+	// inception as the tensorflow label_image example runs it
+	"inception": {Size: image.Pt(224, 224), DType: "float32", Mean: [3]float32{117, 117, 117}, Scale: 1},
+	// pixels in [0,1]
+	"unit": {Size: image.Pt(224, 224), DType: "float32", Scale: 255},
+	// nothing set, for flags to fill in
+	"custom": {DType: "float32", Scale: 1},
+}
+
+// ProfileNames are the names of Profiles, sorted
+func ProfileNames() string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// Check the preprocessing can be run
+func (p Preprocess) Check() error {
+	if p.Size.X <= 0 || p.Size.Y <= 0 {
+		return fmt.Errorf("preprocess size %v, expected a positive width and height", p.Size)
+	}
+	switch p.DType {
+	case "uint8":
+	case "float32":
+		if p.Scale == 0 {
+			return fmt.Errorf("preprocess scale 0 divides by zero")
+		}
+	default:
+		return fmt.Errorf("unsupported preprocess dtype %q, expected uint8 or float32", p.DType)
+	}
+	return nil
+}
+
+// Tensor of ims, scaled to Size
+func (p Preprocess) Tensor(ims []image.Image) (*tf.Tensor, error) {
+	if p.DType == "uint8" {
+		batch := make([][][][]uint8, len(ims))
+		for i, im := range ims {
+			scaled := scale(im, p.Size)
+			batch[i] = make([][][]uint8, p.Size.Y)
+			for y := range batch[i] {
+				batch[i][y] = make([][]uint8, p.Size.X)
+				for x := range batch[i][y] {
+					o := scaled.PixOffset(x, y)
+					batch[i][y][x] = []uint8{scaled.Pix[o], scaled.Pix[o+1], scaled.Pix[o+2]}
+				}
+			}
+		}
+		return tf.NewTensor(batch)
+	}
+	batch := make([][][][]float32, len(ims))
+	for i, im := range ims {
+		batch[i] = p.Floats(im)
+	}
+	return tf.NewTensor(batch)
+}
+
+// Floats scales im to Size, as [H][W][3] float32 rgb values
+func (p Preprocess) Floats(im image.Image) [][][]float32 {
+	scaled := scale(im, p.Size)
+	px := make([][][]float32, p.Size.Y)
+	for y := range px {
+		px[y] = make([][]float32, p.Size.X)
+		for x := range px[y] {
+			o := scaled.PixOffset(x, y)
+			px[y][x] = make([]float32, 3)
+			for c := range px[y][x] {
+				px[y][x][c] = (float32(scaled.Pix[o+c]) - p.Mean[c]) / p.Scale
+			}
+		}
+	}
+	return px
+}
+
+func scale(im image.Image, size image.Point) *image.RGBA {
+	scaled := image.NewRGBA(image.Rectangle{Max: size})
+	draw.BiLinear.Scale(scaled, scaled.Bounds(), im, im.Bounds(), draw.Src, nil)
+	return scaled
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+	"github.com/tensorflow/tensorflow/tensorflow/go/op"
+)
+
+// Restore initializes the variables of a graph that isn't frozen from a
+// checkpoint, eg. model.ckpt-1000, or the latest checkpoint of a dir. The
+// restore op of a saver in the graph is used, otherwise each variable is
+// restored by name.
+func (m *Model) Restore(checkpoint string) error {
+	prefix, err := checkpointPrefix(checkpoint)
+	if err != nil {
+		return err
+	}
+	file, err := tf.NewTensor(prefix)
+	if err != nil {
+		return err
+	}
+
+	// the saver added by tf.train.Saver; the file name const is fed
+	restoreAll, filename := m.Graph.Operation("save/restore_all"), m.Graph.Operation("save/Const")
+	if restoreAll != nil && filename != nil {
+		_, err := m.Session.Run(map[tf.Output]*tf.Tensor{filename.Output(0): file}, nil, []*tf.Operation{restoreAll})
+		return err
+	}
+
+	var vars []*tf.Operation
+	ops := m.Graph.Operations()
+	for i := range ops {
+		if t := ops[i].Type(); t == "VariableV2" || t == "VarHandleOp" {
+			vars = append(vars, &ops[i])
+		}
+	}
+	if len(vars) == 0 {
+		return fmt.Errorf("no variables to restore, the graph is frozen")
+	}
+
+	s := op.NewScopeWithGraph(m.Graph).SubScope("restore")
+	names := make([]string, len(vars))
+	dtypes := make([]tf.DataType, len(vars))
+	for i, v := range vars {
+		names[i] = v.Name()
+		dt, err := v.Attr("dtype")
+		if err != nil {
+			return err
+		}
+		dtypes[i] = dt.(tf.DataType)
+	}
+	path := op.Placeholder(s, tf.String)
+	restore := s.AddOperation(tf.OpSpec{
+		Type:  "RestoreV2",
+		Input: []tf.Input{path, op.Const(s, names), op.Const(s, make([]string, len(vars)))},
+		Attrs: map[string]interface{}{"dtypes": dtypes},
+	})
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	assigns := make([]*tf.Operation, len(vars))
+	for i, v := range vars {
+		spec := tf.OpSpec{Type: "Assign", Input: []tf.Input{v.Output(0), restore.Output(i)}}
+		// resource variables
+		if v.Type() == "VarHandleOp" {
+			spec = tf.OpSpec{Type: "AssignVariableOp", Input: []tf.Input{v.Output(0), restore.Output(i)}, Attrs: map[string]interface{}{"dtype": dtypes[i]}}
+		}
+		assigns[i] = s.AddOperation(spec)
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	_, err = m.Session.Run(map[tf.Output]*tf.Tensor{path: file}, nil, assigns)
+	return err
+}
+
+var latestCheckpoint = regexp.MustCompile(`(?m)^model_checkpoint_path:\s*"([^"]*)"`)
+
+// the prefix of the latest checkpoint of a dir, as listed in its checkpoint
+// file, or the prefix itself
+func checkpointPrefix(checkpoint string) (string, error) {
+	info, err := os.Stat(checkpoint)
+	if err != nil || !info.IsDir() {
+		return checkpoint, nil
+	}
+	b, err := ioutil.ReadFile(filepath.Join(checkpoint, "checkpoint"))
+	if err != nil {
+		return "", err
+	}
+	m := latestCheckpoint.FindSubmatch(b)
+	if m == nil {
+		return "", fmt.Errorf("%s: no model_checkpoint_path", checkpoint)
+	}
+	prefix := string(m[1])
+	if !filepath.IsAbs(prefix) {
+		prefix = filepath.Join(checkpoint, prefix)
+	}
+	return prefix, nil
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+	"github.com/tensorflow/tensorflow/tensorflow/go/op"
+)
+
+// TrainSoftmax fits a softmax layer to features x of classes y by minibatch
+// gradient descent on the cross entropy, with l2 weight decay. The layer is
+// trained in a graph of its own, its gradients added by tensorflow.
+func TrainSoftmax(x [][]float32, y []int, classes, epochs, batch int, rate, l2 float64) (*SoftmaxLayer, error) {
+	if len(x) == 0 {
+		return nil, fmt.Errorf("no features to train on")
+	}
+	features := len(x[0])
+
+	g := tf.NewGraph()
+	s := op.NewScopeWithGraph(g)
+	xs := op.Placeholder(s.SubScope("x"), tf.Float, op.PlaceholderShape(tf.MakeShape(-1, int64(features))))
+	ys := op.Placeholder(s.SubScope("y"), tf.Float, op.PlaceholderShape(tf.MakeShape(-1, int64(classes))))
+
+	// the layer starts at zero, the loss is convex
+	w0 := make([][]float32, features)
+	for i := range w0 {
+		w0[i] = make([]float32, classes)
+	}
+	wv := op.VarHandleOp(s.SubScope("W"), tf.Float, tf.MakeShape(int64(features), int64(classes)))
+	bv := op.VarHandleOp(s.SubScope("b"), tf.Float, tf.MakeShape(int64(classes)))
+	init := []*tf.Operation{
+		op.AssignVariableOp(s.SubScope("init_W"), wv, op.Const(s.SubScope("zero_W"), w0)),
+		op.AssignVariableOp(s.SubScope("init_b"), bv, op.Const(s.SubScope("zero_b"), make([]float32, classes))),
+	}
+	w := op.ReadVariableOp(s.SubScope("read_W"), wv, tf.Float)
+	b := op.ReadVariableOp(s.SubScope("read_b"), bv, tf.Float)
+
+	logits := op.Add(s, op.MatMul(s, xs, w), b)
+	xent, _ := op.SoftmaxCrossEntropyWithLogits(s, logits, ys)
+	// l2 loss is half the sum of squares, its gradient the weights
+	loss := op.Add(s.SubScope("loss"),
+		op.Mean(s, xent, op.Const(s.SubScope("batch_axis"), []int32{0})),
+		op.Mul(s, op.Const(s.SubScope("l2"), float32(l2)), op.L2Loss(s, w)))
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	grads, err := g.AddGradients("gradients", []tf.Output{loss}, []tf.Output{w, b}, nil)
+	if err != nil {
+		return nil, err
+	}
+	alpha := op.Const(s.SubScope("learning_rate"), float32(rate))
+	step := []*tf.Operation{
+		op.ResourceApplyGradientDescent(s.SubScope("step_W"), wv, alpha, grads[0]),
+		op.ResourceApplyGradientDescent(s.SubScope("step_b"), bv, alpha, grads[1]),
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	session, err := tf.NewSession(g, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	if _, err := session.Run(nil, nil, init); err != nil {
+		return nil, err
+	}
+
+	order := rand.Perm(len(x))
+	for epoch := 0; epoch < epochs; epoch++ {
+		rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		for start := 0; start < len(order); start += batch {
+			end := start + batch
+			if end > len(order) {
+				end = len(order)
+			}
+			bx := make([][]float32, 0, end-start)
+			by := make([][]float32, 0, end-start)
+			for _, n := range order[start:end] {
+				onehot := make([]float32, classes)
+				onehot[y[n]] = 1
+				bx, by = append(bx, x[n]), append(by, onehot)
+			}
+			xt, err := tf.NewTensor(bx)
+			if err != nil {
+				return nil, err
+			}
+			yt, err := tf.NewTensor(by)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := session.Run(map[tf.Output]*tf.Tensor{xs: xt, ys: yt}, nil, step); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	trained, err := session.Run(nil, []tf.Output{w, b}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SoftmaxLayer{W: trained[0].Value().([][]float32), B: trained[1].Value().([]float32)}, nil
+}
+
+// AppendSoftmax adds a trained softmax layer on top of the bottleneck, as
+// constants, with its probabilities output by an op called name
+func (m *Model) AppendSoftmax(bottleneck tf.Output, l *SoftmaxLayer, name string) error {
+	s := op.NewScopeWithGraph(m.Graph).SubScope(name + "_layer")
+	// pooling outputs [N,1,1,D], flattened to the [N,D] the layer takes
+	if bottleneck.Shape().NumDimensions() == 4 {
+		bottleneck = op.Reshape(s, bottleneck, op.Const(s.SubScope("shape"), []int32{-1, int32(len(l.W))}))
+	}
+	logits := op.Add(s, op.MatMul(s, bottleneck, op.Const(s, l.W)), op.Const(s, l.B))
+	if err := s.Err(); err != nil {
+		return err
+	}
+	_, err := m.Graph.AddOperation(tf.OpSpec{Type: "Softmax", Name: name, Input: []tf.Input{logits}})
+	return err
+}
+
+// Save writes the graph as a frozen GraphDef
+func (m *Model) Save(modelfile string) error {
+	f, err := os.Create(modelfile)
+	if err != nil {
+		return err
+	}
+	if _, err := m.Graph.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// Scorer runs a model on a batch of inputs that aren't images, eg.
+// spectrograms or strings, producing [N,C] class scores
+type Scorer struct {
+	*Model
+	Input  tf.Output
+	output tf.Output
+}
+
+func NewScorer(modelfile, input, output string) (*Scorer, error) {
+	m, err := Load(modelfile)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scorer{Model: m}
+	if s.Input, err = m.Output(input); err != nil {
+		return nil, err
+	}
+	if s.output, err = m.Output(output); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Score feeds batch, anything tf.NewTensor takes in the shape and type of
+// the input
+func (s *Scorer) Score(batch interface{}) ([][]float32, error) {
+	tensor, err := tf.NewTensor(batch)
+	if err != nil {
+		return nil, err
+	}
+	output, err := s.Session.Run(map[tf.Output]*tf.Tensor{s.Input: tensor}, []tf.Output{s.output}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return output[0].Value().([][]float32), nil
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+	"github.com/tensorflow/tensorflow/tensorflow/go/op"
+)
+
+// ShapeReport is the static shape of an output, as declared in the graph and
+// as propagated from the shape of an input
+type ShapeReport struct {
+	Output     string
+	DType      string
+	Declared   string
+	Propagated string
+}
+
+// ParseShape parses a shape such as 1,300,300,3, with ? or -1 for unknown dims
+func ParseShape(s string) ([]int64, error) {
+	s = strings.Trim(s, "[]() ")
+	if s == "" {
+		return []int64{}, nil
+	}
+	var shape []int64
+	for _, d := range strings.Split(s, ",") {
+		d = strings.TrimSpace(d)
+		if d == "?" {
+			shape = append(shape, -1)
+			continue
+		}
+		n, err := strconv.ParseInt(d, 10, 64)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("invalid shape %q", s)
+		}
+		shape = append(shape, n)
+	}
+	return shape, nil
+}
+
+// PropagateShapes imports the graph again with the input replaced by a
+// placeholder of the given shape, so the static shape inference of tf runs
+// from it, and reports the shapes of the outputs. A shape the input can't
+// have, eg. an H and W other than the ones the graph was built for, is an
+// error naming the op that rejects it, rather than a runtime error on the
+// first image.
+func (m *Model) PropagateShapes(input string, shape []int64, outputs []string) ([]ShapeReport, error) {
+	propagated, err := m.propagate(input, shape)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]ShapeReport, len(outputs))
+	for i, name := range outputs {
+		out, err := m.Output(name)
+		if err != nil {
+			return nil, err
+		}
+		p, err := propagated.Output(name)
+		if err != nil {
+			return nil, err
+		}
+		reports[i] = ShapeReport{
+			Output:     name,
+			DType:      TypeName(p.DataType()),
+			Declared:   ShapeString(out.Shape()),
+			Propagated: ShapeString(p.Shape()),
+		}
+	}
+	return reports, nil
+}
+
+// the graph imported again with input fed by a placeholder of shape, for
+// the shapes of its ops inferred from it
+func (m *Model) propagate(input string, shape []int64) (*Model, error) {
+	in, err := m.Output(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkShape(in.Shape(), shape); err != nil {
+		return nil, fmt.Errorf("input %s: %v", input, err)
+	}
+
+	var def bytes.Buffer
+	if _, err := m.Graph.WriteTo(&def); err != nil {
+		return nil, err
+	}
+	g := tf.NewGraph()
+	s := op.NewScopeWithGraph(g).SubScope("propagate")
+	feed := op.Placeholder(s, in.DataType(), op.PlaceholderShape(tf.MakeShape(shape...)))
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	var opts tf.GraphImportOptions
+	opts.AddInputMapping(in.Op.Name(), in.Index, feed)
+	if err := g.ImportWithOptions(def.Bytes(), opts); err != nil {
+		return nil, fmt.Errorf("input %s of shape %s: %v", input, ShapeString(tf.MakeShape(shape...)), err)
+	}
+	return &Model{Graph: g}, nil
+}
+
+// the dims of a shape that the graph fixes must match
+func checkShape(declared tf.Shape, shape []int64) error {
+	rank := declared.NumDimensions()
+	if rank < 0 {
+		return nil
+	}
+	if rank != len(shape) {
+		return fmt.Errorf("expects %d dims %s, not %d", rank, ShapeString(declared), len(shape))
+	}
+	for i, d := range shape {
+		if want := declared.Size(i); want >= 0 && d >= 0 && want != d {
+			return fmt.Errorf("expects %s, not %s; dim %d is %d", ShapeString(declared), ShapeString(tf.MakeShape(shape...)), i, want)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// Signature maps the keys of a SavedModel signature to the names of its
+// input and output tensors, eg. inputs to image_tensor:0
+type Signature struct {
+	Inputs  map[string]string
+	Outputs map[string]string
+}
+
+// LoadSignatures reads the signatures of the meta graph of a SavedModel dir
+// with the tags, by name, eg. serving_default
+func LoadSignatures(dir string, tags []string) (map[string]Signature, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "saved_model.pb"))
+	if err != nil {
+		return nil, err
+	}
+	// SavedModel meta_graphs = 2
+	fields, err := parseFields(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", dir, err)
+	}
+	for _, f := range fields {
+		if f.num != 2 || f.wire != wireBytes {
+			continue
+		}
+		sigs, found, err := metaGraphSignatures(f.bytes, tags)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", dir, err)
+		}
+		if found {
+			return sigs, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no meta graph tagged %s", dir, strings.Join(tags, ","))
+}
+
+// MetaGraphDef meta_info_def = 1 of MetaInfoDef tags = 4, signature_def = 5
+// a map of SignatureDef
+func metaGraphSignatures(b []byte, tags []string) (map[string]Signature, bool, error) {
+	fields, err := parseFields(b)
+	if err != nil {
+		return nil, false, err
+	}
+	have := make(map[string]bool)
+	sigs := make(map[string]Signature)
+	for _, f := range fields {
+		switch {
+		case f.num == 1 && f.wire == wireBytes:
+			info, err := parseFields(f.bytes)
+			if err != nil {
+				return nil, false, err
+			}
+			for _, t := range info {
+				if t.num == 4 && t.wire == wireBytes {
+					have[string(t.bytes)] = true
+				}
+			}
+		case f.num == 5 && f.wire == wireBytes:
+			name, def, err := mapEntry(f.bytes)
+			if err != nil {
+				return nil, false, err
+			}
+			if sigs[name], err = signatureDef(def); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	if len(have) != len(tags) {
+		return nil, false, nil
+	}
+	for _, t := range tags {
+		if !have[t] {
+			return nil, false, nil
+		}
+	}
+	return sigs, true, nil
+}
+
+// SignatureDef inputs = 1 and outputs = 2, maps of TensorInfo name = 1
+func signatureDef(b []byte) (Signature, error) {
+	sig := Signature{Inputs: map[string]string{}, Outputs: map[string]string{}}
+	fields, err := parseFields(b)
+	if err != nil {
+		return sig, err
+	}
+	for _, f := range fields {
+		if (f.num != 1 && f.num != 2) || f.wire != wireBytes {
+			continue
+		}
+		key, info, err := mapEntry(f.bytes)
+		if err != nil {
+			return sig, err
+		}
+		name, err := nestedString(info, 1)
+		if err != nil {
+			return sig, err
+		}
+		if f.num == 1 {
+			sig.Inputs[key] = name
+		} else {
+			sig.Outputs[key] = name
+		}
+	}
+	return sig, nil
+}
+
+// map entries are messages of key = 1 and value = 2
+func mapEntry(b []byte) (string, []byte, error) {
+	fields, err := parseFields(b)
+	if err != nil {
+		return "", nil, err
+	}
+	var key string
+	var value []byte
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			key = string(f.bytes)
+		case 2:
+			value = f.bytes
+		}
+	}
+	return key, value, nil
+}
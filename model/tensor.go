@@ -0,0 +1,143 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+// numpy dtypes of the tensor types, little endian
+var npyTypes = map[tf.DataType]string{
+	tf.Float:  "<f4",
+	tf.Double: "<f8",
+	tf.Half:   "<f2",
+	tf.Int8:   "|i1",
+	tf.Int16:  "<i2",
+	tf.Int32:  "<i4",
+	tf.Int64:  "<i8",
+	tf.Uint8:  "|u1",
+	tf.Uint16: "<u2",
+	tf.Uint32: "<u4",
+	tf.Uint64: "<u8",
+	tf.Bool:   "|b1",
+}
+
+var typeNames = map[tf.DataType]string{
+	tf.Float: "float", tf.Double: "double", tf.Half: "half",
+	tf.Int8: "int8", tf.Int16: "int16", tf.Int32: "int32", tf.Int64: "int64",
+	tf.Uint8: "uint8", tf.Uint16: "uint16", tf.Uint32: "uint32", tf.Uint64: "uint64",
+	tf.Bool: "bool", tf.String: "string",
+}
+
+// TypeName of a tensor type, eg. float
+func TypeName(t tf.DataType) string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("type(%d)", t)
+}
+
+// NpyTensor makes a tensor of an npy array
+func NpyTensor(n *Npy) (*tf.Tensor, error) {
+	// the byte order of single byte types is |, and = is native, little
+	// endian on every platform tf runs on
+	descr := strings.Replace(n.Descr, "=", "<", 1)
+	if len(descr) < 2 {
+		return nil, fmt.Errorf("unsupported npy dtype %q", n.Descr)
+	}
+	for t, d := range npyTypes {
+		if d == descr || (d[0] == '|' && descr[1:] == d[1:]) {
+			return tf.ReadTensor(t, n.Shape, bytes.NewReader(n.Data))
+		}
+	}
+	return nil, fmt.Errorf("unsupported npy dtype %q", n.Descr)
+}
+
+// TensorNpy is the npy array of a tensor
+func TensorNpy(t *tf.Tensor) (*Npy, error) {
+	descr, ok := npyTypes[t.DataType()]
+	if !ok {
+		return nil, fmt.Errorf("%s tensors can't be written as npy", TypeName(t.DataType()))
+	}
+	buf := bytes.Buffer{}
+	if _, err := t.WriteContentsTo(&buf); err != nil {
+		return nil, err
+	}
+	return &Npy{Descr: descr, Shape: t.Shape(), Data: buf.Bytes()}, nil
+}
+
+// JsonTensor makes a tensor of dtype from a json number, string, bool or
+// nested array of them, eg. [[1, 2], [3, 4]]
+func JsonTensor(value string, dtype tf.DataType) (*tf.Tensor, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return nil, err
+	}
+	converted, err := convertJson(v, dtype)
+	if err != nil {
+		return nil, err
+	}
+	return tf.NewTensor(converted)
+}
+
+// nested []interface{} of float64 into the typed slices NewTensor takes
+func convertJson(v interface{}, dtype tf.DataType) (interface{}, error) {
+	switch v := v.(type) {
+	case []interface{}:
+		if len(v) == 0 {
+			return nil, fmt.Errorf("empty array")
+		}
+		items := make([]interface{}, len(v))
+		for i := range v {
+			c, err := convertJson(v[i], dtype)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = c
+		}
+		return typedSlice(items)
+	case float64:
+		switch dtype {
+		case tf.Float:
+			return float32(v), nil
+		case tf.Double:
+			return v, nil
+		case tf.Int32:
+			return int32(v), nil
+		case tf.Int64:
+			return int64(v), nil
+		case tf.Uint8:
+			return uint8(v), nil
+		}
+	case string:
+		if dtype == tf.String {
+			return v, nil
+		}
+	case bool:
+		if dtype == tf.Bool {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("can't feed %v as %s", v, TypeName(dtype))
+}
+
+// []interface{} of items of one type into a slice of that type, eg.
+// []float32 or [][]float32
+func typedSlice(items []interface{}) (interface{}, error) {
+	t := reflect.TypeOf(items[0])
+	s := reflect.MakeSlice(reflect.SliceOf(t), len(items), len(items))
+	for i, item := range items {
+		// tensors are rectangular
+		v := reflect.ValueOf(item)
+		if v.Type() != t || v.Kind() == reflect.Slice && v.Len() != reflect.ValueOf(items[0]).Len() {
+			return nil, fmt.Errorf("ragged array")
+		}
+		s.Index(i).Set(v)
+	}
+	return s.Interface(), nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"image"
+	"image/jpeg"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	"github.com/jw3/example-tensorflow-golang/model"
+)
+
+type verdict struct {
+	Image    string             `json:"image"`
+	Verdict  string             `json:"verdict"`
+	Because  []string           `json:"because,omitempty"`
+	Scores   map[string]float32 `json:"scores"`
+	Location string             `json:"location,omitempty"`
+}
+
+func main() {
+	modelfile := flag.String("model", "", "Path to an image classification model, eg. nsfw or violence")
+	labelfile := flag.String("labels", "labels.txt", "Path of the category labels of the model")
+	input := flag.String("input", "input", "Input op of the model, [N,H,W,3] float pixels in [0,1]")
+	output := flag.String("output", "predictions", "Output op of the model, [N,C] category scores")
+	size := flag.Int("size", 224, "Input size of the model, read from the input shape when static")
+	config := flag.String("config", "moderation.json", "Json of the review and block thresholds of each category")
+	var imagefiles Strings
+	flag.Var(&imagefiles, "image", "Image to moderate; file, dir, archive or http(s) url. Repeatable")
+	outdir := flag.String("outdir", "moderated", "Dir with allow, review and block subdirs to sort images into")
+	action := flag.String("action", "copy", "copy or move images into -outdir, or tag to only print the verdicts")
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
+
+	SetUsage("moderate", `Classify images against moderation categories, sorting them into allow, review and block dirs and printing a json verdict per image.`,
+		"moderate -model nsfw.pb -labels nsfw.txt -config moderation.json -image uploads/ -action move",
+		"moderate -model nsfw.pb -labels nsfw.txt -image uploads/ -action tag > verdicts.jsonl")
+
+	flag.Parse()
+	if *completion != "" {
+		PrintCompletion(*completion, "moderate")
+		return
+	}
+	if *modelfile == "" || len(imagefiles) == 0 {
+		flag.Usage()
+		return
+	}
+	if *action != "copy" && *action != "move" && *action != "tag" {
+		log.Fatalf("unsupported action %q, expected copy, move or tag", *action)
+	}
+
+	labels, err := LoadLabels(*labelfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	moderation, err := LoadModeration(*config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	classifier, err := model.NewClassifier(*modelfile, *input, *output, image.Pt(*size, *size))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer classifier.Close()
+
+	if *action != "tag" {
+		for _, v := range []string{Allow, Review, Block} {
+			if err := os.MkdirAll(filepath.Join(*outdir, v), 0755); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	for _, uri := range imagefiles {
+		src, err := OpenSource(uri)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for {
+			frame, err := src.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			scores, err := classifier.Classify([]image.Image{frame.Im})
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			v := verdict{Image: frame.Name, Scores: make(map[string]float32)}
+			v.Verdict, v.Because = moderation.Verdict(scores[0], labels)
+			for c, score := range scores[0] {
+				v.Scores[labels.Name(CID(c))] = score
+			}
+			if *action != "tag" {
+				kind := src.Meta().Kind
+				v.Location, err = quarantine(frame, kind == "file" || kind == "dir", filepath.Join(*outdir, v.Verdict), *action == "move")
+				if err != nil {
+					log.Fatal(err)
+				}
+			}
+			if err := out.Encode(v); err != nil {
+				log.Fatal(err)
+			}
+		}
+		src.Close()
+	}
+}
+
+// put the image in dir, images that are files on disk are copied or moved
+// as is, others are written as jpeg. Names taken in dir get an index.
+func quarantine(frame *Frame, onDisk bool, dir string, move bool) (string, error) {
+	if !onDisk {
+		_, name, _ := SplitPath(frame.Name)
+		f, err := CreateUnique(dir, name, ".jpg")
+		if err != nil {
+			return "", err
+		}
+		if err := jpeg.Encode(f, frame.Im, &jpeg.Options{Quality: 95}); err != nil {
+			f.Close()
+			return "", err
+		}
+		return f.Name(), f.Close()
+	}
+
+	base := filepath.Base(frame.Name)
+	ext := filepath.Ext(base)
+	out, err := CreateUnique(dir, strings.TrimSuffix(base, ext), ext)
+	if err != nil {
+		return "", err
+	}
+	path := out.Name()
+	if move {
+		// replaces the empty file holding the name
+		err := os.Rename(frame.Name, path)
+		if le, ok := err.(*os.LinkError); !ok || le.Err != syscall.EXDEV {
+			out.Close()
+			if err != nil {
+				os.Remove(path)
+			}
+			return path, err
+		}
+		// across filesystems, fall back to copy and remove
+	}
+	in, err := os.Open(frame.Name)
+	if err != nil {
+		out.Close()
+		os.Remove(path)
+		return "", err
+	}
+	defer in.Close()
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	if move {
+		return path, os.Remove(frame.Name)
+	}
+	return path, nil
+}
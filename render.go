@@ -1,7 +1,6 @@
 package main
 
 import (
-	. "./common"
 	"encoding/csv"
 	"flag"
 	"fmt"
@@ -11,6 +10,8 @@ import (
 	"image/draw"
 	"log"
 	"os"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
 )
 
 func main() {
@@ -19,12 +20,20 @@ func main() {
 	minConf := flag.Float64("confidence", .5, "Confidence threshold")
 	debugmode := flag.Bool("debug", false, "Enable debug mode")
 	outdir := flag.String("outdir", os.Getenv("PWD"), "Dir to write rendered image file")
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
 
 	const (
 		H, W = 544, 544
 	)
 
+	SetUsage("render", `Draw predictions onto the source image and save it as <image>-detects.jpg.`,
+		"detect -model m.pb -image 2122.jpg | render -image 2122.jpg")
+
 	flag.Parse()
+	if *completion != "" {
+		PrintCompletion(*completion, "render")
+		return
+	}
 	if *pFile == "" || *imagefile == "" {
 		flag.Usage()
 		return
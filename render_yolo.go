@@ -1,7 +1,6 @@
 package main
 
 import (
-	. "./common"
 	"encoding/csv"
 	"flag"
 	"github.com/fogleman/gg"
@@ -14,14 +13,24 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
 )
 
 // read a directory of chips and create a mosaic with bounding boxes
 func main() {
 	sourcedir := flag.String("source", "", "Source dir")
 	targetdir := flag.String("target", "", "Output dir")
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
+
+	SetUsage("render-yolo", `Draw the boxes from a dir of yolo label files onto their matching jpg chips.`,
+		"render-yolo -source chips/ -target rendered/")
 
 	flag.Parse()
+	if *completion != "" {
+		PrintCompletion(*completion, "render-yolo")
+		return
+	}
 	if *sourcedir == "" || *targetdir == "" {
 		flag.Usage()
 		return
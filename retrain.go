@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	"github.com/jw3/example-tensorflow-golang/model"
+)
+
+func main() {
+	modelfile := flag.String("model", "", "Path to a pretrained image classification or embedding model")
+	input := flag.String("input", "input", "Input op of the model, [N,H,W,3] float pixels in [0,1]")
+	bottleneck := flag.String("bottleneck", "", "Op of the [N,D] or [N,1,1,D] features the new layer is trained on, eg. the pooling before the original logits")
+	size := flag.Int("size", 224, "Input size of the model, read from the input shape when static")
+	imagedir := flag.String("image-dir", "", "Dir with a subdir of images per class, the subdir names are the labels")
+	out := flag.String("out", "retrained.pb", "Path to write the graph with the new layer to")
+	outlabels := flag.String("out-labels", "retrained_labels.txt", "Path to write the labels of the new layer to")
+	outputop := flag.String("output-op", "final_result", "Name of the op of the new class probabilities")
+	epochs := flag.Int("epochs", 100, "Passes over the training images")
+	batchsize := flag.Int("batch-size", 32, "Images per feature extraction run and per training step")
+	rate := flag.Float64("learning-rate", 0.01, "Gradient descent learning rate")
+	l2 := flag.Float64("l2", 0.0001, "Weight decay of the new layer")
+	validation := flag.Float64("validation", 0.1, "Fraction of images held out to report the accuracy on")
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
+
+	SetUsage("retrain", `Transfer learning on the last layer; extracts bottleneck features of the
+images of each class with a pretrained model, trains a softmax layer on them,
+and writes the model with the new layer and its labels, ready for classify
+or moderate.`,
+		"retrain -model mobilenet_v2.pb -bottleneck MobilenetV2/Logits/AvgPool -image-dir flowers/",
+		"retrain -model inception_v3.pb -input Mul -bottleneck pool_3/_reshape -size 299 -image-dir flowers/ -epochs 300")
+
+	flag.Parse()
+	if *completion != "" {
+		PrintCompletion(*completion, "retrain")
+		return
+	}
+	if *modelfile == "" || *bottleneck == "" || *imagedir == "" {
+		flag.Usage()
+		return
+	}
+
+	// a class per subdir
+	dirs, err := ioutil.ReadDir(*imagedir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var labels []string
+	for _, d := range dirs {
+		if d.IsDir() && !strings.HasPrefix(d.Name(), ".") {
+			labels = append(labels, d.Name())
+		}
+	}
+	if len(labels) < 2 {
+		log.Fatalf("%s: expected a subdir of images for each of at least 2 classes", *imagedir)
+	}
+
+	embedder, err := model.NewEmbedder(*modelfile, *input, *bottleneck, image.Pt(*size, *size))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer embedder.Close()
+
+	var features [][]float32
+	var classes []int
+	for c, label := range labels {
+		x, err := extract(embedder, filepath.Join(*imagedir, label), *batchsize)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%s: %d images", label, len(x))
+		for _, f := range x {
+			features, classes = append(features, f), append(classes, c)
+		}
+	}
+
+	// hold out a shuffled fraction for validation
+	perm := rand.Perm(len(features))
+	held := int(float64(len(features)) * *validation)
+	var trainX, validX [][]float32
+	var trainY, validY []int
+	for i, n := range perm {
+		if i < held {
+			validX, validY = append(validX, features[n]), append(validY, classes[n])
+		} else {
+			trainX, trainY = append(trainX, features[n]), append(trainY, classes[n])
+		}
+	}
+
+	layer, err := model.TrainSoftmax(trainX, trainY, len(labels), *epochs, *batchsize, *rate, *l2)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("train accuracy %.3f", layer.Accuracy(trainX, trainY))
+	if held > 0 {
+		log.Printf("validation accuracy %.3f on %d images", layer.Accuracy(validX, validY), held)
+	}
+
+	bottleneckOp, err := embedder.Output(*bottleneck)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := embedder.AppendSoftmax(bottleneckOp, layer, *outputop); err != nil {
+		log.Fatal(err)
+	}
+	if err := embedder.Save(*out); err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*outlabels, []byte(strings.Join(labels, "\n")+"\n"), 0644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s with output %s, and %s\n", *out, *outputop, *outlabels)
+}
+
+// bottleneck features of the images under dir
+func extract(embedder *model.Embedder, dir string, batchsize int) ([][]float32, error) {
+	src, err := OpenSource(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var features [][]float32
+	var batch []image.Image
+	run := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		f, err := embedder.Embed(batch)
+		features = append(features, f...)
+		batch = batch[:0]
+		return err
+	}
+	for {
+		frame, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if batch = append(batch, frame.Im); len(batch) >= batchsize {
+			if err := run(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return features, run()
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
+	"github.com/jw3/example-tensorflow-golang/model"
+	tf "github.com/tensorflow/tensorflow/tensorflow/go"
+)
+
+type fetched struct {
+	Fetch string      `json:"fetch"`
+	Type  string      `json:"dtype"`
+	Shape []int64     `json:"shape"`
+	Value interface{} `json:"value,omitempty"`
+	File  string      `json:"file,omitempty"`
+	// against the -compare reference
+	MaxDiff  *jsonFloat `json:"max_diff,omitempty"`
+	MeanDiff *jsonFloat `json:"mean_diff,omitempty"`
+	MaxAt    []int64    `json:"max_at,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+func main() {
+	modelfile := flag.String("model", "", "Path to a GraphDef, frozen unless -restore is given")
+	restore := flag.String("restore", "", "Checkpoint to initialize the variables of a -model that isn't frozen from, eg. model.ckpt-1000 or a training dir")
+	var feeds, fetches, targets Strings
+	flag.Var(&feeds, "feed", "Feed an op with op=@file.npy, or op=json such as op=[[1,2]] converted to the type of the op. Any op can be fed, intermediate ones too, cutting off the graph before it. Repeatable")
+	flag.Var(&fetches, "fetch", "Op to fetch, op:index for outputs past the first. Repeatable")
+	flag.Var(&targets, "target", "Op to run without fetching its output, eg. an init op. Repeatable")
+	outdir := flag.String("outdir", "", "Write each fetch to <op>.npy in this dir, rather than printing its value")
+	compare := flag.String("compare", "", "Compare each fetch to <op>.npy in this dir, eg. dumped from the python reference, printing the differences rather than the value")
+	tolerance := flag.Float64("tolerance", 1e-4, "Largest absolute difference from a -compare reference that still matches")
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
+
+	SetUsage("run", `Run any graph with the given feeds and fetches, printing a json line per
+fetch with its type, shape and value. For debugging models outside of the
+detect pipeline; feeding intermediate ops and comparing fetches to those of
+a reference isolates the stage of a model that diverges.`,
+		"run -model model.pb -feed input=@tensor.npy -fetch logits -fetch probs",
+		"run -model model.pb -feed conv3/Relu=@conv3.npy -fetch logits -compare reference/",
+		`run -model model.pb -feed "keep_prob=1.0" -feed input=@batch.npy -fetch predictions -outdir out/`)
+
+	flag.Parse()
+	if *completion != "" {
+		PrintCompletion(*completion, "run")
+		return
+	}
+	if *modelfile == "" || len(fetches)+len(targets) == 0 {
+		flag.Usage()
+		return
+	}
+
+	m, err := model.Load(*modelfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer m.Close()
+	if *restore != "" {
+		if err := m.Restore(*restore); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	feedTensors := make(map[tf.Output]*tf.Tensor)
+	for _, feed := range feeds {
+		splits := strings.SplitN(feed, "=", 2)
+		if len(splits) != 2 {
+			log.Fatalf("invalid feed %q, expected op=@file.npy or op=json", feed)
+		}
+		out, err := m.Output(splits[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		var t *tf.Tensor
+		if strings.HasPrefix(splits[1], "@") {
+			var n *Npy
+			if n, err = LoadNpy(splits[1][1:]); err == nil {
+				t, err = model.NpyTensor(n)
+			}
+		} else {
+			t, err = model.JsonTensor(splits[1], out.DataType())
+		}
+		if err != nil {
+			log.Fatalf("feed %s: %v", splits[0], err)
+		}
+		feedTensors[out] = t
+	}
+
+	fetchOutputs := make([]tf.Output, len(fetches))
+	for i, fetch := range fetches {
+		if fetchOutputs[i], err = m.Output(fetch); err != nil {
+			log.Fatal(err)
+		}
+	}
+	targetOps := make([]*tf.Operation, len(targets))
+	for i, target := range targets {
+		if targetOps[i] = m.Graph.Operation(target); targetOps[i] == nil {
+			log.Fatalf("no operation %q in graph", target)
+		}
+	}
+
+	results, err := m.Session.Run(feedTensors, fetchOutputs, targetOps)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *outdir != "" {
+		if err := os.MkdirAll(*outdir, 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	diverged := false
+	for i, t := range results {
+		f := fetched{Fetch: fetches[i], Type: model.TypeName(t.DataType()), Shape: t.Shape()}
+		if *outdir == "" && *compare == "" {
+			f.Value = jsonValue(reflect.ValueOf(t.Value()))
+		} else {
+			n, err := model.TensorNpy(t)
+			if err != nil {
+				log.Fatalf("fetch %s: %v", fetches[i], err)
+			}
+			if *outdir != "" {
+				f.File = filepath.Join(*outdir, npyName(fetches[i]))
+				if err := n.Save(f.File); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if *compare != "" {
+				if err := compareFetch(&f, n, filepath.Join(*compare, npyName(fetches[i]))); err != nil {
+					f.Error = err.Error()
+				}
+				diverged = diverged || f.Error != "" || !(f.MaxDiff.v <= *tolerance)
+			}
+		}
+		if err := enc.Encode(f); err != nil {
+			log.Fatalf("fetch %s: %v", fetches[i], err)
+		}
+	}
+	if diverged {
+		os.Exit(1)
+	}
+}
+
+// op names are paths, eg. scope/logits:1
+func npyName(fetch string) string {
+	return strings.NewReplacer("/", "_", ":", "_").Replace(fetch) + ".npy"
+}
+
+func compareFetch(f *fetched, n *Npy, reffile string) error {
+	ref, err := LoadNpy(reffile)
+	if err != nil {
+		return err
+	}
+	d, err := CompareNpy(n, ref)
+	if err != nil {
+		return err
+	}
+	f.MaxDiff, f.MeanDiff = &jsonFloat{d.Max, 64}, &jsonFloat{d.Mean, 64}
+	// the flat index of the largest difference as an index into the shape
+	f.MaxAt = make([]int64, len(n.Shape))
+	idx := int64(d.Index)
+	for i := len(n.Shape) - 1; i >= 0; i-- {
+		if n.Shape[i] > 0 {
+			f.MaxAt[i], idx = idx%n.Shape[i], idx/n.Shape[i]
+		}
+	}
+	return nil
+}
+
+// jsonFloat is a float as json, nan and infinities, which json has no
+// numbers for, as the strings "NaN", "Infinity" and "-Infinity" python's
+// json reads
+type jsonFloat struct {
+	v    float64
+	bits int
+}
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	switch {
+	case math.IsNaN(f.v):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(f.v, 1):
+		return []byte(`"Infinity"`), nil
+	case math.IsInf(f.v, -1):
+		return []byte(`"-Infinity"`), nil
+	}
+	return []byte(strconv.FormatFloat(f.v, 'g', -1, f.bits)), nil
+}
+
+// the value of a tensor as it can be encoded, floats as jsonFloats and
+// uint8s as numbers rather than the base64 of []byte
+func jsonValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Float32:
+		return jsonFloat{v.Float(), 32}
+	case reflect.Float64:
+		return jsonFloat{v.Float(), 64}
+	case reflect.Uint8:
+		return v.Uint()
+	case reflect.Slice:
+		switch v.Type().Elem().Kind() {
+		case reflect.Float32, reflect.Float64, reflect.Uint8, reflect.Slice:
+			out := make([]interface{}, v.Len())
+			for i := range out {
+				out[i] = jsonValue(v.Index(i))
+			}
+			return out
+		}
+	}
+	return v.Interface()
+}
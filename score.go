@@ -1,7 +1,6 @@
 package main
 
 import (
-	. "./common"
 	"encoding/csv"
 	"encoding/json"
 	"flag"
@@ -10,6 +9,8 @@ import (
 	"log"
 	"os"
 	"strings"
+
+	. "github.com/jw3/example-tensorflow-golang/common"
 )
 
 type FeatureCollection struct {
@@ -34,8 +35,16 @@ func main() {
 	tFile := flag.String("groundtruth", "", "Path to ground-truth geojson")
 	minIou := flag.Float64("iou", .5, "IOU threshold")
 	minConf := flag.Float64("confidence", .5, "Confidence threshold")
+	completion := flag.String("completion", "", "Print a shell completion script for bash, zsh or fish")
+
+	SetUsage("score", `Score predictions against xView ground-truth, printing a per-class confusion summary.`,
+		"score -predictions predictions.txt -groundtruth xview/labels/2122.geojson")
 
 	flag.Parse()
+	if *completion != "" {
+		PrintCompletion(*completion, "score")
+		return
+	}
 	if *pFile == "" || *tFile == "" {
 		flag.Usage()
 		return